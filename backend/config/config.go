@@ -4,15 +4,25 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Redis     RedisConfig
+	Reporting ReportingConfig
+	Callbacks CallbackConfig
+	Stats     StatsConfig
+	Security  SecurityConfig
+	Health    HealthConfig
+	WebSocket WebSocketConfig
+	RateLimit RateLimitConfig
 }
 
 // ServerConfig holds server-specific configuration
@@ -41,6 +51,73 @@ type RedisConfig struct {
 	DB       int
 }
 
+// ReportingConfig holds settings for date-based filtering and reports
+type ReportingConfig struct {
+	Timezone string
+	Location *time.Location
+}
+
+// CallbackConfig holds settings for job completion callbacks
+type CallbackConfig struct {
+	SigningSecret string
+}
+
+// StatsConfig holds settings for the statistics endpoints
+type StatsConfig struct {
+	CacheTTL time.Duration // How long GET /api/stats serves a cached response
+}
+
+// HealthConfig holds the thresholds past which health checks report the
+// service as degraded
+type HealthConfig struct {
+	LatencyThreshold    time.Duration // Slowest acceptable dependency ping
+	QueueDepthThreshold int64         // Most pending jobs before the queue counts as backed up; 0 disables
+	CacheTTL            time.Duration // How long dependency check results are reused
+}
+
+// WebSocketConfig holds the credentials accepted for /ws connections
+type WebSocketConfig struct {
+	APIKeys        []string // Static tokens accepted as is
+	TokenSecret    string   // HMAC secret for HS256 JWTs; empty disables JWTs
+	AllowAnonymous bool     // Accept connections without a token
+	AdminKeys      []string // Bearer tokens for the detailed /ws/stats view and /ws/send
+
+	ReplayBufferSize int           // Recent events kept for clients that reconnect; 0 disables replay
+	CoalesceWindow   time.Duration // Progress events for a job within this window are merged; 0 disables
+
+	Compression      bool // Negotiate permessage-deflate with clients that offer it
+	CompressionLevel int  // flate level, 1 (fastest) to 9 (smallest)
+
+	MaxConnections int // Concurrent connections accepted; 0 means no cap
+
+	ConnectRate           int  // Connection attempts per minute per IP; 0 disables the limit
+	ConnectBurst          int  // Attempts an IP may make at once before the rate applies
+	ConnectExemptLoopback bool // Skip the limit for loopback addresses
+
+	SlowClientMaxDrops int           // Dropped messages within SlowClientWindow before a client is evicted; 0 never evicts
+	SlowClientWindow   time.Duration // Window over which drops are counted
+}
+
+// RateLimit is the policy of one rate limit bucket: Limit requests per IP
+// within each Window
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+// RateLimitConfig holds the named rate limit buckets applied to API routes
+type RateLimitConfig struct {
+	Buckets map[string]RateLimit // "generate", "write" and "read" are applied in main
+}
+
+// DefaultRateLimits are the buckets used unless RATE_LIMITS overrides them
+const DefaultRateLimits = "generate:10/1m,write:60/1m,read:300/1m"
+
+// SecurityConfig holds keys for protecting stored data
+type SecurityConfig struct {
+	SettingsEncryptionKey string // 32-byte key, hex or base64, for secrets in settings
+}
+
 // LoadConfig loads configuration from environment variables
 func LoadConfig() (*Config, error) {
 	// Load .env file from parent directory (project root)
@@ -70,7 +147,113 @@ func LoadConfig() (*Config, error) {
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       0,
 		},
+		Reporting: ReportingConfig{
+			Timezone: getEnv("REPORTING_TIMEZONE", "UTC"),
+		},
+		Callbacks: CallbackConfig{
+			SigningSecret: getEnv("CALLBACK_SIGNING_SECRET", ""),
+		},
+		Security: SecurityConfig{
+			SettingsEncryptionKey: getEnv("SETTINGS_ENCRYPTION_KEY", ""),
+		},
+	}
+
+	cacheTTL, err := time.ParseDuration(getEnv("STATS_CACHE_TTL", "5s"))
+	if err != nil || cacheTTL < 0 {
+		return nil, fmt.Errorf("invalid STATS_CACHE_TTL %q: must be a non-negative duration", getEnv("STATS_CACHE_TTL", ""))
+	}
+	config.Stats.CacheTTL = cacheTTL
+
+	latencyThreshold, err := time.ParseDuration(getEnv("HEALTH_LATENCY_THRESHOLD", "500ms"))
+	if err != nil || latencyThreshold <= 0 {
+		return nil, fmt.Errorf("invalid HEALTH_LATENCY_THRESHOLD %q: must be a positive duration", getEnv("HEALTH_LATENCY_THRESHOLD", ""))
+	}
+	config.Health.LatencyThreshold = latencyThreshold
+
+	queueDepthThreshold, err := strconv.ParseInt(getEnv("HEALTH_QUEUE_DEPTH_THRESHOLD", "1000"), 10, 64)
+	if err != nil || queueDepthThreshold < 0 {
+		return nil, fmt.Errorf("invalid HEALTH_QUEUE_DEPTH_THRESHOLD %q: must be a non-negative integer", getEnv("HEALTH_QUEUE_DEPTH_THRESHOLD", ""))
+	}
+	config.Health.QueueDepthThreshold = queueDepthThreshold
+
+	healthCacheTTL, err := time.ParseDuration(getEnv("HEALTH_CACHE_TTL", "5s"))
+	if err != nil || healthCacheTTL < 0 {
+		return nil, fmt.Errorf("invalid HEALTH_CACHE_TTL %q: must be a non-negative duration", getEnv("HEALTH_CACHE_TTL", ""))
+	}
+	config.Health.CacheTTL = healthCacheTTL
+
+	config.WebSocket = WebSocketConfig{
+		APIKeys:     splitList(getEnv("WS_API_KEYS", "")),
+		TokenSecret: getEnv("WS_TOKEN_SECRET", ""),
+		AdminKeys:   splitList(getEnv("WS_ADMIN_KEYS", "")),
+		// Anonymous connections are convenient locally but off elsewhere
+		AllowAnonymous: getEnv("WS_ALLOW_ANONYMOUS", strconv.FormatBool(config.IsDevelopment())) == "true",
+	}
+
+	replaySize, err := strconv.Atoi(getEnv("WS_REPLAY_BUFFER_SIZE", "200"))
+	if err != nil || replaySize < 0 {
+		return nil, fmt.Errorf("invalid WS_REPLAY_BUFFER_SIZE %q: must be a non-negative integer", getEnv("WS_REPLAY_BUFFER_SIZE", ""))
+	}
+	config.WebSocket.ReplayBufferSize = replaySize
+
+	coalesceWindow, err := time.ParseDuration(getEnv("WS_COALESCE_WINDOW", "250ms"))
+	if err != nil || coalesceWindow < 0 {
+		return nil, fmt.Errorf("invalid WS_COALESCE_WINDOW %q: must be a non-negative duration", getEnv("WS_COALESCE_WINDOW", ""))
+	}
+	config.WebSocket.CoalesceWindow = coalesceWindow
+
+	config.WebSocket.Compression = getEnv("WS_COMPRESSION", "false") == "true"
+	compressionLevel, err := strconv.Atoi(getEnv("WS_COMPRESSION_LEVEL", "1"))
+	if err != nil || compressionLevel < 1 || compressionLevel > 9 {
+		return nil, fmt.Errorf("invalid WS_COMPRESSION_LEVEL %q: must be an integer from 1 to 9", getEnv("WS_COMPRESSION_LEVEL", ""))
+	}
+	config.WebSocket.CompressionLevel = compressionLevel
+
+	maxConnections, err := strconv.Atoi(getEnv("WS_MAX_CONNECTIONS", "1000"))
+	if err != nil || maxConnections < 0 {
+		return nil, fmt.Errorf("invalid WS_MAX_CONNECTIONS %q: must be a non-negative integer", getEnv("WS_MAX_CONNECTIONS", ""))
 	}
+	config.WebSocket.MaxConnections = maxConnections
+
+	connectRate, err := strconv.Atoi(getEnv("WS_CONNECT_RATE", "60"))
+	if err != nil || connectRate < 0 {
+		return nil, fmt.Errorf("invalid WS_CONNECT_RATE %q: must be a non-negative integer", getEnv("WS_CONNECT_RATE", ""))
+	}
+	config.WebSocket.ConnectRate = connectRate
+
+	connectBurst, err := strconv.Atoi(getEnv("WS_CONNECT_BURST", "10"))
+	if err != nil || connectBurst < 1 {
+		return nil, fmt.Errorf("invalid WS_CONNECT_BURST %q: must be a positive integer", getEnv("WS_CONNECT_BURST", ""))
+	}
+	config.WebSocket.ConnectBurst = connectBurst
+
+	// Local tools reconnect freely in development
+	config.WebSocket.ConnectExemptLoopback = getEnv("WS_CONNECT_EXEMPT_LOCALHOST", strconv.FormatBool(config.IsDevelopment())) == "true"
+
+	slowClientMaxDrops, err := strconv.Atoi(getEnv("WS_SLOW_CLIENT_MAX_DROPS", "100"))
+	if err != nil || slowClientMaxDrops < 0 {
+		return nil, fmt.Errorf("invalid WS_SLOW_CLIENT_MAX_DROPS %q: must be a non-negative integer", getEnv("WS_SLOW_CLIENT_MAX_DROPS", ""))
+	}
+	config.WebSocket.SlowClientMaxDrops = slowClientMaxDrops
+
+	slowClientWindow, err := time.ParseDuration(getEnv("WS_SLOW_CLIENT_WINDOW", "10s"))
+	if err != nil || slowClientWindow <= 0 {
+		return nil, fmt.Errorf("invalid WS_SLOW_CLIENT_WINDOW %q: must be a positive duration", getEnv("WS_SLOW_CLIENT_WINDOW", ""))
+	}
+	config.WebSocket.SlowClientWindow = slowClientWindow
+
+	rateLimits, err := rateLimitBuckets(getEnv("RATE_LIMITS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMITS %q: %w", getEnv("RATE_LIMITS", ""), err)
+	}
+	config.RateLimit.Buckets = rateLimits
+
+	// Resolve the reporting timezone used to interpret date-only values
+	location, err := time.LoadLocation(config.Reporting.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REPORTING_TIMEZONE %q: %w", config.Reporting.Timezone, err)
+	}
+	config.Reporting.Location = location
 
 	return config, nil
 }
@@ -84,6 +267,86 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ParseRateLimits parses comma-separated rate limit buckets written as
+// name:limit/window, e.g. "generate:10/1m,read:300/1m". Names are lower
+// case letters, digits, "-" and "_"; limits are positive integers and
+// windows positive durations. An empty value has no buckets.
+func ParseRateLimits(value string) (map[string]RateLimit, error) {
+	buckets := make(map[string]RateLimit)
+	for _, entry := range splitList(value) {
+		name, policy, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, fmt.Errorf("%q: expected name:limit/window", entry)
+		}
+		name = strings.TrimSpace(name)
+		if !validBucketName(name) {
+			return nil, fmt.Errorf("%q: bucket name must be lower case letters, digits, - or _", entry)
+		}
+		if _, ok := buckets[name]; ok {
+			return nil, fmt.Errorf("%q: bucket %s is defined twice", entry, name)
+		}
+
+		limitText, windowText, found := strings.Cut(policy, "/")
+		if !found {
+			return nil, fmt.Errorf("%q: expected name:limit/window", entry)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(limitText))
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("%q: limit must be a positive integer", entry)
+		}
+		window, err := time.ParseDuration(strings.TrimSpace(windowText))
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("%q: window must be a positive duration such as 1m", entry)
+		}
+
+		buckets[name] = RateLimit{Limit: limit, Window: window}
+	}
+	return buckets, nil
+}
+
+// rateLimitBuckets parses overrides and merges them over the defaults:
+// buckets it names replace the defaults of the same name, the rest keep
+// their defaults
+func rateLimitBuckets(overrides string) (map[string]RateLimit, error) {
+	buckets, err := ParseRateLimits(DefaultRateLimits)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := ParseRateLimits(overrides)
+	if err != nil {
+		return nil, err
+	}
+	for name, limit := range parsed {
+		buckets[name] = limit
+	}
+	return buckets, nil
+}
+
+// validBucketName reports whether name is a non-empty run of lower case
+// letters, digits, "-" and "_"
+func validBucketName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
 // GetServerAddress returns the full server address
 func (c *Config) GetServerAddress() string {
 	return fmt.Sprintf("%s:%s", c.Server.Host, c.Server.Port)
@@ -99,6 +362,14 @@ func (c *Config) IsDevelopment() bool {
 	return c.Server.Environment == "development"
 }
 
+// ReportingLocation returns the timezone used for date-based reporting
+func (c *Config) ReportingLocation() *time.Location {
+	if c.Reporting.Location == nil {
+		return time.UTC
+	}
+	return c.Reporting.Location
+}
+
 // IsProduction checks if the environment is production
 func (c *Config) IsProduction() bool {
 	return c.Server.Environment == "production"
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[string]RateLimit
+	}{
+		{"", map[string]RateLimit{}},
+		{" , ", map[string]RateLimit{}},
+		{"generate:10/1m", map[string]RateLimit{"generate": {Limit: 10, Window: time.Minute}}},
+		{" write : 60 / 30s , read:300/1h ", map[string]RateLimit{
+			"write": {Limit: 60, Window: 30 * time.Second},
+			"read":  {Limit: 300, Window: time.Hour},
+		}},
+		{"bulk-export_2:1/500ms", map[string]RateLimit{"bulk-export_2": {Limit: 1, Window: 500 * time.Millisecond}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseRateLimits(tt.value)
+		if err != nil {
+			t.Errorf("ParseRateLimits(%q) error: %v", tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRateLimits(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseRateLimitsRejects(t *testing.T) {
+	tests := []struct {
+		value string
+		err   string // part of the expected message
+	}{
+		// Bad format
+		{"generate", "expected name:limit/window"},
+		{"generate:10", "expected name:limit/window"},
+		{"generate=10/1m", "expected name:limit/window"},
+		{":10/1m", "bucket name"},
+		{"Generate:10/1m", "bucket name"},
+		{"gen rate:10/1m", "bucket name"},
+		{"read:300/1m,read:10/1m", "defined twice"},
+		// Zero, negative or non-integer limits
+		{"generate:0/1m", "limit must be a positive integer"},
+		{"generate:-5/1m", "limit must be a positive integer"},
+		{"generate:1.5/1m", "limit must be a positive integer"},
+		{"generate:/1m", "limit must be a positive integer"},
+		// Bad windows
+		{"generate:10/", "window must be a positive duration"},
+		{"generate:10/60", "window must be a positive duration"},
+		{"generate:10/minute", "window must be a positive duration"},
+		{"generate:10/0s", "window must be a positive duration"},
+		{"generate:10/-1m", "window must be a positive duration"},
+		// One bad entry rejects the whole value
+		{"read:300/1m,write:0/1m", "limit must be a positive integer"},
+	}
+	for _, tt := range tests {
+		got, err := ParseRateLimits(tt.value)
+		if err == nil {
+			t.Errorf("ParseRateLimits(%q) = %v, want an error", tt.value, got)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ParseRateLimits(%q) error = %q, want it to mention %q", tt.value, err, tt.err)
+		}
+	}
+}
+
+func TestRateLimitBucketsMergeOverDefaults(t *testing.T) {
+	defaults := map[string]RateLimit{
+		"generate": {Limit: 10, Window: time.Minute},
+		"write":    {Limit: 60, Window: time.Minute},
+		"read":     {Limit: 300, Window: time.Minute},
+	}
+
+	got, err := rateLimitBuckets("")
+	if err != nil || !reflect.DeepEqual(got, defaults) {
+		t.Errorf("rateLimitBuckets(\"\") = %v, %v, want the defaults %v", got, err, defaults)
+	}
+
+	got, err = rateLimitBuckets("generate:2/10s,export:5/1h")
+	want := map[string]RateLimit{
+		"generate": {Limit: 2, Window: 10 * time.Second},
+		"write":    defaults["write"],
+		"read":     defaults["read"],
+		"export":   {Limit: 5, Window: time.Hour},
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("rateLimitBuckets with overrides = %v, %v, want %v", got, err, want)
+	}
+
+	if got, err := rateLimitBuckets("generate:0/1m"); err == nil {
+		t.Errorf("rateLimitBuckets with an invalid override = %v, want an error", got)
+	}
+}
+
+func TestLoadConfigRejectsInvalidRateLimits(t *testing.T) {
+	t.Setenv("RATE_LIMITS", "generate:-1/1m")
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "RATE_LIMITS") {
+		t.Errorf("LoadConfig with RATE_LIMITS=generate:-1/1m error = %v, want one naming RATE_LIMITS", err)
+	}
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"botrix-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StatsResponse represents the comprehensive statistics response
+type StatsResponse struct {
+	Success          bool                   `json:"success"`
+	TotalAccounts    int64                  `json:"total_accounts"`
+	SuccessRate      float64                `json:"success_rate"`
+	FailureRate      float64                `json:"failure_rate"`
+	AccountStats     *models.AccountStats   `json:"account_stats"`
+	JobStats         *models.JobStats       `json:"job_stats"`
+	QueueStats       map[string]interface{} `json:"queue_stats"`
+	HotmailRemaining int                    `json:"hotmail_pool_remaining"`
+	GeneratedAt      time.Time              `json:"generated_at"`
+	Error            string                 `json:"error,omitempty"`
+}
+
+// GetStats handles GET /api/stats
+// Responses are cached for the configured STATS_CACHE_TTL; generated_at
+// shows when they were computed and ?fresh=true bypasses the cache.
+func (h *AccountsHandler) GetStats(c *fiber.Ctx) error {
+	stats, err := h.stats.Get(c.QueryBool("fresh"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(StatsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(stats)
+}
+
+// CachedStats returns the stats /api/stats serves, from the cache when it
+// holds an entry
+func (h *AccountsHandler) CachedStats() (*StatsResponse, error) {
+	return h.stats.Get(false)
+}
+
+// computeStats gathers the statistics served by GetStats. Returned errors
+// carry a client-facing message; details are logged here.
+func (h *AccountsHandler) computeStats() (*StatsResponse, error) {
+	// Get account statistics
+	accountStats, err := h.db.GetAccountStats()
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to get account stats: %v", err)
+		return nil, errors.New("Failed to retrieve account statistics")
+	}
+
+	// Get job statistics
+	jobStats, err := h.db.GetJobStats()
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to get job stats: %v", err)
+		return nil, errors.New("Failed to retrieve job statistics")
+	}
+
+	// Get queue statistics
+	queueStats, err := h.queue.GetQueueStats()
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to get queue stats: %v", err)
+		queueStats = map[string]interface{}{
+			"error": "Queue unavailable",
+		}
+	}
+
+	// Calculate success/fail ratio (test-mode dry runs are excluded)
+	completed, failed, err := h.db.GetJobOutcomeCounts()
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to get job outcome counts: %v", err)
+		return nil, errors.New("Failed to retrieve job statistics")
+	}
+
+	totalJobs := completed + failed
+	var successRate, failureRate float64
+	if totalJobs > 0 {
+		successRate = (float64(completed) / float64(totalJobs)) * 100
+		failureRate = (float64(failed) / float64(totalJobs)) * 100
+	}
+
+	// Email pool remaining, as last reported by the workers
+	hotmailRemaining := 0
+	if available, known, err := h.queue.GetEmailPoolAvailable(); err == nil && known {
+		hotmailRemaining = int(available)
+	}
+
+	return &StatsResponse{
+		Success:          true,
+		TotalAccounts:    accountStats.Total,
+		SuccessRate:      successRate,
+		FailureRate:      failureRate,
+		AccountStats:     accountStats,
+		JobStats:         jobStats,
+		QueueStats:       queueStats,
+		HotmailRemaining: hotmailRemaining,
+		GeneratedAt:      time.Now(),
+	}, nil
+}
+
+// GetTimeseries handles GET /api/stats/timeseries
+// Returns accounts created and jobs completed/failed per calendar day in the
+// reporting timezone for the last ?days days (default 30, max 365).
+func (h *AccountsHandler) GetTimeseries(c *fiber.Ctx) error {
+	days, err := strconv.Atoi(c.Query("days", "30"))
+	if err != nil || days < 1 || days > models.MaxTimeseriesDays {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   fmt.Sprintf("days must be between 1 and %d", models.MaxTimeseriesDays),
+		})
+	}
+
+	loc := h.config.ReportingLocation()
+	series, err := h.db.GetDailyStats(days, loc)
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to get daily stats: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve statistics",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"days":     days,
+		"timezone": loc.String(),
+		"data":     series,
+	})
+}
+
+// GetThroughput handles GET /api/stats/throughput
+// Returns completed accounts and average job duration per clock hour for
+// the last ?hours hours (default 48, max 168), plus a projection of the
+// current, partial hour.
+func (h *AccountsHandler) GetThroughput(c *fiber.Ctx) error {
+	hours, err := strconv.Atoi(c.Query("hours", "48"))
+	if err != nil || hours < 1 || hours > models.MaxThroughputHours {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   fmt.Sprintf("hours must be between 1 and %d", models.MaxThroughputHours),
+		})
+	}
+
+	loc := h.config.ReportingLocation()
+	series, err := h.db.GetHourlyThroughput(hours, loc)
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to get hourly throughput: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve statistics",
+		})
+	}
+
+	current := series[len(series)-1]
+
+	return c.JSON(fiber.Map{
+		"success":                 true,
+		"hours":                   hours,
+		"timezone":                loc.String(),
+		"data":                    series,
+		"current_hour_projection": models.ProjectHour(current.AccountsCompleted, current.Hour, time.Now()),
+	})
+}
+
+// GetDurations handles GET /api/stats/durations
+// Returns count, average, p50 and p95 run time per priority for non-test
+// jobs completed in the last ?days days (default 7, max 90).
+func (h *AccountsHandler) GetDurations(c *fiber.Ctx) error {
+	days, err := strconv.Atoi(c.Query("days", "7"))
+	if err != nil || days < 1 || days > models.MaxDurationDays {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   fmt.Sprintf("days must be between 1 and %d", models.MaxDurationDays),
+		})
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -days)
+	stats, err := h.db.GetDurationStatsByPriority(from, to)
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to get duration stats: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve statistics",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"window": fiber.Map{
+			"from": from,
+			"to":   to,
+		},
+		"data": stats,
+	})
+}
+
+// GetFailures handles GET /api/stats/failures
+// Returns failed non-test jobs from the last ?days calendar days (default 7,
+// max 90) grouped by failure category, with the most frequent messages in
+// each. Messages no rule recognizes are listed under "unknown".
+func (h *AccountsHandler) GetFailures(c *fiber.Ctx) error {
+	days, err := strconv.Atoi(c.Query("days", "7"))
+	if err != nil || days < 1 || days > models.MaxFailureDays {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   fmt.Sprintf("days must be between 1 and %d", models.MaxFailureDays),
+		})
+	}
+
+	loc := h.config.ReportingLocation()
+	from, breakdown, err := h.db.GetFailureBreakdown(days, loc)
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to get failure breakdown: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve statistics",
+		})
+	}
+
+	var total int64
+	for _, category := range breakdown {
+		total += category.Count
+	}
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"days":     days,
+		"timezone": loc.String(),
+		"from":     from,
+		"total":    total,
+		"data":     breakdown,
+	})
+}
@@ -1,11 +1,19 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net/mail"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"botrix-backend/config"
 	"botrix-backend/models"
 	"botrix-backend/services"
 
@@ -15,43 +23,115 @@ import (
 
 // AccountsHandler handles account-related requests
 type AccountsHandler struct {
-	db    *services.Database
-	queue *services.QueueService
+	db       *services.Database
+	queue    *services.QueueService
+	config   *config.Config
+	webhooks *services.WebhookDispatcher
+	stats    *statsCache
 }
 
 // GenerateAccountsRequest represents the request to generate accounts
 type GenerateAccountsRequest struct {
 	Count    int    `json:"count" validate:"required,min=1,max=100"`
-	Priority string `json:"priority,omitempty"` // "low", "normal", "high"
+	Priority string `json:"priority,omitempty"`  // "low", "normal", "high"
+	Mode     string `json:"mode,omitempty"`      // "per_account" (default), "batch"
+	Force    bool   `json:"force,omitempty"`     // Skip the email pool capacity check
+	TestMode bool   `json:"test_mode,omitempty"` // Dry run, excluded from success-rate stats
+	Label    string `json:"label,omitempty"`     // Groups related jobs, max 64 characters
+
+	// Optional URL POSTed to when each created job reaches a terminal state
+	CallbackURL string `json:"callback_url,omitempty"`
+
+	// Optional username and password generation options
+	UsernamePrefix       string                 `json:"username_prefix,omitempty"`
+	UsernameSuffixLength int                    `json:"username_suffix_length,omitempty"`
+	PasswordPolicy       *models.PasswordPolicy `json:"password_policy,omitempty"`
 }
 
+// Generation modes
+//
+// per_account (default) creates one job per requested account. Each account
+// is tracked, retried and cancelled independently and work spreads across
+// workers, at the cost of flooding the queue with many tiny jobs.
+//
+// batch creates a single job with Count = N. The queue stays small and
+// progress is reported on one job, but a single worker processes the whole
+// batch sequentially and cancelling or failing the job affects every account.
+const (
+	GenerateModePerAccount = "per_account"
+	GenerateModeBatch      = "batch"
+)
+
 // GenerateAccountsResponse represents the response for account generation
 type GenerateAccountsResponse struct {
 	Success bool     `json:"success"`
+	Mode    string   `json:"mode,omitempty"`
+	JobID   string   `json:"job_id,omitempty"` // Set in batch mode
 	JobIDs  []string `json:"job_ids"`
 	Message string   `json:"message"`
 	Error   string   `json:"error,omitempty"`
+
+	// Per-job outcome, set when some jobs could not be queued
+	Requested int                `json:"requested,omitempty"`
+	Created   int                `json:"created,omitempty"`
+	Errors    []GenerateJobError `json:"errors,omitempty"`
+
+	// Emails left in the pool, set when the request exceeds capacity
+	Available *int64 `json:"available,omitempty"`
+}
+
+// GenerateJobError describes a job that could not be queued
+type GenerateJobError struct {
+	Index int    `json:"index"`
+	JobID string `json:"job_id"`
+	Error string `json:"error"`
+}
+
+// maxGenerateFailureRatio is the share of jobs that may fail to queue before
+// a generation request as a whole is reported as unsuccessful
+const maxGenerateFailureRatio = 0.5
+
+// BulkStatusRequest represents a request to change the status of many accounts
+type BulkStatusRequest struct {
+	IDs    []uint `json:"ids"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// StatusActionRequest is the body of ban and unban requests
+type StatusActionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// CheckoutRequest identifies the consumer checking out accounts
+type CheckoutRequest struct {
+	Consumer string `json:"consumer,omitempty"`
 }
 
-// StatsResponse represents the comprehensive statistics response
-type StatsResponse struct {
-	Success          bool                   `json:"success"`
-	TotalAccounts    int64                  `json:"total_accounts"`
-	SuccessRate      float64                `json:"success_rate"`
-	FailureRate      float64                `json:"failure_rate"`
-	AccountStats     *models.AccountStats   `json:"account_stats"`
-	JobStats         *models.JobStats       `json:"job_stats"`
-	QueueStats       map[string]interface{} `json:"queue_stats"`
-	HotmailRemaining int                    `json:"hotmail_pool_remaining"`
-	Error            string                 `json:"error,omitempty"`
+// maxCheckoutCount caps the number of accounts a single checkout may return
+const maxCheckoutCount = 20
+
+// BulkDeleteRequest represents a request to delete many accounts, either by
+// ID or every account produced by a job
+type BulkDeleteRequest struct {
+	IDs     []uint `json:"ids,omitempty"`
+	JobID   string `json:"job_id,omitempty"`
+	Confirm bool   `json:"confirm"`
 }
 
+// maxBulkAccountIDs caps the number of accounts a single bulk request may touch
+const maxBulkAccountIDs = 500
+
 // NewAccountsHandler creates a new accounts handler
-func NewAccountsHandler(db *services.Database, queue *services.QueueService) *AccountsHandler {
-	return &AccountsHandler{
-		db:    db,
-		queue: queue,
+func NewAccountsHandler(db *services.Database, queue *services.QueueService, cfg *config.Config, webhooks *services.WebhookDispatcher) *AccountsHandler {
+	h := &AccountsHandler{
+		db:       db,
+		queue:    queue,
+		config:   cfg,
+		webhooks: webhooks,
 	}
+	h.stats = newStatsCache(cfg.Stats.CacheTTL, h.computeStats)
+	return h
 }
 
 // GenerateAccounts handles POST /api/accounts/generate
@@ -76,65 +156,174 @@ func (h *AccountsHandler) GenerateAccounts(c *fiber.Ctx) error {
 	}
 
 	// Parse priority
-	priority := 1 // Default: normal
-	switch strings.ToLower(req.Priority) {
-	case "low":
-		priority = 0
-	case "normal", "":
-		priority = 1
-	case "high":
-		priority = 2
-	default:
+	priority, ok := parsePriority(req.Priority)
+	if !ok {
 		return c.Status(fiber.StatusBadRequest).JSON(GenerateAccountsResponse{
 			Success: false,
 			Error:   "Priority must be 'low', 'normal', or 'high'",
 		})
 	}
 
-	// Create jobs (one job per account for better tracking)
-	jobIDs := make([]string, 0, req.Count)
+	// Validate callback URL
+	callbackURL := strings.TrimSpace(req.CallbackURL)
+	if callbackURL != "" {
+		if err := validateOutboundURL("callback_url", callbackURL, h.config.IsProduction()); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(GenerateAccountsResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+	}
+
+	// Normalize label
+	label, err := models.NormalizeJobLabel(req.Label)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(GenerateAccountsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	// Validate generation options
+	if err := models.ValidateUsernameOptions(req.UsernamePrefix, req.UsernameSuffixLength); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(GenerateAccountsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if req.PasswordPolicy != nil {
+		if err := req.PasswordPolicy.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(GenerateAccountsResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+	}
+
+	// Resolve mode: one job per account (default) or a single batch job
+	mode := strings.ToLower(req.Mode)
+	jobCount, accountsPerJob := req.Count, 1
+	switch mode {
+	case "", GenerateModePerAccount:
+		mode = GenerateModePerAccount
+	case GenerateModeBatch:
+		jobCount, accountsPerJob = 1, req.Count
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(GenerateAccountsResponse{
+			Success: false,
+			Error:   "Mode must be 'per_account' or 'batch'",
+		})
+	}
+
+	// Each account consumes one email; refuse work the pool cannot cover
+	if !req.Force {
+		available, known, err := h.queue.GetEmailPoolAvailable()
+		if err != nil {
+			log.Printf("[AccountsHandler] Failed to read email pool capacity, skipping check: %v", err)
+		} else if known && int64(req.Count) > available {
+			return c.Status(fiber.StatusConflict).JSON(GenerateAccountsResponse{
+				Success:   false,
+				Error:     fmt.Sprintf("Not enough emails in pool: requested %d, available %d", req.Count, available),
+				Available: &available,
+			})
+		}
+	}
 
-	for i := 0; i < req.Count; i++ {
-		job := models.Job{
+	// Build jobs
+	jobs := make([]*models.Job, 0, jobCount)
+	for i := 0; i < jobCount; i++ {
+		job := &models.Job{
 			ID:       uuid.New().String(),
-			Count:    1, // One account per job
+			Type:     models.JobTypeGenerate,
+			Label:    label,
+			Count:    accountsPerJob,
 			Status:   models.JobStatusPending,
+			TestMode: req.TestMode,
 			Priority: priority,
-		}
 
-		// Save job to database
-		if err := h.db.CreateJob(&job); err != nil {
-			log.Printf("[AccountsHandler] Failed to create job: %v", err)
-			continue
+			UsernamePrefix:       req.UsernamePrefix,
+			UsernameSuffixLength: req.UsernameSuffixLength,
+			PasswordPolicy:       req.PasswordPolicy,
 		}
+		if callbackURL != "" {
+			job.CallbackURL = callbackURL
+			job.CallbackStatus = models.CallbackPending
+		}
+		jobs = append(jobs, job)
+	}
+
+	// Save all jobs in one transaction so a failure leaves no partial rows
+	if err := h.db.CreateJobsBatch(jobs); err != nil {
+		log.Printf("[AccountsHandler] Failed to create jobs: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(GenerateAccountsResponse{
+			Success: false,
+			Error:   "Failed to create jobs",
+		})
+	}
+
+	// Add to Redis queue; jobs the queue rejects are marked failed
+	jobIDs := make([]string, 0, jobCount)
+	var jobErrors []GenerateJobError
 
-		// Add to Redis queue
-		if _, err := h.queue.AddJob(job); err != nil {
+	for i, job := range jobs {
+		if _, err := h.queue.AddJob(*job); err != nil {
 			log.Printf("[AccountsHandler] Failed to enqueue job %s: %v", job.ID, err)
-			// Mark job as failed in database
-			job.Status = models.JobStatusFailed
-			job.ErrorMsg = err.Error()
-			h.db.UpdateJob(&job)
+			job.Fail(fmt.Sprintf("failed to enqueue: %v", err))
+			if err := h.db.UpdateJob(job); err != nil {
+				log.Printf("[AccountsHandler] Failed to mark job %s as failed: %v", job.ID, err)
+			}
+			h.logJob(job.ID, models.JobLogError, "Failed to enqueue job: %v", err)
+			jobErrors = append(jobErrors, GenerateJobError{
+				Index: i,
+				JobID: job.ID,
+				Error: err.Error(),
+			})
 			continue
 		}
 
+		h.logJob(job.ID, models.JobLogInfo, "Job queued to generate %d account(s)", job.Count)
 		jobIDs = append(jobIDs, job.ID)
 	}
 
-	if len(jobIDs) == 0 {
-		return c.Status(fiber.StatusInternalServerError).JSON(GenerateAccountsResponse{
-			Success: false,
-			Error:   "Failed to create any jobs",
+	if len(jobErrors) > 0 {
+		failureRatio := float64(len(jobErrors)) / float64(len(jobs))
+		success := failureRatio <= maxGenerateFailureRatio
+
+		status := fiber.StatusMultiStatus
+		if len(jobIDs) == 0 {
+			status = fiber.StatusServiceUnavailable
+		}
+
+		log.Printf("[AccountsHandler] Queued %d of %d jobs for account generation (%d failed)",
+			len(jobIDs), len(jobs), len(jobErrors))
+
+		return c.Status(status).JSON(GenerateAccountsResponse{
+			Success:   success,
+			Mode:      mode,
+			JobIDs:    jobIDs,
+			Message:   fmt.Sprintf("Queued %d of %d jobs", len(jobIDs), len(jobs)),
+			Error:     "Some jobs could not be queued",
+			Requested: len(jobs),
+			Created:   len(jobIDs),
+			Errors:    jobErrors,
 		})
 	}
 
-	log.Printf("[AccountsHandler] Created %d jobs for account generation", len(jobIDs))
+	log.Printf("[AccountsHandler] Created %d jobs for account generation (mode: %s, test_mode: %v)",
+		len(jobIDs), mode, req.TestMode)
 
-	return c.Status(fiber.StatusCreated).JSON(GenerateAccountsResponse{
+	response := GenerateAccountsResponse{
 		Success: true,
+		Mode:    mode,
 		JobIDs:  jobIDs,
 		Message: "Jobs queued successfully",
-	})
+	}
+	if mode == GenerateModeBatch {
+		response.JobID = jobIDs[0]
+		response.Message = fmt.Sprintf("Batch job queued for %d accounts", req.Count)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response)
 }
 
 // ListAccounts handles GET /api/accounts
@@ -142,7 +331,6 @@ func (h *AccountsHandler) ListAccounts(c *fiber.Ctx) error {
 	// Parse pagination parameters
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 	offset, _ := strconv.Atoi(c.Query("offset", "0"))
-	status := c.Query("status", "") // Filter by status: active, banned, suspended
 
 	// Validate and cap limit
 	if limit < 1 {
@@ -152,124 +340,133 @@ func (h *AccountsHandler) ListAccounts(c *fiber.Ctx) error {
 		limit = 100
 	}
 
-	// Get accounts from database
-	accounts, err := h.db.ListAccounts(limit, offset)
-	if err != nil {
-		log.Printf("[AccountsHandler] Failed to retrieve accounts: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(models.AccountResponse{
-			Success: false,
-			Error:   "Failed to retrieve accounts",
-		})
+	if offset < 0 {
+		offset = 0
 	}
 
-	// Filter by status if specified
-	if status != "" {
-		filtered := make([]models.Account, 0)
-		for _, account := range accounts {
-			if strings.EqualFold(account.Status, status) {
-				filtered = append(filtered, account)
-			}
+	// Filter by status (active, banned, suspended) and producing job
+	filter := models.AccountFilter{
+		Status:         strings.ToLower(c.Query("status")),
+		JobID:          c.Query("job_id"),
+		IncludeDeleted: wantsDeleted(c),
+	}
+	if checkedOut := c.Query("checked_out"); checkedOut != "" {
+		value, err := strconv.ParseBool(checkedOut)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+				Success: false,
+				Error:   "checked_out must be true or false",
+			})
 		}
-		accounts = filtered
+		filter.CheckedOut = &value
 	}
-
-	// Get total count for pagination info
-	stats, _ := h.db.GetAccountStats()
-	totalCount := stats.Total
-
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    accounts,
-		"pagination": fiber.Map{
-			"limit":  limit,
-			"offset": offset,
-			"total":  totalCount,
-			"count":  len(accounts),
-		},
-	})
-}
-
-// GetAccount handles GET /api/accounts/:id
-func (h *AccountsHandler) GetAccount(c *fiber.Ctx) error {
-	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
-	if err != nil {
+	if filter.Status != "" && !models.IsValidAccountStatus(filter.Status) {
 		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
 			Success: false,
-			Error:   "Invalid account ID",
+			Error:   "Status must be one of: active, banned, suspended",
 		})
 	}
 
-	account, err := h.db.GetAccount(uint(id))
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(models.AccountResponse{
-			Success: false,
-			Error:   "Account not found",
-		})
+	// Parse date range (RFC3339 or YYYY-MM-DD in the reporting timezone)
+	loc := h.config.ReportingLocation()
+	if from := c.Query("created_from"); from != "" {
+		t, err := parseDateParam(from, loc, false)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid created_from: %v", err),
+			})
+		}
+		filter.CreatedFrom = &t
 	}
-
-	return c.JSON(models.AccountResponse{
-		Success: true,
-		Account: account,
-	})
-}
-
-// CreateAccount handles POST /api/accounts
-func (h *AccountsHandler) CreateAccount(c *fiber.Ctx) error {
-	var req models.AccountCreateRequest
-	if err := c.BodyParser(&req); err != nil {
+	if to := c.Query("created_to"); to != "" {
+		t, err := parseDateParam(to, loc, true)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid created_to: %v", err),
+			})
+		}
+		filter.CreatedTo = &t
+	}
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && filter.CreatedFrom.After(*filter.CreatedTo) {
 		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
 			Success: false,
-			Error:   "Invalid request body",
+			Error:   "created_from must be earlier than or equal to created_to",
 		})
 	}
 
-	// Validate count
-	if req.Count < 1 {
-		req.Count = 1
+	// Parse sorting (whitelisted fields only)
+	sortSpec := models.DefaultAccountSort
+	if field := c.Query("sort"); field != "" {
+		if !models.IsValidAccountSortField(field) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid sort field %q (allowed: created_at, updated_at, username, email, status)", field),
+			})
+		}
+		sortSpec.Field = field
 	}
-	if req.Count > 100 {
+	switch strings.ToLower(c.Query("order")) {
+	case "":
+		// Keep default direction
+	case "asc":
+		sortSpec.Descending = false
+	case "desc":
+		sortSpec.Descending = true
+	default:
 		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
 			Success: false,
-			Error:   "Count must be between 1 and 100",
+			Error:   "Order must be 'asc' or 'desc'",
 		})
 	}
 
-	// Create a job for account creation
-	job := &models.Job{
-		ID:       uuid.New().String(),
-		Count:    req.Count,
-		Username: req.Username,
-		Password: req.Password,
-		Status:   models.JobStatusPending,
-		TestMode: false,
-		Priority: 0,
-	}
-
-	// Save job to database
-	if err := h.db.CreateJob(job); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+	// Get accounts from database
+	accounts, err := h.db.ListAccounts(filter, sortSpec, limit, offset)
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to retrieve accounts: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.AccountResponse{
 			Success: false,
-			Error:   "Failed to create job",
+			Error:   "Failed to retrieve accounts",
 		})
 	}
 
-	// Enqueue job
-	if err := h.queue.EnqueueJob(job); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
-			Success: false,
-			Error:   "Failed to enqueue job",
-		})
+	// Mask credentials unless explicitly requested
+	if !wantsCredentials(c) {
+		for i := range accounts {
+			accounts[i].HidePasswords()
+		}
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(models.JobResponse{
-		Success: true,
-		Message: "Account creation job queued",
-		Job:     job,
+	// Get total count for pagination info; filtered listings need a matching COUNT
+	var totalCount int64
+	if filter.IsEmpty() {
+		stats, err := h.db.GetAccountStats()
+		if err == nil {
+			totalCount = stats.Total
+		}
+	} else {
+		totalCount, err = h.db.CountAccountsFiltered(filter)
+		if err != nil {
+			log.Printf("[AccountsHandler] Failed to count filtered accounts: %v", err)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    accounts,
+		"pagination": fiber.Map{
+			"limit":    limit,
+			"offset":   offset,
+			"total":    totalCount,
+			"count":    len(accounts),
+			"has_more": int64(offset+len(accounts)) < totalCount,
+		},
 	})
 }
 
-// UpdateAccount handles PUT /api/accounts/:id
-func (h *AccountsHandler) UpdateAccount(c *fiber.Ctx) error {
+// GetAccount handles GET /api/accounts/:id
+func (h *AccountsHandler) GetAccount(c *fiber.Ctx) error {
 	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
@@ -286,32 +483,34 @@ func (h *AccountsHandler) UpdateAccount(c *fiber.Ctx) error {
 		})
 	}
 
-	// Parse update data
-	if err := c.BodyParser(account); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
-			Success: false,
-			Error:   "Invalid request body",
-		})
+	if !wantsCredentials(c) {
+		account.HidePasswords()
 	}
 
-	// Update in database
-	if err := h.db.UpdateAccount(account); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.AccountResponse{
-			Success: false,
-			Error:   "Failed to update account",
-		})
+	// ?expand=kick_data inlines the parsed Kick data next to the account
+	if c.Query("expand") == "kick_data" {
+		response := fiber.Map{
+			"success": true,
+			"account": account,
+		}
+		kickData, err := account.ParseKickData()
+		if err != nil {
+			response["kick_data_error"] = err.Error()
+		} else {
+			response["kick_data"] = kickData
+		}
+		return c.JSON(response)
 	}
 
 	return c.JSON(models.AccountResponse{
 		Success: true,
-		Message: "Account updated successfully",
 		Account: account,
 	})
 }
 
-// DeleteAccount handles DELETE /api/accounts/:accountId
-func (h *AccountsHandler) DeleteAccount(c *fiber.Ctx) error {
-	accountID, err := strconv.ParseUint(c.Params("accountId"), 10, 32)
+// GetKickData handles GET /api/accounts/:id/kick-data
+func (h *AccountsHandler) GetKickData(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
@@ -319,234 +518,1090 @@ func (h *AccountsHandler) DeleteAccount(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get account first to verify it exists
-	account, err := h.db.GetAccount(uint(accountID))
+	account, err := h.db.GetAccount(uint(id))
 	if err != nil {
-		log.Printf("[AccountsHandler] Account not found: %d", accountID)
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
 			"error":   "Account not found",
 		})
 	}
 
-	// Soft delete (GORM automatically sets DeletedAt)
-	if err := h.db.DeleteAccount(uint(accountID)); err != nil {
-		log.Printf("[AccountsHandler] Failed to delete account %d: %v", accountID, err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+	kickData, err := account.ParseKickData()
+	if err != nil {
+		log.Printf("[AccountsHandler] Account %d has malformed kick_data: %v", account.ID, err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
-			"error":   "Failed to delete account",
+			"error":   "Stored kick_data is not valid JSON",
+			"detail":  err.Error(),
 		})
 	}
 
-	log.Printf("[AccountsHandler] Account %d (%s) soft deleted", accountID, account.Username)
-
 	return c.JSON(fiber.Map{
 		"success": true,
-		"message": "Account deleted successfully",
-		"account": fiber.Map{
-			"id":       accountID,
-			"username": account.Username,
-			"email":    account.Email,
-		},
+		"data":    kickData,
 	})
 }
 
-// GetStats handles GET /api/stats
-func (h *AccountsHandler) GetStats(c *fiber.Ctx) error {
-	// Get account statistics
-	accountStats, err := h.db.GetAccountStats()
+// SetKickData handles PUT /api/accounts/:id/kick-data
+// The request body is the Kick data document itself.
+func (h *AccountsHandler) SetKickData(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
-		log.Printf("[AccountsHandler] Failed to get account stats: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(StatsResponse{
-			Success: false,
-			Error:   "Failed to retrieve account statistics",
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid account ID",
 		})
 	}
 
-	// Get job statistics
-	jobStats, err := h.db.GetJobStats()
-	if err != nil {
-		log.Printf("[AccountsHandler] Failed to get job stats: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(StatsResponse{
-			Success: false,
-			Error:   "Failed to retrieve job statistics",
+	body := c.Body()
+	if err := models.ValidateKickData(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
 		})
 	}
 
-	// Get queue statistics
-	queueStats, err := h.queue.GetQueueStats()
+	account, err := h.db.GetAccount(uint(id))
 	if err != nil {
-		log.Printf("[AccountsHandler] Failed to get queue stats: %v", err)
-		queueStats = map[string]interface{}{
-			"error": "Queue unavailable",
-		}
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Account not found",
+		})
 	}
 
-	// Calculate success/fail ratio
-	totalJobs := jobStats.Completed + jobStats.Failed
-	var successRate, failureRate float64
-	if totalJobs > 0 {
-		successRate = (float64(jobStats.Completed) / float64(totalJobs)) * 100
-		failureRate = (float64(jobStats.Failed) / float64(totalJobs)) * 100
+	// Store compacted so responses are not padded with client whitespace
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "kick_data must be valid JSON",
+		})
 	}
 
-	// TODO: Get hotmail pool remaining from email pool service
-	// For now, return a placeholder
-	hotmailRemaining := 0
-
-	response := StatsResponse{
-		Success:          true,
-		TotalAccounts:    accountStats.Total,
-		SuccessRate:      successRate,
-		FailureRate:      failureRate,
-		AccountStats:     accountStats,
-		JobStats:         jobStats,
-		QueueStats:       queueStats,
-		HotmailRemaining: hotmailRemaining,
+	account.KickData = compacted.String()
+	if err := h.db.UpdateAccount(account); err != nil {
+		log.Printf("[AccountsHandler] Failed to update kick_data for account %d: %v", account.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to update kick data",
+		})
 	}
 
-	return c.JSON(response)
-}
+	log.Printf("[AccountsHandler] Kick data updated for account %d (%d bytes)", account.ID, compacted.Len())
 
-// GetJobs handles GET /api/jobs
-func (h *AccountsHandler) GetJobs(c *fiber.Ctx) error {
-	limit, _ := strconv.Atoi(c.Query("limit", "50"))
-	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    json.RawMessage(account.KickData),
+	})
+}
 
-	if limit > 100 {
-		limit = 100
+// GetAccountJob handles GET /api/accounts/:id/job
+func (h *AccountsHandler) GetAccountJob(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid account ID",
+		})
 	}
 
-	jobs, err := h.db.ListJobs(limit, offset)
+	account, err := h.db.GetAccount(uint(id))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
-			Success: false,
-			Error:   "Failed to retrieve jobs",
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Account not found",
 		})
 	}
 
-	return c.JSON(models.JobResponse{
-		Success: true,
-		Jobs:    jobs,
-	})
-}
-
-// GetJob handles GET /api/jobs/:jobId
-func (h *AccountsHandler) GetJob(c *fiber.Ctx) error {
-	jobID := c.Params("jobId")
-
-	if jobID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+	if account.JobID == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
-			"error":   "Job ID is required",
+			"error":   "Account has no originating job (created before job tracking)",
 		})
 	}
 
-	// Get job from database
-	job, err := h.db.GetJob(jobID)
+	job, err := h.db.GetJob(account.JobID)
 	if err != nil {
-		log.Printf("[AccountsHandler] Job not found: %s", jobID)
+		log.Printf("[AccountsHandler] Job %s for account %d not found", account.JobID, account.ID)
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
-			"error":   "Job not found",
+			"error":   "Originating job no longer exists",
+			"job_id":  account.JobID,
 		})
 	}
 
 	// Get status from Redis (more up-to-date than database)
-	redisStatus, err := h.queue.GetJobStatus(jobID)
+	redisStatus, err := h.queue.GetJobStatus(job.ID)
 	if err == nil && redisStatus != "" {
 		job.Status = models.JobStatus(redisStatus)
 	}
 
-	// Calculate progress percentage
-	var progressPercent float64
-	if job.Count > 0 {
-		progressPercent = (float64(job.Progress) / float64(job.Count)) * 100
-	}
-
-	// Calculate duration if job has started
-	var duration string
-	if job.StartedAt != nil {
-		if job.CompletedAt != nil {
-			duration = job.CompletedAt.Sub(*job.StartedAt).String()
-		} else {
-			duration = time.Since(*job.StartedAt).String()
-		}
-	}
-
+	// ToJSON omits credentials
 	return c.JSON(fiber.Map{
 		"success": true,
 		"job":     job,
-		"progress": fiber.Map{
-			"current":    job.Progress,
-			"total":      job.Count,
-			"percentage": progressPercent,
-			"successful": job.Successful,
-			"failed":     job.Failed,
-		},
-		"duration": duration,
-		"status":   string(job.Status),
+		"account": account.ToJSON(),
 	})
 }
 
-// CancelJob handles POST /api/jobs/:id/cancel
-func (h *AccountsHandler) CancelJob(c *fiber.Ctx) error {
-	id := c.Params("id")
-
-	job, err := h.db.GetJob(id)
+// DownloadAccount handles GET /api/accounts/:id/download
+// Returns the account's credentials as a text (default) or JSON attachment.
+// It sits behind the same API middleware as the export endpoints, and every
+// download is recorded in the account history.
+func (h *AccountsHandler) DownloadAccount(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(models.JobResponse{
-			Success: false,
-			Error:   "Job not found",
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid account ID",
 		})
 	}
 
-	if !job.CanBeCancelled() {
-		return c.Status(fiber.StatusBadRequest).JSON(models.JobResponse{
-			Success: false,
-			Error:   "Job cannot be cancelled in current state",
+	format := strings.ToLower(c.Query("format", "txt"))
+	if format != "txt" && format != "json" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Format must be 'txt' or 'json'",
+		})
+	}
+
+	account, err := h.db.GetAccount(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Account not found",
+		})
+	}
+
+	// Credential reads are always audited
+	if err := h.db.RecordAccountHistory(&models.AccountHistory{
+		AccountID: account.ID,
+		Action:    models.AccountActionCredentialsDownloaded,
+		NewValue:  format,
+		RequestID: requestID(c),
+	}); err != nil {
+		log.Printf("[AccountsHandler] Failed to audit credential download for account %d: %v", account.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to record download",
+		})
+	}
+
+	log.Printf("[AccountsHandler] Credentials for account %d downloaded as %s (ip: %s)", account.ID, format, c.IP())
+
+	credentials := fiber.Map{
+		"email":          account.Email,
+		"email_password": account.EmailPassword,
+		"username":       account.Username,
+		"password":       account.Password,
+		"birthdate":      account.Birthdate,
+		"created_at":     account.CreatedAt.UTC().Format(time.RFC3339),
+	}
+
+	filename := fmt.Sprintf("account-%d-%s.%s", account.ID, account.Username, format)
+	c.Attachment(filename)
+
+	if format == "json" {
+		return c.JSON(credentials)
+	}
+
+	var b strings.Builder
+	for _, key := range []string{"email", "email_password", "username", "password", "birthdate", "created_at"} {
+		fmt.Fprintf(&b, "%s: %s\n", key, credentials[key])
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	return c.SendString(b.String())
+}
+
+// GetAccountByUsername handles GET /api/accounts/by-username/:username
+func (h *AccountsHandler) GetAccountByUsername(c *fiber.Ctx) error {
+	username, err := url.PathUnescape(c.Params("username"))
+	if err != nil || strings.TrimSpace(username) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Invalid username",
+		})
+	}
+
+	return h.lookupAccount(c, "username", username)
+}
+
+// GetAccountByEmail handles GET /api/accounts/by-email/:email
+func (h *AccountsHandler) GetAccountByEmail(c *fiber.Ctx) error {
+	email, err := url.PathUnescape(c.Params("email"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Invalid email address",
+		})
+	}
+
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Invalid email address",
+		})
+	}
+
+	return h.lookupAccount(c, "email", email)
+}
+
+// lookupAccount responds with the account matching column=value. Soft-deleted
+// accounts are reported as not found unless ?include_deleted=true is passed.
+func (h *AccountsHandler) lookupAccount(c *fiber.Ctx, column, value string) error {
+	account, err := h.db.FindAccountBy(column, value, wantsDeleted(c))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Account not found",
+		})
+	}
+
+	if !wantsCredentials(c) {
+		account.HidePasswords()
+	}
+
+	return c.JSON(models.AccountResponse{
+		Success: true,
+		Account: account,
+	})
+}
+
+// CreateAccount handles POST /api/accounts
+func (h *AccountsHandler) CreateAccount(c *fiber.Ctx) error {
+	var req models.AccountCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	// Validate count
+	if req.Count < 1 {
+		req.Count = 1
+	}
+	if req.Count > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Count must be between 1 and 100",
 		})
 	}
 
-	job.Status = models.JobStatusCancelled
-	now := time.Now()
-	job.CompletedAt = &now
+	// Create a job for account creation
+	job := &models.Job{
+		ID:       uuid.New().String(),
+		Type:     models.JobTypeGenerate,
+		Count:    req.Count,
+		Username: req.Username,
+		Password: req.Password,
+		Status:   models.JobStatusPending,
+		TestMode: false,
+		Priority: 0,
+	}
+
+	// Save job to database
+	if err := h.db.CreateJob(job); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to create job",
+		})
+	}
 
-	if err := h.db.UpdateJob(job); err != nil {
+	// Enqueue job
+	if err := h.queue.EnqueueJob(job); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
 			Success: false,
-			Error:   "Failed to cancel job",
+			Error:   "Failed to enqueue job",
 		})
 	}
 
-	return c.JSON(models.JobResponse{
+	return c.Status(fiber.StatusCreated).JSON(models.JobResponse{
 		Success: true,
-		Message: "Job cancelled successfully",
+		Message: "Account creation job queued",
 		Job:     job,
 	})
 }
 
-// GetJobStats handles GET /api/jobs/stats
-func (h *AccountsHandler) GetJobStats(c *fiber.Ctx) error {
-	stats, err := h.db.GetJobStats()
+// UpdateAccount handles PUT/PATCH /api/accounts/:id
+// Only the fields in models.AccountUpdateRequest can be changed; any other
+// key in the body (id, email, username, job_id, timestamps...) is rejected.
+func (h *AccountsHandler) UpdateAccount(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Invalid account ID",
+		})
+	}
+
+	// Reject immutable or unknown fields before touching the account
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(c.Body(), &raw); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	rejected := make([]string, 0)
+	for key := range raw {
+		if !models.AccountUpdatableFields[key] {
+			rejected = append(rejected, key)
+		}
+	}
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success":         false,
+			"error":           "Request contains fields that cannot be updated",
+			"rejected_fields": rejected,
+		})
+	}
+
+	var req models.AccountUpdateRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	account, err := h.db.GetAccount(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Account not found",
+		})
+	}
+
+	oldStatus := account.Status
+	req.ApplyTo(account)
+
+	// Update in database
+	if err := h.db.UpdateAccount(account); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Failed to update account",
+		})
+	}
+
+	h.webhooks.AccountStatusChanged(account, oldStatus, account.Status, "")
+	if account.Status != oldStatus {
+		h.queue.PublishAccountEvent("account_status_changed", account.ID, map[string]interface{}{
+			"username":   account.Username,
+			"old_status": oldStatus,
+			"new_status": account.Status,
+		})
+	}
+
+	account.HidePasswords()
+
+	return c.JSON(models.AccountResponse{
+		Success: true,
+		Message: "Account updated successfully",
+		Account: account,
+	})
+}
+
+// UpdateAccountNotes handles PUT /api/accounts/:id/notes
+// Works for any status; the previous notes are kept in the account history.
+func (h *AccountsHandler) UpdateAccountNotes(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Invalid account ID",
+		})
+	}
+
+	var req models.AccountNotesRequest
+	if err := c.BodyParser(&req); err != nil || req.Notes == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Request body must contain a notes field",
+		})
+	}
+	if len(*req.Notes) > models.MaxNotesLength {
+		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Notes must be at most %d characters", models.MaxNotesLength),
+		})
+	}
+
+	if _, err := h.db.GetAccount(uint(id)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Account not found",
+		})
+	}
+
+	account, err := h.db.UpdateAccountNotes(uint(id), *req.Notes, requestID(c))
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to update notes for account %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Failed to update notes",
+		})
+	}
+
+	log.Printf("[AccountsHandler] Notes updated for account %d", id)
+
+	account.HidePasswords()
+	return c.JSON(models.AccountResponse{
+		Success: true,
+		Message: "Notes updated",
+		Account: account,
+	})
+}
+
+// BulkUpdateStatus handles POST /api/accounts/bulk-status
+func (h *AccountsHandler) BulkUpdateStatus(c *fiber.Ctx) error {
+	var req BulkStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+
+	if len(req.IDs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "ids must contain at least one account ID",
+		})
+	}
+	if len(req.IDs) > maxBulkAccountIDs {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   fmt.Sprintf("At most %d account IDs can be updated at once", maxBulkAccountIDs),
+		})
+	}
+	if !models.IsValidAccountStatus(req.Status) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
-			"error":   "Failed to retrieve job statistics",
+			"error":   "Status must be one of: active, banned, suspended",
 		})
 	}
 
-	queueStats, err := h.queue.GetQueueStats()
+	ids := uniqueIDs(req.IDs)
+	affected, previous, missing, err := h.db.BulkUpdateAccountStatus(ids, req.Status, req.Reason, requestID(c))
 	if err != nil {
+		log.Printf("[AccountsHandler] Bulk status update failed: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
-			"error":   "Failed to retrieve queue statistics",
+			"error":   "Failed to update account statuses",
+		})
+	}
+
+	if missing == nil {
+		missing = []uint{}
+	}
+
+	log.Printf("[AccountsHandler] Bulk status update to '%s': %d updated, %d not found", req.Status, affected, len(missing))
+
+	if affected > 0 {
+		h.queue.PublishAccountEvent("accounts_status_updated", 0, map[string]interface{}{
+			"status":   req.Status,
+			"reason":   req.Reason,
+			"affected": affected,
 		})
 	}
 
+	for id, oldStatus := range previous {
+		h.webhooks.AccountStatusChanged(&models.Account{ID: id}, oldStatus, req.Status, req.Reason)
+	}
+
 	return c.JSON(fiber.Map{
-		"success":     true,
-		"job_stats":   stats,
-		"queue_stats": queueStats,
+		"success":   true,
+		"message":   "Account statuses updated",
+		"status":    req.Status,
+		"affected":  affected,
+		"not_found": missing,
+	})
+}
+
+// DeleteAccount handles DELETE /api/accounts/:id
+func (h *AccountsHandler) DeleteAccount(c *fiber.Ctx) error {
+	accountID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid account ID",
+		})
+	}
+
+	// Get account first to verify it exists
+	account, err := h.db.GetAccount(uint(accountID))
+	if err != nil {
+		log.Printf("[AccountsHandler] Account not found: %d", accountID)
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Account not found",
+		})
+	}
+
+	// Soft delete (GORM automatically sets DeletedAt)
+	if err := h.db.DeleteAccount(uint(accountID)); err != nil {
+		log.Printf("[AccountsHandler] Failed to delete account %d: %v", accountID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to delete account",
+		})
+	}
+
+	log.Printf("[AccountsHandler] Account %d (%s) soft deleted", accountID, account.Username)
+
+	h.queue.PublishAccountEvent("account_deleted", account.ID, map[string]interface{}{
+		"username": account.Username,
 	})
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Account deleted successfully",
+		"account": fiber.Map{
+			"id":       accountID,
+			"username": account.Username,
+			"email":    account.Email,
+		},
+	})
+}
+
+// BulkDeleteAccounts handles DELETE /api/accounts
+func (h *AccountsHandler) BulkDeleteAccounts(c *fiber.Ctx) error {
+	var req BulkDeleteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+
+	if !req.Confirm {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Bulk delete requires \"confirm\": true",
+		})
+	}
+	if (len(req.IDs) == 0) == (req.JobID == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Provide either ids or job_id",
+		})
+	}
+
+	ids := req.IDs
+	if req.JobID != "" {
+		if _, err := h.db.GetJob(req.JobID); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"error":   "Job not found",
+			})
+		}
+
+		jobAccountIDs, err := h.db.GetAccountIDsByJobID(req.JobID)
+		if err != nil {
+			log.Printf("[AccountsHandler] Failed to get accounts for job %s: %v", req.JobID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"error":   "Failed to retrieve job accounts",
+			})
+		}
+		ids = jobAccountIDs
+	}
+
+	ids = uniqueIDs(ids)
+	if len(ids) > maxBulkAccountIDs {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   fmt.Sprintf("At most %d accounts can be deleted at once", maxBulkAccountIDs),
+		})
+	}
+
+	result := &models.BulkDeleteResult{Deleted: []uint{}, AlreadyDeleted: []uint{}, NotFound: []uint{}}
+	if len(ids) > 0 {
+		var err error
+		result, err = h.db.BulkDeleteAccounts(ids)
+		if err != nil {
+			log.Printf("[AccountsHandler] Bulk delete failed: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"error":   "Failed to delete accounts",
+			})
+		}
+	}
+
+	log.Printf("[AccountsHandler] Bulk delete (request_id: %s): %d deleted, %d already deleted, %d not found",
+		requestID(c), len(result.Deleted), len(result.AlreadyDeleted), len(result.NotFound))
+
+	// One summary event instead of one per account
+	if len(result.Deleted) > 0 {
+		h.queue.PublishAccountEvent("accounts_deleted", 0, map[string]interface{}{
+			"deleted": len(result.Deleted),
+			"job_id":  req.JobID,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":         true,
+		"message":         "Bulk delete completed",
+		"deleted":         len(result.Deleted),
+		"already_deleted": len(result.AlreadyDeleted),
+		"not_found":       len(result.NotFound),
+		"ids":             result,
+	})
+}
+
+// RestoreAccount handles POST /api/accounts/:id/restore
+func (h *AccountsHandler) RestoreAccount(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Invalid account ID",
+		})
+	}
+
+	account, err := h.db.GetAccountUnscoped(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Account not found",
+		})
+	}
+
+	if !account.DeletedAt.Valid {
+		return c.Status(fiber.StatusConflict).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Account is not deleted",
+		})
+	}
+
+	// A newer account may have claimed the same email or username
+	conflict, err := h.db.FindAccountConflict(account.ID, account.Email, account.Username)
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to check restore conflicts for account %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Failed to restore account",
+		})
+	}
+	if conflict != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success":        false,
+			"error":          "Another account already uses this email or username",
+			"conflicting_id": conflict.ID,
+		})
+	}
+
+	reqID := requestID(c)
+	if err := h.db.RestoreAccount(account.ID, reqID); err != nil {
+		log.Printf("[AccountsHandler] Failed to restore account %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Failed to restore account",
+		})
+	}
+
+	log.Printf("[AccountsHandler] Account %d (%s) restored (request_id=%s, ip=%s)", account.ID, account.Username, reqID, c.IP())
+
+	restored, err := h.db.GetAccount(account.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Account restored but could not be reloaded",
+		})
+	}
+	h.webhooks.AccountRestored(restored)
+	h.queue.PublishAccountEvent("account_restored", restored.ID, map[string]interface{}{
+		"username": restored.Username,
+		"status":   restored.Status,
+	})
+	restored.HidePasswords()
+
+	return c.JSON(models.AccountResponse{
+		Success: true,
+		Message: "Account restored successfully",
+		Account: restored,
+	})
+}
+
+// CheckoutAccounts handles POST /api/accounts/checkout
+// Hands out the oldest unused active accounts (?count=N, default 1) with
+// full credentials and marks them checked out until checked back in.
+func (h *AccountsHandler) CheckoutAccounts(c *fiber.Ctx) error {
+	count := c.QueryInt("count", 1)
+	if count < 1 || count > maxCheckoutCount {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   fmt.Sprintf("Count must be between 1 and %d", maxCheckoutCount),
+		})
+	}
+
+	var req CheckoutRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "Invalid request body",
+			})
+		}
+	}
+	consumer := strings.TrimSpace(req.Consumer)
+	if consumer == "" {
+		consumer = c.IP()
+	}
+
+	reqID := requestID(c)
+	accounts, err := h.db.CheckoutAccounts(count, consumer, reqID)
+	if err != nil {
+		log.Printf("[AccountsHandler] Checkout failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to check out accounts",
+		})
+	}
+
+	if len(accounts) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "No unused active accounts available",
+		})
+	}
+
+	log.Printf("[AccountsHandler] %d account(s) checked out by %s (request_id=%s)", len(accounts), consumer, reqID)
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"requested": count,
+		"count":     len(accounts),
+		"data":      accounts,
+	})
+}
+
+// CheckinAccount handles POST /api/accounts/:id/checkin
+func (h *AccountsHandler) CheckinAccount(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Invalid account ID",
+		})
+	}
+
+	if _, err := h.db.GetAccount(uint(id)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Account not found",
+		})
+	}
+
+	released, err := h.db.CheckinAccount(uint(id), requestID(c))
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to check in account %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Failed to check in account",
+		})
+	}
+	if !released {
+		return c.Status(fiber.StatusConflict).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Account is not checked out",
+		})
+	}
+
+	log.Printf("[AccountsHandler] Account %d checked in", id)
+
+	return c.JSON(models.AccountResponse{
+		Success: true,
+		Message: "Account checked in",
+	})
+}
+
+// BanAccount handles POST /api/accounts/:id/ban
+func (h *AccountsHandler) BanAccount(c *fiber.Ctx) error {
+	return h.transitionStatus(c, models.AccountStatusBanned,
+		models.AccountStatusActive, models.AccountStatusSuspended)
+}
+
+// UnbanAccount handles POST /api/accounts/:id/unban
+func (h *AccountsHandler) UnbanAccount(c *fiber.Ctx) error {
+	return h.transitionStatus(c, models.AccountStatusActive, models.AccountStatusBanned)
+}
+
+// transitionStatus moves an account to status if its current status is one
+// of allowedFrom, records the reason in the account history and publishes
+// an account_status_changed event
+func (h *AccountsHandler) transitionStatus(c *fiber.Ctx, status string, allowedFrom ...string) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Invalid account ID",
+		})
+	}
+
+	var req StatusActionRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.AccountResponse{
+				Success: false,
+				Error:   "Invalid request body",
+			})
+		}
+	}
+
+	reqID := requestID(c)
+	oldStatus, err := h.db.TransitionAccountStatus(uint(id), status, allowedFrom, req.Reason, reqID)
+	switch {
+	case errors.Is(err, services.ErrAccountNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Account not found",
+		})
+	case errors.Is(err, services.ErrStatusTransition):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success":        false,
+			"error":          fmt.Sprintf("Cannot change account status from '%s' to '%s'", oldStatus, status),
+			"current_status": oldStatus,
+		})
+	case err != nil:
+		log.Printf("[AccountsHandler] Failed to set account %d status to %s: %v", id, status, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Failed to update account status",
+		})
+	}
+
+	account, err := h.db.GetAccount(uint(id))
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to reload account %d after status change: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.AccountResponse{
+			Success: false,
+			Error:   "Failed to load account",
+		})
+	}
+
+	log.Printf("[AccountsHandler] Account %d status changed %s -> %s (request_id=%s, reason=%q)",
+		account.ID, oldStatus, status, reqID, req.Reason)
+
+	h.queue.PublishAccountEvent("account_status_changed", account.ID, map[string]interface{}{
+		"username":   account.Username,
+		"old_status": oldStatus,
+		"new_status": status,
+		"reason":     req.Reason,
+	})
+	h.webhooks.AccountStatusChanged(account, oldStatus, status, req.Reason)
+
+	account.HidePasswords()
+
+	return c.JSON(models.AccountResponse{
+		Success: true,
+		Message: fmt.Sprintf("Account status changed to %s", status),
+		Account: account,
+	})
+}
+
+// VerifyAccount handles POST /api/accounts/:id/verify
+// It schedules a verify job; the outcome is written back to the account's
+// status and notes by services.JobWatcher when the job completes.
+func (h *AccountsHandler) VerifyAccount(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Invalid account ID",
+		})
+	}
+
+	account, err := h.db.GetAccount(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Account not found",
+		})
+	}
+
+	job := &models.Job{
+		ID:        uuid.New().String(),
+		Type:      models.JobTypeVerify,
+		Count:     1,
+		AccountID: account.ID,
+		Status:    models.JobStatusPending,
+		Priority:  int(services.PriorityNormal),
+	}
+
+	if err := h.db.CreateAccountJob(job); err != nil {
+		if errors.Is(err, services.ErrJobInFlight) {
+			return c.Status(fiber.StatusConflict).JSON(models.JobResponse{
+				Success: false,
+				Error:   "A verification is already in progress for this account",
+			})
+		}
+		log.Printf("[AccountsHandler] Failed to create verify job: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to create job",
+		})
+	}
+
+	if err := h.queue.EnqueueJob(job); err != nil {
+		log.Printf("[AccountsHandler] Failed to enqueue verify job %s: %v", job.ID, err)
+		job.Fail(err.Error())
+		if err := h.db.UpdateJob(job); err != nil {
+			log.Printf("[AccountsHandler] Failed to mark job %s as failed: %v", job.ID, err)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to enqueue job",
+		})
+	}
+
+	log.Printf("[AccountsHandler] Verification job %s scheduled for account %d", job.ID, account.ID)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success":    true,
+		"message":    "Verification scheduled",
+		"job_id":     job.ID,
+		"account_id": account.ID,
+	})
+}
+
+// validateOutboundURL checks a URL the backend will POST to (job callbacks,
+// webhooks): absolute http(s) with a host, https only in production, and
+// within the stored size limit. field names the request field in errors.
+func validateOutboundURL(field, raw string, production bool) error {
+	if len(raw) > models.MaxCallbackURLLength {
+		return fmt.Errorf("%s must be at most %d characters", field, models.MaxCallbackURLLength)
+	}
+
+	u, err := url.ParseRequestURI(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%s must be an absolute URL", field)
+	}
+
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if production {
+			return fmt.Errorf("%s must use https in production", field)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s must use http or https", field)
+	}
+}
+
+// requestID returns the ID assigned by the requestid middleware
+func requestID(c *fiber.Ctx) string {
+	if id, ok := c.Locals("requestid").(string); ok {
+		return id
+	}
+	return ""
+}
+
+// uniqueStrings removes duplicate strings while preserving order
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// uniqueIDs removes duplicate IDs while preserving order
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// wantsCredentials reports whether the caller opted in to raw credentials
+// via ?include_credentials=true. Responses mask passwords otherwise.
+// TODO: also require an authorized role once API auth exists
+func wantsCredentials(c *fiber.Ctx) bool {
+	return strings.EqualFold(c.Query("include_credentials"), "true")
+}
+
+// credentialKeys are result fields that hold secrets
+var credentialKeys = map[string]bool{
+	"password":          true,
+	"email_password":    true,
+	"new_password":      true,
+	"verification_code": true,
+	"token":             true,
+	"access_token":      true,
+	"secret":            true,
+}
+
+// redactCredentials masks credential-looking fields anywhere in a decoded
+// JSON value
+func redactCredentials(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			lower := strings.ToLower(key)
+			if credentialKeys[lower] || strings.HasSuffix(lower, "_password") {
+				if field != nil && field != "" {
+					v[key] = "********"
+				}
+				continue
+			}
+			v[key] = redactCredentials(field)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = redactCredentials(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// wantsDeleted reports whether the caller asked for soft-deleted accounts
+// via ?include_deleted=true
+// TODO: restrict to admins once API auth exists
+func wantsDeleted(c *fiber.Ctx) bool {
+	return strings.EqualFold(c.Query("include_deleted"), "true")
+}
+
+// parseDateParam parses an RFC3339 timestamp or a YYYY-MM-DD date.
+// Date-only values cover the whole day in loc: the start of the day when
+// endOfDay is false, the last instant of the day when it is true.
+func parseDateParam(value string, loc *time.Location, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC3339 or YYYY-MM-DD, got %q", value)
+	}
+
+	if endOfDay {
+		return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return day, nil
 }
@@ -0,0 +1,1362 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"botrix-backend/models"
+	"botrix-backend/services"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/google/uuid"
+)
+
+// createTestAccount stores an active account with a unique email and
+// username, created at createdAt when that is set
+func createTestAccount(t *testing.T, h *AccountsHandler, n int, createdAt time.Time) *models.Account {
+	t.Helper()
+	account := &models.Account{
+		Email:         fmt.Sprintf("user%d@example.com", n),
+		Username:      fmt.Sprintf("user%d", n),
+		Password:      "password",
+		EmailPassword: "email-password",
+		Status:        models.AccountStatusActive,
+		CreatedAt:     createdAt,
+	}
+	if err := h.db.CreateAccount(account); err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	return account
+}
+
+func TestParseDateParam(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	tests := []struct {
+		value    string
+		loc      *time.Location
+		endOfDay bool
+		want     time.Time
+	}{
+		{"2026-10-01", time.UTC, false, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"2026-10-07", time.UTC, true, time.Date(2026, 10, 7, 23, 59, 59, 999999999, time.UTC)},
+		{"2026-10-01", berlin, false, time.Date(2026, 9, 30, 22, 0, 0, 0, time.UTC)},
+		{"2026-10-07", berlin, true, time.Date(2026, 10, 7, 21, 59, 59, 999999999, time.UTC)},
+		// RFC3339 values are exact instants whatever the location or bound
+		{"2026-10-07T12:30:00Z", berlin, true, time.Date(2026, 10, 7, 12, 30, 0, 0, time.UTC)},
+		{"2026-10-07T12:30:00+02:00", time.UTC, false, time.Date(2026, 10, 7, 10, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseDateParam(tt.value, tt.loc, tt.endOfDay)
+		if err != nil {
+			t.Errorf("parseDateParam(%q, %s, %v): %v", tt.value, tt.loc, tt.endOfDay, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseDateParam(%q, %s, %v) = %s, want %s", tt.value, tt.loc, tt.endOfDay, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "yesterday", "2026-13-01", "2026-10-01T25:00:00Z", "10/01/2026"} {
+		if _, err := parseDateParam(value, time.UTC, false); err == nil {
+			t.Errorf("parseDateParam(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestListAccountsDateRange(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Get("/api/accounts", h.ListAccounts)
+
+	// Accounts on both sides of each boundary of the week Oct 1-7
+	createTestAccount(t, h, 1, time.Date(2026, 9, 30, 23, 59, 59, 0, time.UTC))
+	createTestAccount(t, h, 2, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC))
+	createTestAccount(t, h, 3, time.Date(2026, 10, 4, 12, 0, 0, 0, time.UTC))
+	createTestAccount(t, h, 4, time.Date(2026, 10, 7, 23, 59, 59, 0, time.UTC))
+	createTestAccount(t, h, 5, time.Date(2026, 10, 8, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"whole days are inclusive", "created_from=2026-10-01&created_to=2026-10-07", []string{"user2", "user3", "user4"}},
+		{"single day", "created_from=2026-10-07&created_to=2026-10-07", []string{"user4"}},
+		{"from only", "created_from=2026-10-07", []string{"user4", "user5"}},
+		{"to only", "created_to=2026-09-30", []string{"user1"}},
+		{"RFC3339 bounds are inclusive", "created_from=2026-10-01T00:00:00Z&created_to=2026-10-07T23:59:59Z", []string{"user2", "user3", "user4"}},
+		{"RFC3339 just past the bounds", "created_from=2026-10-01T00:00:01Z&created_to=2026-10-07T23:59:58Z", []string{"user3"}},
+		{"equal instants", "created_from=2026-10-04T12:00:00Z&created_to=2026-10-04T12:00:00Z", []string{"user3"}},
+		{"RFC3339 with an offset", "created_from=2026-10-08T01:00:00%2B01:00", []string{"user5"}},
+		{"empty range", "created_from=2026-11-01", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := doRequest(t, app, http.MethodGet, "/api/accounts?sort=created_at&order=asc&"+tt.query, "")
+			if status != fiber.StatusOK {
+				t.Fatalf("status = %d, want 200 (body %v)", status, body)
+			}
+
+			var got []string
+			for _, item := range body["data"].([]interface{}) {
+				got = append(got, item.(map[string]interface{})["username"].(string))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("accounts = %v, want %v", got, tt.want)
+			}
+
+			total := body["pagination"].(map[string]interface{})["total"].(float64)
+			if int(total) != len(tt.want) {
+				t.Errorf("pagination total = %v, want %d", total, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestListAccountsDateRangeTimezone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	h, _ := newTestAccountsHandler(t)
+	h.config.Reporting.Location = berlin
+	app := fiber.New()
+	app.Get("/api/accounts", h.ListAccounts)
+
+	// 23:30 UTC on Sep 30 is already Oct 1 in Berlin; 22:30 UTC on Oct 7 is Oct 8
+	createTestAccount(t, h, 1, time.Date(2026, 9, 30, 21, 59, 59, 0, time.UTC))
+	createTestAccount(t, h, 2, time.Date(2026, 9, 30, 23, 30, 0, 0, time.UTC))
+	createTestAccount(t, h, 3, time.Date(2026, 10, 7, 22, 30, 0, 0, time.UTC))
+
+	status, body := doRequest(t, app, http.MethodGet, "/api/accounts?created_from=2026-10-01&created_to=2026-10-07", "")
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200 (body %v)", status, body)
+	}
+	data := body["data"].([]interface{})
+	if len(data) != 1 || data[0].(map[string]interface{})["username"] != "user2" {
+		t.Errorf("accounts = %v, want only user2", data)
+	}
+}
+
+func TestListAccountsDateRangeValidation(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Get("/api/accounts", h.ListAccounts)
+
+	for _, query := range []string{
+		"created_from=2026-10-08&created_to=2026-10-07",
+		"created_from=2026-10-07T00:00:01Z&created_to=2026-10-07T00:00:00Z",
+		"created_from=tomorrow",
+		"created_to=2026-02-30",
+	} {
+		status, body := doRequest(t, app, http.MethodGet, "/api/accounts?"+query, "")
+		if status != fiber.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, status)
+		}
+		if msg, _ := body["error"].(string); msg == "" {
+			t.Errorf("%s: response has no error message", query)
+		}
+	}
+}
+
+// listedUsernames returns the usernames in a listing response, in order
+func listedUsernames(t *testing.T, body map[string]interface{}) []string {
+	t.Helper()
+	data, ok := body["data"].([]interface{})
+	if !ok {
+		t.Fatalf("response has no data list: %v", body)
+	}
+	usernames := make([]string, 0, len(data))
+	for _, item := range data {
+		usernames = append(usernames, item.(map[string]interface{})["username"].(string))
+	}
+	return usernames
+}
+
+func TestListAccountsSortOrder(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Get("/api/accounts", h.ListAccounts)
+
+	// user2-user4 share a creation time and user2/user4 a status, so only
+	// the ID tiebreak orders them
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	createdAt := []time.Time{base, base.Add(time.Hour), base.Add(time.Hour), base.Add(time.Hour), base.Add(2 * time.Hour)}
+	for i, at := range createdAt {
+		account := createTestAccount(t, h, i+1, at)
+		if i == 1 || i == 3 {
+			if err := h.db.UpdateAccountStatus(account.ID, models.AccountStatusBanned); err != nil {
+				t.Fatalf("UpdateAccountStatus: %v", err)
+			}
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"user5", "user4", "user3", "user2", "user1"}},
+		{"sort=created_at&order=asc", []string{"user1", "user2", "user3", "user4", "user5"}},
+		{"sort=created_at&order=desc", []string{"user5", "user4", "user3", "user2", "user1"}},
+		{"order=asc", []string{"user1", "user2", "user3", "user4", "user5"}},
+		{"sort=status&order=asc", []string{"user1", "user3", "user5", "user2", "user4"}},
+		{"sort=status&order=DESC", []string{"user4", "user2", "user5", "user3", "user1"}},
+		{"sort=username&order=desc", []string{"user5", "user4", "user3", "user2", "user1"}},
+	}
+	for _, tt := range tests {
+		status, body := doRequest(t, app, http.MethodGet, "/api/accounts?"+tt.query, "")
+		if status != fiber.StatusOK {
+			t.Fatalf("%q: status = %d, want 200 (body %v)", tt.query, status, body)
+		}
+		if got := listedUsernames(t, body); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%q: accounts = %v, want %v", tt.query, got, tt.want)
+		}
+
+		// Paging through the ties visits every account exactly once, in
+		// the same order
+		var paged []string
+		for offset := 0; offset < len(tt.want); offset += 2 {
+			_, page := doRequest(t, app, http.MethodGet, fmt.Sprintf("/api/accounts?limit=2&offset=%d&%s", offset, tt.query), "")
+			paged = append(paged, listedUsernames(t, page)...)
+		}
+		if fmt.Sprint(paged) != fmt.Sprint(tt.want) {
+			t.Errorf("%q: pages = %v, want %v", tt.query, paged, tt.want)
+		}
+	}
+
+	for _, query := range []string{"sort=password", "sort=id;DROP TABLE accounts", "order=up"} {
+		if status, _ := doRequest(t, app, http.MethodGet, "/api/accounts?"+query, ""); status != fiber.StatusBadRequest {
+			t.Errorf("%q: status = %d, want 400", query, status)
+		}
+	}
+}
+
+func TestUpdateAccountRejectsImmutableFields(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Patch("/api/accounts/:id", h.UpdateAccount)
+	app.Put("/api/accounts/:id", h.UpdateAccount)
+
+	account := createTestAccount(t, h, 1, time.Time{})
+	target := fmt.Sprintf("/api/accounts/%d", account.ID)
+
+	tests := []struct {
+		body     string
+		rejected []string
+	}{
+		{`{"id": 999}`, []string{"id"}},
+		{`{"id": 999, "notes": "re-keyed"}`, []string{"id"}},
+		{`{"email": "other@example.com", "job_id": "x", "created_at": "2020-01-01T00:00:00Z"}`, []string{"created_at", "email", "job_id"}},
+		{`{"deleted_at": "2020-01-01T00:00:00Z", "status": "banned"}`, []string{"deleted_at"}},
+	}
+	for _, method := range []string{http.MethodPatch, http.MethodPut} {
+		for _, tt := range tests {
+			status, body := doRequest(t, app, method, target, tt.body)
+			if status != fiber.StatusBadRequest {
+				t.Errorf("%s %s: status = %d, want 400", method, tt.body, status)
+				continue
+			}
+			if got := fmt.Sprint(body["rejected_fields"]); got != fmt.Sprint(tt.rejected) {
+				t.Errorf("%s %s: rejected_fields = %s, want %v", method, tt.body, got, tt.rejected)
+			}
+		}
+	}
+
+	// The row is untouched and nothing was created under the requested ID
+	stored, err := h.db.GetAccount(account.ID)
+	if err != nil {
+		t.Fatalf("GetAccount(%d): %v", account.ID, err)
+	}
+	if stored.Email != account.Email || stored.Status != models.AccountStatusActive || stored.Notes != "" {
+		t.Errorf("account changed by rejected updates: %+v", stored)
+	}
+	if _, err := h.db.GetAccount(999); err == nil {
+		t.Error("account 999 exists after rejected updates")
+	}
+}
+
+func TestUpdateAccountAppliesMutableFields(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Patch("/api/accounts/:id", h.UpdateAccount)
+
+	account := createTestAccount(t, h, 1, time.Time{})
+	target := fmt.Sprintf("/api/accounts/%d", account.ID)
+
+	status, body := doRequest(t, app, http.MethodPatch, target, `{"notes": "checked", "status": "suspended"}`)
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200 (body %v)", status, body)
+	}
+	if id := body["account"].(map[string]interface{})["id"].(float64); uint(id) != account.ID {
+		t.Errorf("response account id = %v, want %d", id, account.ID)
+	}
+
+	stored, err := h.db.GetAccount(account.ID)
+	if err != nil {
+		t.Fatalf("GetAccount: %v", err)
+	}
+	if stored.Notes != "checked" || stored.Status != models.AccountStatusSuspended {
+		t.Errorf("stored notes/status = %q/%q, want checked/suspended", stored.Notes, stored.Status)
+	}
+	if stored.Password != account.Password || stored.Email != account.Email {
+		t.Error("fields absent from the request were changed")
+	}
+
+	status, _ = doRequest(t, app, http.MethodPatch, target, `{"status": "deleted"}`)
+	if status != fiber.StatusBadRequest {
+		t.Errorf("unknown status: status = %d, want 400", status)
+	}
+}
+
+func TestVerifyAccountRejectsDuplicates(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Post("/api/accounts/:id/verify", h.VerifyAccount)
+
+	account := createTestAccount(t, h, 1, time.Time{})
+	target := fmt.Sprintf("/api/accounts/%d/verify", account.ID)
+
+	// A burst of clicks schedules exactly one verification
+	const clicks = 10
+	statuses := make(chan int, clicks)
+	var wg sync.WaitGroup
+	for i := 0; i < clicks; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, _ := doRequest(t, app, http.MethodPost, target, "")
+			statuses <- status
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[fiber.StatusAccepted] != 1 || counts[fiber.StatusConflict] != clicks-1 {
+		t.Errorf("statuses = %v, want one 202 and %d 409", counts, clicks-1)
+	}
+
+	var jobs int64
+	h.db.GetDB().Model(&models.Job{}).Where("account_id = ? AND type = ?", account.ID, models.JobTypeVerify).Count(&jobs)
+	if jobs != 1 {
+		t.Errorf("verify jobs for the account = %d, want 1", jobs)
+	}
+
+	// Once the job finishes a new verification can be scheduled
+	if err := h.db.GetDB().Model(&models.Job{}).Where("account_id = ?", account.ID).
+		Update("status", models.JobStatusCompleted).Error; err != nil {
+		t.Fatalf("completing job: %v", err)
+	}
+	if status, body := doRequest(t, app, http.MethodPost, target, ""); status != fiber.StatusAccepted {
+		t.Errorf("after completion: status = %d, want 202 (body %v)", status, body)
+	}
+}
+
+func TestAccountStatusTransitions(t *testing.T) {
+	statuses := []string{models.AccountStatusActive, models.AccountStatusBanned, models.AccountStatusSuspended}
+	actions := []struct {
+		name    string
+		to      string
+		allowed map[string]bool // current statuses the action applies to
+	}{
+		{"ban", models.AccountStatusBanned, map[string]bool{models.AccountStatusActive: true, models.AccountStatusSuspended: true}},
+		{"unban", models.AccountStatusActive, map[string]bool{models.AccountStatusBanned: true}},
+	}
+
+	for _, action := range actions {
+		for _, from := range statuses {
+			t.Run(action.name+" from "+from, func(t *testing.T) {
+				h, _ := newTestAccountsHandler(t)
+				app := fiber.New()
+				app.Post("/api/accounts/:id/ban", h.BanAccount)
+				app.Post("/api/accounts/:id/unban", h.UnbanAccount)
+
+				account := createTestAccount(t, h, 1, time.Time{})
+				if err := h.db.UpdateAccountStatus(account.ID, from); err != nil {
+					t.Fatalf("UpdateAccountStatus: %v", err)
+				}
+				events := subscribeTest(t, h.queue, services.AccountUpdatesChannel)
+
+				target := fmt.Sprintf("/api/accounts/%d/%s", account.ID, action.name)
+				status, body := doRequest(t, app, http.MethodPost, target, `{"reason": "test"}`)
+
+				stored, err := h.db.GetAccount(account.ID)
+				if err != nil {
+					t.Fatalf("GetAccount: %v", err)
+				}
+				history, err := h.db.GetAccountHistory(account.ID)
+				if err != nil {
+					t.Fatalf("GetAccountHistory: %v", err)
+				}
+
+				if !action.allowed[from] {
+					if status != fiber.StatusConflict {
+						t.Fatalf("status = %d, want 409 (body %v)", status, body)
+					}
+					if body["current_status"] != from {
+						t.Errorf("current_status = %v, want %s", body["current_status"], from)
+					}
+					if stored.Status != from {
+						t.Errorf("stored status = %s, want it unchanged at %s", stored.Status, from)
+					}
+					if len(history) != 0 {
+						t.Errorf("history = %+v, want none for a refused transition", history)
+					}
+					return
+				}
+
+				if status != fiber.StatusOK {
+					t.Fatalf("status = %d, want 200 (body %v)", status, body)
+				}
+				if stored.Status != action.to {
+					t.Errorf("stored status = %s, want %s", stored.Status, action.to)
+				}
+				if got := body["account"].(map[string]interface{})["status"]; got != action.to {
+					t.Errorf("response status = %v, want %s", got, action.to)
+				}
+				if len(history) != 1 || history[0].OldValue != from || history[0].NewValue != action.to || history[0].Reason != "test" {
+					t.Errorf("history = %+v, want one %s -> %s entry with the reason", history, from, action.to)
+				}
+
+				select {
+				case msg := <-events:
+					if !strings.Contains(msg.Payload, `"account_status_changed"`) {
+						t.Errorf("published %s, want an account_status_changed event", msg.Payload)
+					}
+				case <-time.After(time.Second):
+					t.Error("no account_status_changed event published")
+				}
+			})
+		}
+	}
+}
+
+func TestAccountStatusTransitionMissingAccount(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Post("/api/accounts/:id/ban", h.BanAccount)
+
+	if status, _ := doRequest(t, app, http.MethodPost, "/api/accounts/42/ban", ""); status != fiber.StatusNotFound {
+		t.Errorf("status = %d, want 404", status)
+	}
+}
+
+func TestConcurrentBansChangeStatusOnce(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Post("/api/accounts/:id/ban", h.BanAccount)
+
+	account := createTestAccount(t, h, 1, time.Time{})
+	target := fmt.Sprintf("/api/accounts/%d/ban", account.ID)
+
+	const requests = 10
+	statuses := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, _ := doRequest(t, app, http.MethodPost, target, `{"reason": "race"}`)
+			statuses <- status
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[fiber.StatusOK] != 1 || counts[fiber.StatusConflict] != requests-1 {
+		t.Errorf("statuses = %v, want one 200 and %d 409", counts, requests-1)
+	}
+
+	history, err := h.db.GetAccountHistory(account.ID)
+	if err != nil {
+		t.Fatalf("GetAccountHistory: %v", err)
+	}
+	if len(history) != 1 {
+		t.Errorf("history entries = %d, want 1", len(history))
+	}
+}
+
+func TestCredentialsMaskedByDefault(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Get("/api/accounts", h.ListAccounts)
+	app.Get("/api/accounts/by-username/:username", h.GetAccountByUsername)
+	app.Get("/api/accounts/:id", h.GetAccount)
+	app.Get("/api/jobs/:id/accounts", h.GetJobAccounts)
+
+	job := &models.Job{ID: uuid.New().String(), Count: 1, Status: models.JobStatusCompleted}
+	if err := h.db.CreateJob(job); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	account := createTestAccount(t, h, 1, time.Time{})
+	if err := h.db.GetDB().Model(account).Update("job_id", job.ID).Error; err != nil {
+		t.Fatalf("linking account to job: %v", err)
+	}
+
+	// accountIn finds the account in any of the response shapes
+	accountIn := func(body map[string]interface{}) map[string]interface{} {
+		if account, ok := body["account"].(map[string]interface{}); ok {
+			return account
+		}
+		if data, ok := body["data"].([]interface{}); ok && len(data) == 1 {
+			return data[0].(map[string]interface{})
+		}
+		t.Fatalf("response holds no single account: %v", body)
+		return nil
+	}
+
+	targets := []string{
+		"/api/accounts",
+		fmt.Sprintf("/api/accounts/%d", account.ID),
+		fmt.Sprintf("/api/accounts/%d?expand=kick_data", account.ID),
+		"/api/accounts/by-username/user1",
+		"/api/jobs/" + job.ID + "/accounts",
+	}
+	for _, target := range targets {
+		separator := "?"
+		if strings.Contains(target, "?") {
+			separator = "&"
+		}
+		for query, revealed := range map[string]bool{
+			"":                          false,
+			"include_credentials=false": false,
+			"include_credentials=1":     false,
+			"include_credentials=true":  true,
+			"include_credentials=TRUE":  true,
+		} {
+			url := target
+			if query != "" {
+				url += separator + query
+			}
+			status, body := doRequest(t, app, http.MethodGet, url, "")
+			if status != fiber.StatusOK {
+				t.Fatalf("GET %s: status = %d, want 200 (body %v)", url, status, body)
+			}
+
+			got := accountIn(body)
+			wantPassword, wantEmailPassword := "********", "********"
+			if revealed {
+				wantPassword, wantEmailPassword = account.Password, account.EmailPassword
+			}
+			if got["password"] != wantPassword || got["email_password"] != wantEmailPassword {
+				t.Errorf("GET %s: password/email_password = %v/%v, want %s/%s",
+					url, got["password"], got["email_password"], wantPassword, wantEmailPassword)
+			}
+		}
+	}
+}
+
+func TestRedactCredentials(t *testing.T) {
+	result := map[string]interface{}{
+		"accounts_created": float64(2),
+		"accounts": []interface{}{
+			map[string]interface{}{
+				"username":          "user1",
+				"password":          "secret-1",
+				"email_password":    "mail-1",
+				"verification_code": "123456",
+				"Access_Token":      "tok",
+			},
+			map[string]interface{}{
+				"username": "user2",
+				"password": "",
+				"proxy":    map[string]interface{}{"host": "10.0.0.1", "proxy_password": "p"},
+			},
+		},
+		"secret": nil,
+		"errors": []interface{}{"password rejected by Kick"},
+	}
+
+	got := redactCredentials(result).(map[string]interface{})
+	accounts := got["accounts"].([]interface{})
+	first := accounts[0].(map[string]interface{})
+	second := accounts[1].(map[string]interface{})
+
+	for field, value := range map[string]interface{}{
+		"password":          first["password"],
+		"email_password":    first["email_password"],
+		"verification_code": first["verification_code"],
+		"Access_Token":      first["Access_Token"],
+		"proxy_password":    second["proxy"].(map[string]interface{})["proxy_password"],
+	} {
+		if value != "********" {
+			t.Errorf("%s = %v, want it masked", field, value)
+		}
+	}
+
+	// Empty values stay empty, and everything else is untouched
+	if second["password"] != "" || got["secret"] != nil {
+		t.Errorf("empty credentials = %q/%v, want them left empty", second["password"], got["secret"])
+	}
+	if first["username"] != "user1" || got["accounts_created"] != float64(2) ||
+		second["proxy"].(map[string]interface{})["host"] != "10.0.0.1" ||
+		got["errors"].([]interface{})[0] != "password rejected by Kick" {
+		t.Errorf("non-credential fields changed: %v", got)
+	}
+
+	// Non-JSON results pass through
+	if got := redactCredentials("raw result"); got != "raw result" {
+		t.Errorf("redactCredentials(string) = %v", got)
+	}
+}
+
+func TestJobResultMasksNestedCredentials(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Get("/api/jobs/:id/result", h.GetJobResult)
+
+	job := &models.Job{ID: uuid.New().String(), Count: 1, Status: models.JobStatusCompleted}
+	if err := h.db.CreateJob(job); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	if err := h.queue.SaveJobResult(job.ID, map[string]interface{}{
+		"accounts": []map[string]interface{}{{"username": "user1", "password": "secret-1"}},
+	}); err != nil {
+		t.Fatalf("SaveJobResult: %v", err)
+	}
+
+	for query, want := range map[string]string{"": "********", "?include_credentials=true": "secret-1"} {
+		status, body := doRequest(t, app, http.MethodGet, "/api/jobs/"+job.ID+"/result"+query, "")
+		if status != fiber.StatusOK {
+			t.Fatalf("GET result%s: status = %d, want 200 (body %v)", query, status, body)
+		}
+		account := body["result"].(map[string]interface{})["accounts"].([]interface{})[0].(map[string]interface{})
+		if account["password"] != want || account["username"] != "user1" {
+			t.Errorf("GET result%s: account = %v, want password %q", query, account, want)
+		}
+	}
+}
+
+func TestRestoreAccount(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Use(requestid.New())
+	app.Get("/api/accounts", h.ListAccounts)
+	app.Post("/api/accounts/:id/restore", h.RestoreAccount)
+
+	deleted := createTestAccount(t, h, 1, time.Time{})
+	active := createTestAccount(t, h, 2, time.Time{})
+	if err := h.db.DeleteAccount(deleted.ID); err != nil {
+		t.Fatalf("DeleteAccount: %v", err)
+	}
+	events := subscribeTest(t, h.queue, services.AccountUpdatesChannel)
+
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/accounts/%d/restore", deleted.ID), nil)
+	req.Header.Set(fiber.HeaderXRequestID, "restore-request-1")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("restoring: %v", err)
+	}
+	var body map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK || body["success"] != true {
+		t.Fatalf("restoring a deleted account: status = %d, want 200 (body %v)", resp.StatusCode, body)
+	}
+	restored := body["account"].(map[string]interface{})
+	if uint(restored["id"].(float64)) != deleted.ID || restored["password"] != "********" || restored["email_password"] != "********" {
+		t.Errorf("restored account = %v, want account %d with passwords hidden", restored, deleted.ID)
+	}
+
+	// The account is listed again and the restore is traceable to the request
+	_, listing := doRequest(t, app, http.MethodGet, "/api/accounts?sort=username&order=asc", "")
+	if got := listedUsernames(t, listing); fmt.Sprint(got) != "[user1 user2]" {
+		t.Errorf("accounts after restore = %v, want [user1 user2]", got)
+	}
+	history, err := h.db.GetAccountHistory(deleted.ID)
+	if err != nil {
+		t.Fatalf("GetAccountHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].Action != models.AccountActionRestored || history[0].RequestID != "restore-request-1" {
+		t.Errorf("history = %+v, want one restored entry with the request ID", history)
+	}
+	select {
+	case msg := <-events:
+		if !strings.Contains(msg.Payload, `"account_restored"`) {
+			t.Errorf("account event = %s, want account_restored", msg.Payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("no account_restored event published")
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{"already restored", fmt.Sprintf("/api/accounts/%d/restore", deleted.ID), fiber.StatusConflict},
+		{"never deleted", fmt.Sprintf("/api/accounts/%d/restore", active.ID), fiber.StatusConflict},
+		{"unknown ID", "/api/accounts/9999/restore", fiber.StatusNotFound},
+		{"invalid ID", "/api/accounts/abc/restore", fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		status, body := doRequest(t, app, http.MethodPost, tt.target, "")
+		if status != tt.status || body["success"] != false {
+			t.Errorf("%s: status = %d, want %d (body %v)", tt.name, status, tt.status, body)
+		}
+	}
+
+	// Refused restores record nothing
+	if history, _ := h.db.GetAccountHistory(active.ID); len(history) != 0 {
+		t.Errorf("history of the never-deleted account = %+v, want none", history)
+	}
+}
+
+// enqueueHook intercepts the ZADD that puts a job on the queue. before can
+// refuse the nth enqueue (counting from 1) and after runs once it succeeded.
+type enqueueHook struct {
+	n      int
+	before func(n int) error
+	after  func(n int)
+}
+
+func (h *enqueueHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	if !h.isEnqueue(cmd) {
+		return ctx, nil
+	}
+	h.n++
+	if h.before != nil {
+		if err := h.before(h.n); err != nil {
+			return ctx, err
+		}
+	}
+	return ctx, nil
+}
+
+func (h *enqueueHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if h.isEnqueue(cmd) && cmd.Err() == nil && h.after != nil {
+		h.after(h.n)
+	}
+	return nil
+}
+
+func (h *enqueueHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *enqueueHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func (h *enqueueHook) isEnqueue(cmd redis.Cmder) bool {
+	args := cmd.Args()
+	return cmd.Name() == "zadd" && len(args) > 1 && args[1] == services.JobQueueKey
+}
+
+// isQueued reports whether jobID is on the Redis job queue
+func isQueued(t *testing.T, server *miniredis.Miniredis, jobID string) bool {
+	t.Helper()
+	members, err := server.ZMembers(services.JobQueueKey)
+	if err != nil && err != miniredis.ErrKeyNotFound {
+		t.Fatalf("ZMembers: %v", err)
+	}
+	for _, member := range members {
+		if member == jobID {
+			return true
+		}
+	}
+	return false
+}
+
+// generateAccounts posts body to the generate endpoint and decodes the reply
+func generateAccounts(t *testing.T, app *fiber.App, body string) (int, GenerateAccountsResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/accounts/generate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("POST /api/accounts/generate: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var decoded GenerateAccountsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("decoding generate response: %v", err)
+	}
+	return resp.StatusCode, decoded
+}
+
+func TestGenerateAccountsModes(t *testing.T) {
+	h, server := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Post("/api/accounts/generate", h.GenerateAccounts)
+
+	tests := []struct {
+		body  string
+		mode  string
+		jobs  int
+		count int // accounts per job
+	}{
+		{`{"count":3}`, GenerateModePerAccount, 3, 1},
+		{`{"count":3,"mode":"per_account"}`, GenerateModePerAccount, 3, 1},
+		{`{"count":4,"mode":"BATCH"}`, GenerateModeBatch, 1, 4},
+	}
+	for _, tt := range tests {
+		status, resp := generateAccounts(t, app, tt.body)
+		if status != fiber.StatusCreated || !resp.Success || resp.Mode != tt.mode || len(resp.JobIDs) != tt.jobs {
+			t.Errorf("%s: %d %+v, want 201 with %d %s job(s)", tt.body, status, resp, tt.jobs, tt.mode)
+			continue
+		}
+		if tt.mode == GenerateModeBatch && resp.JobID != resp.JobIDs[0] {
+			t.Errorf("%s: job_id = %q, want the only job %q", tt.body, resp.JobID, resp.JobIDs[0])
+		}
+		if tt.mode == GenerateModePerAccount && resp.JobID != "" {
+			t.Errorf("%s: job_id = %q, want it unset outside batch mode", tt.body, resp.JobID)
+		}
+		for _, id := range resp.JobIDs {
+			job, err := h.db.GetJob(id)
+			if err != nil {
+				t.Fatalf("GetJob(%s): %v", id, err)
+			}
+			if job.Type != models.JobTypeGenerate || job.Count != tt.count || job.Status != models.JobStatusPending {
+				t.Errorf("%s: job %s = %s count=%d %s, want a pending generate job of %d",
+					tt.body, id, job.Type, job.Count, job.Status, tt.count)
+			}
+			if !isQueued(t, server, id) {
+				t.Errorf("%s: job %s is not queued", tt.body, id)
+			}
+		}
+	}
+
+	if status, resp := generateAccounts(t, app, `{"count":2,"mode":"bulk"}`); status != fiber.StatusBadRequest || resp.Success {
+		t.Errorf("unknown mode: %d %+v, want 400", status, resp)
+	}
+}
+
+func TestGenerateAccountsPartialQueueFailure(t *testing.T) {
+	h, server := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Post("/api/accounts/generate", h.GenerateAccounts)
+
+	hook := &enqueueHook{}
+	h.queue.GetRedisClient().AddHook(hook)
+
+	// One of three jobs failing is within maxGenerateFailureRatio
+	hook.n = 0
+	hook.before = func(n int) error {
+		if n == 2 {
+			return fmt.Errorf("queue full")
+		}
+		return nil
+	}
+	status, resp := generateAccounts(t, app, `{"count":3}`)
+	if status != fiber.StatusMultiStatus || !resp.Success || resp.Requested != 3 || resp.Created != 2 || len(resp.JobIDs) != 2 {
+		t.Fatalf("one failed enqueue: %d %+v, want 207, success, 2 of 3 created", status, resp)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Index != 1 || !strings.Contains(resp.Errors[0].Error, "queue full") {
+		t.Fatalf("errors = %+v, want index 1 reporting the queue error", resp.Errors)
+	}
+	failed, err := h.db.GetJob(resp.Errors[0].JobID)
+	if err != nil {
+		t.Fatalf("GetJob(%s): %v", resp.Errors[0].JobID, err)
+	}
+	if failed.Status != models.JobStatusFailed || !strings.Contains(failed.ErrorMsg, "queue full") {
+		t.Errorf("failed job = %s %q, want failed with the queue error", failed.Status, failed.ErrorMsg)
+	}
+	if isQueued(t, server, failed.ID) {
+		t.Errorf("failed job %s is on the queue", failed.ID)
+	}
+	for _, id := range resp.JobIDs {
+		if job, err := h.db.GetJob(id); err != nil || job.Status != models.JobStatusPending {
+			t.Errorf("queued job %s = %v %v, want pending", id, job, err)
+		}
+	}
+
+	// Most jobs failing still returns 207 but reports the request as failed
+	hook.n = 0
+	hook.before = func(n int) error {
+		if n > 1 {
+			return fmt.Errorf("queue full")
+		}
+		return nil
+	}
+	status, resp = generateAccounts(t, app, `{"count":3}`)
+	if status != fiber.StatusMultiStatus || resp.Success || resp.Created != 1 || len(resp.Errors) != 2 {
+		t.Errorf("two failed enqueues: %d %+v, want 207, unsuccessful, 1 of 3 created", status, resp)
+	}
+
+	// A batch job that cannot be queued leaves nothing to report on
+	hook.n = 0
+	hook.before = func(int) error { return fmt.Errorf("queue full") }
+	status, resp = generateAccounts(t, app, `{"count":5,"mode":"batch"}`)
+	if status != fiber.StatusServiceUnavailable || resp.Success || resp.Created != 0 || len(resp.JobIDs) != 0 || len(resp.Errors) != 1 {
+		t.Errorf("failed batch enqueue: %d %+v, want 503 with one error", status, resp)
+	}
+}
+
+func TestBulkUpdateStatusMixedOutcomes(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Post("/api/accounts/bulk-status", h.BulkUpdateStatus)
+
+	first := createTestAccount(t, h, 1, time.Time{})
+	second := createTestAccount(t, h, 2, time.Time{})
+	untouched := createTestAccount(t, h, 3, time.Time{})
+	gone := createTestAccount(t, h, 4, time.Time{})
+	if err := h.db.DeleteAccount(gone.ID); err != nil {
+		t.Fatalf("DeleteAccount: %v", err)
+	}
+
+	// Duplicates are updated once; unknown and soft-deleted IDs are not found
+	body := fmt.Sprintf(`{"ids":[%d,%d,%d,9999,%d,9999],"status":"banned","reason":"spam"}`,
+		first.ID, second.ID, first.ID, gone.ID)
+	status, resp := doRequest(t, app, http.MethodPost, "/api/accounts/bulk-status", body)
+	if status != fiber.StatusOK || resp["success"] != true || resp["affected"] != float64(2) {
+		t.Fatalf("bulk status: %d %v, want 200 with 2 affected", status, resp)
+	}
+	if got := fmt.Sprint(resp["not_found"]); got != fmt.Sprintf("[9999 %d]", gone.ID) {
+		t.Errorf("not_found = %s, want [9999 %d]", got, gone.ID)
+	}
+
+	for _, tt := range []struct {
+		account *models.Account
+		status  string
+		history int
+	}{
+		{first, models.AccountStatusBanned, 1},
+		{second, models.AccountStatusBanned, 1},
+		{untouched, models.AccountStatusActive, 0},
+	} {
+		account, err := h.db.GetAccount(tt.account.ID)
+		if err != nil {
+			t.Fatalf("GetAccount(%d): %v", tt.account.ID, err)
+		}
+		history, _ := h.db.GetAccountHistory(tt.account.ID)
+		if account.Status != tt.status || len(history) != tt.history {
+			t.Errorf("account %d = %s with %d history entries, want %s with %d",
+				tt.account.ID, account.Status, len(history), tt.status, tt.history)
+		}
+	}
+
+	// Nothing found updates nothing
+	status, resp = doRequest(t, app, http.MethodPost, "/api/accounts/bulk-status", `{"ids":[9998,9999],"status":"active"}`)
+	if status != fiber.StatusOK || resp["affected"] != float64(0) || fmt.Sprint(resp["not_found"]) != "[9998 9999]" {
+		t.Errorf("only unknown IDs: %d %v, want 200 with nothing affected", status, resp)
+	}
+}
+
+func TestBulkDeleteAccountsMixedOutcomes(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Delete("/api/accounts", h.BulkDeleteAccounts)
+
+	active := createTestAccount(t, h, 1, time.Time{})
+	deleted := createTestAccount(t, h, 2, time.Time{})
+	kept := createTestAccount(t, h, 3, time.Time{})
+	if err := h.db.DeleteAccount(deleted.ID); err != nil {
+		t.Fatalf("DeleteAccount: %v", err)
+	}
+	events := subscribeTest(t, h.queue, services.AccountUpdatesChannel)
+
+	body := fmt.Sprintf(`{"ids":[%d,%d,9999,%d],"confirm":true}`, active.ID, deleted.ID, active.ID)
+	status, resp := doRequest(t, app, http.MethodDelete, "/api/accounts", body)
+	if status != fiber.StatusOK || resp["success"] != true ||
+		resp["deleted"] != float64(1) || resp["already_deleted"] != float64(1) || resp["not_found"] != float64(1) {
+		t.Fatalf("bulk delete: %d %v, want 200 with 1 deleted, 1 already deleted, 1 not found", status, resp)
+	}
+	ids := resp["ids"].(map[string]interface{})
+	want := map[string]string{
+		"deleted":         fmt.Sprintf("[%d]", active.ID),
+		"already_deleted": fmt.Sprintf("[%d]", deleted.ID),
+		"not_found":       "[9999]",
+	}
+	for key, ids := range ids {
+		if fmt.Sprint(ids) != want[key] {
+			t.Errorf("ids.%s = %v, want %s", key, ids, want[key])
+		}
+	}
+
+	if _, err := h.db.GetAccount(active.ID); err == nil {
+		t.Errorf("account %d is still visible after bulk delete", active.ID)
+	}
+	if _, err := h.db.GetAccount(kept.ID); err != nil {
+		t.Errorf("account %d outside the request was deleted: %v", kept.ID, err)
+	}
+	select {
+	case msg := <-events:
+		if !strings.Contains(msg.Payload, `"accounts_deleted"`) || !strings.Contains(msg.Payload, `"deleted":1`) {
+			t.Errorf("account event = %s, want accounts_deleted for 1 account", msg.Payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("no accounts_deleted event published")
+	}
+
+	// Repeating the request deletes nothing and announces nothing
+	status, resp = doRequest(t, app, http.MethodDelete, "/api/accounts", body)
+	if status != fiber.StatusOK || resp["deleted"] != float64(0) || resp["already_deleted"] != float64(2) {
+		t.Errorf("repeated bulk delete: %d %v, want 0 deleted and 2 already deleted", status, resp)
+	}
+	select {
+	case msg := <-events:
+		t.Errorf("repeated bulk delete published %s", msg.Payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	for _, tt := range []struct{ name, body string }{
+		{"unconfirmed", fmt.Sprintf(`{"ids":[%d]}`, kept.ID)},
+		{"no selection", `{"confirm":true}`},
+		{"ids and job_id", fmt.Sprintf(`{"ids":[%d],"job_id":"job-1","confirm":true}`, kept.ID)},
+	} {
+		if status, resp := doRequest(t, app, http.MethodDelete, "/api/accounts", tt.body); status != fiber.StatusBadRequest {
+			t.Errorf("%s: %d %v, want 400", tt.name, status, resp)
+		}
+	}
+	if _, err := h.db.GetAccount(kept.ID); err != nil {
+		t.Errorf("refused bulk deletes removed account %d: %v", kept.ID, err)
+	}
+}
+
+func TestListAccountsFiltersAndPagination(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Get("/api/accounts", h.ListAccounts)
+
+	// Five accounts from job-a (one banned, one deleted) and two from job-b
+	var accounts []*models.Account
+	for i := 1; i <= 7; i++ {
+		account := createTestAccount(t, h, i, time.Time{})
+		account.JobID = "job-a"
+		if i > 5 {
+			account.JobID = "job-b"
+		}
+		if i == 2 || i == 6 {
+			account.Status = models.AccountStatusBanned
+		}
+		if err := h.db.UpdateAccount(account); err != nil {
+			t.Fatalf("UpdateAccount: %v", err)
+		}
+		accounts = append(accounts, account)
+	}
+	if err := h.db.DeleteAccount(accounts[2].ID); err != nil {
+		t.Fatalf("DeleteAccount: %v", err)
+	}
+
+	tests := []struct {
+		query     string
+		usernames string
+		total     float64
+		hasMore   bool
+	}{
+		{"", "[user1 user2 user4 user5 user6 user7]", 6, false},
+		{"limit=4", "[user1 user2 user4 user5]", 6, true},
+		{"limit=4&offset=4", "[user6 user7]", 6, false},
+		{"status=banned", "[user2 user6]", 2, false},
+		{"status=ACTIVE", "[user1 user4 user5 user7]", 4, false},
+		{"job_id=job-a", "[user1 user2 user4 user5]", 4, false},
+		{"job_id=job-a&limit=3", "[user1 user2 user4]", 4, true},
+		{"job_id=job-a&limit=3&offset=3", "[user5]", 4, false},
+		{"job_id=job-a&status=banned", "[user2]", 1, false},
+		{"job_id=job-c", "[]", 0, false},
+		{"include_deleted=true", "[user1 user2 user3 user4 user5 user6 user7]", 7, false},
+		{"include_deleted=true&limit=6", "[user1 user2 user3 user4 user5 user6]", 7, true},
+		{"include_deleted=true&job_id=job-a&status=active", "[user1 user3 user4 user5]", 4, false},
+	}
+	for _, tt := range tests {
+		status, body := doRequest(t, app, http.MethodGet, "/api/accounts?sort=username&order=asc&"+tt.query, "")
+		if status != fiber.StatusOK {
+			t.Errorf("%s: status = %d, want 200 (body %v)", tt.query, status, body)
+			continue
+		}
+		if got := fmt.Sprint(listedUsernames(t, body)); got != tt.usernames {
+			t.Errorf("%s: accounts = %s, want %s", tt.query, got, tt.usernames)
+		}
+		pagination := body["pagination"].(map[string]interface{})
+		if pagination["total"] != tt.total || pagination["has_more"] != tt.hasMore {
+			t.Errorf("%s: total = %v, has_more = %v, want %v and %v",
+				tt.query, pagination["total"], pagination["has_more"], tt.total, tt.hasMore)
+		}
+	}
+
+	if status, _ := doRequest(t, app, http.MethodGet, "/api/accounts?status=deleted", ""); status != fiber.StatusBadRequest {
+		t.Errorf("unknown status filter: status = %d, want 400", status)
+	}
+}
+
+func TestDownloadAccount(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Use(requestid.New())
+	app.Get("/api/accounts/:id/download", h.DownloadAccount)
+
+	account := createTestAccount(t, h, 1, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	deleted := createTestAccount(t, h, 2, time.Time{})
+	if err := h.db.DeleteAccount(deleted.ID); err != nil {
+		t.Fatalf("DeleteAccount: %v", err)
+	}
+
+	download := func(query string) (*http.Response, string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/accounts/%d/download%s", account.ID, query), nil)
+		req.Header.Set(fiber.HeaderXRequestID, "download"+query)
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("downloading: %v", err)
+		}
+		defer resp.Body.Close()
+		raw, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("reading download: %v", err)
+		}
+		return resp, string(raw)
+	}
+
+	tests := []struct {
+		query, filename, contentType string
+	}{
+		{"", "account-1-user1.txt", fiber.MIMETextPlainCharsetUTF8},
+		{"?format=TXT", "account-1-user1.txt", fiber.MIMETextPlainCharsetUTF8},
+		{"?format=json", "account-1-user1.json", fiber.MIMEApplicationJSON},
+	}
+	for _, tt := range tests {
+		resp, body := download(tt.query)
+		if resp.StatusCode != fiber.StatusOK {
+			t.Errorf("%q: status = %d, want 200 (body %s)", tt.query, resp.StatusCode, body)
+			continue
+		}
+		if got, want := resp.Header.Get(fiber.HeaderContentDisposition), `attachment; filename="`+tt.filename+`"`; got != want {
+			t.Errorf("%q: Content-Disposition = %q, want %q", tt.query, got, want)
+		}
+		if got := resp.Header.Get(fiber.HeaderContentType); got != tt.contentType {
+			t.Errorf("%q: Content-Type = %q, want %q", tt.query, got, tt.contentType)
+		}
+
+		if tt.contentType == fiber.MIMEApplicationJSON {
+			var credentials map[string]string
+			if err := json.Unmarshal([]byte(body), &credentials); err != nil {
+				t.Fatalf("%q: decoding %s: %v", tt.query, body, err)
+			}
+			if credentials["password"] != "password" || credentials["email_password"] != "email-password" ||
+				credentials["created_at"] != "2024-03-01T12:00:00Z" {
+				t.Errorf("%q: credentials = %v, want the raw passwords and creation time", tt.query, credentials)
+			}
+			continue
+		}
+		for _, line := range []string{"email: user1@example.com\n", "email_password: email-password\n", "password: password\n", "created_at: 2024-03-01T12:00:00Z\n"} {
+			if !strings.Contains(body, line) {
+				t.Errorf("%q: body %q is missing %q", tt.query, body, line)
+			}
+		}
+	}
+
+	// Each download is audited with its format and request
+	history, err := h.db.GetAccountHistory(account.ID)
+	if err != nil {
+		t.Fatalf("GetAccountHistory: %v", err)
+	}
+	if len(history) != len(tests) {
+		t.Fatalf("history = %+v, want %d download entries", history, len(tests))
+	}
+	for _, entry := range history {
+		if entry.Action != models.AccountActionCredentialsDownloaded || !strings.HasPrefix(entry.RequestID, "download") {
+			t.Errorf("history entry = %+v, want a credentials download with its request ID", entry)
+		}
+	}
+
+	refused := []struct {
+		target string
+		status int
+	}{
+		{fmt.Sprintf("/api/accounts/%d/download", deleted.ID), fiber.StatusNotFound},
+		{"/api/accounts/9999/download?format=json", fiber.StatusNotFound},
+		{fmt.Sprintf("/api/accounts/%d/download?format=csv", account.ID), fiber.StatusBadRequest},
+		{"/api/accounts/abc/download", fiber.StatusBadRequest},
+	}
+	for _, tt := range refused {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("GET %s: %v", tt.target, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || resp.Header.Get(fiber.HeaderContentDisposition) != "" {
+			t.Errorf("GET %s: status = %d, Content-Disposition = %q, want %d and no attachment",
+				tt.target, resp.StatusCode, resp.Header.Get(fiber.HeaderContentDisposition), tt.status)
+		}
+	}
+	if history, _ := h.db.GetAccountHistory(deleted.ID); len(history) != 0 {
+		t.Errorf("history of the deleted account = %+v, want no download recorded", history)
+	}
+}
+
+func TestUpdateAccountNotesHistory(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Use(requestid.New())
+	app.Put("/api/accounts/:id/notes", h.UpdateAccountNotes)
+
+	account := createTestAccount(t, h, 1, time.Time{})
+	target := fmt.Sprintf("/api/accounts/%d/notes", account.ID)
+
+	// Unchanged notes add no entry; clearing them does
+	for _, notes := range []string{"first", "second", "second", ""} {
+		status, body := doRequest(t, app, http.MethodPut, target, fmt.Sprintf(`{"notes":%q}`, notes))
+		if status != fiber.StatusOK || body["success"] != true {
+			t.Fatalf("setting notes to %q: status = %d, want 200 (body %v)", notes, status, body)
+		}
+		saved := body["account"].(map[string]interface{})
+		if got, _ := saved["notes"].(string); got != notes || saved["password"] != "********" {
+			t.Errorf("account after setting %q = %v, want those notes and the password hidden", notes, saved)
+		}
+	}
+
+	history, err := h.db.GetAccountHistory(account.ID)
+	if err != nil {
+		t.Fatalf("GetAccountHistory: %v", err)
+	}
+	want := [][2]string{{"second", ""}, {"first", "second"}, {"", "first"}}
+	if len(history) != len(want) {
+		t.Fatalf("history = %+v, want %d note changes", history, len(want))
+	}
+	for i, entry := range history {
+		if entry.Action != models.AccountActionNoteChanged || entry.OldValue != want[i][0] || entry.NewValue != want[i][1] || entry.RequestID == "" {
+			t.Errorf("history[%d] = %s %q -> %q (request %q), want note_changed %q -> %q with a request ID",
+				i, entry.Action, entry.OldValue, entry.NewValue, entry.RequestID, want[i][0], want[i][1])
+		}
+	}
+
+	refused := []struct {
+		target, body string
+		status       int
+	}{
+		{target, `{}`, fiber.StatusBadRequest},
+		{target, fmt.Sprintf(`{"notes":%q}`, strings.Repeat("x", models.MaxNotesLength+1)), fiber.StatusBadRequest},
+		{"/api/accounts/9999/notes", `{"notes":"x"}`, fiber.StatusNotFound},
+		{"/api/accounts/abc/notes", `{"notes":"x"}`, fiber.StatusBadRequest},
+	}
+	for _, tt := range refused {
+		if status, body := doRequest(t, app, http.MethodPut, tt.target, tt.body); status != tt.status {
+			t.Errorf("PUT %s %.20s: status = %d, want %d (body %v)", tt.target, tt.body, status, tt.status, body)
+		}
+	}
+	if history, _ := h.db.GetAccountHistory(account.ID); len(history) != len(want) {
+		t.Errorf("refused updates changed the history to %d entries", len(history))
+	}
+}
+
+func TestGenerateAccountsQueueOutageMidRequest(t *testing.T) {
+	h, server := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Post("/api/accounts/generate", h.GenerateAccounts)
+
+	// Redis goes away right after the first job is queued
+	h.queue.GetRedisClient().AddHook(&enqueueHook{after: func(n int) {
+		if n == 1 {
+			server.Close()
+		}
+	}})
+
+	status, resp := generateAccounts(t, app, `{"count":3}`)
+	if status != fiber.StatusMultiStatus || resp.Success || resp.Requested != 3 || resp.Created != 1 || len(resp.JobIDs) != 1 {
+		t.Fatalf("outage after the first job: %d %+v, want 207, unsuccessful, 1 of 3 created", status, resp)
+	}
+	if len(resp.Errors) != 2 || resp.Errors[0].Index != 1 || resp.Errors[1].Index != 2 {
+		t.Fatalf("errors = %+v, want the second and third jobs", resp.Errors)
+	}
+
+	// The first job stays queued; the rest are failed rather than left pending
+	if job, err := h.db.GetJob(resp.JobIDs[0]); err != nil || job.Status != models.JobStatusPending {
+		t.Errorf("queued job = %v %v, want pending", job, err)
+	}
+	if !isQueued(t, server, resp.JobIDs[0]) {
+		t.Errorf("job %s queued before the outage is missing from the queue", resp.JobIDs[0])
+	}
+	for _, jobErr := range resp.Errors {
+		job, err := h.db.GetJob(jobErr.JobID)
+		if err != nil {
+			t.Fatalf("GetJob(%s): %v", jobErr.JobID, err)
+		}
+		if job.Status != models.JobStatusFailed || !strings.HasPrefix(job.ErrorMsg, "failed to enqueue") || job.CompletedAt == nil {
+			t.Errorf("job %s = %s %q, want failed with the enqueue error", job.ID, job.Status, job.ErrorMsg)
+		}
+		if isQueued(t, server, job.ID) {
+			t.Errorf("job %s that failed to enqueue is on the queue", job.ID)
+		}
+	}
+	if total, err := h.db.CountJobs(); err != nil || total != 3 {
+		t.Errorf("CountJobs = %d %v, want every requested job recorded", total, err)
+	}
+}
+
+func TestMalformedStoredKickData(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Get("/api/accounts/:id", h.GetAccount)
+	app.Get("/api/accounts/:id/kick-data", h.GetKickData)
+	app.Put("/api/accounts/:id/kick-data", h.SetKickData)
+
+	// Rows written before kick_data was validated may hold anything
+	malformed := createTestAccount(t, h, 1, time.Time{})
+	malformed.KickData = `{"channel": "user1",`
+	if err := h.db.UpdateAccount(malformed); err != nil {
+		t.Fatalf("UpdateAccount: %v", err)
+	}
+	valid := createTestAccount(t, h, 2, time.Time{})
+	valid.KickData = `{"channel":"user2","followers":3}`
+	if err := h.db.UpdateAccount(valid); err != nil {
+		t.Fatalf("UpdateAccount: %v", err)
+	}
+	empty := createTestAccount(t, h, 3, time.Time{})
+
+	status, body := doRequest(t, app, http.MethodGet, fmt.Sprintf("/api/accounts/%d/kick-data", malformed.ID), "")
+	if status != fiber.StatusBadRequest || body["success"] != false || body["error"] != "Stored kick_data is not valid JSON" {
+		t.Errorf("malformed kick-data: %d %v, want a 400 naming the stored data", status, body)
+	}
+
+	status, body = doRequest(t, app, http.MethodGet, fmt.Sprintf("/api/accounts/%d?expand=kick_data", malformed.ID), "")
+	if status != fiber.StatusOK || body["success"] != true || body["account"] == nil {
+		t.Fatalf("expanding malformed kick_data: %d %v, want the account with 200", status, body)
+	}
+	if _, ok := body["kick_data"]; ok || !strings.Contains(fmt.Sprint(body["kick_data_error"]), "malformed") {
+		t.Errorf("expanding malformed kick_data: kick_data = %v, kick_data_error = %v, want only the error",
+			body["kick_data"], body["kick_data_error"])
+	}
+
+	for _, tt := range []struct {
+		account *models.Account
+		want    string
+	}{
+		{valid, "map[channel:user2 followers:3]"},
+		{empty, "<nil>"},
+	} {
+		status, body := doRequest(t, app, http.MethodGet, fmt.Sprintf("/api/accounts/%d/kick-data", tt.account.ID), "")
+		if status != fiber.StatusOK || fmt.Sprint(body["data"]) != tt.want {
+			t.Errorf("kick-data of account %d: %d %v, want 200 with %s", tt.account.ID, status, body, tt.want)
+		}
+		status, body = doRequest(t, app, http.MethodGet, fmt.Sprintf("/api/accounts/%d?expand=kick_data", tt.account.ID), "")
+		if status != fiber.StatusOK || fmt.Sprint(body["kick_data"]) != tt.want || body["kick_data_error"] != nil {
+			t.Errorf("expanded account %d: %d %v, want kick_data %s", tt.account.ID, status, body, tt.want)
+		}
+	}
+
+	// Replacing the malformed document repairs the account
+	target := fmt.Sprintf("/api/accounts/%d/kick-data", malformed.ID)
+	if status, body := doRequest(t, app, http.MethodPut, target, `{"channel": "user1"}`); status != fiber.StatusOK {
+		t.Fatalf("replacing malformed kick_data: %d %v, want 200", status, body)
+	}
+	if status, body := doRequest(t, app, http.MethodGet, target, ""); status != fiber.StatusOK || fmt.Sprint(body["data"]) != "map[channel:user1]" {
+		t.Errorf("kick-data after repair: %d %v, want the new document", status, body)
+	}
+}
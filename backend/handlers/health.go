@@ -1,39 +1,432 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
 	"time"
 
+	"botrix-backend/config"
+	"botrix-backend/services"
+	"botrix-backend/utils"
+	"botrix-backend/version"
+
 	"github.com/gofiber/fiber/v2"
 )
 
-// HealthHandler handles health check requests
-type HealthHandler struct{}
+// healthCheckTimeout bounds each dependency probe so a hung connection
+// cannot stall the health endpoint past the probe's own timeout. Checks
+// that ignore their context are abandoned once it expires.
+const healthCheckTimeout = 2 * time.Second
+
+// Health check components
+const (
+	healthComponentDatabase = "database"
+	healthComponentRedis    = "redis"
+	healthComponentQueue    = "queue"
+)
+
+// Health statuses. Degraded responses are still 200 so load balancers keep
+// routing; only unhealthy ones are 503.
+const (
+	HealthStatusHealthy   = "healthy"
+	HealthStatusDegraded  = "degraded"
+	HealthStatusUnhealthy = "unhealthy"
+
+	ServiceStatusUp       = "up"
+	ServiceStatusDegraded = "degraded"
+	ServiceStatusDown     = "down"
+)
 
-// NewHealthHandler creates a new health handler
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+// HealthHandler handles health check requests.
+//
+// The database and Redis are critical: if either is down the service is
+// unhealthy. The job queue is non-critical, since the API keeps serving
+// while it is backed up; a failing queue check, a queue deeper than the
+// configured threshold, or a dependency ping slower than the latency
+// threshold makes the service degraded.
+type HealthHandler struct {
+	db         *services.Database
+	queue      *services.QueueService
+	thresholds config.HealthConfig
+	readiness  *utils.ReadinessTracker
+	ws         *WebSocketHandler
+	started    time.Time
+	checks     *healthCache
+
+	// lastSuccess holds when each component last passed a check
+	mu          sync.Mutex
+	lastSuccess map[string]time.Time
 }
 
-// HealthResponse represents the health check response
+// NewHealthHandler creates a new health handler probing db and queue.
+// Check results are cached for cfg.Health.CacheTTL. Readiness also waits
+// for every startup gate in readiness. Details also reports the state of
+// ws's Redis subscription. Uptime is measured from its creation.
+func NewHealthHandler(db *services.Database, queue *services.QueueService, cfg *config.Config, readiness *utils.ReadinessTracker, ws *WebSocketHandler) *HealthHandler {
+	h := &HealthHandler{
+		db:          db,
+		queue:       queue,
+		thresholds:  cfg.Health,
+		readiness:   readiness,
+		ws:          ws,
+		started:     time.Now(),
+		lastSuccess: make(map[string]time.Time),
+	}
+	h.checks = newHealthCache(cfg.Health.CacheTTL, h.probeAll)
+	return h
+}
+
+// HealthResponse represents the health check response. CacheAgeSeconds is
+// how old the dependency checks are.
 type HealthResponse struct {
-	Status   string                 `json:"status"`
-	Services map[string]interface{} `json:"services"`
-	Version  string                 `json:"version"`
+	Status          string                 `json:"status"`
+	Services        map[string]interface{} `json:"services"`
+	Version         string                 `json:"version"`
+	Commit          string                 `json:"commit"`
+	BuildTime       string                 `json:"build_time"`
+	CacheAgeSeconds float64                `json:"cache_age_seconds"`
+}
+
+// ServiceHealth is the result of probing one dependency. Warning explains
+// a degraded status. LastSuccessAt is only reported by /health/details.
+type ServiceHealth struct {
+	Status        string     `json:"status"`
+	LatencyMS     int64      `json:"latency_ms"`
+	Warning       string     `json:"warning,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+}
+
+// QueueHealth is the result of reading the job queue depth
+type QueueHealth struct {
+	ServiceHealth
+	Pending    int64 `json:"pending"`
+	Processing int64 `json:"processing"`
 }
 
-// Check handles GET /health
+// Check handles GET /health. It is 503 when a critical dependency is down
+// and 200 otherwise, with the status telling healthy and degraded apart.
 func (h *HealthHandler) Check(c *fiber.Ctx) error {
+	snapshot := h.checks.Get(false)
+
+	build := version.Get()
 	response := HealthResponse{
-		Status:  "healthy",
-		Version: "1.0.0",
+		Status:          snapshot.status(),
+		Version:         build.Version,
+		Commit:          build.Commit,
+		BuildTime:       build.BuildTime,
+		CacheAgeSeconds: time.Since(snapshot.CheckedAt).Seconds(),
 		Services: map[string]interface{}{
 			"api":      "operational",
-			"database": "connected",
-			"redis":    "connected",
+			"database": snapshot.Database,
+			"redis":    snapshot.Redis,
+			"queue":    snapshot.Queue,
 		},
 	}
 
-	return c.JSON(response)
+	return c.Status(healthStatusCode(response.Status)).JSON(response)
+}
+
+// overallHealth combines component results: any critical component down
+// is unhealthy; any other component down, or any component degraded, is
+// degraded
+func overallHealth(critical, nonCritical []ServiceHealth) string {
+	status := HealthStatusHealthy
+	for _, result := range critical {
+		switch result.Status {
+		case ServiceStatusDown:
+			return HealthStatusUnhealthy
+		case ServiceStatusDegraded:
+			status = HealthStatusDegraded
+		}
+	}
+	for _, result := range nonCritical {
+		if result.Status != ServiceStatusUp {
+			status = HealthStatusDegraded
+		}
+	}
+	return status
+}
+
+// status is the overall health of a snapshot. The database and Redis are
+// critical; the queue is not.
+func (s *healthSnapshot) status() string {
+	return overallHealth(
+		[]ServiceHealth{s.Database, s.Redis},
+		[]ServiceHealth{s.Queue.ServiceHealth},
+	)
+}
+
+// healthStatusCode maps an overall health status to the HTTP status
+func healthStatusCode(status string) int {
+	if status == HealthStatusUnhealthy {
+		return fiber.StatusServiceUnavailable
+	}
+	return fiber.StatusOK
+}
+
+// HealthDetailsResponse is the verbose health report for triage. Sizes are
+// in bytes and durations in seconds.
+type HealthDetailsResponse struct {
+	Status        string               `json:"status"`
+	Version       string               `json:"version"`
+	Commit        string               `json:"commit"`
+	BuildTime     string               `json:"build_time"`
+	StartedAt     time.Time            `json:"started_at"`
+	UptimeSeconds float64              `json:"uptime_seconds"`
+	CacheAgeSecs  float64              `json:"cache_age_seconds"`
+	Runtime       RuntimeDetails       `json:"runtime"`
+	Latencies     HealthLatencies      `json:"latencies"`
+	Database      DatabaseHealthDetail `json:"database"`
+	Redis         RedisHealthDetail    `json:"redis"`
+	Queue         QueueHealth          `json:"queue"`
+}
+
+// RuntimeDetails describes the Go runtime of the API process
+type RuntimeDetails struct {
+	GoVersion           string  `json:"go_version"`
+	Goroutines          int     `json:"goroutines"`
+	HeapInUseBytes      uint64  `json:"heap_in_use_bytes"`
+	HeapAllocBytes      uint64  `json:"heap_alloc_bytes"`
+	SysBytes            uint64  `json:"sys_bytes"`
+	GCCycles            uint32  `json:"gc_cycles"`
+	GCPauseTotalSeconds float64 `json:"gc_pause_total_seconds"`
+	GCLastPauseSeconds  float64 `json:"gc_last_pause_seconds"`
+}
+
+// HealthLatencies lists how long each dependency check took, so a slow
+// backend stands out at a glance
+type HealthLatencies struct {
+	DBPingMS     int64 `json:"db_ping_ms"`
+	RedisPingMS  int64 `json:"redis_ping_ms"`
+	QueueStatsMS int64 `json:"queue_stats_ms"`
+}
+
+// DatabaseHealthDetail is the database probe plus its connection pool
+type DatabaseHealthDetail struct {
+	ServiceHealth
+	OpenConnections     int     `json:"open_connections"`
+	InUse               int     `json:"in_use"`
+	Idle                int     `json:"idle"`
+	WaitCount           int64   `json:"wait_count"`
+	WaitSeconds         float64 `json:"wait_seconds"`
+	MaxOpenConns        int     `json:"max_open_connections"`
+	MigrationsCompleted bool    `json:"migrations_completed"`
+	PoolStatsError      string  `json:"pool_stats_error,omitempty"`
+}
+
+// RedisHealthDetail is the Redis probe plus its client connection pool and
+// whether the pub/sub subscription relaying job updates is up
+type RedisHealthDetail struct {
+	ServiceHealth
+	SubscriptionHealthy bool `json:"redis_subscription_healthy"`
+
+	TotalConnections uint32 `json:"total_connections"`
+	IdleConnections  uint32 `json:"idle_connections"`
+	StaleConnections uint32 `json:"stale_connections"`
+	Hits             uint32 `json:"hits"`
+	Misses           uint32 `json:"misses"`
+	Timeouts         uint32 `json:"timeouts"`
+}
+
+// Details handles GET /health/details. The dependency checks run
+// concurrently, each bounded by healthCheckTimeout, so the endpoint answers
+// within one timeout even when several dependencies hang. Cached checks are
+// reused unless ?fresh=true is given. The status follows the same rules as
+// Check.
+func (h *HealthHandler) Details(c *fiber.Ctx) error {
+	snapshot := h.checks.Get(c.QueryBool("fresh"))
+	now := time.Now()
+	build := version.Get()
+
+	response := HealthDetailsResponse{
+		Version:       build.Version,
+		Commit:        build.Commit,
+		BuildTime:     build.BuildTime,
+		StartedAt:     h.started,
+		UptimeSeconds: now.Sub(h.started).Seconds(),
+		Runtime:       runtimeDetails(),
+	}
+
+	database, redis, queue := snapshot.Database, snapshot.Redis, snapshot.Queue
+	h.stampLastSuccess(&database, healthComponentDatabase)
+	h.stampLastSuccess(&redis, healthComponentRedis)
+	h.stampLastSuccess(&queue.ServiceHealth, healthComponentQueue)
+	response.Latencies = HealthLatencies{
+		DBPingMS:     database.LatencyMS,
+		RedisPingMS:  redis.LatencyMS,
+		QueueStatsMS: queue.LatencyMS,
+	}
+
+	response.Database.ServiceHealth = database
+	response.Database.MigrationsCompleted = h.db.Migrated()
+	if stats, err := h.db.PoolStats(); err != nil {
+		response.Database.PoolStatsError = err.Error()
+	} else {
+		response.Database.OpenConnections = stats.OpenConnections
+		response.Database.InUse = stats.InUse
+		response.Database.Idle = stats.Idle
+		response.Database.WaitCount = stats.WaitCount
+		response.Database.WaitSeconds = stats.WaitDuration.Seconds()
+		response.Database.MaxOpenConns = stats.MaxOpenConnections
+	}
+
+	response.Redis.ServiceHealth = redis
+	response.Redis.SubscriptionHealthy = h.ws.SubscriptionHealthy()
+	if stats := h.queue.PoolStats(); stats != nil {
+		response.Redis.TotalConnections = stats.TotalConns
+		response.Redis.IdleConnections = stats.IdleConns
+		response.Redis.StaleConnections = stats.StaleConns
+		response.Redis.Hits = stats.Hits
+		response.Redis.Misses = stats.Misses
+		response.Redis.Timeouts = stats.Timeouts
+	}
+
+	response.Queue = queue
+
+	response.Status = snapshot.status()
+	response.CacheAgeSecs = now.Sub(snapshot.CheckedAt).Seconds()
+	return c.Status(healthStatusCode(response.Status)).JSON(response)
+}
+
+// runtimeDetails reads goroutine and memory statistics. ReadMemStats
+// briefly stops the world, which is acceptable for a triage endpoint.
+func runtimeDetails() RuntimeDetails {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	details := RuntimeDetails{
+		GoVersion:           runtime.Version(),
+		Goroutines:          runtime.NumGoroutine(),
+		HeapInUseBytes:      mem.HeapInuse,
+		HeapAllocBytes:      mem.HeapAlloc,
+		SysBytes:            mem.Sys,
+		GCCycles:            mem.NumGC,
+		GCPauseTotalSeconds: time.Duration(mem.PauseTotalNs).Seconds(),
+	}
+	if mem.NumGC > 0 {
+		// PauseNs is a circular buffer; the latest pause is at (NumGC+255)%256
+		details.GCLastPauseSeconds = time.Duration(mem.PauseNs[(mem.NumGC+255)%256]).Seconds()
+	}
+	return details
+}
+
+// probeService runs a health check under healthCheckTimeout
+func probeService(ctx context.Context, check func(context.Context) error) ServiceHealth {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	// The check runs apart so one that ignores ctx cannot hold up the caller
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := ServiceHealth{
+		Status:    ServiceStatusUp,
+		LatencyMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = ServiceStatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// probeAll checks the database, Redis and the queue concurrently. The
+// checks are not tied to any request, since their result is shared.
+func (h *HealthHandler) probeAll() *healthSnapshot {
+	ctx := context.Background()
+	var database, redis ServiceHealth
+	var queue QueueHealth
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		database = h.probe(ctx, healthComponentDatabase, h.db.Health)
+	}()
+	go func() {
+		defer wg.Done()
+		redis = h.probe(ctx, healthComponentRedis, h.queue.Health)
+	}()
+	go func() {
+		defer wg.Done()
+		queue = h.probeQueue(ctx)
+	}()
+	wg.Wait()
+
+	return &healthSnapshot{
+		Database:  database,
+		Redis:     redis,
+		Queue:     queue,
+		CheckedAt: time.Now(),
+	}
+}
+
+// probe runs a health check and marks it degraded when it answered slower
+// than the latency threshold. A check that answered at all is recorded as
+// the component's last success.
+func (h *HealthHandler) probe(ctx context.Context, component string, check func(context.Context) error) ServiceHealth {
+	result := probeService(ctx, check)
+	if result.Status == ServiceStatusDown {
+		return result
+	}
+
+	h.mu.Lock()
+	h.lastSuccess[component] = time.Now()
+	h.mu.Unlock()
+
+	if result.LatencyMS > h.thresholds.LatencyThreshold.Milliseconds() {
+		result.Status = ServiceStatusDegraded
+		result.Warning = fmt.Sprintf("responded in %dms, above the %s threshold",
+			result.LatencyMS, h.thresholds.LatencyThreshold)
+	}
+	return result
+}
+
+// stampLastSuccess sets when component last passed a check, if ever
+func (h *HealthHandler) stampLastSuccess(result *ServiceHealth, component string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if at, ok := h.lastSuccess[component]; ok {
+		result.LastSuccessAt = &at
+	}
+}
+
+// probeQueue reads the queue depth under healthCheckTimeout, marking the
+// queue degraded when more jobs are pending than the depth threshold
+func (h *HealthHandler) probeQueue(ctx context.Context) QueueHealth {
+	// The counts are only read once the check has returned; a timed out
+	// check may still write them later
+	var pending, processing int64
+	result := QueueHealth{}
+	result.ServiceHealth = h.probe(ctx, healthComponentQueue, func(ctx context.Context) error {
+		var err error
+		pending, processing, err = h.queue.GetQueueDepth(ctx)
+		return err
+	})
+	if result.Status == ServiceStatusDown {
+		return result
+	}
+	result.Pending, result.Processing = pending, processing
+
+	threshold := h.thresholds.QueueDepthThreshold
+	if threshold > 0 && result.Pending > threshold {
+		result.Status = ServiceStatusDegraded
+		result.Warning = fmt.Sprintf("%d jobs pending, above the threshold of %d", result.Pending, threshold)
+	}
+	return result
 }
 
 // Ping handles GET /ping
@@ -46,14 +439,38 @@ func (h *HealthHandler) Ping(c *fiber.Ctx) error {
 	})
 }
 
-// Ready handles GET /ready (for Kubernetes readiness probe)
+// Ready handles GET /ready (for Kubernetes readiness probe). The pod is
+// ready once every startup gate is marked and the database and Redis
+// answer a ping, slowly or not; until then the response is 503 listing the
+// pending gates and failing components.
 func (h *HealthHandler) Ready(c *fiber.Ctx) error {
+	snapshot := h.checks.Get(false)
+
+	pending := h.readiness.Pending()
+	failing := []string{}
+	if snapshot.Database.Status == ServiceStatusDown {
+		failing = append(failing, "database")
+	}
+	if snapshot.Redis.Status == ServiceStatusDown {
+		failing = append(failing, "redis")
+	}
+
+	if len(pending) > 0 || len(failing) > 0 {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"ready":   false,
+			"pending": pending,
+			"failing": failing,
+		})
+	}
+
 	return c.JSON(fiber.Map{
 		"ready": true,
 	})
 }
 
-// Live handles GET /live (for Kubernetes liveness probe)
+// Live handles GET /live (for Kubernetes liveness probe). It checks no
+// dependencies, so a database or Redis outage marks pods unready rather
+// than getting them restarted.
 func (h *HealthHandler) Live(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"alive": true,
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// healthSnapshot is one round of dependency checks
+type healthSnapshot struct {
+	Database  ServiceHealth
+	Redis     ServiceHealth
+	Queue     QueueHealth
+	CheckedAt time.Time
+}
+
+// healthCache serves recent dependency checks so frequent probes do not
+// ping the database and Redis on every request. It works like statsCache:
+// a stale snapshot is served while one background refresh runs, and
+// requests without a snapshot share one round of checks.
+type healthCache struct {
+	ttl   time.Duration
+	check func() *healthSnapshot
+
+	mu         sync.Mutex
+	entry      *healthSnapshot
+	inflight   *healthCall
+	refreshing bool
+}
+
+// healthCall is a round of checks shared by every request waiting on it
+type healthCall struct {
+	done     chan struct{}
+	snapshot *healthSnapshot
+}
+
+// newHealthCache creates a cache around check. A ttl of zero disables
+// caching, though concurrent requests still share one round of checks.
+func newHealthCache(ttl time.Duration, check func() *healthSnapshot) *healthCache {
+	return &healthCache{
+		ttl:   ttl,
+		check: check,
+	}
+}
+
+// Get returns the cached snapshot, or runs the checks when there is none
+// or fresh is set
+func (h *healthCache) Get(fresh bool) *healthSnapshot {
+	h.mu.Lock()
+
+	if !fresh && h.ttl > 0 && h.entry != nil {
+		entry := h.entry
+		if time.Since(entry.CheckedAt) >= h.ttl && !h.refreshing && h.inflight == nil {
+			h.refreshing = true
+			go h.refresh()
+		}
+		h.mu.Unlock()
+		return entry
+	}
+
+	call := h.inflight
+	if call == nil {
+		call = &healthCall{done: make(chan struct{})}
+		h.inflight = call
+		go h.run(call)
+	}
+	h.mu.Unlock()
+
+	<-call.done
+	return call.snapshot
+}
+
+// run performs a shared round of checks and stores its result
+func (h *healthCache) run(call *healthCall) {
+	call.snapshot = h.check()
+
+	h.mu.Lock()
+	h.store(call.snapshot)
+	h.inflight = nil
+	h.mu.Unlock()
+
+	close(call.done)
+}
+
+// refresh re-runs the checks for a stale snapshot in the background.
+// Failed checks are results too, so the snapshot is always replaced.
+func (h *healthCache) refresh() {
+	snapshot := h.check()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.refreshing = false
+	h.store(snapshot)
+}
+
+// store keeps snapshot unless a newer one was stored meanwhile. Callers
+// hold h.mu.
+func (h *healthCache) store(snapshot *healthSnapshot) {
+	if h.entry == nil || snapshot.CheckedAt.After(h.entry.CheckedAt) {
+		h.entry = snapshot
+	}
+}
@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"botrix-backend/config"
+	"botrix-backend/services"
+	"botrix-backend/utils"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+)
+
+// healthTestEnv is a health handler with handles on the dependencies it
+// probes, so tests can take them down
+type healthTestEnv struct {
+	app       *fiber.App
+	db        *services.Database
+	queue     *services.QueueService
+	redis     *miniredis.Miniredis
+	readiness *utils.ReadinessTracker
+}
+
+// newHealthTestEnv serves the health routes from a fresh database and
+// in-memory Redis, with the given startup gates pending. Results are not
+// cached, so each request probes again.
+func newHealthTestEnv(t *testing.T, gates ...string) *healthTestEnv {
+	t.Helper()
+	return newHealthTestEnvWith(t, config.HealthConfig{LatencyThreshold: time.Second}, gates...)
+}
+
+// newHealthTestEnvWith is newHealthTestEnv with the given thresholds
+func newHealthTestEnvWith(t *testing.T, thresholds config.HealthConfig, gates ...string) *healthTestEnv {
+	t.Helper()
+	cfg := newTestConfig(t)
+	cfg.Health = thresholds
+	queue, server := newTestQueue(t, cfg)
+	db := newTestDatabase(t, cfg)
+	readiness := utils.NewReadinessTracker(gates...)
+	ws := NewWebSocketHandlerWithLogger(services.NewHub(cfg.WebSocket), queue, db, utils.GetDefaultLogger(), cfg.WebSocket)
+	h := NewHealthHandler(db, queue, cfg, readiness, ws)
+
+	app := fiber.New()
+	app.Get("/health", h.Check)
+	app.Get("/health/details", h.Details)
+	app.Get("/health/ready", h.Ready)
+	app.Get("/health/live", h.Live)
+	return &healthTestEnv{app: app, db: db, queue: queue, redis: server, readiness: readiness}
+}
+
+// serviceStatus returns the status reported for one service by /health
+func serviceStatus(t *testing.T, body map[string]interface{}, service string) string {
+	t.Helper()
+	results, _ := body["services"].(map[string]interface{})
+	result, ok := results[service].(map[string]interface{})
+	if !ok {
+		t.Fatalf("/health has no %s result: %v", service, body)
+	}
+	status, _ := result["status"].(string)
+	return status
+}
+
+func TestHealthCheckProbesDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		stop     func(env *healthTestEnv)
+		code     int
+		status   string
+		database string
+		redis    string
+	}{
+		{
+			name:     "all up",
+			stop:     func(env *healthTestEnv) {},
+			code:     fiber.StatusOK,
+			status:   HealthStatusHealthy,
+			database: ServiceStatusUp,
+			redis:    ServiceStatusUp,
+		},
+		{
+			name:     "redis stopped",
+			stop:     func(env *healthTestEnv) { env.redis.Close() },
+			code:     fiber.StatusServiceUnavailable,
+			status:   HealthStatusUnhealthy,
+			database: ServiceStatusUp,
+			redis:    ServiceStatusDown,
+		},
+		{
+			name:     "database closed",
+			stop:     func(env *healthTestEnv) { env.db.Close() },
+			code:     fiber.StatusServiceUnavailable,
+			status:   HealthStatusUnhealthy,
+			database: ServiceStatusDown,
+			redis:    ServiceStatusUp,
+		},
+		{
+			name:     "both down",
+			stop:     func(env *healthTestEnv) { env.redis.Close(); env.db.Close() },
+			code:     fiber.StatusServiceUnavailable,
+			status:   HealthStatusUnhealthy,
+			database: ServiceStatusDown,
+			redis:    ServiceStatusDown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newHealthTestEnv(t)
+			tt.stop(env)
+
+			code, body := doRequest(t, env.app, "GET", "/health", "")
+			if code != tt.code || body["status"] != tt.status {
+				t.Errorf("GET /health = %d %v, want %d %s", code, body["status"], tt.code, tt.status)
+			}
+			if got := serviceStatus(t, body, "database"); got != tt.database {
+				t.Errorf("database status = %s, want %s", got, tt.database)
+			}
+			if got := serviceStatus(t, body, "redis"); got != tt.redis {
+				t.Errorf("redis status = %s, want %s", got, tt.redis)
+			}
+
+			// Liveness never depends on the database or Redis
+			if code, _ := doRequest(t, env.app, "GET", "/health/live", ""); code != fiber.StatusOK {
+				t.Errorf("GET /health/live = %d, want 200", code)
+			}
+		})
+	}
+}
+
+func TestHealthCheckRecovers(t *testing.T) {
+	env := newHealthTestEnv(t)
+	addr := env.redis.Addr()
+	env.redis.Close()
+
+	if code, _ := doRequest(t, env.app, "GET", "/health", ""); code != fiber.StatusServiceUnavailable {
+		t.Fatalf("GET /health with Redis stopped = %d, want 503", code)
+	}
+
+	if err := env.redis.StartAddr(addr); err != nil {
+		t.Fatalf("restarting Redis: %v", err)
+	}
+	if code, body := doRequest(t, env.app, "GET", "/health", ""); code != fiber.StatusOK || body["status"] != HealthStatusHealthy {
+		t.Errorf("GET /health after Redis restarted = %d %v, want 200 healthy", code, body["status"])
+	}
+}
+
+func TestReadyFailureCombinations(t *testing.T) {
+	const gate = "websocket_subscribed"
+
+	for _, gatePending := range []bool{false, true} {
+		for _, databaseDown := range []bool{false, true} {
+			for _, redisDown := range []bool{false, true} {
+				name := fmt.Sprintf("gate pending=%v database down=%v redis down=%v", gatePending, databaseDown, redisDown)
+				t.Run(name, func(t *testing.T) {
+					env := newHealthTestEnv(t, gate)
+					wantPending, wantFailing := []string{}, []string{}
+					if gatePending {
+						wantPending = append(wantPending, gate)
+					} else {
+						env.readiness.Mark(gate)
+					}
+					if databaseDown {
+						env.db.Close()
+						wantFailing = append(wantFailing, "database")
+					}
+					if redisDown {
+						env.redis.Close()
+						wantFailing = append(wantFailing, "redis")
+					}
+
+					code, body := doRequest(t, env.app, "GET", "/health/ready", "")
+					ready := !gatePending && !databaseDown && !redisDown
+					if ready {
+						if code != fiber.StatusOK || body["ready"] != true {
+							t.Errorf("GET /health/ready = %d %v, want 200 ready", code, body)
+						}
+						return
+					}
+
+					if code != fiber.StatusServiceUnavailable || body["ready"] != false {
+						t.Fatalf("GET /health/ready = %d %v, want 503 not ready", code, body)
+					}
+					if got, _ := stringList(body["pending"]); !reflect.DeepEqual(got, wantPending) {
+						t.Errorf("pending = %v, want %v", got, wantPending)
+					}
+					if got, _ := stringList(body["failing"]); !reflect.DeepEqual(got, wantFailing) {
+						t.Errorf("failing = %v, want %v", got, wantFailing)
+					}
+
+					if code, _ := doRequest(t, env.app, "GET", "/health/live", ""); code != fiber.StatusOK {
+						t.Errorf("GET /health/live = %d, want 200", code)
+					}
+				})
+			}
+		}
+	}
+}
+
+// healthFaultHook makes Redis answer pings slowly or fail the pipelined
+// queue depth read, while other commands still work
+type healthFaultHook struct {
+	pingDelay  time.Duration
+	failDepths bool
+}
+
+func (h *healthFaultHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	if cmd.Name() == "ping" {
+		time.Sleep(h.pingDelay)
+	}
+	return ctx, nil
+}
+
+func (h *healthFaultHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *healthFaultHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	if h.failDepths {
+		return ctx, errors.New("queue depth unavailable")
+	}
+	return ctx, nil
+}
+
+func (h *healthFaultHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func TestHealthStatusCombinations(t *testing.T) {
+	const (
+		up      = "up"
+		slow    = "slow"
+		down    = "down"
+		backlog = "backlog"
+	)
+
+	// The database and Redis are critical: either one down is a 503. The
+	// queue is not: a failing or backed up queue, like a slow ping, is
+	// degraded but still 200.
+	tests := []struct {
+		database, redis, queue string
+		code                   int
+		status                 string
+	}{
+		{up, up, up, fiber.StatusOK, HealthStatusHealthy},
+		{up, up, backlog, fiber.StatusOK, HealthStatusDegraded},
+		{up, up, down, fiber.StatusOK, HealthStatusDegraded},
+		{up, slow, up, fiber.StatusOK, HealthStatusDegraded},
+		{up, slow, backlog, fiber.StatusOK, HealthStatusDegraded},
+		{up, slow, down, fiber.StatusOK, HealthStatusDegraded},
+		{up, down, down, fiber.StatusServiceUnavailable, HealthStatusUnhealthy},
+		{down, up, up, fiber.StatusServiceUnavailable, HealthStatusUnhealthy},
+		{down, up, backlog, fiber.StatusServiceUnavailable, HealthStatusUnhealthy},
+		{down, up, down, fiber.StatusServiceUnavailable, HealthStatusUnhealthy},
+		{down, slow, up, fiber.StatusServiceUnavailable, HealthStatusUnhealthy},
+		{down, slow, down, fiber.StatusServiceUnavailable, HealthStatusUnhealthy},
+		{down, down, down, fiber.StatusServiceUnavailable, HealthStatusUnhealthy},
+	}
+
+	for _, tt := range tests {
+		name := fmt.Sprintf("database %s, redis %s, queue %s", tt.database, tt.redis, tt.queue)
+		t.Run(name, func(t *testing.T) {
+			env := newHealthTestEnvWith(t, config.HealthConfig{
+				LatencyThreshold:    20 * time.Millisecond,
+				QueueDepthThreshold: 1,
+			})
+			hook := &healthFaultHook{}
+			env.queue.GetRedisClient().AddHook(hook)
+
+			if tt.database == down {
+				env.db.Close()
+			}
+			switch tt.redis {
+			case slow:
+				hook.pingDelay = 50 * time.Millisecond
+			case down:
+				env.redis.Close()
+			}
+			switch tt.queue {
+			case backlog:
+				env.redis.ZAdd(services.JobQueueKey, 0, "job-1")
+				env.redis.ZAdd(services.JobQueueKey, 0, "job-2")
+			case down:
+				hook.failDepths = true
+			}
+
+			code, body := doRequest(t, env.app, "GET", "/health", "")
+			if code != tt.code || body["status"] != tt.status {
+				t.Errorf("GET /health = %d %v, want %d %s", code, body["status"], tt.code, tt.status)
+			}
+
+			want := map[string]string{
+				"database": map[string]string{up: ServiceStatusUp, down: ServiceStatusDown}[tt.database],
+				"redis":    map[string]string{up: ServiceStatusUp, slow: ServiceStatusDegraded, down: ServiceStatusDown}[tt.redis],
+				"queue":    map[string]string{up: ServiceStatusUp, backlog: ServiceStatusDegraded, down: ServiceStatusDown}[tt.queue],
+			}
+			for service, status := range want {
+				if got := serviceStatus(t, body, service); got != status {
+					t.Errorf("%s status = %s, want %s", service, got, status)
+				}
+			}
+		})
+	}
+}
+
+// blockingHook holds every Redis command until release is closed,
+// ignoring the context as a hung connection would
+type blockingHook struct {
+	release chan struct{}
+}
+
+func (h *blockingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	<-h.release
+	return ctx, nil
+}
+
+func (h *blockingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *blockingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	<-h.release
+	return ctx, nil
+}
+
+func (h *blockingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func TestBlockedHealthCheckDoesNotDelayResponse(t *testing.T) {
+	env := newHealthTestEnvWith(t, config.HealthConfig{LatencyThreshold: time.Second, CacheTTL: time.Hour})
+	if code, body := doRequest(t, env.app, "GET", "/health", ""); code != fiber.StatusOK || body["status"] != HealthStatusHealthy {
+		t.Fatalf("GET /health = %d %v, want 200 healthy", code, body["status"])
+	}
+
+	hook := &blockingHook{release: make(chan struct{})}
+	env.queue.GetRedisClient().AddHook(hook)
+	t.Cleanup(func() { close(hook.release) })
+
+	// Cached results are served at once while Redis hangs
+	start := time.Now()
+	code, body := doRequest(t, env.app, "GET", "/health", "")
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("cached GET /health took %s", elapsed)
+	}
+	if code != fiber.StatusOK || body["status"] != HealthStatusHealthy {
+		t.Errorf("cached GET /health = %d %v, want the cached 200 healthy", code, body["status"])
+	}
+	if age, _ := body["cache_age_seconds"].(float64); age < 0 || age > 60 {
+		t.Errorf("cache_age_seconds = %v, want the age of the first check", body["cache_age_seconds"])
+	}
+
+	// A fresh round gives up on the hung checks after the timeout
+	start = time.Now()
+	code, body = doRequest(t, env.app, "GET", "/health/details?fresh=true", "")
+	if elapsed := time.Since(start); elapsed > healthCheckTimeout+time.Second {
+		t.Errorf("GET /health/details with Redis hung took %s, want about %s", elapsed, healthCheckTimeout)
+	}
+	if code != fiber.StatusServiceUnavailable || body["status"] != HealthStatusUnhealthy {
+		t.Errorf("GET /health/details with Redis hung = %d %v, want 503 unhealthy", code, body["status"])
+	}
+	redisResult, _ := body["redis"].(map[string]interface{})
+	if redisResult["status"] != ServiceStatusDown || redisResult["error"] != context.DeadlineExceeded.Error() {
+		t.Errorf("redis result = %v, want down with %q", redisResult, context.DeadlineExceeded)
+	}
+}
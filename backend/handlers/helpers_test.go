@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"botrix-backend/config"
+	"botrix-backend/services"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+)
+
+// newTestConfig returns a configuration with a fresh SQLite file and no
+// Redis address; newTestQueue fills that in
+func newTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+	return &config.Config{
+		Server:    config.ServerConfig{Environment: "test"},
+		Database:  config.DatabaseConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "botrix.db")},
+		Reporting: config.ReportingConfig{Timezone: "UTC", Location: time.UTC},
+	}
+}
+
+// newTestDatabase opens the configured database, closing it when the test ends
+func newTestDatabase(t *testing.T, cfg *config.Config) *services.Database {
+	t.Helper()
+	db, err := services.NewDatabase(cfg)
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// newTestQueue starts an in-memory Redis and connects a queue to it. The
+// server is returned so tests can inspect keys or stop it.
+func newTestQueue(t *testing.T, cfg *config.Config) (*services.QueueService, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	cfg.Redis.Host = server.Host()
+	cfg.Redis.Port = server.Port()
+
+	queue, err := services.NewQueueService(cfg)
+	if err != nil {
+		t.Fatalf("NewQueueService: %v", err)
+	}
+	t.Cleanup(func() { queue.Close() })
+	return queue, server
+}
+
+// subscribeTest subscribes to a Redis channel for the rest of the test,
+// returning once the subscription is active
+func subscribeTest(t *testing.T, queue *services.QueueService, channel string) <-chan *redis.Message {
+	t.Helper()
+	ctx := context.Background()
+	pubsub := queue.GetRedisClient().Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		t.Fatalf("subscribing to %s: %v", channel, err)
+	}
+	t.Cleanup(func() { pubsub.Close() })
+	return pubsub.Channel()
+}
+
+// newTestAccountsHandler returns an accounts handler backed by a fresh
+// database and in-memory Redis
+func newTestAccountsHandler(t *testing.T) (*AccountsHandler, *miniredis.Miniredis) {
+	t.Helper()
+	cfg := newTestConfig(t)
+	queue, server := newTestQueue(t, cfg)
+	return NewAccountsHandler(newTestDatabase(t, cfg), queue, cfg, nil), server
+}
+
+// doRequest sends a request through app and decodes the JSON response body
+// into a map, returning it with the status code
+func doRequest(t *testing.T, app *fiber.App, method, target, body string) (int, map[string]interface{}) {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, target, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading %s %s: %v", method, target, err)
+	}
+	var decoded map[string]interface{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			t.Fatalf("%s %s: decoding %q: %v", method, target, raw, err)
+		}
+	}
+	return resp.StatusCode, decoded
+}
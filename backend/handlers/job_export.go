@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"botrix-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// jobExportBatchSize is the number of jobs read from the database at a time
+const jobExportBatchSize = 500
+
+// jobExportColumns is the header row of the jobs CSV export
+var jobExportColumns = []string{
+	"id", "created_at", "status", "count", "successful", "failed",
+	"duration_seconds", "priority", "test_mode", "error_msg",
+}
+
+// ExportJobs handles GET /api/jobs/export
+// It streams jobs matching the jobs list filters (label, status, from, to)
+// as CSV, oldest first. Timestamps are RFC3339 UTC and durations are whole
+// seconds, empty for jobs that have not finished.
+func (h *AccountsHandler) ExportJobs(c *fiber.Ctx) error {
+	format := strings.ToLower(c.Query("format", "csv"))
+	if format != "csv" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "format must be csv",
+		})
+	}
+
+	filter, err := h.parseJobFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, jobExportFilename(filter)))
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		out := csv.NewWriter(w)
+		if err := out.Write(jobExportColumns); err != nil {
+			return
+		}
+
+		rows := 0
+		err := h.db.IterateJobs(filter, jobExportBatchSize, func(jobs []models.Job) error {
+			for i := range jobs {
+				if err := out.Write(jobExportRow(&jobs[i])); err != nil {
+					return err
+				}
+				rows++
+			}
+			// Push each batch to the client as it is read
+			out.Flush()
+			if err := out.Error(); err != nil {
+				return err
+			}
+			return w.Flush()
+		})
+		out.Flush()
+
+		if err != nil {
+			log.Printf("[AccountsHandler] Job export aborted after %d rows: %v", rows, err)
+			return
+		}
+		log.Printf("[AccountsHandler] Exported %d jobs", rows)
+	})
+
+	return nil
+}
+
+// jobExportRow formats a job as a CSV row matching jobExportColumns
+func jobExportRow(job *models.Job) []string {
+	var duration string
+	if job.StartedAt != nil && job.CompletedAt != nil {
+		duration = strconv.FormatInt(int64(job.CompletedAt.Sub(*job.StartedAt).Seconds()), 10)
+	}
+
+	return []string{
+		job.ID,
+		job.CreatedAt.UTC().Format(time.RFC3339),
+		string(job.Status),
+		strconv.Itoa(job.Count),
+		strconv.Itoa(job.Successful),
+		strconv.Itoa(job.Failed),
+		duration,
+		strconv.Itoa(job.Priority),
+		strconv.FormatBool(job.TestMode),
+		job.ErrorMsg,
+	}
+}
+
+// jobExportFilename embeds the export's date range in the file name
+func jobExportFilename(filter models.JobFilter) string {
+	from, to := "start", time.Now().UTC().Format("2006-01-02")
+	if filter.CreatedFrom != nil {
+		from = filter.CreatedFrom.Format("2006-01-02")
+	}
+	if filter.CreatedTo != nil {
+		to = filter.CreatedTo.Format("2006-01-02")
+	}
+	return fmt.Sprintf("jobs_%s_to_%s.csv", from, to)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"botrix-backend/models"
+	"botrix-backend/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// sseHeartbeatInterval keeps idle job streams alive through proxies
+const sseHeartbeatInterval = 15 * time.Second
+
+// StreamJob handles GET /api/jobs/:id/stream
+// It streams a job's updates as Server-Sent Events: an initial "status"
+// snapshot, then "progress", "status" and "log" events from the job updates
+// channel, and a final "done" event once the job reaches a terminal state.
+func (h *AccountsHandler) StreamJob(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	job, err := h.db.GetJob(jobID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Job not found",
+		})
+	}
+
+	// Get status from Redis (more up-to-date than database)
+	if redisStatus, err := h.queue.GetJobStatus(jobID); err == nil && redisStatus != "" {
+		job.Status = models.JobStatus(redisStatus)
+	}
+
+	pubsub, err := h.queue.Subscribe(services.JobUpdatesChannel)
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to subscribe for job %s stream: %v", jobID, err)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"success": false,
+			"error":   "Job updates unavailable",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// A failed write means the client went away; closing the
+		// subscription here unsubscribes from Redis promptly
+		defer pubsub.Close()
+
+		if writeSSE(w, "status", job.ToJSON()) != nil {
+			return
+		}
+		if job.IsCompleted() {
+			writeSSE(w, "done", map[string]interface{}{"job_id": jobID, "status": job.Status})
+			return
+		}
+
+		heartbeat := time.NewTicker(sseHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				update, err := services.ParseJobUpdate(msg.Payload)
+				if err != nil || update.JobID != jobID {
+					continue
+				}
+
+				if writeSSE(w, sseEventName(update), update.Raw) != nil {
+					return
+				}
+
+				if update.IsTerminal() {
+					writeSSE(w, "done", map[string]interface{}{"job_id": jobID, "status": update.Status})
+					return
+				}
+
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+// sseEventName classifies a job update as a log, progress or status event
+func sseEventName(update *services.JobUpdate) string {
+	if update.Event == services.JobLogEvent {
+		return "log"
+	}
+	if _, ok := update.Raw["progress"]; ok {
+		return "progress"
+	}
+	if data, ok := update.Raw["data"].(map[string]interface{}); ok {
+		if _, ok := data["progress"]; ok {
+			return "progress"
+		}
+	}
+	return "status"
+}
+
+// writeSSE writes one Server-Sent Event and flushes it to the client
+func writeSSE(w *bufio.Writer, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"botrix-backend/models"
+	"botrix-backend/services"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+)
+
+// sseEvent is one parsed Server-Sent Event
+type sseEvent struct {
+	name string
+	data map[string]interface{}
+}
+
+// readSSE parses events from r until it ends
+func readSSE(t *testing.T, r io.Reader) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &current.data); err != nil {
+				t.Fatalf("decoding event data %q: %v", line, err)
+			}
+		case line == "" && current.name != "":
+			events = append(events, current)
+			current = sseEvent{}
+		}
+	}
+	return events
+}
+
+// waitForSubscribers waits until channel has n subscribers
+func waitForSubscribers(t *testing.T, server *miniredis.Miniredis, channel string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for server.PubSubNumSub(channel)[channel] != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s has %d subscribers, want %d", channel, server.PubSubNumSub(channel)[channel], n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// publishFake publishes update on the job updates channel as a worker would
+func publishFake(server *miniredis.Miniredis, update map[string]interface{}) {
+	payload, _ := json.Marshal(update)
+	server.Publish(services.JobUpdatesChannel, string(payload))
+}
+
+func TestStreamJobEvents(t *testing.T) {
+	h, server := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Get("/api/jobs/:id/stream", h.StreamJob)
+
+	job := createTestJob(t, h, models.JobStatusRunning)
+
+	// The fake publisher waits for the stream's subscription, then reports
+	// another job, progress, a log line and completion
+	go func() {
+		for server.PubSubNumSub(services.JobUpdatesChannel)[services.JobUpdatesChannel] == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		publishFake(server, map[string]interface{}{"event": services.JobProgressEvent, "job_id": "other-job", "progress": 1})
+		publishFake(server, map[string]interface{}{"event": services.JobProgressEvent, "job_id": job.ID, "progress": 1})
+		publishFake(server, map[string]interface{}{"event": services.JobLogEvent, "job_id": job.ID, "message": "account 1 created"})
+		publishFake(server, map[string]interface{}{"event": "job_completed", "job_id": job.ID,
+			"data": map[string]interface{}{"job_id": job.ID, "status": "completed"}})
+	}()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/jobs/"+job.ID+"/stream", nil), 10000)
+	if err != nil {
+		t.Fatalf("streaming: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get(fiber.HeaderContentType); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+	if got := resp.Header.Get(fiber.HeaderCacheControl); got != "no-cache" {
+		t.Errorf("Cache-Control = %q, want no-cache", got)
+	}
+
+	events := readSSE(t, resp.Body)
+	var names []string
+	for _, event := range events {
+		names = append(names, event.name)
+	}
+	if got := strings.Join(names, ","); got != "status,progress,log,status,done" {
+		t.Fatalf("events = %s, want status,progress,log,status,done", got)
+	}
+	if events[0].data["id"] != job.ID || events[0].data["status"] != string(models.JobStatusRunning) {
+		t.Errorf("snapshot = %v, want job %s running", events[0].data, job.ID)
+	}
+	if events[2].data["message"] != "account 1 created" {
+		t.Errorf("log event = %v, want the published line", events[2].data)
+	}
+	if done := events[4].data; done["job_id"] != job.ID || done["status"] != "completed" {
+		t.Errorf("done event = %v, want job %s completed", done, job.ID)
+	}
+
+	// The stream unsubscribes once it ends
+	waitForSubscribers(t, server, services.JobUpdatesChannel, 0)
+}
+
+func TestStreamFinishedJob(t *testing.T) {
+	h, server := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Get("/api/jobs/:id/stream", h.StreamJob)
+
+	// Redis is more current than the database row
+	job := createTestJob(t, h, models.JobStatusRunning)
+	if err := h.queue.UpdateJobStatus(job.ID, string(models.JobStatusFailed)); err != nil {
+		t.Fatalf("UpdateJobStatus: %v", err)
+	}
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/jobs/"+job.ID+"/stream", nil), 10000)
+	if err != nil {
+		t.Fatalf("streaming: %v", err)
+	}
+	events := readSSE(t, resp.Body)
+	resp.Body.Close()
+	if len(events) != 2 || events[0].name != "status" || events[1].name != "done" || events[1].data["status"] != "failed" {
+		t.Errorf("events = %+v, want a snapshot and done with status failed", events)
+	}
+	waitForSubscribers(t, server, services.JobUpdatesChannel, 0)
+
+	if code, _ := doRequest(t, app, http.MethodGet, "/api/jobs/missing/stream", ""); code != fiber.StatusNotFound {
+		t.Errorf("streaming an unknown job: status = %d, want 404", code)
+	}
+}
+
+func TestStreamJobClientDisconnect(t *testing.T) {
+	h, server := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Get("/api/jobs/:id/stream", h.StreamJob)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+
+	job := createTestJob(t, h, models.JobStatusRunning)
+	resp, err := http.Get(fmt.Sprintf("http://%s/api/jobs/%s/stream", ln.Addr(), job.ID))
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	reader := bufio.NewReader(resp.Body)
+	if line, err := reader.ReadString('\n'); err != nil || line != "event: status\n" {
+		t.Fatalf("first line = %q, %v, want the status snapshot", line, err)
+	}
+	waitForSubscribers(t, server, services.JobUpdatesChannel, 1)
+
+	// The next write after the client leaves fails and ends the stream
+	resp.Body.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for server.PubSubNumSub(services.JobUpdatesChannel)[services.JobUpdatesChannel] != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream still subscribed after the client disconnected")
+		}
+		publishFake(server, map[string]interface{}{"event": services.JobProgressEvent, "job_id": job.ID, "progress": 1})
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,1219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"botrix-backend/models"
+	"botrix-backend/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// RetryJobRequest controls how a failed job is retried
+type RetryJobRequest struct {
+	AsNew bool `json:"as_new,omitempty"` // clone into a new job, keeping the old one as history
+}
+
+// BulkCancelRequest selects jobs to cancel, either by ID or every job in a status
+type BulkCancelRequest struct {
+	IDs    []string `json:"ids,omitempty"`
+	Status string   `json:"status,omitempty"` // only "pending" is supported
+}
+
+// UpdateJobRequest changes a pending job
+type UpdateJobRequest struct {
+	Priority *string `json:"priority"` // "low", "normal" or "high"
+}
+
+// BatchCreateJobsRequest creates several jobs in one call
+type BatchCreateJobsRequest struct {
+	Jobs   []models.JobCreateRequest `json:"jobs"`
+	Atomic bool                      `json:"atomic,omitempty"`
+}
+
+// BatchJobResult reports the outcome of one entry of a job batch
+type BatchJobResult struct {
+	Index   int    `json:"index"`
+	Success bool   `json:"success"`
+	JobID   string `json:"job_id,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// maxBatchJobs caps the number of jobs a single batch may create
+const maxBatchJobs = 50
+
+// maxBulkJobIDs caps the number of jobs a single bulk request may touch
+const maxBulkJobIDs = 500
+
+// etaThroughputWindow is the number of recently completed jobs averaged for
+// job ETAs
+const etaThroughputWindow = 50
+
+// GetJobs handles GET /api/jobs
+func (h *AccountsHandler) GetJobs(c *fiber.Ctx) error {
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+
+	if limit < 1 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	filter, err := h.parseJobFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.JobResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	jobs, err := h.db.ListJobs(filter, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to retrieve jobs",
+		})
+	}
+
+	// Total for pagination; filtered listings need a matching COUNT
+	var total int64
+	if filter.IsEmpty() {
+		total, err = h.db.CountJobs()
+	} else {
+		total, err = h.db.CountJobsFiltered(filter)
+	}
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to count jobs: %v", err)
+	}
+
+	return c.JSON(models.JobResponse{
+		Success: true,
+		Jobs:    jobs,
+		Pagination: &models.Pagination{
+			Limit:   limit,
+			Offset:  offset,
+			Total:   total,
+			Count:   len(jobs),
+			HasMore: int64(offset+len(jobs)) < total,
+		},
+	})
+}
+
+// GetJobLabels handles GET /api/jobs/labels
+func (h *AccountsHandler) GetJobLabels(c *fiber.Ctx) error {
+	labels, err := h.db.GetJobLabelStats()
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to get job label stats: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve job labels",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"labels":  labels,
+	})
+}
+
+// GetJob handles GET /api/jobs/:id
+func (h *AccountsHandler) GetJob(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	if jobID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Job ID is required",
+		})
+	}
+
+	// Get job from database
+	job, err := h.db.GetJob(jobID)
+	if err != nil {
+		log.Printf("[AccountsHandler] Job not found: %s", jobID)
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Job not found",
+		})
+	}
+
+	// Get status from Redis (more up-to-date than database)
+	redisStatus, err := h.queue.GetJobStatus(jobID)
+	if err == nil && redisStatus != "" {
+		job.Status = models.JobStatus(redisStatus)
+	}
+
+	// Calculate progress percentage
+	var progressPercent float64
+	if job.Count > 0 {
+		progressPercent = (float64(job.Progress) / float64(job.Count)) * 100
+	}
+
+	// Calculate duration if job has started
+	var duration string
+	if job.StartedAt != nil {
+		if job.CompletedAt != nil {
+			duration = job.CompletedAt.Sub(*job.StartedAt).String()
+		} else {
+			duration = time.Since(*job.StartedAt).String()
+		}
+	}
+
+	// Pending jobs report their place in the Redis queue. A pending job
+	// Redis no longer holds is reported as desynced; paused jobs are held
+	// out of the queue on purpose.
+	var jobsAhead *int64
+	desynced := false
+	if job.Status == models.JobStatusPending && !job.Paused {
+		rank, err := h.queue.GetJobPositionByID(jobID)
+		switch {
+		case err == nil:
+			jobsAhead = &rank
+		case err == services.ErrJobNotQueued:
+			desynced = true
+		default:
+			log.Printf("[AccountsHandler] Failed to get queue position of job %s: %v", jobID, err)
+		}
+	}
+
+	// Estimate completion; both fields stay null without enough history
+	var etaSeconds *int64
+	var estimatedCompletionAt *time.Time
+	if eta, ok := h.estimateJobETA(job, jobsAhead); ok {
+		seconds := int64(eta.Round(time.Second).Seconds())
+		completion := time.Now().Add(eta)
+		etaSeconds = &seconds
+		estimatedCompletionAt = &completion
+	}
+
+	response := fiber.Map{
+		"success": true,
+		"job":     job,
+		"progress": fiber.Map{
+			"current":    job.Progress,
+			"total":      job.Count,
+			"percentage": progressPercent,
+			"successful": job.Successful,
+			"failed":     job.Failed,
+		},
+		"duration":                duration,
+		"eta_seconds":             etaSeconds,
+		"estimated_completion_at": estimatedCompletionAt,
+		"status":                  string(job.Status),
+		"result_url":              fmt.Sprintf("/api/jobs/%s/result", job.ID),
+		"generation": fiber.Map{
+			"username_prefix":        job.UsernamePrefix,
+			"username_suffix_length": job.EffectiveUsernameSuffixLength(),
+			"password_policy":        job.EffectivePasswordPolicy(),
+		},
+	}
+
+	// queue_position is 1 for the next job to be dequeued
+	if job.Status == models.JobStatusPending && !job.Paused {
+		if jobsAhead != nil {
+			response["queue_position"] = *jobsAhead + 1
+		} else {
+			response["queue_position"] = nil
+		}
+		if desynced {
+			response["desynced"] = true
+		}
+	}
+
+	return c.JSON(response)
+}
+
+// estimateJobETA estimates the time left for a job from recent throughput.
+// jobsAhead is the job's queue rank when known; otherwise pending jobs are
+// ranked from the database.
+func (h *AccountsHandler) estimateJobETA(job *models.Job, jobsAhead *int64) (time.Duration, bool) {
+	if job.IsCompleted() {
+		return 0, false
+	}
+
+	throughput, err := h.db.GetRecentThroughput(etaThroughputWindow)
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to load throughput for job %s ETA: %v", job.ID, err)
+		return 0, false
+	}
+
+	var ahead int64
+	if jobsAhead != nil {
+		ahead = *jobsAhead
+	} else if job.Status == models.JobStatusPending {
+		ahead, err = h.db.CountPendingJobsAhead(job)
+		if err != nil {
+			log.Printf("[AccountsHandler] Failed to count jobs ahead of %s: %v", job.ID, err)
+			return 0, false
+		}
+	}
+
+	return job.EstimateRemaining(throughput, ahead)
+}
+
+// GetJobAccounts handles GET /api/jobs/:id/accounts
+func (h *AccountsHandler) GetJobAccounts(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	if _, err := h.db.GetJob(jobID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Job not found",
+		})
+	}
+
+	accounts, err := h.db.GetAccountsByJobID(jobID, limit, offset)
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to retrieve accounts for job %s: %v", jobID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve accounts",
+		})
+	}
+
+	total, err := h.db.CountAccountsByJobID(jobID)
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to count accounts for job %s: %v", jobID, err)
+	}
+
+	// Mask credentials unless explicitly requested
+	if !wantsCredentials(c) {
+		for i := range accounts {
+			accounts[i].HidePasswords()
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"job_id":  jobID,
+		"data":    accounts,
+		"pagination": fiber.Map{
+			"limit":    limit,
+			"offset":   offset,
+			"total":    total,
+			"count":    len(accounts),
+			"has_more": int64(offset+len(accounts)) < total,
+		},
+	})
+}
+
+// GetJobResult handles GET /api/jobs/:id/result
+// Results live in Redis for services.JobTTL; there is no database copy yet.
+func (h *AccountsHandler) GetJobResult(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	job, err := h.db.GetJob(jobID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Job not found",
+		})
+	}
+
+	// Get status from Redis (more up-to-date than database)
+	if redisStatus, err := h.queue.GetJobStatus(jobID); err == nil && redisStatus != "" {
+		job.Status = models.JobStatus(redisStatus)
+	}
+
+	raw, err := h.queue.GetJobResult(jobID)
+	if err == services.ErrJobResultNotFound {
+		if job.IsCompleted() {
+			return c.Status(fiber.StatusGone).JSON(fiber.Map{
+				"success": false,
+				"error":   "Job result has expired",
+				"status":  string(job.Status),
+			})
+		}
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Job has no result yet",
+			"status":  string(job.Status),
+		})
+	}
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to get result for job %s: %v", jobID, err)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve job result",
+		})
+	}
+
+	var result interface{}
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		// Not JSON; return the stored value as-is
+		result = raw
+	}
+
+	if !wantsCredentials(c) {
+		result = redactCredentials(result)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"job_id":  jobID,
+		"status":  string(job.Status),
+		"result":  result,
+	})
+}
+
+// CreateJob handles POST /api/jobs
+// It is the canonical way to create a job from a models.JobCreateRequest.
+func (h *AccountsHandler) CreateJob(c *fiber.Ctx) error {
+	var req models.JobCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	job, err := h.buildJob(&req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.JobResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if err := h.db.CreateJob(job); err != nil {
+		log.Printf("[AccountsHandler] Failed to create job: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to create job",
+		})
+	}
+
+	if err := h.enqueueCreatedJob(job); err != nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to enqueue job",
+			Job:     job,
+		})
+	}
+
+	log.Printf("[AccountsHandler] Job %s created (type: %s, count: %d, test_mode: %v)", job.ID, job.Type, job.Count, job.TestMode)
+
+	c.Set(fiber.HeaderLocation, fmt.Sprintf("/api/jobs/%s", job.ID))
+	return c.Status(fiber.StatusCreated).JSON(models.JobResponse{
+		Success: true,
+		Message: "Job queued",
+		Job:     job,
+	})
+}
+
+// BatchCreateJobs handles POST /api/jobs/batch
+// Each entry is validated, created and enqueued on its own, so one bad entry
+// does not reject the rest. With "atomic": true every entry must be valid
+// and all jobs are written in one transaction before any is enqueued.
+// Results follow the input order.
+func (h *AccountsHandler) BatchCreateJobs(c *fiber.Ctx) error {
+	var req BatchCreateJobsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+
+	if len(req.Jobs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "jobs must contain at least one entry",
+		})
+	}
+	if len(req.Jobs) > maxBatchJobs {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   fmt.Sprintf("At most %d jobs can be created at once", maxBatchJobs),
+		})
+	}
+
+	// Validate every entry up front
+	results := make([]BatchJobResult, len(req.Jobs))
+	jobs := make([]*models.Job, len(req.Jobs))
+	invalid := 0
+	for i := range req.Jobs {
+		results[i].Index = i
+		job, err := h.buildJob(&req.Jobs[i])
+		if err != nil {
+			results[i].Error = err.Error()
+			invalid++
+			continue
+		}
+		jobs[i] = job
+	}
+
+	if req.Atomic {
+		if invalid > 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   fmt.Sprintf("%d of %d entries are invalid; nothing was created", invalid, len(req.Jobs)),
+				"results": results,
+			})
+		}
+
+		if err := h.db.CreateJobsBatch(jobs); err != nil {
+			log.Printf("[AccountsHandler] Failed to create job batch: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"error":   "Failed to create jobs",
+			})
+		}
+	} else {
+		for i, job := range jobs {
+			if job == nil {
+				continue
+			}
+			if err := h.db.CreateJob(job); err != nil {
+				log.Printf("[AccountsHandler] Failed to create job %s: %v", job.ID, err)
+				results[i].Error = "Failed to create job"
+				jobs[i] = nil
+			}
+		}
+	}
+
+	// Enqueue only once the rows exist; queue failures mark the job failed
+	created := 0
+	for i, job := range jobs {
+		if job == nil {
+			continue
+		}
+		results[i].JobID = job.ID
+		if err := h.enqueueCreatedJob(job); err != nil {
+			results[i].Error = fmt.Sprintf("failed to enqueue: %v", err)
+			continue
+		}
+		results[i].Success = true
+		created++
+	}
+
+	log.Printf("[AccountsHandler] Job batch: %d of %d created (atomic: %v)", created, len(req.Jobs), req.Atomic)
+
+	status := fiber.StatusCreated
+	switch {
+	case created == 0:
+		status = fiber.StatusBadRequest
+		if invalid < len(req.Jobs) {
+			status = fiber.StatusServiceUnavailable
+		}
+	case created < len(req.Jobs):
+		status = fiber.StatusMultiStatus
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"success":   created == len(req.Jobs),
+		"message":   fmt.Sprintf("Queued %d of %d jobs", created, len(req.Jobs)),
+		"requested": len(req.Jobs),
+		"created":   created,
+		"results":   results,
+	})
+}
+
+// parseJobFilter reads the job listing filters: label, status and a
+// from/to creation date range (RFC3339 or YYYY-MM-DD in the reporting
+// timezone)
+func (h *AccountsHandler) parseJobFilter(c *fiber.Ctx) (models.JobFilter, error) {
+	var filter models.JobFilter
+
+	label, err := models.NormalizeJobLabel(c.Query("label"))
+	if err != nil {
+		return filter, err
+	}
+	filter.Label = label
+
+	if status := strings.ToLower(c.Query("status")); status != "" {
+		if !models.IsValidJobStatus(status) {
+			return filter, fmt.Errorf("status must be one of: pending, running, completed, failed, cancelled")
+		}
+		filter.Status = models.JobStatus(status)
+	}
+
+	loc := h.config.ReportingLocation()
+	if from := c.Query("from"); from != "" {
+		t, err := parseDateParam(from, loc, false)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from: %v", err)
+		}
+		filter.CreatedFrom = &t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseDateParam(to, loc, true)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to: %v", err)
+		}
+		filter.CreatedTo = &t
+	}
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && filter.CreatedFrom.After(*filter.CreatedTo) {
+		return filter, fmt.Errorf("from must be earlier than or equal to to")
+	}
+
+	return filter, nil
+}
+
+// parsePriority maps a priority name to its queue priority; an empty name
+// is normal priority
+func parsePriority(name string) (int, bool) {
+	switch strings.ToLower(name) {
+	case "low":
+		return int(services.PriorityLow), true
+	case "normal", "":
+		return int(services.PriorityNormal), true
+	case "high":
+		return int(services.PriorityHigh), true
+	default:
+		return 0, false
+	}
+}
+
+// buildJob validates a job creation request and builds the pending job
+func (h *AccountsHandler) buildJob(req *models.JobCreateRequest) (*models.Job, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	label, err := models.NormalizeJobLabel(req.Label)
+	if err != nil {
+		return nil, err
+	}
+
+	callbackURL := strings.TrimSpace(req.CallbackURL)
+	if callbackURL != "" {
+		if err := validateOutboundURL("callback_url", callbackURL, h.config.IsProduction()); err != nil {
+			return nil, err
+		}
+	}
+
+	job := &models.Job{
+		ID:       uuid.New().String(),
+		Type:     req.Type,
+		Label:    label,
+		Count:    req.Count,
+		Username: req.Username,
+		Password: req.Password,
+		Status:   models.JobStatusPending,
+		TestMode: req.TestMode,
+		Priority: req.Priority,
+	}
+	if callbackURL != "" {
+		job.CallbackURL = callbackURL
+		job.CallbackStatus = models.CallbackPending
+	}
+	return job, nil
+}
+
+// enqueueCreatedJob adds a saved job to the Redis queue. A job the queue
+// rejects is marked failed.
+func (h *AccountsHandler) enqueueCreatedJob(job *models.Job) error {
+	if err := h.queue.EnqueueJob(job); err != nil {
+		log.Printf("[AccountsHandler] Failed to enqueue job %s: %v", job.ID, err)
+		job.Fail(fmt.Sprintf("failed to enqueue: %v", err))
+		if err := h.db.UpdateJob(job); err != nil {
+			log.Printf("[AccountsHandler] Failed to mark job %s as failed: %v", job.ID, err)
+		}
+		h.logJob(job.ID, models.JobLogError, "Failed to enqueue job: %v", err)
+		return err
+	}
+
+	h.logJob(job.ID, models.JobLogInfo, "Job queued to generate %d account(s)", job.Count)
+	return nil
+}
+
+// UpdateJob handles PATCH /api/jobs/:id
+// Only the priority of a pending job can be changed; the job is re-scored
+// in the Redis queue so the change affects dequeue order.
+func (h *AccountsHandler) UpdateJob(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var req UpdateJobRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Priority == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.JobResponse{
+			Success: false,
+			Error:   "priority is required",
+		})
+	}
+	priority, ok := parsePriority(*req.Priority)
+	if !ok || *req.Priority == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Priority must be 'low', 'normal', or 'high'",
+		})
+	}
+
+	job, err := h.db.GetJob(id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Job not found",
+		})
+	}
+
+	// Both the database and Redis must still consider the job pending
+	status := job.Status
+	if redisStatus, err := h.queue.GetJobStatus(id); err == nil && redisStatus != "" {
+		status = models.JobStatus(redisStatus)
+	}
+	if job.Status != models.JobStatusPending || status != models.JobStatusPending {
+		return c.Status(fiber.StatusConflict).JSON(models.JobResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Job priority cannot be changed in state '%s'", status),
+		})
+	}
+
+	if err := h.queue.UpdateJobPriority(id, priority); err != nil {
+		if err == services.ErrJobNotQueued {
+			return c.Status(fiber.StatusConflict).JSON(models.JobResponse{
+				Success: false,
+				Error:   "Job is no longer queued",
+			})
+		}
+		log.Printf("[AccountsHandler] Failed to re-score job %s: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to update job priority",
+		})
+	}
+
+	previous := job.Priority
+	job.Priority = priority
+	if err := h.db.UpdateJob(job); err != nil {
+		log.Printf("[AccountsHandler] Job %s re-scored but database update failed: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to update job priority",
+		})
+	}
+
+	h.logJob(id, models.JobLogInfo, "Priority changed from %d to %d", previous, priority)
+	log.Printf("[AccountsHandler] Job %s priority changed from %d to %d", id, previous, priority)
+
+	response := fiber.Map{
+		"success": true,
+		"message": "Job priority updated",
+		"job":     job,
+	}
+	if rank, err := h.queue.GetJobPositionByID(id); err == nil {
+		response["queue_position"] = rank + 1
+	}
+
+	return c.JSON(response)
+}
+
+// CancelJob handles POST /api/jobs/:id/cancel
+func (h *AccountsHandler) CancelJob(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	job, err := h.db.GetJob(id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Job not found",
+		})
+	}
+
+	if !job.CanBeCancelled() {
+		return c.Status(fiber.StatusBadRequest).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Job cannot be cancelled in current state",
+		})
+	}
+
+	job.Cancel()
+
+	if err := h.db.UpdateJob(job); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to cancel job",
+		})
+	}
+
+	// Remove the job from the Redis queue so no worker picks it up. A job
+	// Redis no longer knows about (expired keys) is already out of the queue.
+	if err := h.queue.CancelJob(id); err != nil {
+		log.Printf("[AccountsHandler] WARNING: Job %s cancelled in database but not in queue: %v", id, err)
+	}
+	h.logJob(id, models.JobLogInfo, "Job cancelled")
+
+	return c.JSON(models.JobResponse{
+		Success: true,
+		Message: "Job cancelled successfully",
+		Job:     job,
+	})
+}
+
+// BulkCancelJobs handles POST /api/jobs/bulk-cancel
+func (h *AccountsHandler) BulkCancelJobs(c *fiber.Ctx) error {
+	var req BulkCancelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+
+	if (len(req.IDs) == 0) == (req.Status == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Provide either ids or status",
+		})
+	}
+
+	ids := req.IDs
+	if req.Status != "" {
+		if models.JobStatus(req.Status) != models.JobStatusPending {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "Only status 'pending' can be bulk cancelled",
+			})
+		}
+
+		// Fetch one more than the cap to detect oversized requests
+		pendingIDs, err := h.db.ListJobIDsByStatus(models.JobStatusPending, maxBulkJobIDs+1)
+		if err != nil {
+			log.Printf("[AccountsHandler] Failed to list pending jobs: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"error":   "Failed to retrieve pending jobs",
+			})
+		}
+		ids = pendingIDs
+	}
+
+	ids = uniqueStrings(ids)
+	if len(ids) > maxBulkJobIDs {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   fmt.Sprintf("At most %d jobs can be cancelled at once", maxBulkJobIDs),
+		})
+	}
+
+	result := &models.BulkCancelResult{Cancelled: []string{}, NotCancellable: []string{}, NotFound: []string{}}
+	if len(ids) > 0 {
+		var err error
+		result, err = h.db.CancelJobs(ids)
+		if err != nil {
+			log.Printf("[AccountsHandler] Bulk cancel failed: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"error":   "Failed to cancel jobs",
+			})
+		}
+	}
+
+	// Drain the Redis queue; per-job events still fire so callbacks run
+	for _, id := range result.Cancelled {
+		if err := h.queue.CancelJob(id); err != nil {
+			log.Printf("[AccountsHandler] Failed to cancel job %s in queue: %v", id, err)
+		}
+		h.logJob(id, models.JobLogInfo, "Job cancelled by bulk cancel")
+	}
+
+	log.Printf("[AccountsHandler] Bulk cancel: %d cancelled, %d not cancellable, %d not found",
+		len(result.Cancelled), len(result.NotCancellable), len(result.NotFound))
+
+	if len(result.Cancelled) > 0 {
+		h.queue.PublishEvent("jobs_cancelled", map[string]interface{}{
+			"cancelled": len(result.Cancelled),
+			"job_ids":   result.Cancelled,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": fmt.Sprintf("%d job(s) cancelled", len(result.Cancelled)),
+		"summary": fiber.Map{
+			"requested":       len(ids),
+			"cancelled":       len(result.Cancelled),
+			"not_cancellable": len(result.NotCancellable),
+			"not_found":       len(result.NotFound),
+		},
+		"results": result,
+	})
+}
+
+// PauseJob handles POST /api/jobs/:id/pause
+// A pending job is held out of the queue; a running job is halted by its
+// worker before the next account.
+func (h *AccountsHandler) PauseJob(c *fiber.Ctx) error {
+	job, err := h.loadActiveJob(c)
+	if job == nil {
+		return err
+	}
+
+	if job.Paused {
+		return c.Status(fiber.StatusConflict).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Job is already paused",
+		})
+	}
+
+	if err := h.queue.PauseJob(job.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to pause job",
+		})
+	}
+
+	job.Pause()
+	if err := h.db.UpdateJob(job); err != nil {
+		log.Printf("[AccountsHandler] Job %s paused in queue but database update failed: %v", job.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to pause job",
+		})
+	}
+
+	h.logJob(job.ID, models.JobLogInfo, "Job paused")
+	log.Printf("[AccountsHandler] Job %s paused (status: %s)", job.ID, job.Status)
+
+	return c.JSON(models.JobResponse{
+		Success: true,
+		Message: "Job paused",
+		Job:     job,
+	})
+}
+
+// ResumeJob handles POST /api/jobs/:id/resume
+func (h *AccountsHandler) ResumeJob(c *fiber.Ctx) error {
+	job, err := h.loadActiveJob(c)
+	if job == nil {
+		return err
+	}
+
+	if !job.Paused {
+		return c.Status(fiber.StatusConflict).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Job is not paused",
+		})
+	}
+
+	if err := h.queue.ResumeJob(*job); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to resume job",
+		})
+	}
+
+	job.Resume()
+	if err := h.db.UpdateJob(job); err != nil {
+		log.Printf("[AccountsHandler] Job %s resumed in queue but database update failed: %v", job.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to resume job",
+		})
+	}
+
+	h.logJob(job.ID, models.JobLogInfo, "Job resumed")
+	log.Printf("[AccountsHandler] Job %s resumed (status: %s)", job.ID, job.Status)
+
+	return c.JSON(models.JobResponse{
+		Success: true,
+		Message: "Job resumed",
+		Job:     job,
+	})
+}
+
+// loadActiveJob resolves the :id parameter to a pending or running job. On
+// failure it writes a 404/409 response and returns a nil job with the
+// response's error.
+func (h *AccountsHandler) loadActiveJob(c *fiber.Ctx) (*models.Job, error) {
+	id := c.Params("id")
+
+	job, err := h.db.GetJob(id)
+	if err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Job not found",
+		})
+	}
+
+	// Redis is more up-to-date than the database
+	if redisStatus, err := h.queue.GetJobStatus(id); err == nil && redisStatus != "" {
+		job.Status = models.JobStatus(redisStatus)
+	}
+
+	if job.IsCompleted() {
+		return nil, c.Status(fiber.StatusConflict).JSON(models.JobResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Job cannot be paused or resumed in state '%s'", job.Status),
+		})
+	}
+
+	return job, nil
+}
+
+// RetryJob handles POST /api/jobs/:id/retry
+func (h *AccountsHandler) RetryJob(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var req RetryJobRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.JobResponse{
+				Success: false,
+				Error:   "Invalid request body",
+			})
+		}
+	}
+
+	job, err := h.db.GetJob(id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Job not found",
+		})
+	}
+
+	if !job.CanBeRetried() {
+		return c.Status(fiber.StatusConflict).JSON(models.JobResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Job cannot be retried in state '%s'", job.Status),
+		})
+	}
+
+	if req.AsNew {
+		retry := job.CloneForRetry(uuid.New().String())
+		if err := h.db.CreateJob(retry); err != nil {
+			log.Printf("[AccountsHandler] Failed to create retry of job %s: %v", job.ID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+				Success: false,
+				Error:   "Failed to create retry job",
+			})
+		}
+		job = retry
+	} else {
+		job.ResetForRetry()
+		if err := h.db.UpdateJob(job); err != nil {
+			log.Printf("[AccountsHandler] Failed to reset job %s: %v", job.ID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+				Success: false,
+				Error:   "Failed to retry job",
+			})
+		}
+	}
+
+	if _, err := h.queue.AddJob(*job); err != nil {
+		log.Printf("[AccountsHandler] Failed to enqueue retry of job %s: %v", job.ID, err)
+		job.Fail(fmt.Sprintf("failed to enqueue: %v", err))
+		if err := h.db.UpdateJob(job); err != nil {
+			log.Printf("[AccountsHandler] Failed to mark job %s as failed: %v", job.ID, err)
+		}
+		h.logJob(job.ID, models.JobLogError, "Failed to enqueue retry: %v", err)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to enqueue job",
+			Job:     job,
+		})
+	}
+
+	log.Printf("[AccountsHandler] Job %s retried as %s (as_new: %v)", id, job.ID, req.AsNew)
+	if req.AsNew {
+		h.logJob(job.ID, models.JobLogInfo, "Job created as a retry of %s", id)
+	} else {
+		h.logJob(job.ID, models.JobLogInfo, "Job queued for retry")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(models.JobResponse{
+		Success: true,
+		Message: "Job queued for retry",
+		Job:     job,
+	})
+}
+
+// DeleteJob handles DELETE /api/jobs/:id
+// Only terminal jobs can be deleted. Accounts the job produced are kept
+// unless ?delete_accounts=true is passed.
+func (h *AccountsHandler) DeleteJob(c *fiber.Ctx) error {
+	id := c.Params("id")
+	deleteAccounts := c.QueryBool("delete_accounts", false)
+
+	job, err := h.db.GetJob(id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Job not found",
+		})
+	}
+
+	// Redis is more up-to-date than the database
+	if redisStatus, err := h.queue.GetJobStatus(id); err == nil && redisStatus != "" {
+		job.Status = models.JobStatus(redisStatus)
+	}
+
+	if !job.IsCompleted() {
+		return c.Status(fiber.StatusConflict).JSON(models.JobResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Job cannot be deleted in state '%s'; cancel it first", job.Status),
+		})
+	}
+
+	var accountsDeleted int64
+	if deleteAccounts {
+		accountsDeleted, err = h.db.DeleteJobWithAccounts(id)
+	} else {
+		err = h.db.DeleteJob(id)
+	}
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to delete job %s: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to delete job",
+		})
+	}
+
+	if err := h.queue.RemoveJobArtifacts(id); err != nil {
+		log.Printf("[AccountsHandler] Job %s deleted but Redis cleanup failed: %v", id, err)
+	}
+
+	log.Printf("[AccountsHandler] Job %s deleted (delete_accounts: %v, accounts deleted: %d)", id, deleteAccounts, accountsDeleted)
+
+	message := "Job deleted; associated accounts were kept"
+	if deleteAccounts {
+		message = fmt.Sprintf("Job deleted along with %d associated account(s)", accountsDeleted)
+	}
+
+	return c.JSON(fiber.Map{
+		"success":          true,
+		"message":          message,
+		"job_id":           id,
+		"accounts_kept":    !deleteAccounts,
+		"accounts_deleted": accountsDeleted,
+	})
+}
+
+// GetJobLogs handles GET /api/jobs/:id/logs
+// Returns the job's most recent log lines oldest first. ?level sets the
+// minimum level; ?limit defaults to 100.
+func (h *AccountsHandler) GetJobLogs(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	level := strings.ToLower(c.Query("level"))
+	if level != "" && !models.IsValidJobLogLevel(level) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "level must be one of: debug, info, warn, error",
+		})
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "100"))
+	if limit < 1 || limit > models.MaxJobLogLines {
+		limit = 100
+	}
+
+	if _, err := h.db.GetJob(jobID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Job not found",
+		})
+	}
+
+	logs, err := h.db.ListJobLogs(jobID, level, limit)
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to list logs for job %s: %v", jobID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve job logs",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"job_id":  jobID,
+		"data":    logs,
+		"count":   len(logs),
+	})
+}
+
+// GetJobEvents handles GET /api/jobs/:id/events
+// Returns the job's persisted status timeline, oldest first.
+func (h *AccountsHandler) GetJobEvents(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	if _, err := h.db.GetJob(jobID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Job not found",
+		})
+	}
+
+	events, err := h.db.ListJobEvents(jobID)
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to list events for job %s: %v", jobID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve job events",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"job_id":  jobID,
+		"data":    events,
+		"count":   len(events),
+	})
+}
+
+// logJob appends a line to a job's log and publishes it to live viewers.
+// Failures are logged and never fail the request.
+func (h *AccountsHandler) logJob(jobID, level, format string, args ...interface{}) {
+	entry, err := h.db.AppendJobLog(jobID, level, fmt.Sprintf(format, args...))
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to append log for job %s: %v", jobID, err)
+		return
+	}
+	h.queue.PublishJobLog(entry)
+}
+
+// GetJobStats handles GET /api/jobs/stats
+func (h *AccountsHandler) GetJobStats(c *fiber.Ctx) error {
+	stats, err := h.db.GetJobStats()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve job statistics",
+		})
+	}
+
+	queueStats, err := h.queue.GetQueueStats()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve queue statistics",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":     true,
+		"job_stats":   stats,
+		"queue_stats": queueStats,
+	})
+}
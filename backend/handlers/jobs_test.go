@@ -0,0 +1,574 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"botrix-backend/models"
+	"botrix-backend/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// createTestJob stores a generate job of two accounts in the given status.
+// Finished jobs get start and completion times and an error message.
+func createTestJob(t *testing.T, h *AccountsHandler, status models.JobStatus) *models.Job {
+	t.Helper()
+	job := &models.Job{
+		ID:       fmt.Sprintf("job-%d", time.Now().UnixNano()),
+		Type:     models.JobTypeGenerate,
+		Count:    2,
+		Status:   status,
+		Priority: 2,
+	}
+	if job.IsCompleted() {
+		started := time.Now().Add(-time.Minute)
+		completed := time.Now()
+		job.StartedAt, job.CompletedAt = &started, &completed
+		job.Progress, job.Successful, job.Failed = 100, 1, 1
+		job.ErrorMsg = "worker crashed"
+	}
+	if err := h.db.CreateJob(job); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	return job
+}
+
+// jobRequest sends a request through app and decodes the job response
+func jobRequest(t *testing.T, app *fiber.App, method, target, body string) (int, models.JobResponse) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, target, err)
+	}
+	defer resp.Body.Close()
+
+	var decoded models.JobResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("%s %s: decoding response: %v", method, target, err)
+	}
+	return resp.StatusCode, decoded
+}
+
+func TestRetryJobInPlace(t *testing.T) {
+	h, server := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Post("/api/jobs/:id/retry", h.RetryJob)
+
+	for _, status := range []models.JobStatus{models.JobStatusFailed, models.JobStatusCancelled} {
+		job := createTestJob(t, h, status)
+		code, resp := jobRequest(t, app, http.MethodPost, "/api/jobs/"+job.ID+"/retry", "")
+		if code != fiber.StatusAccepted || !resp.Success || resp.Job == nil || resp.Job.ID != job.ID {
+			t.Fatalf("retrying a %s job: %d %+v, want 202 with the same job", status, code, resp)
+		}
+
+		stored, err := h.db.GetJob(job.ID)
+		if err != nil {
+			t.Fatalf("GetJob: %v", err)
+		}
+		if stored.Status != models.JobStatusPending || stored.Progress != 0 || stored.Successful != 0 || stored.Failed != 0 ||
+			stored.ErrorMsg != "" || stored.StartedAt != nil || stored.CompletedAt != nil {
+			t.Errorf("%s job after retry = %+v, want it reset to pending", status, stored)
+		}
+		if stored.Count != job.Count || stored.Priority != job.Priority {
+			t.Errorf("%s job after retry has count %d priority %d, want %d and %d",
+				status, stored.Count, stored.Priority, job.Count, job.Priority)
+		}
+		if !isQueued(t, server, job.ID) {
+			t.Errorf("retried %s job is not queued", status)
+		}
+	}
+
+	refused := []struct {
+		name   string
+		status models.JobStatus
+		body   string
+		code   int
+	}{
+		{"pending", models.JobStatusPending, "", fiber.StatusConflict},
+		{"running", models.JobStatusRunning, "", fiber.StatusConflict},
+		{"completed", models.JobStatusCompleted, "", fiber.StatusConflict},
+		{"invalid body", models.JobStatusFailed, "{", fiber.StatusBadRequest},
+	}
+	for _, tt := range refused {
+		job := createTestJob(t, h, tt.status)
+		if code, resp := jobRequest(t, app, http.MethodPost, "/api/jobs/"+job.ID+"/retry", tt.body); code != tt.code || resp.Success {
+			t.Errorf("%s: %d %+v, want %d", tt.name, code, resp, tt.code)
+		}
+		if isQueued(t, server, job.ID) {
+			t.Errorf("%s: job was queued by a refused retry", tt.name)
+		}
+	}
+	if code, _ := jobRequest(t, app, http.MethodPost, "/api/jobs/missing/retry", ""); code != fiber.StatusNotFound {
+		t.Errorf("unknown job: status = %d, want 404", code)
+	}
+}
+
+func TestRetryJobAsNew(t *testing.T) {
+	h, server := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Post("/api/jobs/:id/retry", h.RetryJob)
+
+	original := createTestJob(t, h, models.JobStatusFailed)
+	code, resp := jobRequest(t, app, http.MethodPost, "/api/jobs/"+original.ID+"/retry", `{"as_new":true}`)
+	if code != fiber.StatusAccepted || !resp.Success || resp.Job == nil || resp.Job.ID == original.ID {
+		t.Fatalf("retrying as new: %d %+v, want 202 with a new job", code, resp)
+	}
+
+	clone, err := h.db.GetJob(resp.Job.ID)
+	if err != nil {
+		t.Fatalf("GetJob(clone): %v", err)
+	}
+	if clone.Status != models.JobStatusPending || clone.Type != original.Type || clone.Count != original.Count ||
+		clone.Priority != original.Priority || clone.Successful != 0 || clone.ErrorMsg != "" {
+		t.Errorf("clone = %+v, want a pending copy of the original configuration", clone)
+	}
+	if !isQueued(t, server, clone.ID) {
+		t.Error("clone is not queued")
+	}
+
+	// The original is kept as history
+	kept, err := h.db.GetJob(original.ID)
+	if err != nil {
+		t.Fatalf("GetJob(original): %v", err)
+	}
+	if kept.Status != models.JobStatusFailed || kept.ErrorMsg != original.ErrorMsg || kept.Successful != original.Successful {
+		t.Errorf("original after retry = %+v, want it unchanged", kept)
+	}
+	if isQueued(t, server, original.ID) {
+		t.Error("original job was queued again")
+	}
+}
+
+func TestRetryJobEnqueueFailure(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Post("/api/jobs/:id/retry", h.RetryJob)
+
+	h.queue.GetRedisClient().AddHook(&enqueueHook{before: func(int) error {
+		return fmt.Errorf("queue unavailable")
+	}})
+
+	for _, body := range []string{"", `{"as_new":true}`} {
+		original := createTestJob(t, h, models.JobStatusFailed)
+		code, resp := jobRequest(t, app, http.MethodPost, "/api/jobs/"+original.ID+"/retry", body)
+		if code != fiber.StatusServiceUnavailable || resp.Success || resp.Job == nil {
+			t.Fatalf("retry %q with the queue down: %d %+v, want 503 with the job", body, code, resp)
+		}
+
+		// The retried job is failed again rather than left pending off the queue
+		stored, err := h.db.GetJob(resp.Job.ID)
+		if err != nil {
+			t.Fatalf("GetJob: %v", err)
+		}
+		if stored.Status != models.JobStatusFailed || !strings.Contains(stored.ErrorMsg, "queue unavailable") || stored.CompletedAt == nil {
+			t.Errorf("retry %q: job = %s %q, want failed with the enqueue error", body, stored.Status, stored.ErrorMsg)
+		}
+		if body != "" {
+			if resp.Job.ID == original.ID {
+				t.Errorf("retry %q: response job is the original, want the clone", body)
+			}
+			if kept, _ := h.db.GetJob(original.ID); kept == nil || kept.ErrorMsg != original.ErrorMsg {
+				t.Errorf("retry %q: original = %+v, want it unchanged", body, kept)
+			}
+		}
+	}
+}
+
+func TestDeleteJob(t *testing.T) {
+	h, server := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Get("/api/jobs/:id", h.GetJob)
+	app.Delete("/api/jobs/:id", h.DeleteJob)
+
+	// createJobWithAccount stores a job in status with one account it produced
+	n := 0
+	createJobWithAccount := func(status models.JobStatus) (*models.Job, *models.Account) {
+		t.Helper()
+		job := createTestJob(t, h, status)
+		n++
+		account := createTestAccount(t, h, n, time.Time{})
+		account.JobID = job.ID
+		if err := h.db.UpdateAccount(account); err != nil {
+			t.Fatalf("UpdateAccount: %v", err)
+		}
+		return job, account
+	}
+
+	// Only terminal jobs can be deleted
+	for _, status := range []models.JobStatus{models.JobStatusPending, models.JobStatusRunning} {
+		job, _ := createJobWithAccount(status)
+		if code, body := doRequest(t, app, http.MethodDelete, "/api/jobs/"+job.ID, ""); code != fiber.StatusConflict {
+			t.Errorf("deleting a %s job: %d %v, want 409", status, code, body)
+		}
+		if _, err := h.db.GetJob(job.ID); err != nil {
+			t.Errorf("refused delete removed the %s job: %v", status, err)
+		}
+	}
+
+	// Redis knows better than a stale row: a job still running there is kept
+	stale, _ := createJobWithAccount(models.JobStatusFailed)
+	if err := h.queue.UpdateJobStatus(stale.ID, string(models.JobStatusRunning)); err != nil {
+		t.Fatalf("UpdateJobStatus: %v", err)
+	}
+	if code, body := doRequest(t, app, http.MethodDelete, "/api/jobs/"+stale.ID, ""); code != fiber.StatusConflict {
+		t.Errorf("deleting a job Redis reports running: %d %v, want 409", code, body)
+	}
+
+	// By default the accounts are kept
+	job, account := createJobWithAccount(models.JobStatusCompleted)
+	if err := h.queue.UpdateJobStatus(job.ID, string(models.JobStatusCompleted)); err != nil {
+		t.Fatalf("UpdateJobStatus: %v", err)
+	}
+	code, body := doRequest(t, app, http.MethodDelete, "/api/jobs/"+job.ID, "")
+	if code != fiber.StatusOK || body["accounts_kept"] != true || body["accounts_deleted"] != float64(0) {
+		t.Errorf("deleting a completed job: %d %v, want 200 with the accounts kept", code, body)
+	}
+	if _, err := h.db.GetAccount(account.ID); err != nil {
+		t.Errorf("account of the deleted job was removed: %v", err)
+	}
+	if server.Exists(services.JobStatusKey + job.ID) {
+		t.Error("Redis status of the deleted job remains")
+	}
+	if code, _ := doRequest(t, app, http.MethodGet, "/api/jobs/"+job.ID, ""); code != fiber.StatusNotFound {
+		t.Errorf("GET of the deleted job: status = %d, want 404", code)
+	}
+	if code, _ := doRequest(t, app, http.MethodDelete, "/api/jobs/"+job.ID, ""); code != fiber.StatusNotFound {
+		t.Errorf("deleting the job again: status = %d, want 404", code)
+	}
+
+	// delete_accounts=true cascades to the job's accounts only
+	job, account = createJobWithAccount(models.JobStatusCancelled)
+	other := createTestAccount(t, h, 100, time.Time{})
+	code, body = doRequest(t, app, http.MethodDelete, "/api/jobs/"+job.ID+"?delete_accounts=true", "")
+	if code != fiber.StatusOK || body["accounts_kept"] != false || body["accounts_deleted"] != float64(1) {
+		t.Errorf("deleting with delete_accounts: %d %v, want 200 with 1 account deleted", code, body)
+	}
+	if _, err := h.db.GetAccount(account.ID); err == nil {
+		t.Error("account of the cascaded job is still visible")
+	}
+	if _, err := h.db.GetAccount(other.ID); err != nil {
+		t.Errorf("unrelated account was deleted: %v", err)
+	}
+}
+
+func TestCancelJobRemovesItFromTheQueue(t *testing.T) {
+	h, server := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Post("/api/jobs/:id/cancel", h.CancelJob)
+
+	job := createTestJob(t, h, models.JobStatusPending)
+	if _, err := h.queue.AddJob(*job); err != nil {
+		t.Fatalf("AddJob: %v", err)
+	}
+
+	code, resp := jobRequest(t, app, http.MethodPost, "/api/jobs/"+job.ID+"/cancel", "")
+	if code != fiber.StatusOK || !resp.Success || resp.Job == nil || resp.Job.Status != models.JobStatusCancelled {
+		t.Fatalf("cancelling a queued job: %d %+v, want 200 with the job cancelled", code, resp)
+	}
+	if stored, err := h.db.GetJob(job.ID); err != nil || stored.Status != models.JobStatusCancelled || stored.CompletedAt == nil {
+		t.Errorf("stored job = %+v, %v, want cancelled", stored, err)
+	}
+	if status, _ := server.Get(services.JobStatusKey + job.ID); status != string(models.JobStatusCancelled) {
+		t.Errorf("Redis status = %q, want cancelled", status)
+	}
+	if dequeued, err := h.queue.DequeueJob(); err != nil || dequeued != nil {
+		t.Errorf("DequeueJob after cancel = %v, %v, want nothing", dequeued, err)
+	}
+
+	// A pending job Redis no longer knows is cancelled all the same
+	expired := createTestJob(t, h, models.JobStatusPending)
+	if code, resp := jobRequest(t, app, http.MethodPost, "/api/jobs/"+expired.ID+"/cancel", ""); code != fiber.StatusOK || !resp.Success {
+		t.Errorf("cancelling a job missing from Redis: %d %+v, want 200", code, resp)
+	}
+
+	// Terminal jobs keep their state
+	for _, status := range []models.JobStatus{models.JobStatusCompleted, models.JobStatusFailed, models.JobStatusCancelled} {
+		job := createTestJob(t, h, status)
+		if code, resp := jobRequest(t, app, http.MethodPost, "/api/jobs/"+job.ID+"/cancel", ""); code != fiber.StatusBadRequest || resp.Success {
+			t.Errorf("cancelling a %s job: %d %+v, want 400", status, code, resp)
+		}
+		if stored, _ := h.db.GetJob(job.ID); stored == nil || stored.Status != status {
+			t.Errorf("%s job after a refused cancel = %+v, want it unchanged", status, stored)
+		}
+		if server.Exists(services.JobStatusKey + job.ID) {
+			t.Errorf("refused cancel of a %s job wrote a Redis status", status)
+		}
+	}
+	if code, _ := jobRequest(t, app, http.MethodPost, "/api/jobs/missing/cancel", ""); code != fiber.StatusNotFound {
+		t.Errorf("cancelling an unknown job: status = %d, want 404", code)
+	}
+}
+
+func TestGetJobsPagination(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Get("/api/jobs", h.GetJobs)
+
+	// Three failed "nightly" jobs and two pending "manual" ones
+	for i, tt := range []struct {
+		label  string
+		status models.JobStatus
+	}{
+		{"nightly", models.JobStatusFailed},
+		{"nightly", models.JobStatusFailed},
+		{"nightly", models.JobStatusFailed},
+		{"manual", models.JobStatusPending},
+		{"manual", models.JobStatusPending},
+	} {
+		job := &models.Job{ID: fmt.Sprintf("job-%d", i), Type: models.JobTypeGenerate, Count: 1, Label: tt.label, Status: tt.status}
+		if err := h.db.CreateJob(job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query   string
+		count   int
+		total   int64
+		hasMore bool
+	}{
+		{"", 5, 5, false},
+		{"limit=2", 2, 5, true},
+		{"limit=2&offset=2", 2, 5, true},
+		{"limit=2&offset=4", 1, 5, false},
+		{"offset=10", 0, 5, false},
+		{"status=failed", 3, 3, false},
+		{"status=failed&limit=2", 2, 3, true},
+		{"status=FAILED&limit=2&offset=2", 1, 3, false},
+		{"label=manual", 2, 2, false},
+		{"label=manual&status=failed", 0, 0, false},
+	}
+	for _, tt := range tests {
+		code, resp := jobRequest(t, app, http.MethodGet, "/api/jobs?"+tt.query, "")
+		if code != fiber.StatusOK || resp.Pagination == nil {
+			t.Errorf("%q: %d %+v, want 200 with pagination", tt.query, code, resp)
+			continue
+		}
+		p := resp.Pagination
+		if len(resp.Jobs) != tt.count || p.Count != tt.count || p.Total != tt.total || p.HasMore != tt.hasMore {
+			t.Errorf("%q: %d jobs, pagination %+v, want %d jobs of %d total, has_more %v",
+				tt.query, len(resp.Jobs), *p, tt.count, tt.total, tt.hasMore)
+		}
+	}
+
+	// Out-of-range paging values fall back to the defaults
+	if _, resp := jobRequest(t, app, http.MethodGet, "/api/jobs?limit=0&offset=-3", ""); resp.Pagination == nil ||
+		resp.Pagination.Limit != 50 || resp.Pagination.Offset != 0 {
+		t.Errorf("limit=0&offset=-3: pagination = %+v, want limit 50 and offset 0", resp.Pagination)
+	}
+	if _, resp := jobRequest(t, app, http.MethodGet, "/api/jobs?limit=1000", ""); resp.Pagination == nil || resp.Pagination.Limit != 100 {
+		t.Errorf("limit=1000: pagination = %+v, want the limit capped at 100", resp.Pagination)
+	}
+	if code, _ := jobRequest(t, app, http.MethodGet, "/api/jobs?status=queued", ""); code != fiber.StatusBadRequest {
+		t.Errorf("unknown status filter: status = %d, want 400", code)
+	}
+}
+
+func TestGetJobStatsTotals(t *testing.T) {
+	h, _ := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Get("/api/jobs/stats", h.GetJobStats)
+
+	// One high, two normal and one low priority job queued, plus finished ones
+	for i, priority := range []int{2, 1, 1, 0} {
+		job := &models.Job{ID: fmt.Sprintf("queued-%d", i), Type: models.JobTypeGenerate, Count: 1,
+			Status: models.JobStatusPending, Priority: priority}
+		if err := h.db.CreateJob(job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+		if _, err := h.queue.AddJob(*job); err != nil {
+			t.Fatalf("AddJob: %v", err)
+		}
+	}
+	createTestJob(t, h, models.JobStatusCompleted)
+	createTestJob(t, h, models.JobStatusFailed)
+	createTestJob(t, h, models.JobStatusFailed)
+	verify := &models.Job{ID: "verify-1", Type: models.JobTypeVerify, Count: 1, Status: models.JobStatusRunning}
+	if err := h.db.CreateJob(verify); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+
+	code, body := doRequest(t, app, http.MethodGet, "/api/jobs/stats", "")
+	if code != fiber.StatusOK || body["success"] != true {
+		t.Fatalf("GET /api/jobs/stats: %d %v, want 200", code, body)
+	}
+
+	jobStats := body["job_stats"].(map[string]interface{})
+	for key, want := range map[string]float64{
+		"total": 8, "pending": 4, "running": 1, "completed": 1, "failed": 2, "cancelled": 0,
+	} {
+		if jobStats[key] != want {
+			t.Errorf("job_stats.%s = %v, want %v", key, jobStats[key], want)
+		}
+	}
+	if byType := fmt.Sprint(jobStats["by_type"]); byType != "map[generate:7 verify:1]" {
+		t.Errorf("job_stats.by_type = %s, want 7 generate and 1 verify", byType)
+	}
+
+	// Each queued job is counted in exactly one priority bucket
+	queueStats := body["queue_stats"].(map[string]interface{})
+	for key, want := range map[string]float64{
+		"queue_length": 4, "high_priority": 1, "normal_priority": 2, "low_priority": 1,
+	} {
+		if queueStats[key] != want {
+			t.Errorf("queue_stats.%s = %v, want %v", key, queueStats[key], want)
+		}
+	}
+}
+
+func TestGetJobQueuePosition(t *testing.T) {
+	h, server := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Get("/api/jobs/:id", h.GetJob)
+
+	// low is queued first but the high priority job is dequeued before it
+	low := &models.Job{ID: "job-low", Type: models.JobTypeGenerate, Count: 1, Status: models.JobStatusPending, Priority: 0}
+	high := &models.Job{ID: "job-high", Type: models.JobTypeGenerate, Count: 1, Status: models.JobStatusPending, Priority: 2}
+	for _, job := range []*models.Job{low, high} {
+		if err := h.db.CreateJob(job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+		if _, err := h.queue.AddJob(*job); err != nil {
+			t.Fatalf("AddJob: %v", err)
+		}
+	}
+	for id, want := range map[string]float64{high.ID: 1, low.ID: 2} {
+		_, body := doRequest(t, app, http.MethodGet, "/api/jobs/"+id, "")
+		if body["queue_position"] != want || body["desynced"] != nil {
+			t.Errorf("job %s: queue_position = %v, desynced = %v, want %v and no desync hint",
+				id, body["queue_position"], body["desynced"], want)
+		}
+	}
+
+	// A pending job Redis has lost is flagged instead of given a position
+	server.ZRem(services.JobQueueKey, low.ID)
+	_, body := doRequest(t, app, http.MethodGet, "/api/jobs/"+low.ID, "")
+	if position, ok := body["queue_position"]; !ok || position != nil || body["desynced"] != true {
+		t.Errorf("desynced job: queue_position = %v (present %v), desynced = %v, want null and true",
+			position, ok, body["desynced"])
+	}
+
+	// Jobs that are not waiting in the queue have no position
+	running := createTestJob(t, h, models.JobStatusRunning)
+	finished := createTestJob(t, h, models.JobStatusCompleted)
+	for _, job := range []*models.Job{running, finished} {
+		_, body := doRequest(t, app, http.MethodGet, "/api/jobs/"+job.ID, "")
+		if _, ok := body["queue_position"]; ok || body["desynced"] != nil {
+			t.Errorf("%s job: queue_position = %v, desynced = %v, want both omitted", job.Status, body["queue_position"], body["desynced"])
+		}
+	}
+}
+
+func TestUpdateJobPriorityKeepsDatabaseAndRedisInStep(t *testing.T) {
+	h, server := newTestAccountsHandler(t)
+	app := fiber.New()
+	app.Get("/api/jobs/:id", h.GetJob)
+	app.Patch("/api/jobs/:id", h.UpdateJob)
+
+	// queueJob stores and queues a pending job at normal priority
+	queueJob := func(id string) *models.Job {
+		t.Helper()
+		job := &models.Job{ID: id, Type: models.JobTypeGenerate, Count: 1, Status: models.JobStatusPending, Priority: 1}
+		if err := h.db.CreateJob(job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+		if _, err := h.queue.AddJob(*job); err != nil {
+			t.Fatalf("AddJob: %v", err)
+		}
+		return job
+	}
+	// checkPriority asserts the row, the queue score and the queued job data
+	checkPriority := func(id string, priority int) {
+		t.Helper()
+		if stored, err := h.db.GetJob(id); err != nil || stored.Priority != priority {
+			t.Errorf("job %s row = %+v, %v, want priority %d", id, stored, err, priority)
+		}
+		if score, err := server.ZScore(services.JobQueueKey, id); err != nil || score != float64(-priority) {
+			t.Errorf("job %s queue score = %v, %v, want %d", id, score, err, -priority)
+		}
+		var data models.Job
+		raw, _ := server.Get(services.JobDataKey + id)
+		if err := json.Unmarshal([]byte(raw), &data); err != nil || data.Priority != priority {
+			t.Errorf("job %s queued data = %s, want priority %d", id, raw, priority)
+		}
+	}
+
+	first := queueJob("job-a")
+	second := queueJob("job-b")
+
+	// Raising the later job moves it to the front in every store
+	code, body := doRequest(t, app, http.MethodPatch, "/api/jobs/"+second.ID, `{"priority":"high"}`)
+	if code != fiber.StatusOK || body["queue_position"] != float64(1) {
+		t.Fatalf("raising the priority: %d %v, want 200 at queue position 1", code, body)
+	}
+	checkPriority(second.ID, 2)
+	checkPriority(first.ID, 1)
+	if _, body := doRequest(t, app, http.MethodGet, "/api/jobs/"+first.ID, ""); body["queue_position"] != float64(2) {
+		t.Errorf("GET of the other job: queue_position = %v, want 2", body["queue_position"])
+	}
+	if job, err := h.queue.DequeueJob(); err != nil || job == nil || job.ID != second.ID || job.Priority != 2 {
+		t.Errorf("DequeueJob = %+v, %v, want %s at priority 2", job, err, second.ID)
+	}
+
+	// Lowering works the same way
+	if code, body := doRequest(t, app, http.MethodPatch, "/api/jobs/"+first.ID, `{"priority":"low"}`); code != fiber.StatusOK {
+		t.Fatalf("lowering the priority: %d %v, want 200", code, body)
+	}
+	checkPriority(first.ID, 0)
+
+	// A worker took the job but the row still says pending: Redis wins and
+	// nothing changes
+	taken := queueJob("job-c")
+	if err := h.queue.UpdateJobStatus(taken.ID, string(models.JobStatusRunning)); err != nil {
+		t.Fatalf("UpdateJobStatus: %v", err)
+	}
+	if code, body := doRequest(t, app, http.MethodPatch, "/api/jobs/"+taken.ID, `{"priority":"high"}`); code != fiber.StatusConflict {
+		t.Errorf("job running in Redis: %d %v, want 409", code, body)
+	}
+	checkPriority(taken.ID, 1)
+	if _, body := doRequest(t, app, http.MethodGet, "/api/jobs/"+taken.ID, ""); body["status"] != "running" || body["queue_position"] != nil {
+		t.Errorf("GET of the taken job = status %v, queue_position %v, want running without a position",
+			body["status"], body["queue_position"])
+	}
+
+	// The queue lost the job: refused, and GET reports the desync
+	lost := queueJob("job-d")
+	server.ZRem(services.JobQueueKey, lost.ID)
+	if code, body := doRequest(t, app, http.MethodPatch, "/api/jobs/"+lost.ID, `{"priority":"high"}`); code != fiber.StatusConflict {
+		t.Errorf("job missing from the queue: %d %v, want 409", code, body)
+	}
+	if stored, _ := h.db.GetJob(lost.ID); stored == nil || stored.Priority != 1 {
+		t.Errorf("row of the lost job = %+v, want priority 1", stored)
+	}
+	if _, body := doRequest(t, app, http.MethodGet, "/api/jobs/"+lost.ID, ""); body["desynced"] != true {
+		t.Errorf("GET of the lost job: desynced = %v, want true", body["desynced"])
+	}
+
+	refused := []struct {
+		name, target, body string
+		code               int
+	}{
+		{"running row", "/api/jobs/" + createTestJob(t, h, models.JobStatusRunning).ID, `{"priority":"high"}`, fiber.StatusConflict},
+		{"finished row", "/api/jobs/" + createTestJob(t, h, models.JobStatusCompleted).ID, `{"priority":"high"}`, fiber.StatusConflict},
+		{"unknown job", "/api/jobs/missing", `{"priority":"high"}`, fiber.StatusNotFound},
+		{"no priority", "/api/jobs/" + first.ID, `{}`, fiber.StatusBadRequest},
+		{"empty priority", "/api/jobs/" + first.ID, `{"priority":""}`, fiber.StatusBadRequest},
+		{"unknown priority", "/api/jobs/" + first.ID, `{"priority":"urgent"}`, fiber.StatusBadRequest},
+	}
+	for _, tt := range refused {
+		if code, body := doRequest(t, app, http.MethodPatch, tt.target, tt.body); code != tt.code {
+			t.Errorf("%s: %d %v, want %d", tt.name, code, body, tt.code)
+		}
+	}
+	checkPriority(first.ID, 0)
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"botrix-backend/models"
+	"botrix-backend/services"
+	"botrix-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metric names exposed on /metrics. These are a stable contract for
+// dashboards and alerts; rename only with a deprecation period.
+const (
+	// Counter of handled HTTP requests, labeled method, route and status
+	metricHTTPRequests = "botrix_http_requests_total"
+	// Histogram of HTTP request latency in seconds, labeled method and route
+	metricHTTPDuration = "botrix_http_request_duration_seconds"
+
+	// Gauge of jobs waiting in the Redis queue
+	metricQueuePending = "botrix_queue_pending_jobs"
+	// Gauge of jobs currently being processed by workers
+	metricQueueProcessing = "botrix_queue_processing_jobs"
+	// Gauge of queued jobs, labeled priority (low, normal, high)
+	metricQueueByPriority = "botrix_queue_jobs_by_priority"
+
+	// Gauge of jobs recorded in a terminal status, labeled status
+	// (completed, failed, cancelled). Deleting jobs lowers these values.
+	metricJobsFinished = "botrix_jobs_finished"
+
+	// Gauge of connected WebSocket clients
+	metricWebSocketClients = "botrix_websocket_connected_clients"
+
+	// Database connection pool gauges
+	metricDBOpen      = "botrix_db_open_connections"
+	metricDBInUse     = "botrix_db_in_use_connections"
+	metricDBIdle      = "botrix_db_idle_connections"
+	metricDBWaitCount = "botrix_db_wait_count"
+)
+
+// latencyBuckets are the request latency histogram buckets, in seconds
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// unmatchedRoute labels requests that did not match a registered route, so
+// arbitrary paths cannot create unbounded label values
+const unmatchedRoute = "unmatched"
+
+// MetricsHandler exposes Prometheus metrics for the API, queue and database
+type MetricsHandler struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	serve           fiber.Handler
+}
+
+// NewMetricsHandler creates a metrics handler. Queue, job, WebSocket and
+// database gauges are read from their services on each scrape.
+func NewMetricsHandler(db *services.Database, queue *services.QueueService, ws *WebSocketHandler) *MetricsHandler {
+	h := &MetricsHandler{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: metricHTTPRequests,
+			Help: "Total HTTP requests handled.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    metricHTTPDuration,
+			Help:    "HTTP request latency in seconds.",
+			Buckets: latencyBuckets,
+		}, []string{"method", "route"}),
+	}
+
+	h.registry.MustRegister(h.requests, h.requestDuration, &serviceCollector{
+		db:     db,
+		queue:  queue,
+		ws:     ws,
+		logger: utils.GetDefaultLogger().WithComponent("METRICS"),
+	})
+	h.serve = adaptor.HTTPHandler(promhttp.HandlerFor(h.registry, promhttp.HandlerOpts{}))
+
+	return h
+}
+
+// Middleware records request counts and latencies by route pattern
+func (h *MetricsHandler) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The error handler has not written the response yet
+			status = fiber.StatusInternalServerError
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			}
+		}
+
+		// Unmatched paths fall through to the catch-all 404 handler on "/"
+		route := unmatchedRoute
+		if r := c.Route(); r != nil && r.Path != "" && !(r.Path == "/" && status == fiber.StatusNotFound) {
+			route = r.Path
+		}
+
+		h.requests.WithLabelValues(c.Method(), route, strconv.Itoa(status)).Inc()
+		h.requestDuration.WithLabelValues(c.Method(), route).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+// Serve handles GET /metrics through promhttp, which negotiates the
+// exposition format with the scraper
+func (h *MetricsHandler) Serve(c *fiber.Ctx) error {
+	return h.serve(c)
+}
+
+// Descriptions of the gauges read from services on each scrape
+var (
+	descQueuePending = prometheus.NewDesc(metricQueuePending,
+		"Jobs waiting in the queue.", nil, nil)
+	descQueueProcessing = prometheus.NewDesc(metricQueueProcessing,
+		"Jobs currently being processed.", nil, nil)
+	descQueueByPriority = prometheus.NewDesc(metricQueueByPriority,
+		"Queued jobs by priority.", []string{"priority"}, nil)
+	descJobsFinished = prometheus.NewDesc(metricJobsFinished,
+		"Jobs recorded in a terminal status.", []string{"status"}, nil)
+	descWebSocketClients = prometheus.NewDesc(metricWebSocketClients,
+		"Connected WebSocket clients.", nil, nil)
+	descDBOpen = prometheus.NewDesc(metricDBOpen,
+		"Open database connections.", nil, nil)
+	descDBInUse = prometheus.NewDesc(metricDBInUse,
+		"Database connections in use.", nil, nil)
+	descDBIdle = prometheus.NewDesc(metricDBIdle,
+		"Idle database connections.", nil, nil)
+	descDBWaitCount = prometheus.NewDesc(metricDBWaitCount,
+		"Connections waited for since startup.", nil, nil)
+)
+
+// serviceCollector is a prometheus.Collector reading queue, job, WebSocket
+// and database pool gauges from their services when scraped. A source that
+// cannot be reached is logged and its gauges are left out of that scrape.
+type serviceCollector struct {
+	db     *services.Database
+	queue  *services.QueueService
+	ws     *WebSocketHandler
+	logger *utils.Logger
+}
+
+// Describe sends the descriptions of every gauge the collector reports
+func (sc *serviceCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		descQueuePending, descQueueProcessing, descQueueByPriority,
+		descJobsFinished, descWebSocketClients,
+		descDBOpen, descDBInUse, descDBIdle, descDBWaitCount,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect reads each source once and sends its gauges
+func (sc *serviceCollector) Collect(ch chan<- prometheus.Metric) {
+	gauge := func(desc *prometheus.Desc, value float64, labelValues ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labelValues...)
+	}
+
+	if stats, err := sc.queue.GetQueueStats(); err != nil {
+		sc.logger.WithField("error", err.Error()).Warn("Failed to collect queue stats")
+	} else {
+		gauge(descQueuePending, toFloat(stats["queue_length"]))
+		gauge(descQueueProcessing, toFloat(stats["processing_count"]))
+		gauge(descQueueByPriority, toFloat(stats["low_priority"]), "low")
+		gauge(descQueueByPriority, toFloat(stats["normal_priority"]), "normal")
+		gauge(descQueueByPriority, toFloat(stats["high_priority"]), "high")
+	}
+
+	if stats, err := sc.db.GetJobStats(); err != nil {
+		sc.logger.WithField("error", err.Error()).Warn("Failed to collect job stats")
+	} else {
+		gauge(descJobsFinished, float64(stats.Completed), string(models.JobStatusCompleted))
+		gauge(descJobsFinished, float64(stats.Failed), string(models.JobStatusFailed))
+		gauge(descJobsFinished, float64(stats.Cancelled), string(models.JobStatusCancelled))
+	}
+
+	gauge(descWebSocketClients, float64(sc.ws.ClientCount()))
+
+	if stats, err := sc.db.PoolStats(); err != nil {
+		sc.logger.WithField("error", err.Error()).Warn("Failed to collect database pool stats")
+	} else {
+		gauge(descDBOpen, float64(stats.OpenConnections))
+		gauge(descDBInUse, float64(stats.InUse))
+		gauge(descDBIdle, float64(stats.Idle))
+		gauge(descDBWaitCount, float64(stats.WaitCount))
+	}
+}
+
+// toFloat converts a numeric stats value to float64
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"botrix-backend/services"
+	"botrix-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMetricsExposeKeySeries(t *testing.T) {
+	cfg := newTestConfig(t)
+	queue, _ := newTestQueue(t, cfg)
+	db := newTestDatabase(t, cfg)
+	metrics := NewMetricsHandler(db, queue, NewWebSocketHandlerWithLogger(
+		services.NewHub(cfg.WebSocket), queue, db, utils.GetDefaultLogger(), cfg.WebSocket))
+
+	app := fiber.New()
+	app.Use(metrics.Middleware())
+	app.Get("/metrics", metrics.Serve)
+	app.Get("/api/accounts/:id", func(c *fiber.Ctx) error {
+		return c.SendString(c.Params("id"))
+	})
+
+	for _, target := range []string{"/api/accounts/1", "/api/accounts/2", "/no/such/path"} {
+		resp, err := app.Test(httptest.NewRequest("GET", target, nil), -1)
+		if err != nil {
+			t.Fatalf("GET %s: %v", target, err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil), -1)
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("GET /metrics status = %d, want 200", resp.StatusCode)
+	}
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading /metrics: %v", err)
+	}
+	body := string(raw)
+
+	for _, series := range []string{
+		`botrix_http_requests_total{method="GET",route="/api/accounts/:id",status="200"} 2`,
+		`botrix_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`botrix_http_request_duration_seconds_count{method="GET",route="/api/accounts/:id"} 2`,
+		`botrix_http_request_duration_seconds_bucket{method="GET",route="/api/accounts/:id",le="+Inf"} 2`,
+		`botrix_queue_pending_jobs 0`,
+		`botrix_queue_processing_jobs 0`,
+		`botrix_queue_jobs_by_priority{priority="high"} 0`,
+		`botrix_jobs_finished{status="completed"} 0`,
+		`botrix_websocket_connected_clients 0`,
+		`botrix_db_open_connections `,
+		`botrix_db_wait_count `,
+	} {
+		if !strings.Contains(body, series) {
+			t.Errorf("/metrics is missing %q\n%s", series, body)
+		}
+	}
+}
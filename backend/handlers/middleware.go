@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"strconv"
 	"sync"
 	"time"
 
@@ -162,8 +164,8 @@ func (w bodyLogWriter) Write(b []byte) (int, error) {
 // RequestValidator middleware validates common request parameters
 func RequestValidator() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Validate Content-Type for POST/PUT requests
-		if c.Method() == "POST" || c.Method() == "PUT" {
+		// Validate Content-Type for POST/PUT/PATCH requests
+		if c.Method() == "POST" || c.Method() == "PUT" || c.Method() == "PATCH" {
 			contentType := c.Get("Content-Type")
 			if contentType != "" && contentType != "application/json" {
 				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -177,10 +179,11 @@ func RequestValidator() fiber.Handler {
 	}
 }
 
-// RateLimiter is a simple in-memory rate limiter
+// RateLimiter is a simple in-memory rate limiter for one named bucket
 type RateLimiter struct {
 	requests map[string]*clientRequests
 	mu       sync.RWMutex
+	name     string
 	limit    int
 	window   time.Duration
 	logger   *utils.Logger
@@ -198,8 +201,15 @@ func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
 
 // NewRateLimiterWithLogger creates a new rate limiter with custom logger
 func NewRateLimiterWithLogger(limit int, window time.Duration, logger *utils.Logger) *RateLimiter {
+	return NewNamedRateLimiter("default", limit, window, logger)
+}
+
+// NewNamedRateLimiter creates a rate limiter for a bucket; the name appears
+// in its 429 responses and stats
+func NewNamedRateLimiter(name string, limit int, window time.Duration, logger *utils.Logger) *RateLimiter {
 	rl := &RateLimiter{
 		requests: make(map[string]*clientRequests),
+		name:     name,
 		limit:    limit,
 		window:   window,
 		logger:   logger,
@@ -218,65 +228,95 @@ func NewRateLimiterWithLogger(limit int, window time.Duration, logger *utils.Log
 	return rl
 }
 
-// Middleware returns a Fiber middleware handler
+// Middleware returns a Fiber middleware handler. Every response carries
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset (unix
+// seconds when the window ends) so clients can throttle themselves;
+// rejected requests also get Retry-After in seconds.
 func (rl *RateLimiter) Middleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Use IP address as client identifier
 		clientIP := c.IP()
 
-		rl.mu.Lock()
-		defer rl.mu.Unlock()
-
-		now := time.Now()
-		client, exists := rl.requests[clientIP]
+		count, resetTime, allowed := rl.take(clientIP, time.Now())
 
-		if !exists || now.After(client.resetTime) {
-			// First request or window expired, reset
-			rl.requests[clientIP] = &clientRequests{
-				count:     1,
-				resetTime: now.Add(rl.window),
-			}
-
-			rl.logger.WithFields(map[string]interface{}{
-				"ip":     clientIP,
-				"count":  1,
-				"limit":  rl.limit,
-				"window": rl.window.String(),
-			}).Debug("New rate limit window")
-
-			return c.Next()
+		remaining := rl.limit - count
+		if remaining < 0 {
+			remaining = 0
 		}
-
-		// Check if limit exceeded
-		if client.count >= rl.limit {
-			retryAfter := int(time.Until(client.resetTime).Seconds())
+		c.Set("X-RateLimit-Limit", strconv.Itoa(rl.limit))
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Set("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(float64(resetTime.UnixNano())/float64(time.Second))), 10))
+
+		if !allowed {
+			retryAfter := int(math.Ceil(time.Until(resetTime).Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
 
 			rl.logger.WithFields(map[string]interface{}{
+				"bucket":      rl.name,
 				"ip":          clientIP,
-				"count":       client.count,
+				"count":       count,
 				"limit":       rl.limit,
 				"retry_after": retryAfter,
 			}).Warn("Rate limit exceeded")
 
-			c.Set("Retry-After", string(rune(retryAfter)))
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
 			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
 				"success":             false,
 				"error":               "Rate limit exceeded",
 				"message":             "Too many requests, please try again later",
+				"bucket":              rl.name,
 				"retry_after_seconds": retryAfter,
 			})
 		}
 
-		// Increment count
-		client.count++
+		return c.Next()
+	}
+}
+
+// take counts a request from clientIP, reporting the requests counted in
+// the current window, when it ends and whether this one is allowed. The
+// lock is released before the request is handled.
+func (rl *RateLimiter) take(clientIP string, now time.Time) (count int, resetTime time.Time, allowed bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	client, exists := rl.requests[clientIP]
+	if !exists || now.After(client.resetTime) {
+		// First request or window expired, reset
+		client = &clientRequests{
+			count:     1,
+			resetTime: now.Add(rl.window),
+		}
+		rl.requests[clientIP] = client
+
 		rl.logger.WithFields(map[string]interface{}{
-			"ip":    clientIP,
-			"count": client.count,
-			"limit": rl.limit,
-		}).Debug("Rate limit check passed")
+			"bucket": rl.name,
+			"ip":     clientIP,
+			"count":  1,
+			"limit":  rl.limit,
+			"window": rl.window.String(),
+		}).Debug("New rate limit window")
+
+		return client.count, client.resetTime, true
+	}
 
-		return c.Next()
+	// Check if limit exceeded
+	if client.count >= rl.limit {
+		return client.count, client.resetTime, false
 	}
+
+	// Increment count
+	client.count++
+	rl.logger.WithFields(map[string]interface{}{
+		"bucket": rl.name,
+		"ip":     clientIP,
+		"count":  client.count,
+		"limit":  rl.limit,
+	}).Debug("Rate limit check passed")
+
+	return client.count, client.resetTime, true
 }
 
 // cleanup removes expired entries
@@ -316,6 +356,7 @@ func (rl *RateLimiter) GetStats() map[string]interface{} {
 	}
 
 	return map[string]interface{}{
+		"bucket":         rl.name,
 		"total_clients":  totalClients,
 		"active_clients": activeClients,
 		"limit":          rl.limit,
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"math"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"botrix-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// rateLimitHeaders sends one request through app and returns its status
+// with the rate limit headers
+func rateLimitHeaders(t *testing.T, app *fiber.App) (status int, limit, remaining, reset, retryAfter string) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", "/limited", nil))
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode,
+		resp.Header.Get("X-RateLimit-Limit"),
+		resp.Header.Get("X-RateLimit-Remaining"),
+		resp.Header.Get("X-RateLimit-Reset"),
+		resp.Header.Get(fiber.HeaderRetryAfter)
+}
+
+func TestRateLimitHeadersAtTheBoundary(t *testing.T) {
+	const limit = 3
+	window := time.Minute
+	rl := NewNamedRateLimiter("test", limit, window, utils.GetDefaultLogger().WithComponent("RATELIMIT"))
+
+	app := fiber.New()
+	app.Get("/limited", rl.Middleware(), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	// The window opens with the first request, so its reset time is known
+	// to within the time that request took
+	before := time.Now()
+	status, gotLimit, remaining, reset, retryAfter := rateLimitHeaders(t, app)
+	after := time.Now()
+	earliest := int64(math.Ceil(float64(before.Add(window).UnixNano()) / float64(time.Second)))
+	latest := int64(math.Ceil(float64(after.Add(window).UnixNano()) / float64(time.Second)))
+
+	if status != fiber.StatusNoContent || gotLimit != "3" || remaining != "2" || retryAfter != "" {
+		t.Errorf("first request: %d limit=%q remaining=%q retry-after=%q, want 204 3 2 and no Retry-After",
+			status, gotLimit, remaining, retryAfter)
+	}
+	resetAt, err := strconv.ParseInt(reset, 10, 64)
+	if err != nil || resetAt < earliest || resetAt > latest {
+		t.Fatalf("X-RateLimit-Reset = %q, want unix seconds in [%d, %d]", reset, earliest, latest)
+	}
+
+	rateLimitHeaders(t, app)
+
+	// Last allowed request
+	status, gotLimit, remaining, lastReset, retryAfter := rateLimitHeaders(t, app)
+	if status != fiber.StatusNoContent || gotLimit != "3" || remaining != "0" || lastReset != reset || retryAfter != "" {
+		t.Errorf("last allowed request: %d limit=%q remaining=%q reset=%q retry-after=%q, want 204 3 0 %s and no Retry-After",
+			status, gotLimit, remaining, lastReset, retryAfter, reset)
+	}
+
+	// First rejected request: the window has just under a minute left
+	status, gotLimit, remaining, rejectedReset, retryAfter := rateLimitHeaders(t, app)
+	if status != fiber.StatusTooManyRequests || gotLimit != "3" || remaining != "0" || rejectedReset != reset {
+		t.Errorf("first rejected request: %d limit=%q remaining=%q reset=%q, want 429 3 0 %s",
+			status, gotLimit, remaining, rejectedReset, reset)
+	}
+	if retryAfter != "60" {
+		t.Errorf("Retry-After = %q, want %q", retryAfter, "60")
+	}
+}
+
+func TestRateLimitWindowBoundary(t *testing.T) {
+	rl := NewNamedRateLimiter("test", 2, time.Minute, utils.GetDefaultLogger().WithComponent("RATELIMIT"))
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	resetAt := start.Add(time.Minute)
+
+	steps := []struct {
+		at      time.Time
+		count   int
+		reset   time.Time
+		allowed bool
+	}{
+		{start, 1, resetAt, true},
+		{start.Add(time.Second), 2, resetAt, true},
+		{start.Add(2 * time.Second), 2, resetAt, false},
+		// The reset instant itself still belongs to the old window
+		{resetAt, 2, resetAt, false},
+		{resetAt.Add(time.Nanosecond), 1, resetAt.Add(time.Minute + time.Nanosecond), true},
+	}
+	for i, step := range steps {
+		count, reset, allowed := rl.take("203.0.113.7", step.at)
+		if count != step.count || !reset.Equal(step.reset) || allowed != step.allowed {
+			t.Errorf("step %d: take = %d %v %v, want %d %v %v", i, count, reset, allowed, step.count, step.reset, step.allowed)
+		}
+	}
+
+	// Other clients have windows of their own
+	if count, _, allowed := rl.take("203.0.113.8", start.Add(2*time.Second)); count != 1 || !allowed {
+		t.Errorf("another client: take = %d %v, want 1 true", count, allowed)
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+
+	"botrix-backend/config"
+	"botrix-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Rate limit buckets applied to the API
+const (
+	RateLimitGenerate = "generate" // account generation
+	RateLimitWrite    = "write"    // every other mutation
+	RateLimitRead     = "read"     // every GET
+)
+
+// RateLimiters holds one RateLimiter per configured bucket. Buckets count
+// independently, so a request passing through two of them uses up both.
+type RateLimiters struct {
+	buckets map[string]*RateLimiter
+}
+
+// NewRateLimiters creates a limiter for every configured bucket
+func NewRateLimiters(cfg config.RateLimitConfig, logger *utils.Logger) *RateLimiters {
+	limiters := &RateLimiters{buckets: make(map[string]*RateLimiter, len(cfg.Buckets))}
+	for name, limit := range cfg.Buckets {
+		limiters.buckets[name] = NewNamedRateLimiter(name, limit.Limit, limit.Window, logger)
+	}
+	return limiters
+}
+
+// Middleware returns the middleware for a bucket. An unknown name is a
+// wiring mistake, so it panics at startup rather than leaving routes
+// unlimited.
+func (rl *RateLimiters) Middleware(name string) fiber.Handler {
+	limiter, ok := rl.buckets[name]
+	if !ok {
+		panic(fmt.Sprintf("rate limit bucket %q is not configured", name))
+	}
+	return limiter.Middleware()
+}
+
+// ByMethod returns middleware applying the read bucket to GET and HEAD
+// requests and the write bucket to other methods. Preflight OPTIONS
+// requests are not counted.
+func (rl *RateLimiters) ByMethod(read, write string) fiber.Handler {
+	readLimit := rl.Middleware(read)
+	writeLimit := rl.Middleware(write)
+
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodOptions:
+			return c.Next()
+		case fiber.MethodGet, fiber.MethodHead:
+			return readLimit(c)
+		default:
+			return writeLimit(c)
+		}
+	}
+}
+
+// GetStats returns every bucket's statistics, ordered by name
+func (rl *RateLimiters) GetStats() []map[string]interface{} {
+	names := make([]string, 0, len(rl.buckets))
+	for name := range rl.buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	stats := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		stats = append(stats, rl.buckets[name].GetStats())
+	}
+	return stats
+}
@@ -1,6 +1,11 @@
 package handlers
 
 import (
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+
 	"botrix-backend/models"
 	"botrix-backend/services"
 	"botrix-backend/utils"
@@ -11,18 +16,54 @@ import (
 // SettingsHandler handles settings-related HTTP requests
 type SettingsHandler struct {
 	db     *services.Database
+	queue  *services.QueueService
+	imap   *services.IMAPChecker
+	smtp   *services.SMTPChecker
+	rapid  *services.RapidAPIChecker
 	logger *utils.Logger
 }
 
+// IMAPTestRequest overrides stored IMAP settings for a connection test.
+// Omitted fields, and a password sent back masked, use the stored values.
+type IMAPTestRequest struct {
+	Server   string `json:"imap_server"`
+	Port     int    `json:"imap_port"`
+	Username string `json:"imap_username"`
+	Password string `json:"imap_password"`
+}
+
+// SMTPTestRequest overrides stored SMTP settings for a connection test.
+// Omitted fields, and a password sent back masked, use the stored values.
+// With SendTest set, a test message is sent to SendTo.
+type SMTPTestRequest struct {
+	Server   string `json:"smtp_server"`
+	Port     int    `json:"smtp_port"`
+	Username string `json:"smtp_username"`
+	Password string `json:"smtp_password"`
+	SendTest bool   `json:"send_test"`
+	SendTo   string `json:"send_to"`
+}
+
+// RapidAPITestRequest overrides the stored RapidAPI key for a key check.
+// An omitted or masked key uses the stored one.
+type RapidAPITestRequest struct {
+	RapidAPIKey string `json:"rapidapi_key"`
+}
+
 // NewSettingsHandler creates a new settings handler
-func NewSettingsHandler(db *services.Database) *SettingsHandler {
+func NewSettingsHandler(db *services.Database, queue *services.QueueService) *SettingsHandler {
 	return &SettingsHandler{
 		db:     db,
+		queue:  queue,
+		imap:   services.NewIMAPChecker(),
+		smtp:   services.NewSMTPChecker(),
+		rapid:  services.NewRapidAPIChecker(nil),
 		logger: utils.GetDefaultLogger().WithComponent("SETTINGS"),
 	}
 }
 
-// GetSettings returns the current application settings
+// GetSettings returns the current application settings. Secrets are
+// masked unless ?reveal=true is given.
 // GET /api/settings
 func (h *SettingsHandler) GetSettings(c *fiber.Ctx) error {
 	settings, err := h.db.GetSettings()
@@ -39,7 +80,7 @@ func (h *SettingsHandler) GetSettings(c *fiber.Ctx) error {
 
 	return c.JSON(fiber.Map{
 		"success": true,
-		"data":    settings.ToResponse(),
+		"data":    settings.ToResponse(c.QueryBool("reveal")),
 	})
 }
 
@@ -58,11 +99,26 @@ func (h *SettingsHandler) SaveSettings(c *fiber.Ctx) error {
 		})
 	}
 
-	// Validate required fields (optional - add your validation logic here)
-	// For example, validate RapidAPI key format, email credentials, etc.
+	// Full saves are validated like partial updates and rollbacks
+	if err := input.Validate(); err != nil {
+		return invalidSettings(c, "Invalid settings", err)
+	}
+
+	// Masked secrets echoed back from GetSettings mean "unchanged"
+	current, err := h.db.GetSettings()
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to get settings")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to save settings",
+			"message": err.Error(),
+		})
+	}
+	input.KeepMaskedSecrets(current)
 
 	// Save settings to database
-	if err := h.db.SaveSettings(&input); err != nil {
+	revision, err := h.db.SaveSettings(&input, settingsChangedBy(c))
+	if err != nil {
 		h.logger.WithFields(map[string]interface{}{
 			"error": err.Error(),
 		}).Error("Failed to save settings")
@@ -74,6 +130,7 @@ func (h *SettingsHandler) SaveSettings(c *fiber.Ctx) error {
 	}
 
 	h.logger.Info("Settings saved successfully")
+	h.publishSettingsUpdated(revision)
 
 	// Fetch updated settings to return
 	updatedSettings, err := h.db.GetSettings()
@@ -89,6 +146,464 @@ func (h *SettingsHandler) SaveSettings(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Settings saved successfully",
-		"data":    updatedSettings.ToResponse(),
+		"data":    updatedSettings.ToResponse(false),
+	})
+}
+
+// UpdateSettings changes only the settings fields present in the request
+// body, leaving the rest (notably stored secrets) untouched
+// PATCH /api/settings
+func (h *SettingsHandler) UpdateSettings(c *fiber.Ctx) error {
+	var req models.SettingsUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return invalidSettings(c, "Invalid settings", err)
+	}
+
+	settings, err := h.db.GetSettings()
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to get settings")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve settings",
+		})
+	}
+
+	req.ApplyTo(settings)
+	revision, err := h.db.SaveSettings(settings, settingsChangedBy(c))
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to save settings")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to save settings",
+		})
+	}
+
+	h.logger.Info("Settings updated successfully")
+	h.publishSettingsUpdated(revision)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Settings updated successfully",
+		"data":    settings.ToResponse(false),
+	})
+}
+
+// GetHistory returns recent settings revisions, newest first, with secrets
+// masked
+// GET /api/settings/history
+func (h *SettingsHandler) GetHistory(c *fiber.Ctx) error {
+	limit, _ := strconv.Atoi(c.Query("limit", strconv.Itoa(models.MaxSettingRevisions)))
+	if limit < 1 || limit > models.MaxSettingRevisions {
+		limit = models.MaxSettingRevisions
+	}
+
+	revisions, err := h.db.ListSettingRevisions(limit)
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to list settings revisions")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve settings history",
+		})
+	}
+
+	history := make([]models.SettingRevisionResponse, len(revisions))
+	for i := range revisions {
+		history[i] = models.SettingRevisionResponse{
+			ID:        revisions[i].ID,
+			CreatedAt: revisions[i].CreatedAt,
+			ChangedBy: revisions[i].ChangedBy,
+			Changes:   revisions[i].Changes,
+			Summary:   revisions[i].Summary,
+			Settings:  revisions[i].Snapshot.Response(),
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    history,
+	})
+}
+
+// Rollback restores the settings saved in a revision. The restore is
+// validated like any other update and recorded as a new revision.
+// POST /api/settings/rollback/:id
+func (h *SettingsHandler) Rollback(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid revision ID",
+		})
+	}
+
+	revision, err := h.db.GetSettingRevision(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Settings revision not found",
+		})
+	}
+
+	req := revision.Snapshot.UpdateRequest()
+	if err := req.Validate(); err != nil {
+		return invalidSettings(c, fmt.Sprintf("Revision %d cannot be restored", revision.ID), err)
+	}
+
+	settings, err := h.db.GetSettings()
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to get settings")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve settings",
+		})
+	}
+
+	req.ApplyTo(settings)
+	changedBy := fmt.Sprintf("%s (rollback to revision %d)", settingsChangedBy(c), revision.ID)
+	restored, err := h.db.SaveSettings(settings, changedBy)
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to save settings")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to save settings",
+		})
+	}
+
+	h.logger.WithField("revision_id", revision.ID).Info("Settings rolled back")
+	h.publishSettingsUpdated(restored)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": fmt.Sprintf("Settings restored from revision %d", revision.ID),
+		"data":    settings.ToResponse(false),
+	})
+}
+
+// ExportSettings downloads the settings as a JSON file. Secrets are
+// redacted unless ?include_secrets=true is given.
+// GET /api/settings/export
+func (h *SettingsHandler) ExportSettings(c *fiber.Ctx) error {
+	settings, err := h.db.GetSettings()
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to get settings")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve settings",
+		})
+	}
+
+	includeSecrets := c.QueryBool("include_secrets")
+	export := models.NewSettingsExport(settings, includeSecrets)
+
+	h.logger.WithField("include_secrets", includeSecrets).Info("Settings exported")
+
+	filename := fmt.Sprintf("botrix_settings_%s.json", export.ExportedAt.Format("2006-01-02"))
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	return c.JSON(export)
+}
+
+// ImportSettings applies a settings export file as a new revision. Every
+// field is validated; redacted secrets keep their current values.
+// POST /api/settings/import
+func (h *SettingsHandler) ImportSettings(c *fiber.Ctx) error {
+	var export models.SettingsExport
+	if err := c.BodyParser(&export); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid settings file",
+		})
+	}
+
+	req, err := export.UpdateRequest()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return invalidSettings(c, "Invalid settings file", err)
+	}
+
+	settings, err := h.db.GetSettings()
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to get settings")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve settings",
+		})
+	}
+
+	req.ApplyTo(settings)
+	changedBy := fmt.Sprintf("%s (import)", settingsChangedBy(c))
+	revision, err := h.db.SaveSettings(settings, changedBy)
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to save settings")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to save settings",
+		})
+	}
+
+	h.logger.Info("Settings imported")
+	h.publishSettingsUpdated(revision)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Settings imported successfully",
+		"data":    settings.ToResponse(false),
+	})
+}
+
+// invalidSettings writes a 422 response listing each invalid field
+func invalidSettings(c *fiber.Ctx, message string, err error) error {
+	response := fiber.Map{
+		"success": false,
+		"error":   message,
+	}
+	if validationErr, ok := err.(*models.SettingsValidationError); ok {
+		response["fields"] = validationErr.Fields
+	} else {
+		response["message"] = err.Error()
+	}
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(response)
+}
+
+// settingsChangedBy identifies who made a settings change. There are no
+// user accounts yet, so the client address is recorded.
+func settingsChangedBy(c *fiber.Ctx) string {
+	return c.IP()
+}
+
+// publishSettingsUpdated announces a saved revision on the updates channel
+// so the UI can confirm it and workers re-read their settings. Only field
+// names are sent; values, secret or not, stay behind the API. Saves that
+// changed nothing are not announced.
+func (h *SettingsHandler) publishSettingsUpdated(revision *models.SettingRevision) {
+	if h.queue == nil || revision == nil || len(revision.Changes) == 0 {
+		return
+	}
+	h.queue.PublishEvent(services.SettingsUpdatedEvent, map[string]interface{}{
+		"revision_id": revision.ID,
+		"changes":     revision.Changes,
+	})
+}
+
+// TestIMAP logs in to the IMAP server and selects INBOX, using the stored
+// settings overridden by any fields in the request body
+// POST /api/settings/test-imap
+func (h *SettingsHandler) TestIMAP(c *fiber.Ctx) error {
+	var req IMAPTestRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "Invalid request body",
+			})
+		}
+	}
+
+	settings, err := h.db.GetSettings()
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to get settings")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve settings",
+		})
+	}
+
+	creds := services.IMAPCredentials{
+		Server:   settings.IMAPServer,
+		Port:     settings.IMAPPort,
+		Username: settings.IMAPUsername,
+		Password: settings.IMAPPassword,
+	}
+	if req.Server != "" {
+		creds.Server = req.Server
+	}
+	if req.Port != 0 {
+		creds.Port = req.Port
+	}
+	if req.Username != "" {
+		creds.Username = req.Username
+	}
+	if req.Password != "" && req.Password != models.MaskSecret(settings.IMAPPassword) {
+		creds.Password = req.Password
+	}
+
+	// The password is deliberately left out of every log line
+	checkLogger := h.logger.WithFields(map[string]interface{}{
+		"server":   creds.Server,
+		"port":     creds.Port,
+		"username": creds.Username,
+	})
+
+	count, err := h.imap.Check(c.UserContext(), creds)
+	if err != nil {
+		checkErr, ok := err.(*services.IMAPCheckError)
+		if !ok {
+			checkErr = &services.IMAPCheckError{Kind: services.IMAPErrorProtocol, Message: err.Error()}
+		}
+		checkLogger.WithField("kind", checkErr.Kind).Warn("IMAP connection test failed")
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"success": false,
+			"error":   "IMAP connection test failed",
+			"details": checkErr,
+		})
+	}
+
+	checkLogger.Info("IMAP connection test succeeded")
+
+	return c.JSON(fiber.Map{
+		"success":       true,
+		"message":       "IMAP connection succeeded",
+		"message_count": count,
+	})
+}
+
+// TestSMTP connects to the SMTP server, upgrades to TLS and authenticates,
+// using the stored settings overridden by any fields in the request body.
+// With send_test it also sends a test message to send_to.
+// POST /api/settings/test-smtp
+func (h *SettingsHandler) TestSMTP(c *fiber.Ctx) error {
+	var req SMTPTestRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "Invalid request body",
+			})
+		}
+	}
+
+	var sendTo string
+	if req.SendTest {
+		addr, err := mail.ParseAddress(strings.TrimSpace(req.SendTo))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "send_to must be a valid email address when send_test is set",
+			})
+		}
+		sendTo = addr.Address
+	}
+
+	settings, err := h.db.GetSettings()
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to get settings")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve settings",
+		})
+	}
+
+	creds := services.SMTPCredentials{
+		Server:   settings.SMTPServer,
+		Port:     settings.SMTPPort,
+		Username: settings.SMTPUsername,
+		Password: settings.SMTPPassword,
+	}
+	if req.Server != "" {
+		creds.Server = req.Server
+	}
+	if req.Port != 0 {
+		creds.Port = req.Port
+	}
+	if req.Username != "" {
+		creds.Username = req.Username
+	}
+	if req.Password != "" && req.Password != models.MaskSecret(settings.SMTPPassword) {
+		creds.Password = req.Password
+	}
+
+	// The password is deliberately left out of every log line
+	checkLogger := h.logger.WithFields(map[string]interface{}{
+		"server":    creds.Server,
+		"port":      creds.Port,
+		"username":  creds.Username,
+		"send_test": req.SendTest,
+	})
+
+	if err := h.smtp.Check(c.UserContext(), creds, sendTo); err != nil {
+		checkErr, ok := err.(*services.SMTPCheckError)
+		if !ok {
+			checkErr = &services.SMTPCheckError{Stage: services.SMTPStageConnect, Message: err.Error()}
+		}
+		checkLogger.WithFields(map[string]interface{}{
+			"stage":     checkErr.Stage,
+			"timed_out": checkErr.TimedOut,
+		}).Warn("SMTP connection test failed")
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"success": false,
+			"error":   "SMTP connection test failed",
+			"details": checkErr,
+		})
+	}
+
+	checkLogger.Info("SMTP connection test succeeded")
+
+	message := "SMTP connection succeeded"
+	if req.SendTest {
+		message = "SMTP connection succeeded and a test message was sent"
+	}
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"message":   message,
+		"test_sent": req.SendTest,
+	})
+}
+
+// TestRapidAPI checks that the RapidAPI key is accepted, using the stored
+// key unless the request body overrides it. The key is never returned.
+// POST /api/settings/test-rapidapi
+func (h *SettingsHandler) TestRapidAPI(c *fiber.Ctx) error {
+	var req RapidAPITestRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "Invalid request body",
+			})
+		}
+	}
+
+	settings, err := h.db.GetSettings()
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to get settings")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve settings",
+		})
+	}
+
+	key := settings.RapidAPIKey
+	if req.RapidAPIKey != "" && req.RapidAPIKey != models.MaskSecret(settings.RapidAPIKey) {
+		key = req.RapidAPIKey
+	}
+
+	result, err := h.rapid.Check(c.UserContext(), key)
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Warn("RapidAPI key check failed")
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"success": false,
+			"error":   "Could not reach RapidAPI",
+		})
+	}
+
+	h.logger.WithFields(map[string]interface{}{
+		"valid":       result.Valid,
+		"status_code": result.StatusCode,
+		"latency_ms":  result.LatencyMS,
+	}).Info("RapidAPI key checked")
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    result,
 	})
 }
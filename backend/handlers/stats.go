@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"botrix-backend/services"
+	"botrix-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// statsSectionTimeout bounds how long the summary waits for any one section
+const statsSectionTimeout = 2 * time.Second
+
+// StatsHandler serves dashboard statistics gathered from several sources
+type StatsHandler struct {
+	db          *services.Database
+	queue       *services.QueueService
+	ws          *WebSocketHandler
+	rateLimiter *RateLimiters
+	logger      *utils.Logger
+}
+
+// NewStatsHandler creates a new stats handler
+func NewStatsHandler(db *services.Database, queue *services.QueueService, ws *WebSocketHandler, rateLimiter *RateLimiters) *StatsHandler {
+	return &StatsHandler{
+		db:          db,
+		queue:       queue,
+		ws:          ws,
+		rateLimiter: rateLimiter,
+		logger:      utils.GetDefaultLogger().WithComponent("STATS"),
+	}
+}
+
+// statsSection produces one section of the summary
+type statsSection func() (interface{}, error)
+
+// sectionResult is a finished summary section
+type sectionResult struct {
+	name string
+	data interface{}
+	err  error
+}
+
+// GetSummary handles GET /api/stats/summary
+// Sections are gathered concurrently; a section that fails or exceeds
+// statsSectionTimeout is reported as {"error": ...} instead of failing the
+// whole response.
+func (h *StatsHandler) GetSummary(c *fiber.Ctx) error {
+	sections := map[string]statsSection{
+		"accounts": func() (interface{}, error) {
+			return h.db.GetAccountStats()
+		},
+		"jobs": func() (interface{}, error) {
+			return h.db.GetJobStats()
+		},
+		"queue": func() (interface{}, error) {
+			return h.queue.GetQueueStats()
+		},
+		"websocket": func() (interface{}, error) {
+			return fiber.Map{"connected_clients": h.ws.ClientCount()}, nil
+		},
+		"rate_limiter": func() (interface{}, error) {
+			return h.rateLimiter.GetStats(), nil
+		},
+	}
+
+	// Buffered so sections that finish after the deadline never block
+	results := make(chan sectionResult, len(sections))
+	for name, section := range sections {
+		go func(name string, section statsSection) {
+			data, err := section()
+			results <- sectionResult{name: name, data: data, err: err}
+		}(name, section)
+	}
+
+	summary := fiber.Map{}
+	degraded := false
+	deadline := time.After(statsSectionTimeout)
+
+collect:
+	for range sections {
+		select {
+		case result := <-results:
+			if result.err != nil {
+				h.logger.WithField("section", result.name).WithField("error", result.err.Error()).Warn("Stats section failed")
+				summary[result.name] = fiber.Map{"error": "unavailable"}
+				degraded = true
+				continue
+			}
+			summary[result.name] = result.data
+		case <-deadline:
+			break collect
+		}
+	}
+
+	for name := range sections {
+		if _, ok := summary[name]; !ok {
+			h.logger.WithField("section", name).Warn("Stats section timed out")
+			summary[name] = fiber.Map{"error": fmt.Sprintf("timed out after %s", statsSectionTimeout)}
+			degraded = true
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"degraded":  degraded,
+		"data":      summary,
+		"timestamp": time.Now(),
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// statsCache serves a recently computed StatsResponse. A fresh entry is
+// returned as is; a stale one is returned while a single background refresh
+// runs. Requests without a usable entry share one computation, so
+// concurrent misses do not stampede the database.
+type statsCache struct {
+	ttl     time.Duration
+	compute func() (*StatsResponse, error)
+
+	mu         sync.Mutex
+	entry      *StatsResponse
+	inflight   *statsCall
+	refreshing bool
+}
+
+// statsCall is a computation shared by every request waiting on it
+type statsCall struct {
+	done chan struct{}
+	resp *StatsResponse
+	err  error
+}
+
+// newStatsCache creates a cache around compute. A ttl of zero disables
+// caching, though concurrent requests still share one computation.
+func newStatsCache(ttl time.Duration, compute func() (*StatsResponse, error)) *statsCache {
+	return &statsCache{
+		ttl:     ttl,
+		compute: compute,
+	}
+}
+
+// Get returns cached stats, or computes them when there are none or fresh
+// is set
+func (s *statsCache) Get(fresh bool) (*StatsResponse, error) {
+	s.mu.Lock()
+
+	if !fresh && s.ttl > 0 && s.entry != nil {
+		entry := s.entry
+		if time.Since(entry.GeneratedAt) >= s.ttl && !s.refreshing && s.inflight == nil {
+			s.refreshing = true
+			go s.refresh()
+		}
+		s.mu.Unlock()
+		return entry, nil
+	}
+
+	call := s.inflight
+	if call == nil {
+		call = &statsCall{done: make(chan struct{})}
+		s.inflight = call
+		go s.run(call)
+	}
+	s.mu.Unlock()
+
+	<-call.done
+	return call.resp, call.err
+}
+
+// run performs a shared computation and stores its result
+func (s *statsCache) run(call *statsCall) {
+	call.resp, call.err = s.compute()
+
+	s.mu.Lock()
+	if call.err == nil {
+		s.entry = call.resp
+	}
+	s.inflight = nil
+	s.mu.Unlock()
+
+	close(call.done)
+}
+
+// refresh recomputes a stale entry in the background. On failure the entry
+// is dropped so the next request computes synchronously and sees the error
+// rather than being served increasingly old stats.
+func (s *statsCache) refresh() {
+	resp, err := s.compute()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshing = false
+	if err != nil {
+		log.Printf("[AccountsHandler] Background stats refresh failed: %v", err)
+		s.entry = nil
+		return
+	}
+	// A ?fresh=true request may have stored a newer entry meanwhile
+	if s.entry == nil || resp.GeneratedAt.After(s.entry.GeneratedAt) {
+		s.entry = resp
+	}
+}
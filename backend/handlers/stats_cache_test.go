@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingCompute returns a compute function that blocks until release is
+// closed, counting its calls. Each result carries the call number.
+func countingCompute(calls *int32, release <-chan struct{}) func() (*StatsResponse, error) {
+	return func() (*StatsResponse, error) {
+		n := atomic.AddInt32(calls, 1)
+		<-release
+		return &StatsResponse{Success: true, TotalAccounts: int64(n), GeneratedAt: time.Now()}, nil
+	}
+}
+
+// waitForCalls waits until compute has been called want times
+func waitForCalls(t *testing.T, calls *int32, want int32) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(calls) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("compute called %d times, want %d", atomic.LoadInt32(calls), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStatsCacheConcurrentMissesComputeOnce(t *testing.T) {
+	for _, ttl := range []time.Duration{time.Minute, 0} {
+		var calls int32
+		release := make(chan struct{})
+		cache := newStatsCache(ttl, countingCompute(&calls, release))
+
+		const requests = 20
+		results := make([]*StatsResponse, requests)
+		var started, done sync.WaitGroup
+		started.Add(requests)
+		done.Add(requests)
+		for i := 0; i < requests; i++ {
+			go func(i int) {
+				defer done.Done()
+				started.Done()
+				resp, err := cache.Get(false)
+				if err != nil {
+					t.Errorf("Get: %v", err)
+				}
+				results[i] = resp
+			}(i)
+		}
+
+		// Hold the computation until every request is waiting on it
+		started.Wait()
+		waitForCalls(t, &calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		done.Wait()
+
+		if n := atomic.LoadInt32(&calls); n != 1 {
+			t.Errorf("ttl %s: compute called %d times for %d concurrent misses, want 1", ttl, n, requests)
+		}
+		for i, resp := range results {
+			if resp != results[0] {
+				t.Errorf("ttl %s: request %d got %p, want the shared result %p", ttl, i, resp, results[0])
+			}
+		}
+	}
+}
+
+func TestStatsCacheServesFreshAndStaleEntries(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	close(release)
+	cache := newStatsCache(time.Hour, countingCompute(&calls, release))
+
+	first, err := cache.Get(false)
+	if err != nil || first.TotalAccounts != 1 {
+		t.Fatalf("first Get = %+v, %v, want the first computation", first, err)
+	}
+	if again, _ := cache.Get(false); again != first || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("fresh entry: Get = %+v after %d computations, want the cached entry", again, calls)
+	}
+
+	// ?fresh=true bypasses the cache and replaces the entry
+	bypass, err := cache.Get(true)
+	if err != nil || bypass.TotalAccounts != 2 {
+		t.Fatalf("Get(fresh) = %+v, %v, want a second computation", bypass, err)
+	}
+	if again, _ := cache.Get(false); again != bypass {
+		t.Errorf("after Get(fresh): Get = %+v, want the fresh entry", again)
+	}
+
+	// A stale entry is served at once while one background refresh runs
+	cache.mu.Lock()
+	cache.entry.GeneratedAt = time.Now().Add(-2 * time.Hour)
+	cache.mu.Unlock()
+	for i := 0; i < 5; i++ {
+		if stale, _ := cache.Get(false); stale != bypass {
+			t.Errorf("stale Get %d = %+v, want the stale entry", i, stale)
+		}
+	}
+	waitForCalls(t, &calls, 3)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if resp, _ := cache.Get(false); resp.TotalAccounts == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("refreshed entry never served")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("compute called %d times, want one background refresh", n)
+	}
+}
+
+func TestStatsCacheDoesNotKeepErrors(t *testing.T) {
+	var calls int32
+	fail := true
+	cache := newStatsCache(time.Hour, func() (*StatsResponse, error) {
+		atomic.AddInt32(&calls, 1)
+		if fail {
+			return nil, errors.New("database down")
+		}
+		return &StatsResponse{Success: true, GeneratedAt: time.Now()}, nil
+	})
+
+	if resp, err := cache.Get(false); err == nil || resp != nil {
+		t.Fatalf("Get with a failing compute = %+v, %v, want the error", resp, err)
+	}
+	fail = false
+	if resp, err := cache.Get(false); err != nil || resp == nil {
+		t.Errorf("Get after recovery = %+v, %v, want stats", resp, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("compute called %d times, want the failure retried", n)
+	}
+}
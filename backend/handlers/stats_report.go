@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"botrix-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// statsReportDefaultDays is the report range when from is omitted
+const statsReportDefaultDays = 7
+
+// statsReportColumns is the header row of the stats report CSV
+var statsReportColumns = []string{
+	"date", "accounts_created", "jobs_run", "jobs_completed", "jobs_failed",
+	"success_rate", "avg_job_duration_seconds",
+}
+
+// GetStatsReport handles GET /api/stats/report
+// It reports per-day accounts created, jobs run, success rate and average
+// job duration for the calendar days from ?from to ?to (dates or RFC3339,
+// default the last 7 days, at most 92 days) in the reporting timezone,
+// followed by a totals row. ?format=csv (default) downloads the report;
+// ?format=json returns the same rows.
+func (h *AccountsHandler) GetStatsReport(c *fiber.Ctx) error {
+	format := strings.ToLower(c.Query("format", "csv"))
+	if format != "csv" && format != "json" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "format must be csv or json",
+		})
+	}
+
+	loc := h.config.ReportingLocation()
+	start, days, err := parseReportRange(c, loc)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+
+	rows, totals, err := h.db.GetStatsReport(start, days, loc)
+	if err != nil {
+		log.Printf("[AccountsHandler] Failed to build stats report: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve statistics",
+		})
+	}
+
+	if format == "json" {
+		return c.JSON(fiber.Map{
+			"success":  true,
+			"timezone": loc.String(),
+			"from":     start,
+			"to":       start.AddDate(0, 0, days),
+			"data":     rows,
+			"totals":   totals,
+		})
+	}
+
+	last := start.AddDate(0, 0, days-1)
+	filename := fmt.Sprintf("botrix_stats_report_%s_to_%s.csv", start.Format("2006-01-02"), last.Format("2006-01-02"))
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	out := csv.NewWriter(c)
+	out.Write(statsReportColumns)
+	for _, row := range rows {
+		out.Write(statsReportRecord(row))
+	}
+	out.Write(statsReportRecord(totals))
+	out.Flush()
+
+	return out.Error()
+}
+
+// parseReportRange resolves ?from and ?to to the midnight, in loc, starting
+// the first reported day and the number of days covered
+func parseReportRange(c *fiber.Ctx, loc *time.Location) (time.Time, int, error) {
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		t, err := parseDateParam(value, loc, true)
+		if err != nil {
+			return time.Time{}, 0, fmt.Errorf("invalid to: %v", err)
+		}
+		to = t
+	}
+	last := models.DayStart(to, loc)
+
+	start := last.AddDate(0, 0, -(statsReportDefaultDays - 1))
+	if value := c.Query("from"); value != "" {
+		t, err := parseDateParam(value, loc, false)
+		if err != nil {
+			return time.Time{}, 0, fmt.Errorf("invalid from: %v", err)
+		}
+		start = models.DayStart(t, loc)
+	}
+
+	if start.After(last) {
+		return time.Time{}, 0, fmt.Errorf("from must be earlier than or equal to to")
+	}
+
+	// Count calendar days rather than dividing durations, which DST skews
+	days := 1
+	for day := start; day.Before(last); day = day.AddDate(0, 0, 1) {
+		days++
+		if days > models.MaxReportDays {
+			return time.Time{}, 0, fmt.Errorf("range must not exceed %d days", models.MaxReportDays)
+		}
+	}
+
+	return start, days, nil
+}
+
+// statsReportRecord formats a report row as a CSV record matching
+// statsReportColumns
+func statsReportRecord(row models.StatsReportRow) []string {
+	return []string{
+		row.Date,
+		strconv.FormatInt(row.AccountsCreated, 10),
+		strconv.FormatInt(row.JobsRun, 10),
+		strconv.FormatInt(row.JobsCompleted, 10),
+		strconv.FormatInt(row.JobsFailed, 10),
+		strconv.FormatFloat(row.SuccessRate, 'f', 2, 64),
+		strconv.FormatFloat(row.AvgJobDurationSeconds, 'f', 1, 64),
+	}
+}
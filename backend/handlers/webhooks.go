@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"botrix-backend/config"
+	"botrix-backend/models"
+	"botrix-backend/services"
+	"botrix-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WebhooksHandler handles webhook management requests
+type WebhooksHandler struct {
+	db     *services.Database
+	config *config.Config
+	logger *utils.Logger
+}
+
+// NewWebhooksHandler creates a new webhooks handler
+func NewWebhooksHandler(db *services.Database, cfg *config.Config) *WebhooksHandler {
+	return &WebhooksHandler{
+		db:     db,
+		config: cfg,
+		logger: utils.GetDefaultLogger().WithComponent("WEBHOOKS"),
+	}
+}
+
+// ListWebhooks returns all webhooks with secrets masked
+// GET /api/webhooks
+func (h *WebhooksHandler) ListWebhooks(c *fiber.Ctx) error {
+	webhooks, err := h.db.ListWebhooks()
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to list webhooks")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve webhooks",
+		})
+	}
+
+	for i := range webhooks {
+		webhooks[i].HideSecret()
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    webhooks,
+	})
+}
+
+// GetWebhook returns a single webhook with its secret masked
+// GET /api/webhooks/:id
+func (h *WebhooksHandler) GetWebhook(c *fiber.Ctx) error {
+	webhook, err := h.loadWebhook(c)
+	if webhook == nil {
+		return err
+	}
+
+	webhook.HideSecret()
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    webhook,
+	})
+}
+
+// CreateWebhook registers a new webhook. A signing secret is generated when
+// none is given; it is only returned in this response.
+// POST /api/webhooks
+func (h *WebhooksHandler) CreateWebhook(c *fiber.Ctx) error {
+	var req models.WebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+
+	if req.URL == nil || strings.TrimSpace(*req.URL) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "url is required",
+		})
+	}
+	if err := h.validate(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+
+	webhook := &models.Webhook{Enabled: true}
+	req.ApplyTo(webhook)
+	if webhook.Secret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			h.logger.WithField("error", err.Error()).Error("Failed to generate webhook secret")
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"error":   "Failed to create webhook",
+			})
+		}
+		webhook.Secret = secret
+	}
+
+	if err := h.db.CreateWebhook(webhook); err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to create webhook")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to create webhook",
+		})
+	}
+
+	h.logger.WithField("webhook_id", webhook.ID).Info("Webhook created")
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    webhook,
+	})
+}
+
+// UpdateWebhook changes the provided fields of a webhook
+// PUT /api/webhooks/:id
+func (h *WebhooksHandler) UpdateWebhook(c *fiber.Ctx) error {
+	webhook, err := h.loadWebhook(c)
+	if webhook == nil {
+		return err
+	}
+
+	var req models.WebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+	if err := h.validate(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+
+	req.ApplyTo(webhook)
+	if err := h.db.UpdateWebhook(webhook); err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to update webhook")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to update webhook",
+		})
+	}
+
+	h.logger.WithField("webhook_id", webhook.ID).Info("Webhook updated")
+
+	webhook.HideSecret()
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    webhook,
+	})
+}
+
+// DeleteWebhook removes a webhook
+// DELETE /api/webhooks/:id
+func (h *WebhooksHandler) DeleteWebhook(c *fiber.Ctx) error {
+	webhook, err := h.loadWebhook(c)
+	if webhook == nil {
+		return err
+	}
+
+	if err := h.db.DeleteWebhook(webhook.ID); err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to delete webhook")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to delete webhook",
+		})
+	}
+
+	h.logger.WithField("webhook_id", webhook.ID).Info("Webhook deleted")
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Webhook deleted",
+	})
+}
+
+// ListDeliveries returns a webhook's delivery attempts, newest first
+// GET /api/webhooks/:id/deliveries
+func (h *WebhooksHandler) ListDeliveries(c *fiber.Ctx) error {
+	webhook, err := h.loadWebhook(c)
+	if webhook == nil {
+		return err
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	deliveries, err := h.db.ListWebhookDeliveries(webhook.ID, limit, offset)
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to list webhook deliveries")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to retrieve deliveries",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    deliveries,
+		"pagination": fiber.Map{
+			"limit":  limit,
+			"offset": offset,
+			"count":  len(deliveries),
+		},
+	})
+}
+
+// loadWebhook resolves the :id parameter. On failure it writes a 400/404
+// response and returns a nil webhook with the response's error.
+func (h *WebhooksHandler) loadWebhook(c *fiber.Ctx) (*models.Webhook, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid webhook ID",
+		})
+	}
+
+	webhook, err := h.db.GetWebhook(uint(id))
+	if err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Webhook not found",
+		})
+	}
+
+	return webhook, nil
+}
+
+// validate checks the provided fields of a webhook request
+func (h *WebhooksHandler) validate(req *models.WebhookRequest) error {
+	if req.URL != nil {
+		trimmed := strings.TrimSpace(*req.URL)
+		req.URL = &trimmed
+		if err := validateOutboundURL("url", trimmed, h.config.IsProduction()); err != nil {
+			return err
+		}
+	}
+	return req.ValidateEvents()
+}
+
+// generateWebhookSecret returns a random hex signing secret
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
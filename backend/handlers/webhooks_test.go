@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"botrix-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCreateWebhookEnabledFlag(t *testing.T) {
+	cfg := newTestConfig(t)
+	db := newTestDatabase(t, cfg)
+	h := NewWebhooksHandler(db, cfg)
+	app := fiber.New()
+	app.Post("/api/webhooks", h.CreateWebhook)
+
+	create := func(body string) uint {
+		t.Helper()
+		status, resp := doRequest(t, app, "POST", "/api/webhooks", body)
+		if status != fiber.StatusCreated {
+			t.Fatalf("POST /api/webhooks %s: status %d, body %v", body, status, resp)
+		}
+		return uint(resp["data"].(map[string]interface{})["id"].(float64))
+	}
+	disabled := create(`{"url": "https://example.com/disabled", "enabled": false}`)
+	defaulted := create(`{"url": "https://example.com/default"}`)
+	enabled := create(`{"url": "https://example.com/enabled", "enabled": true}`)
+
+	for id, want := range map[uint]bool{disabled: false, defaulted: true, enabled: true} {
+		webhook, err := db.GetWebhook(id)
+		if err != nil {
+			t.Fatalf("GetWebhook(%d): %v", id, err)
+		}
+		if webhook.Enabled != want {
+			t.Errorf("webhook %s stored with enabled = %v, want %v", webhook.URL, webhook.Enabled, want)
+		}
+		if got := webhook.Matches(models.WebhookEventAccountBanned); got != want {
+			t.Errorf("webhook %s Matches = %v, want %v", webhook.URL, got, want)
+		}
+	}
+
+	listed, err := db.ListEnabledWebhooks()
+	if err != nil {
+		t.Fatalf("ListEnabledWebhooks: %v", err)
+	}
+	var ids []uint
+	for _, webhook := range listed {
+		ids = append(ids, webhook.ID)
+	}
+	if fmt.Sprint(ids) != fmt.Sprint([]uint{defaulted, enabled}) {
+		t.Errorf("enabled webhooks = %v, want %v", ids, []uint{defaulted, enabled})
+	}
+}
@@ -2,252 +2,403 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/rand"
+	"errors"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"botrix-backend/config"
+	"botrix-backend/services"
 	"botrix-backend/utils"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 )
 
 // WebSocketMessage represents the structure of messages sent to clients
-type WebSocketMessage struct {
-	Type   string                 `json:"type"`
-	JobID  string                 `json:"job_id,omitempty"`
-	Status string                 `json:"status,omitempty"`
-	Data   map[string]interface{} `json:"data,omitempty"`
-}
+type WebSocketMessage = services.HubMessage
+
+// AllJobs is the subscription wildcard: a client subscribed to it receives
+// updates for every job
+const AllJobs = services.AllJobs
+
+// AccountUpdateMessage is the type of messages relayed from the account
+// updates channel; their "event" field names the lifecycle event
+const AccountUpdateMessage = "account_update"
 
-// Client represents a connected WebSocket client
+// Client is a WebSocket connection registered with the hub. The embedded
+// HubClient holds its subscriptions, filter, encoding and send buffer.
 type Client struct {
-	ID         string
-	Conn       *websocket.Conn
-	SendChan   chan []byte
-	DisconnCh  chan bool
-	LastActive time.Time
+	*services.HubClient
+	Conn      *websocket.Conn
+	Principal WebSocketPrincipal
+
+	// lastStatsRequest is when the client last sent get_stats; only the
+	// read pump touches it
+	lastStatsRequest time.Time
+}
+
+// Ping sends a WebSocket ping for the hub's liveness check. WriteControl
+// may be called alongside the write pump.
+func (c *Client) Ping() error {
+	return c.Conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(10*time.Second))
+}
+
+// wsClient returns the connection behind a hub client, if it is one of
+// ours
+func wsClient(hc *services.HubClient) (*Client, bool) {
+	client, ok := hc.Conn.(*Client)
+	return client, ok
 }
 
-// WebSocketHandler manages WebSocket connections and Redis subscriptions
+// WebSocketHandler adapts the hub to Fiber: it accepts connections, runs
+// their read and write pumps and relays job updates from Redis to the hub
 type WebSocketHandler struct {
-	clients      map[string]*Client
-	clientsMutex sync.RWMutex
-	register     chan *Client
-	unregister   chan *Client
-	broadcast    chan []byte
-	redisClient  *redis.Client
-	ctx          context.Context
-	logger       *utils.Logger
+	hub    *services.Hub
+	ownHub bool // created by the legacy constructor, so stopped by Shutdown
+	queue  *services.QueueService
+	db     *services.Database
+	ctx    context.Context
+	cancel context.CancelFunc
+	logger *utils.Logger
+
+	// closing is set under connMutex once Shutdown starts; pumps counts the
+	// read and write pumps of accepted connections and workers the Redis
+	// goroutine
+	connMutex sync.Mutex
+	closing   bool
+	pumps     sync.WaitGroup
+	workers   sync.WaitGroup
+
+	// connections counts accepted connections until their read pump ends,
+	// under connMutex; beyond maxConnections (0 = no cap) new ones are
+	// refused
+	connections    int
+	maxConnections int
+
+	// upgrades limits connection attempts per IP; nil when unlimited
+	upgrades *connectLimiter
+
+	// subscribed is closed once the Redis subscription is first confirmed;
+	// subscriptionHealthy tracks whether it is currently up
+	subscribed          chan struct{}
+	subscribedOnce      sync.Once
+	subscriptionHealthy atomic.Bool
+
+	// coalesceWindow is how long progress events for a job are merged
+	// before being sent; counters track what the relay receives and sends
+	coalesceWindow time.Duration
+	counters       relayCounters
+
+	// compressionLevel applies to connections that negotiated
+	// permessage-deflate; 0 keeps the library default
+	compressionLevel int
+
+	// statsPush tracks clients that asked for periodic stats, fetched from
+	// statsSource
+	statsPush   statsPusher
+	statsSource func() (*StatsResponse, error)
+
+	// enricher adds job progress and counts to status-change events
+	enricher *jobEnricher
 }
 
-// NewWebSocketHandler creates a new WebSocket handler (legacy)
-func NewWebSocketHandler(redisClient *redis.Client) *WebSocketHandler {
-	return NewWebSocketHandlerWithLogger(redisClient, utils.GetDefaultLogger().WithComponent("WEBSOCKET"))
+// NewWebSocketHandler creates a new WebSocket handler (legacy) with a hub
+// of its own. Without a database, get_stats leaves out job stats.
+func NewWebSocketHandler(queue *services.QueueService) *WebSocketHandler {
+	cfg := config.WebSocketConfig{
+		ReplayBufferSize:   services.DefaultReplayBufferSize,
+		CoalesceWindow:     DefaultCoalesceWindow,
+		SlowClientMaxDrops: services.DefaultSlowClientMaxDrops,
+		SlowClientWindow:   services.DefaultSlowClientWindow,
+	}
+	handler := NewWebSocketHandlerWithLogger(services.NewHub(cfg), queue, nil, utils.GetDefaultLogger().WithComponent("WEBSOCKET"), cfg)
+	handler.ownHub = true
+	return handler
 }
 
-// NewWebSocketHandlerWithLogger creates a new WebSocket handler with custom logger
-func NewWebSocketHandlerWithLogger(redisClient *redis.Client, logger *utils.Logger) *WebSocketHandler {
+// NewWebSocketHandlerWithLogger creates a new WebSocket handler with custom
+// logger, serving the hub's clients and coalescing by cfg. Job updates
+// arrive through the queue's subscription; the queue and database also
+// answer get_stats requests.
+func NewWebSocketHandlerWithLogger(hub *services.Hub, queue *services.QueueService, db *services.Database, logger *utils.Logger, cfg config.WebSocketConfig) *WebSocketHandler {
+	ctx, cancel := context.WithCancel(context.Background())
 	handler := &WebSocketHandler{
-		clients:     make(map[string]*Client),
-		register:    make(chan *Client),
-		unregister:  make(chan *Client),
-		broadcast:   make(chan []byte, 256),
-		redisClient: redisClient,
-		ctx:         context.Background(),
-		logger:      logger,
+		hub:        hub,
+		queue:      queue,
+		db:         db,
+		ctx:        ctx,
+		cancel:     cancel,
+		logger:     logger,
+		subscribed: make(chan struct{}),
+
+		coalesceWindow: cfg.CoalesceWindow,
+
+		compressionLevel: cfg.CompressionLevel,
+		maxConnections:   cfg.MaxConnections,
+		upgrades:         newConnectLimiter(cfg.ConnectRate, cfg.ConnectBurst, cfg.ConnectExemptLoopback),
+		enricher:         newJobEnricher(nil),
+	}
+	if db != nil {
+		handler.enricher = newJobEnricher(db.GetJobContext)
 	}
-
-	// Start the hub goroutine
-	go handler.run()
 
 	// Start Redis subscriber
+	handler.workers.Add(1)
 	go handler.subscribeToRedis()
 
-	// Start ping ticker
-	go handler.pingClients()
-
 	return handler
 }
 
-// run handles client registration, unregistration, and broadcasting
-func (h *WebSocketHandler) run() {
-	for {
-		select {
-		case client := <-h.register:
-			h.clientsMutex.Lock()
-			h.clients[client.ID] = client
-			total := len(h.clients)
-			h.clientsMutex.Unlock()
+// Subscribed returns a channel that is closed once the handler's Redis
+// subscription is confirmed. It stays open if subscribing failed.
+func (h *WebSocketHandler) Subscribed() <-chan struct{} {
+	return h.subscribed
+}
 
-			h.logger.WithFields(map[string]interface{}{
-				"client_id": client.ID,
-				"total":     total,
-			}).Info("Client registered")
+// Shutdown closes every connection with a going-away close frame and stops
+// the handler. New connections are refused from the start; the write pumps
+// get until ctx's deadline to drain, after which remaining connections are
+// closed outright. The Redis subscription stops last; a shared hub is left
+// running for its owner to stop.
+func (h *WebSocketHandler) Shutdown(ctx context.Context) error {
+	h.connMutex.Lock()
+	h.closing = true
+	h.connMutex.Unlock()
+
+	clients := h.clients()
+	h.logger.WithField("clients", len(clients)).Info("Shutting down WebSocket handler")
+
+	// Clients answer the close frame, which ends their read pumps and
+	// unregisters them
+	for _, client := range clients {
+		h.sendGoingAway(client.Conn)
+	}
 
-		case client := <-h.unregister:
-			h.clientsMutex.Lock()
-			if _, ok := h.clients[client.ID]; ok {
-				delete(h.clients, client.ID)
-				close(client.SendChan)
-				total := len(h.clients)
-				h.clientsMutex.Unlock()
+	var err error
+	if !waitGroupDone(ctx, &h.pumps) {
+		err = ctx.Err()
+		for _, client := range h.clients() {
+			client.Conn.Close()
+		}
+		h.logger.Warn("WebSocket clients did not close in time, connections dropped")
+	}
 
-				h.logger.WithFields(map[string]interface{}{
-					"client_id": client.ID,
-					"total":     total,
-				}).Info("Client unregistered")
-			} else {
-				h.clientsMutex.Unlock()
-			}
+	h.cancel()
+	if !waitGroupDone(ctx, &h.workers) && err == nil {
+		err = ctx.Err()
+	}
+	if h.ownHub {
+		if stopErr := h.hub.Stop(ctx); stopErr != nil && err == nil {
+			err = stopErr
+		}
+	}
+	if err != nil {
+		return err
+	}
 
-		case message := <-h.broadcast:
-			h.clientsMutex.RLock()
-			clientCount := len(h.clients)
-			for _, client := range h.clients {
-				select {
-				case client.SendChan <- message:
-					// Message sent successfully
-				default:
-					// Channel is full, close the client
-					close(client.SendChan)
-					delete(h.clients, client.ID)
-					h.logger.WithField("client_id", client.ID).Warn("Client removed due to slow consumer")
-				}
-			}
-			h.clientsMutex.RUnlock()
+	h.logger.Info("WebSocket handler stopped")
+	return nil
+}
 
-			if clientCount > 0 {
-				h.logger.WithField("clients", clientCount).Debug("Message broadcasted")
-			}
+// clients returns the hub's clients that are WebSocket connections
+func (h *WebSocketHandler) clients() []*Client {
+	hubClients := h.hub.Clients()
+	clients := make([]*Client, 0, len(hubClients))
+	for _, hc := range hubClients {
+		if client, ok := wsClient(hc); ok {
+			clients = append(clients, client)
 		}
 	}
+	return clients
 }
 
-// subscribeToRedis subscribes to Redis pub/sub channel for job updates
-func (h *WebSocketHandler) subscribeToRedis() {
-	pubsub := h.redisClient.Subscribe(h.ctx, "botrix:jobs:updates")
-	defer pubsub.Close()
-
-	h.logger.Info("Subscribed to Redis channel: botrix:jobs:updates")
+// sendGoingAway sends the close frame telling a client the server is
+// restarting
+func (h *WebSocketHandler) sendGoingAway(conn *websocket.Conn) {
+	h.sendClose(conn, websocket.CloseGoingAway, "server restarting")
+}
 
-	// Wait for confirmation that subscription is created
-	_, err := pubsub.Receive(h.ctx)
-	if err != nil {
-		h.logger.WithField("error", err.Error()).Error("Failed to subscribe to Redis channel")
-		return
+// sendClose sends a close frame. WriteControl may be called alongside the
+// write pump.
+func (h *WebSocketHandler) sendClose(conn *websocket.Conn, code int, reason string) {
+	frame := websocket.FormatCloseMessage(code, reason)
+	if err := conn.WriteControl(websocket.CloseMessage, frame, time.Now().Add(time.Second)); err != nil {
+		h.logger.WithField("error", err.Error()).Debug("Failed to send close frame")
 	}
+}
 
-	// Listen for messages
-	ch := pubsub.Channel()
-	for msg := range ch {
-		// Parse the Redis message
-		var redisData map[string]interface{}
-		if err := json.Unmarshal([]byte(msg.Payload), &redisData); err != nil {
-			h.logger.WithField("error", err.Error()).Error("Failed to parse Redis message")
-			continue
-		}
+// waitGroupDone waits for wg, giving up when ctx is done; it reports
+// whether wg finished
+func waitGroupDone(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
 
-		// Create WebSocket message
-		wsMessage := WebSocketMessage{
-			Type:   "job_update",
-			JobID:  getStringValue(redisData, "job_id"),
-			Status: getStringValue(redisData, "status"),
-			Data:   redisData,
-		}
+var (
+	// errShuttingDown is returned by accept once Shutdown has started
+	errShuttingDown = errors.New("websocket handler is shutting down")
 
-		// Broadcast to all connected clients
-		messageBytes, err := json.Marshal(wsMessage)
-		if err != nil {
-			h.logger.WithField("error", err.Error()).Error("Failed to marshal WebSocket message")
-			continue
-		}
+	// errTooManyConnections is returned by accept at the connection cap
+	errTooManyConnections = errors.New("too many websocket connections")
+)
 
-		h.broadcast <- messageBytes
+// accept counts a new connection and its pumps and picks a client ID not
+// in use. It refuses the connection at the connection cap, and once
+// Shutdown has started so Shutdown never waits on connections it did not
+// see.
+func (h *WebSocketHandler) accept() (string, error) {
+	h.connMutex.Lock()
+	defer h.connMutex.Unlock()
+	if h.closing {
+		return "", errShuttingDown
+	}
+	if h.maxConnections > 0 && h.connections >= h.maxConnections {
+		return "", errTooManyConnections
+	}
+	h.connections++
 
-		h.logger.WithFields(map[string]interface{}{
-			"job_id":  wsMessage.JobID,
-			"status":  wsMessage.Status,
-			"clients": len(h.clients),
-		}).Debug("Job update broadcasted")
+	id := generateClientID()
+	for h.hub.Has(id) {
+		id = generateClientID()
 	}
+	h.pumps.Add(2)
+	return id, nil
 }
 
-// pingClients sends ping messages to all clients every 30 seconds
-func (h *WebSocketHandler) pingClients() {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+// release frees a connection's slot under the cap once its read pump ends
+func (h *WebSocketHandler) release() {
+	h.connMutex.Lock()
+	defer h.connMutex.Unlock()
+	h.connections--
+}
 
-	for range ticker.C {
-		h.clientsMutex.RLock()
-		inactiveClients := make([]*Client, 0)
+// isClosing reports whether Shutdown has started
+func (h *WebSocketHandler) isClosing() bool {
+	h.connMutex.Lock()
+	defer h.connMutex.Unlock()
+	return h.closing
+}
 
-		for _, client := range h.clients {
-			// Check if client has been inactive for too long (2 minutes)
-			if time.Since(client.LastActive) > 2*time.Minute {
-				h.logger.WithFields(map[string]interface{}{
-					"client_id": client.ID,
-					"inactive":  time.Since(client.LastActive).String(),
-				}).Debug("Client inactive for too long, disconnecting")
-				inactiveClients = append(inactiveClients, client)
-				continue
-			}
+// emit hands a message to the hub, which numbers it, records it for replay
+// and sends it to the clients that want it. It returns false once the hub
+// is stopped.
+func (h *WebSocketHandler) emit(message WebSocketMessage) bool {
+	if !h.hub.Broadcast(message) {
+		return false
+	}
+	h.counters.out.Add(1)
 
-			// Send ping
-			if err := client.Conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(10*time.Second)); err != nil {
-				h.logger.WithFields(map[string]interface{}{
-					"client_id": client.ID,
-					"error":     err.Error(),
-				}).Debug("Failed to send ping, client will be disconnected")
-				inactiveClients = append(inactiveClients, client)
-			}
-		}
-		h.clientsMutex.RUnlock()
+	h.logger.WithFields(map[string]interface{}{
+		"job_id": message.JobID,
+		"status": message.Status,
+	}).Debug("Job update broadcasted")
+	return true
+}
 
-		// Unregister inactive clients
-		for _, client := range inactiveClients {
-			h.unregister <- client
-		}
+// HandleWebSocket upgrades HTTP connection to WebSocket
+func (h *WebSocketHandler) HandleWebSocket(c *websocket.Conn) {
+	id, err := h.accept()
+	if err == errTooManyConnections {
+		h.logger.WithField("max_connections", h.maxConnections).Warn("Refused WebSocket connection at the connection cap")
+		h.sendClose(c, websocket.CloseTryAgainLater, "try again later")
+		c.Close()
+		return
+	}
+	if err != nil {
+		h.sendGoingAway(c)
+		c.Close()
+		return
+	}
+	defer h.release()
 
-		if len(h.clients) > 0 {
-			h.logger.WithField("active_clients", len(h.clients)).Debug("Ping check completed")
+	// Only takes effect when the client negotiated permessage-deflate;
+	// other connections keep sending uncompressed frames
+	if h.compressionLevel != 0 {
+		if err := c.SetCompressionLevel(h.compressionLevel); err != nil {
+			h.logger.WithField("error", err.Error()).Warn("Invalid WebSocket compression level")
 		}
 	}
-}
 
-// HandleWebSocket upgrades HTTP connection to WebSocket
-func (h *WebSocketHandler) HandleWebSocket(c *websocket.Conn) {
 	// Create new client
-	client := &Client{
-		ID:         generateClientID(),
-		Conn:       c,
-		SendChan:   make(chan []byte, 256),
-		DisconnCh:  make(chan bool),
-		LastActive: time.Now(),
+	client := &Client{Conn: c}
+	client.HubClient = services.NewHubClient(id, client, c.RemoteAddr().String())
+	// WebSocketAuth stores the principal before the upgrade
+	if principal, ok := c.Locals(wsPrincipalKey).(WebSocketPrincipal); ok {
+		client.Principal = principal
+	} else {
+		client.Principal = WebSocketPrincipal{Method: WSAuthAnonymous}
 	}
 
 	h.logger.WithFields(map[string]interface{}{
 		"client_id":   client.ID,
-		"remote_addr": c.RemoteAddr().String(),
+		"remote_addr": client.RemoteAddr,
 		"local_addr":  c.LocalAddr().String(),
+		"subject":     client.Principal.Subject,
+		"auth":        client.Principal.Method,
 	}).Info("New WebSocket connection established")
 
-	// Register client
-	h.register <- client
+	// accept picked a free ID, but two connections accepted together could
+	// still draw the same one; the hub never replaces a registered client
+	if err := h.hub.Register(client.HubClient); err != nil {
+		h.logger.WithField("client_id", client.ID).Warn("Rejected client with a duplicate ID")
+		h.pumps.Add(-2)
+		c.Close()
+		return
+	}
+
+	// Registered after Shutdown took its snapshot, so close it here
+	if h.isClosing() {
+		h.sendGoingAway(c)
+	} else {
+		h.sendHello(client)
+	}
+
+	h.logger.WithFields(map[string]interface{}{
+		"client_id": client.ID,
+		"total":     h.hub.ClientCount(),
+	}).Info("Client registered")
 
 	// Start the write pump in a new goroutine
-	go h.writePump(client)
+	writeDone := make(chan struct{})
+	go func() {
+		defer close(writeDone)
+		defer h.pumps.Done()
+		h.writePump(client)
+	}()
 
 	// Run the read pump in the current goroutine (blocking)
+	defer h.pumps.Done()
 	h.readPump(client)
+
+	// The connection is recycled once this handler returns, so wait for
+	// the write pump; readPump has unregistered the client and closed the
+	// connection, which ends it
+	<-writeDone
 }
 
 // readPump reads messages from the WebSocket connection
 func (h *WebSocketHandler) readPump(client *Client) {
 	defer func() {
 		h.logger.WithField("client_id", client.ID).Debug("ReadPump exiting, unregistering client")
-		h.unregister <- client
+		h.statsPush.remove(client)
+		if h.hub.Unregister(client.HubClient) {
+			h.logger.WithFields(map[string]interface{}{
+				"client_id": client.ID,
+				"total":     h.hub.ClientCount(),
+			}).Info("Client unregistered")
+		}
 		client.Conn.Close()
 	}()
 
@@ -256,7 +407,7 @@ func (h *WebSocketHandler) readPump(client *Client) {
 
 	// Handle pong messages from client's pings
 	client.Conn.SetPongHandler(func(string) error {
-		client.LastActive = time.Now()
+		client.Touch()
 		client.Conn.SetReadDeadline(time.Now().Add(70 * time.Second))
 		h.logger.WithField("client_id", client.ID).Debug("Received pong from client")
 		return nil
@@ -264,7 +415,7 @@ func (h *WebSocketHandler) readPump(client *Client) {
 
 	// Handle ping messages from client (respond with pong)
 	client.Conn.SetPingHandler(func(data string) error {
-		client.LastActive = time.Now()
+		client.Touch()
 		client.Conn.SetReadDeadline(time.Now().Add(70 * time.Second))
 		h.logger.WithField("client_id", client.ID).Debug("Received ping from client, sending pong")
 
@@ -296,38 +447,116 @@ func (h *WebSocketHandler) readPump(client *Client) {
 			break
 		}
 
-		client.LastActive = time.Now()
+		client.Touch()
 		client.Conn.SetReadDeadline(time.Now().Add(70 * time.Second))
 
-		// Handle incoming messages
-		if messageType == websocket.TextMessage {
-			// Try to parse as JSON
-			var msg map[string]interface{}
-			if err := json.Unmarshal(message, &msg); err == nil {
-				msgType, _ := msg["type"].(string)
-
-				// Handle ping messages
-				if msgType == "ping" {
-					h.logger.WithField("client_id", client.ID).Debug("Received ping, sending pong")
-
-					// Send pong response
-					pongMsg := map[string]interface{}{
-						"type":      "pong",
-						"timestamp": time.Now().UnixMilli(),
-					}
-					if pongBytes, err := json.Marshal(pongMsg); err == nil {
-						client.SendChan <- pongBytes
-					}
-					continue
-				}
+		h.handleClientMessage(client, messageType, message)
+	}
+}
 
-				h.logger.WithFields(map[string]interface{}{
-					"client_id": client.ID,
-					"type":      msgType,
-					"message":   string(message),
-				}).Debug("Received message from client")
-			}
+// handleSubscription applies a subscribe or unsubscribe request and
+// acknowledges it with a "subscribed" or "unsubscribed" message
+func (h *WebSocketHandler) handleSubscription(client *Client, msgType string, msg map[string]interface{}) error {
+	jobID := getStringValue(msg, "job_id")
+	if jobID == "" {
+		return &requestError{message: "job_id is required"}
+	}
+
+	ack := "subscribed"
+	if msgType == "subscribe" {
+		client.Subscribe(jobID)
+	} else {
+		client.Unsubscribe(jobID)
+		ack = "unsubscribed"
+	}
+
+	h.logger.WithFields(map[string]interface{}{
+		"client_id": client.ID,
+		"job_id":    jobID,
+	}).Debug("Client " + ack)
+
+	h.sendToClient(client, WebSocketMessage{Type: ack, JobID: jobID})
+	return nil
+}
+
+// handleSetFilter replaces the client's event filter and acknowledges it
+// with a "filter_set" message listing the active filter
+func (h *WebSocketHandler) handleSetFilter(client *Client, _ string, msg map[string]interface{}) error {
+	events, ok := stringList(msg["events"])
+	if !ok {
+		return &requestError{message: "events must be an array of event types"}
+	}
+	client.SetFilter(events)
+
+	h.logger.WithFields(map[string]interface{}{
+		"client_id": client.ID,
+		"events":    events,
+	}).Debug("Client filter set")
+
+	h.sendToClient(client, WebSocketMessage{
+		Type: "filter_set",
+		Data: map[string]interface{}{"events": client.Filter()},
+	})
+	return nil
+}
+
+// stringList converts a decoded JSON array of non-empty strings; a missing
+// value is an empty list
+func stringList(value interface{}) ([]string, bool) {
+	if value == nil {
+		return []string{}, true
+	}
+	raw, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	list := make([]string, 0, len(raw))
+	for _, item := range raw {
+		str, ok := item.(string)
+		if !ok || str == "" {
+			return nil, false
+		}
+		list = append(list, str)
+	}
+	return list, true
+}
+
+// replayTo sends the buffered events newer than sinceMS that the client
+// wants, marked as replayed, then a "replay_complete" message with
+// their count so the client knows live traffic follows
+func (h *WebSocketHandler) replayTo(client *Client, sinceMS int64) {
+	count := 0
+	for _, message := range h.hub.Replay().Since(sinceMS) {
+		if !client.Wants(message.JobID, services.MessageEvent(message)) {
+			continue
 		}
+		message.Replayed = true
+		h.sendToClient(client, message)
+		count++
+	}
+
+	h.logger.WithFields(map[string]interface{}{
+		"client_id": client.ID,
+		"since":     sinceMS,
+		"events":    count,
+	}).Debug("Replayed buffered events")
+
+	h.sendToClient(client, WebSocketMessage{
+		Type: "replay_complete",
+		Data: map[string]interface{}{"count": count},
+	})
+}
+
+// sendToClient queues a message, usually a WebSocketMessage, for one
+// client, dropping it if the client's buffer is full or the client has
+// disconnected. It is safe to call from any goroutine.
+func (h *WebSocketHandler) sendToClient(client *Client, message interface{}) {
+	switch err := h.hub.Send(client.HubClient, message); err {
+	case nil, services.ErrClientNotFound:
+	case services.ErrMessageDropped:
+		h.logger.WithField("client_id", client.ID).Debug("Dropped message for slow client")
+	default:
+		h.logger.WithField("error", err.Error()).Error("Failed to marshal WebSocket message")
 	}
 }
 
@@ -349,14 +578,23 @@ func (h *WebSocketHandler) writePump(client *Client) {
 				return
 			}
 
-			if err := client.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
-				h.logger.WithFields(map[string]interface{}{
-					"client_id": client.ID,
-					"error":     err.Error(),
-				}).Debug("Failed to write message to client")
+			if !h.writeFrame(client, message) {
 				return
 			}
 
+			// Once the buffer has drained, tell the client what it missed
+			if len(client.SendChan) == 0 {
+				if notice, ok := client.TakeDroppedNotice(); ok {
+					f, err := services.EncodeFrame(client.Encoding(), notice)
+					if err == nil && !h.writeFrame(client, f) {
+						return
+					}
+				}
+			}
+
+		case <-h.ctx.Done():
+			return
+
 		case <-ticker.C:
 			// Send ping message
 			client.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
@@ -367,15 +605,119 @@ func (h *WebSocketHandler) writePump(client *Client) {
 	}
 }
 
-// GetStats returns WebSocket statistics
+// writeFrame writes one frame to the client's connection, reporting
+// whether it succeeded
+func (h *WebSocketHandler) writeFrame(client *Client, f services.Frame) bool {
+	messageType := websocket.TextMessage
+	if f.Binary {
+		messageType = websocket.BinaryMessage
+	}
+	if err := client.Conn.WriteMessage(messageType, f.Data); err != nil {
+		h.logger.WithFields(map[string]interface{}{
+			"client_id": client.ID,
+			"error":     err.Error(),
+		}).Debug("Failed to write message to client")
+		return false
+	}
+	client.MarkSent()
+	return true
+}
+
+// ClientInfo describes one connected client in /ws/stats?detail=true. A
+// client whose messages_dropped keeps rising is falling behind and is next
+// to be evicted.
+type ClientInfo struct {
+	ID              string    `json:"id"`
+	RemoteAddr      string    `json:"remote_addr"`
+	Auth            string    `json:"auth"`
+	Subject         string    `json:"subject,omitempty"`
+	ConnectedAt     time.Time `json:"connected_at"`
+	LastActive      time.Time `json:"last_active"`
+	Subscriptions   int       `json:"subscriptions"`
+	Events          []string  `json:"events"`
+	Encoding        string    `json:"encoding"`
+	MessagesSent    uint64    `json:"messages_sent"`
+	MessagesDropped uint64    `json:"messages_dropped"`
+	Pending         int       `json:"pending"`
+}
+
+// GetStats returns WebSocket statistics. With ?detail=true, which
+// WebSocketStatsAuth restricts to admins, it also lists every client.
 func (h *WebSocketHandler) GetStats(c *fiber.Ctx) error {
-	h.clientsMutex.RLock()
-	defer h.clientsMutex.RUnlock()
+	clients := h.clients()
+
+	var authenticated, anonymous int
+	var sent, dropped uint64
+	for _, client := range clients {
+		if client.Principal.Authenticated() {
+			authenticated++
+		} else {
+			anonymous++
+		}
+		sent += client.MessagesSent()
+		dropped += client.MessagesDropped()
+	}
 
-	return c.JSON(fiber.Map{
-		"connected_clients": len(h.clients),
-		"timestamp":         time.Now(),
-	})
+	stats := fiber.Map{
+		"connected_clients":          authenticated + anonymous,
+		"authenticated_clients":      authenticated,
+		"anonymous_clients":          anonymous,
+		"totals":                     fiber.Map{"messages_sent": sent, "messages_dropped": dropped},
+		"messages":                   h.counters.snapshot(),
+		"capacity":                   h.capacity(),
+		"upgrades":                   h.upgrades.stats(),
+		"slow_clients":               h.hub.SlowClientStats(),
+		"redis_subscription_healthy": h.SubscriptionHealthy(),
+		"timestamp":                  time.Now(),
+	}
+	if c.QueryBool("detail") {
+		stats["clients"] = clientInfo(clients)
+	}
+	return c.JSON(stats)
+}
+
+// clientInfo describes clients, ordered by ID
+func clientInfo(clients []*Client) []ClientInfo {
+	infos := make([]ClientInfo, 0, len(clients))
+	for _, client := range clients {
+		infos = append(infos, ClientInfo{
+			ID:              client.ID,
+			RemoteAddr:      client.RemoteAddr,
+			Auth:            client.Principal.Method,
+			Subject:         client.Principal.Subject,
+			ConnectedAt:     client.ConnectedAt,
+			LastActive:      client.LastActive(),
+			Subscriptions:   client.Subscriptions(),
+			Events:          client.Filter(),
+			Encoding:        client.Encoding(),
+			MessagesSent:    client.MessagesSent(),
+			MessagesDropped: client.MessagesDropped(),
+			Pending:         client.Pending(),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// capacity reports open connections against the cap; utilization is the
+// fraction of the cap in use, omitted when there is no cap
+func (h *WebSocketHandler) capacity() fiber.Map {
+	h.connMutex.Lock()
+	defer h.connMutex.Unlock()
+
+	capacity := fiber.Map{
+		"connections":     h.connections,
+		"max_connections": h.maxConnections,
+	}
+	if h.maxConnections > 0 {
+		capacity["utilization"] = float64(h.connections) / float64(h.maxConnections)
+	}
+	return capacity
+}
+
+// ClientCount returns the number of connected WebSocket clients
+func (h *WebSocketHandler) ClientCount() int {
+	return h.hub.ClientCount()
 }
 
 // Helper function to generate unique client ID
@@ -383,12 +725,25 @@ func generateClientID() string {
 	return time.Now().Format("20060102150405") + "-" + randomString(8)
 }
 
-// Helper function to generate random string
+// randomString returns n characters drawn uniformly from [a-zA-Z0-9]
+// using crypto/rand
 func randomString(n int) string {
 	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, n)
-	for i := range b {
-		b[i] = letters[time.Now().UnixNano()%int64(len(letters))]
+	// Bytes at or above the largest multiple of len(letters) are discarded
+	// so every letter is equally likely
+	const limit = 256 - 256%len(letters)
+
+	b := make([]byte, 0, n)
+	buf := make([]byte, n)
+	for len(b) < n {
+		if _, err := rand.Read(buf); err != nil {
+			panic("crypto/rand unavailable: " + err.Error())
+		}
+		for _, v := range buf {
+			if int(v) < limit && len(b) < n {
+				b = append(b, letters[int(v)%len(letters)])
+			}
+		}
 	}
 	return string(b)
 }
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
+
+	"botrix-backend/config"
+	"botrix-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// WebSocketTokenSubprotocol is offered alongside the token by clients
+// that cannot set query parameters, as
+// "Sec-WebSocket-Protocol: botrix.token, <token>". The server selects it,
+// so the token itself is never echoed back.
+const WebSocketTokenSubprotocol = "botrix.token"
+
+// wsPrincipalKey is the c.Locals key holding the connection's principal
+const wsPrincipalKey = "ws_principal"
+
+// WebSocket authentication methods
+const (
+	WSAuthAPIKey    = "api_key"
+	WSAuthJWT       = "jwt"
+	WSAuthAnonymous = "anonymous"
+)
+
+// WebSocketPrincipal identifies who opened a WebSocket connection
+type WebSocketPrincipal struct {
+	Subject string `json:"subject"`
+	Method  string `json:"method"`
+}
+
+// Authenticated reports whether the principal presented a valid token
+func (p WebSocketPrincipal) Authenticated() bool {
+	return p.Method != WSAuthAnonymous
+}
+
+// WebSocketAuth returns middleware for /ws that only lets authenticated
+// upgrade requests through. The token comes from ?token= or the
+// WebSocketTokenSubprotocol pair and may be one of the configured API keys
+// or an HS256 JWT signed with the token secret. Rejected requests get 401
+// before the connection is upgraded.
+func WebSocketAuth(cfg config.WebSocketConfig, logger *utils.Logger) fiber.Handler {
+	keyHashes := hashKeys(cfg.APIKeys)
+
+	return func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+
+		token := webSocketToken(c)
+		if token == "" {
+			if !cfg.AllowAnonymous {
+				return wsUnauthorized(c, "Missing token")
+			}
+			c.Locals(wsPrincipalKey, WebSocketPrincipal{Subject: c.IP(), Method: WSAuthAnonymous})
+			return c.Next()
+		}
+
+		if i := matchKey(keyHashes, token); i >= 0 {
+			c.Locals(wsPrincipalKey, WebSocketPrincipal{
+				Subject: fmt.Sprintf("api-key-%d", i+1),
+				Method:  WSAuthAPIKey,
+			})
+			return c.Next()
+		}
+
+		if cfg.TokenSecret != "" {
+			claims, err := utils.VerifyToken(token, []byte(cfg.TokenSecret), time.Now())
+			if err == nil {
+				c.Locals(wsPrincipalKey, WebSocketPrincipal{Subject: claims.Subject, Method: WSAuthJWT})
+				return c.Next()
+			}
+			if err == utils.ErrTokenExpired {
+				return wsUnauthorized(c, "Token expired")
+			}
+		}
+
+		logger.WithField("ip", c.IP()).Warn("Rejected WebSocket connection with an invalid token")
+		return wsUnauthorized(c, "Invalid token")
+	}
+}
+
+// WebSocketStatsAuth returns middleware for /ws/stats that admits the
+// count-only view to anyone but requires "Authorization: Bearer <key>" with
+// one of the admin keys for ?detail=true, which lists who is connected.
+// Without admin keys the detail view is open only where anonymous
+// WebSocket connections are allowed, i.e. in development by default.
+func WebSocketStatsAuth(cfg config.WebSocketConfig, logger *utils.Logger) fiber.Handler {
+	adminHashes := hashKeys(cfg.AdminKeys)
+
+	return func(c *fiber.Ctx) error {
+		if !c.QueryBool("detail") {
+			return c.Next()
+		}
+		switch adminStatus(c, cfg, adminHashes) {
+		case fiber.StatusForbidden:
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"error":   "Detailed stats are disabled; set WS_ADMIN_KEYS to enable them",
+			})
+		case fiber.StatusUnauthorized:
+			logger.WithField("ip", c.IP()).Warn("Rejected detailed WebSocket stats request")
+			return wsUnauthorized(c, "Admin key required for detailed stats")
+		}
+		return c.Next()
+	}
+}
+
+// WebSocketAdminAuth returns middleware for WebSocket admin endpoints such
+// as /ws/send and /ws/logs, requiring "Authorization: Bearer <key>" with
+// one of the admin keys; upgrade requests may pass the key as a token
+// instead. As with detailed stats, without admin keys the endpoints are
+// open only where anonymous WebSocket connections are allowed.
+func WebSocketAdminAuth(cfg config.WebSocketConfig, logger *utils.Logger) fiber.Handler {
+	adminHashes := hashKeys(cfg.AdminKeys)
+
+	return func(c *fiber.Ctx) error {
+		switch adminStatus(c, cfg, adminHashes) {
+		case fiber.StatusForbidden:
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"error":   "WebSocket admin endpoints are disabled; set WS_ADMIN_KEYS to enable them",
+			})
+		case fiber.StatusUnauthorized:
+			logger.WithFields(map[string]interface{}{
+				"ip":   c.IP(),
+				"path": c.Path(),
+			}).Warn("Rejected WebSocket admin request")
+			return wsUnauthorized(c, "Admin key required")
+		}
+		return c.Next()
+	}
+}
+
+// adminStatus checks a request's admin key: 0 if it may proceed,
+// StatusForbidden if admin access is disabled, or StatusUnauthorized if the
+// key is missing or wrong
+func adminStatus(c *fiber.Ctx, cfg config.WebSocketConfig, adminHashes [][32]byte) int {
+	if len(adminHashes) == 0 {
+		if cfg.AllowAnonymous {
+			return 0
+		}
+		return fiber.StatusForbidden
+	}
+
+	// Browsers cannot set headers on upgrade requests, so those may pass
+	// the key as a WebSocket token instead
+	token, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !found && websocket.IsWebSocketUpgrade(c) {
+		token = webSocketToken(c)
+		found = token != ""
+	}
+	if !found || matchKey(adminHashes, strings.TrimSpace(token)) < 0 {
+		return fiber.StatusUnauthorized
+	}
+	return 0
+}
+
+// hashKeys hashes keys for matchKey. Keys are compared by hash so the
+// comparison time does not depend on how much of a key matched.
+func hashKeys(keys []string) [][32]byte {
+	hashes := make([][32]byte, len(keys))
+	for i, key := range keys {
+		hashes[i] = sha256.Sum256([]byte(key))
+	}
+	return hashes
+}
+
+// matchKey returns the index of the key whose hash token matches, or -1
+func matchKey(hashes [][32]byte, token string) int {
+	tokenHash := sha256.Sum256([]byte(token))
+	match := -1
+	for i, keyHash := range hashes {
+		if subtle.ConstantTimeCompare(tokenHash[:], keyHash[:]) == 1 && match < 0 {
+			match = i
+		}
+	}
+	return match
+}
+
+// webSocketToken reads the token from the query string or, failing that,
+// from the entry following WebSocketTokenSubprotocol in the offered
+// subprotocols
+func webSocketToken(c *fiber.Ctx) string {
+	if token := c.Query("token"); token != "" {
+		return token
+	}
+
+	protocols := strings.Split(c.Get("Sec-WebSocket-Protocol"), ",")
+	for i := 0; i+1 < len(protocols); i++ {
+		if strings.TrimSpace(protocols[i]) == WebSocketTokenSubprotocol {
+			return strings.TrimSpace(protocols[i+1])
+		}
+	}
+	return ""
+}
+
+// wsUnauthorized rejects an upgrade request
+func wsUnauthorized(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"success": false,
+		"error":   message,
+	})
+}
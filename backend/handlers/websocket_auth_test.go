@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"botrix-backend/config"
+	"botrix-backend/utils"
+
+	fastws "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// signTestToken issues an HS256 JWT for claims, as the token service would
+func signTestToken(t *testing.T, secret string, claims utils.TokenClaims) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("encoding claims: %v", err)
+	}
+	input := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) +
+		"." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// webSocketStats fetches /ws/stats?detail=true from the server at addr
+func webSocketStats(t *testing.T, addr string) map[string]interface{} {
+	t.Helper()
+	resp, err := http.Get("http://" + addr + "/ws/stats?detail=true")
+	if err != nil {
+		t.Fatalf("GET /ws/stats: %v", err)
+	}
+	defer resp.Body.Close()
+	var stats map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("decoding /ws/stats: %v", err)
+	}
+	return stats
+}
+
+// connectedPrincipals lists the "auth:subject" of each client in stats
+func connectedPrincipals(stats map[string]interface{}) []string {
+	var principals []string
+	clients, _ := stats["clients"].([]interface{})
+	for _, entry := range clients {
+		client, _ := entry.(map[string]interface{})
+		subject, _ := client["subject"].(string)
+		principals = append(principals, client["auth"].(string)+":"+subject)
+	}
+	sort.Strings(principals)
+	return principals
+}
+
+func TestWebSocketAuthTokens(t *testing.T) {
+	const secret = "jwt-secret"
+	cfg := newTestConfig(t)
+	cfg.WebSocket = config.WebSocketConfig{APIKeys: []string{"key-one", "key-two"}, TokenSecret: secret}
+	h, _ := newTestWebSocketHandler(t, cfg)
+	addr := serveWebSocket(t, h, cfg.WebSocket)
+
+	now := time.Now()
+	valid := signTestToken(t, secret, utils.TokenClaims{Subject: "alice", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()})
+	expired := signTestToken(t, secret, utils.TokenClaims{Subject: "bob", IssuedAt: now.Add(-2 * time.Hour).Unix(), ExpiresAt: now.Add(-time.Hour).Unix()})
+	forged := signTestToken(t, "other-secret", utils.TokenClaims{Subject: "mallory"})
+
+	tests := []struct {
+		name   string
+		query  string
+		header http.Header
+		error  string // the 401 error, empty if the upgrade is accepted
+	}{
+		{"API key in the query", "token=key-one", nil, ""},
+		{"API key as a subprotocol", "", http.Header{"Sec-WebSocket-Protocol": {WebSocketTokenSubprotocol + ", key-two"}}, ""},
+		{"valid JWT", "token=" + valid, nil, ""},
+		{"missing token", "", nil, "Missing token"},
+		{"unknown API key", "token=key-three", nil, "Invalid token"},
+		{"expired JWT", "token=" + expired, nil, "Token expired"},
+		{"JWT signed with another secret", "token=" + forged, nil, "Invalid token"},
+		{"expired JWT as a subprotocol", "", http.Header{"Sec-WebSocket-Protocol": {WebSocketTokenSubprotocol + ", " + expired}}, "Token expired"},
+	}
+	for _, tt := range tests {
+		conn, resp, err := dialWebSocket(t, addr, tt.query, tt.header)
+		if tt.error == "" {
+			if err != nil {
+				t.Errorf("%s: dial = %v, want the upgrade accepted", tt.name, err)
+				continue
+			}
+			var hello HelloMessage
+			readWebSocketJSON(t, conn, &hello)
+			if hello.Type != "hello" {
+				t.Errorf("%s: first message = %+v, want hello", tt.name, hello)
+			}
+			// The token is never echoed back in the selected subprotocol
+			if tt.header != nil && resp.Header.Get("Sec-WebSocket-Protocol") != WebSocketTokenSubprotocol {
+				t.Errorf("%s: selected subprotocol = %q, want %q", tt.name, resp.Header.Get("Sec-WebSocket-Protocol"), WebSocketTokenSubprotocol)
+			}
+			continue
+		}
+
+		if err != fastws.ErrBadHandshake || resp == nil || resp.StatusCode != fiber.StatusUnauthorized {
+			t.Errorf("%s: dial = %v (response %v), want 401 before the upgrade", tt.name, err, resp)
+			continue
+		}
+		var body map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if body["error"] != tt.error {
+			t.Errorf("%s: error = %v, want %q", tt.name, body["error"], tt.error)
+		}
+	}
+
+	// Each accepted connection carries the principal it authenticated as
+	stats := webSocketStats(t, addr)
+	if stats["authenticated_clients"] != float64(3) || stats["anonymous_clients"] != float64(0) {
+		t.Errorf("stats = %v authenticated, %v anonymous, want 3 and 0", stats["authenticated_clients"], stats["anonymous_clients"])
+	}
+	want := []string{"api_key:api-key-1", "api_key:api-key-2", "jwt:alice"}
+	if got := connectedPrincipals(stats); !reflect.DeepEqual(got, want) {
+		t.Errorf("connected principals = %v, want %v", got, want)
+	}
+}
+
+func TestWebSocketAuthAllowsAnonymous(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.WebSocket = config.WebSocketConfig{APIKeys: []string{"key-one"}, AllowAnonymous: true}
+	h, _ := newTestWebSocketHandler(t, cfg)
+	addr := serveWebSocket(t, h, cfg.WebSocket)
+
+	connectWebSocket(t, addr, "")
+	connectWebSocket(t, addr, "token=key-one")
+
+	// A token that is present must still be valid
+	if _, resp, err := dialWebSocket(t, addr, "token=wrong", nil); err == nil || resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("dial with a wrong token = %v, want 401", err)
+	}
+
+	stats := webSocketStats(t, addr)
+	if stats["authenticated_clients"] != float64(1) || stats["anonymous_clients"] != float64(1) {
+		t.Errorf("stats = %v authenticated, %v anonymous, want 1 of each", stats["authenticated_clients"], stats["anonymous_clients"])
+	}
+	if got := connectedPrincipals(stats); len(got) != 2 || got[0] != "anonymous:127.0.0.1" {
+		t.Errorf("connected principals = %v, want an anonymous client identified by its address", got)
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"sync/atomic"
+	"time"
+
+	"botrix-backend/services"
+)
+
+// DefaultCoalesceWindow is how long progress events are merged when no
+// window is configured
+const DefaultCoalesceWindow = 250 * time.Millisecond
+
+// relayCounters count the events the relay receives from Redis, the
+// messages it sends on to the hub and the progress events merged away
+type relayCounters struct {
+	in        atomic.Uint64
+	out       atomic.Uint64
+	coalesced atomic.Uint64
+}
+
+// snapshot returns the counters for /ws/stats
+func (c *relayCounters) snapshot() map[string]uint64 {
+	return map[string]uint64{
+		"in":        c.in.Load(),
+		"out":       c.out.Load(),
+		"coalesced": c.coalesced.Load(),
+	}
+}
+
+// relay sends an event on, holding job progress events in pending so that
+// those arriving within one coalesce window collapse into the latest. Any
+// other event for a job first releases its pending progress, so status
+// changes pass through at once and never overtake earlier progress. It
+// returns false once the handler is stopping.
+func (h *WebSocketHandler) relay(message WebSocketMessage, pending map[string]WebSocketMessage) bool {
+	h.counters.in.Add(1)
+
+	if h.coalesceWindow > 0 && message.JobID != "" && services.MessageEvent(message) == services.JobProgressEvent {
+		if _, ok := pending[message.JobID]; ok {
+			h.counters.coalesced.Add(1)
+		}
+		pending[message.JobID] = message
+		return true
+	}
+
+	if earlier, ok := pending[message.JobID]; ok {
+		delete(pending, message.JobID)
+		if !h.emit(earlier) {
+			return false
+		}
+	}
+	return h.emit(message)
+}
+
+// flushPending sends every held progress event at the end of a window
+func (h *WebSocketHandler) flushPending(pending map[string]WebSocketMessage) bool {
+	for jobID, message := range pending {
+		delete(pending, jobID)
+		if !h.emit(message) {
+			return false
+		}
+	}
+	return true
+}
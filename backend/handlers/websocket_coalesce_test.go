@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"botrix-backend/services"
+)
+
+// progressMessage is a relayed progress event for jobID
+func progressMessage(jobID string, progress int) WebSocketMessage {
+	return WebSocketMessage{
+		Type:  "job_update",
+		JobID: jobID,
+		Data:  map[string]interface{}{"event": services.JobProgressEvent, "job_id": jobID, "progress": progress},
+	}
+}
+
+// drainMessages returns what client has been sent, waiting briefly for more
+func drainMessages(t *testing.T, client *services.HubClient) []WebSocketMessage {
+	t.Helper()
+	var messages []WebSocketMessage
+	for {
+		message, ok := nextMessage(t, client, 100*time.Millisecond)
+		if !ok {
+			return messages
+		}
+		messages = append(messages, message)
+	}
+}
+
+// describe summarizes messages as "job:event" plus ":progress" if set
+func describe(messages []WebSocketMessage) []string {
+	var summary []string
+	for _, message := range messages {
+		line := message.JobID + ":" + services.MessageEvent(message)
+		if progress, ok := message.Data["progress"]; ok {
+			line += fmt.Sprintf(":%v", progress)
+		}
+		summary = append(summary, line)
+	}
+	return summary
+}
+
+func TestRelayCoalescesProgressBursts(t *testing.T) {
+	cfg := newTestConfig(t)
+	// The relay's own ticker never fires; the test flushes by hand
+	cfg.WebSocket.CoalesceWindow = time.Hour
+	h, _ := newTestWebSocketHandler(t, cfg)
+	client := registerHubClient(t, h, "burst-client", AllJobs)
+	pending := make(map[string]WebSocketMessage)
+
+	// A burst of progress for two jobs is held back entirely
+	for i := 1; i <= 100; i++ {
+		h.relay(progressMessage("job-1", i), pending)
+		if i <= 50 {
+			h.relay(progressMessage("job-2", i), pending)
+		}
+	}
+	if got := drainMessages(t, client); len(got) != 0 {
+		t.Fatalf("sent %d messages during the window, want none", len(got))
+	}
+
+	// The flush sends only the latest progress of each job
+	h.flushPending(pending)
+	got := drainMessages(t, client)
+	progress := map[string]interface{}{}
+	for _, message := range got {
+		progress[message.JobID] = message.Data["progress"]
+	}
+	if len(got) != 2 || !reflect.DeepEqual(progress, map[string]interface{}{"job-1": float64(100), "job-2": float64(50)}) {
+		t.Errorf("flushed %v, want the final progress of each job once", describe(got))
+	}
+	want := map[string]uint64{"in": 150, "out": 2, "coalesced": 148}
+	if counters := h.counters.snapshot(); !reflect.DeepEqual(counters, want) {
+		t.Errorf("counters = %v, want %v", counters, want)
+	}
+
+	// A status change passes at once, after the progress it follows
+	h.relay(progressMessage("job-1", 3), pending)
+	h.relay(progressMessage("job-2", 4), pending)
+	h.relay(WebSocketMessage{Type: "job_update", JobID: "job-1", Status: "completed"}, pending)
+	if got, want := describe(drainMessages(t, client)), []string{"job-1:job_progress:3", "job-1:job_completed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v on a status change, want %v", got, want)
+	}
+	if _, held := pending["job-2"]; !held {
+		t.Error("other jobs' progress was released by the status change")
+	}
+}
+
+func TestRelayWithoutWindowSendsEveryEvent(t *testing.T) {
+	h, _ := newTestWebSocketHandler(t, newTestConfig(t))
+	client := registerHubClient(t, h, "plain-client", AllJobs)
+	pending := make(map[string]WebSocketMessage)
+
+	for i := 1; i <= 5; i++ {
+		h.relay(progressMessage("job-1", i), pending)
+	}
+	if got := drainMessages(t, client); len(got) != 5 {
+		t.Errorf("sent %d of 5 progress events with coalescing off", len(got))
+	}
+	if len(pending) != 0 || h.counters.coalesced.Load() != 0 {
+		t.Errorf("%d events held and %d coalesced with coalescing off", len(pending), h.counters.coalesced.Load())
+	}
+}
+
+func TestCoalescedBurstKeepsFinalState(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.WebSocket.CoalesceWindow = DefaultCoalesceWindow
+	h, _ := newTestWebSocketHandler(t, cfg)
+	client := registerHubClient(t, h, "dashboard", AllJobs)
+
+	const burst = 100
+	for i := 1; i <= burst; i++ {
+		publishJobUpdate(t, h.queue, map[string]interface{}{"event": services.JobProgressEvent, "job_id": "job-1", "progress": i})
+	}
+	publishJobUpdate(t, h.queue, map[string]interface{}{"job_id": "job-1", "status": "completed"})
+
+	var progress []float64
+	for {
+		message, ok := nextMessage(t, client, 2*time.Second)
+		if !ok {
+			t.Fatalf("completion not relayed; progress seen %v", progress)
+		}
+		if message.Status == "completed" {
+			break
+		}
+		value, _ := message.Data["progress"].(float64)
+		progress = append(progress, value)
+	}
+
+	if len(progress) == 0 || progress[len(progress)-1] != burst {
+		t.Fatalf("progress relayed as %v, want it to end at %d before completion", progress, burst)
+	}
+	if len(progress) > burst/10 {
+		t.Errorf("relayed %d of %d progress events, want the burst coalesced", len(progress), burst)
+	}
+	for i := 1; i < len(progress); i++ {
+		if progress[i] <= progress[i-1] {
+			t.Errorf("progress relayed out of order: %v", progress)
+			break
+		}
+	}
+
+	counters := h.counters.snapshot()
+	if counters["in"] != burst+1 || counters["out"] != uint64(len(progress))+1 || counters["coalesced"] != burst-uint64(len(progress)) {
+		t.Errorf("counters = %v after relaying %d progress events of %d", counters, len(progress), burst)
+	}
+}
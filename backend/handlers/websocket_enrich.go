@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"botrix-backend/models"
+)
+
+const (
+	// jobEnrichTimeout bounds the job lookup for one event, so a slow
+	// database delays the relay by at most this much
+	jobEnrichTimeout = 250 * time.Millisecond
+
+	// jobEnrichTTL is how long a looked-up job is reused for later events
+	jobEnrichTTL = time.Second
+
+	// jobEnrichCacheSize caps the jobs cached at once
+	jobEnrichCacheSize = 256
+)
+
+// jobStatusEvents are the published events that change a job's status.
+// Workers publish status changes without an event, with "status" set.
+var jobStatusEvents = map[string]bool{
+	"status_updated": true,
+	"job_completed":  true,
+	"job_failed":     true,
+	"job_cancelled":  true,
+	"job_paused":     true,
+	"job_resumed":    true,
+}
+
+// jobEnricher adds a job's progress and counts to its status-change events,
+// so clients need not fetch the job on every one. Only the relay goroutine
+// uses it, so the cache needs no lock.
+type jobEnricher struct {
+	load  func(ctx context.Context, jobID string) (*models.Job, error)
+	cache map[string]cachedJob
+}
+
+// cachedJob is a job's enrichment fields as of fetched
+type cachedJob struct {
+	fields  map[string]interface{}
+	fetched time.Time
+}
+
+// newJobEnricher creates an enricher looking jobs up with load; a nil load
+// leaves every event as is
+func newJobEnricher(load func(ctx context.Context, jobID string) (*models.Job, error)) *jobEnricher {
+	return &jobEnricher{load: load, cache: make(map[string]cachedJob)}
+}
+
+// isJobStatusChange reports whether a relayed message changes a job's
+// status and so is worth enriching
+func isJobStatusChange(message WebSocketMessage) bool {
+	if message.Type != "job_update" || message.JobID == "" {
+		return false
+	}
+	if event := getStringValue(message.Data, "event"); event != "" {
+		return jobStatusEvents[event]
+	}
+	return message.Status != ""
+}
+
+// enrich adds progress, successful, failed, count and percentage to a
+// status-change event's data, keeping any the publisher already set. The
+// job watcher applies the same event to the database concurrently, so the
+// counts may trail it by an event. When the job cannot be loaded in time
+// the event is returned unchanged.
+func (e *jobEnricher) enrich(message WebSocketMessage, now time.Time) (WebSocketMessage, error) {
+	if e.load == nil || !isJobStatusChange(message) {
+		return message, nil
+	}
+
+	fields, err := e.fields(message.JobID, now)
+	if err != nil {
+		return message, err
+	}
+
+	if message.Data == nil {
+		message.Data = make(map[string]interface{}, len(fields))
+	}
+	for key, value := range fields {
+		if _, ok := message.Data[key]; !ok {
+			message.Data[key] = value
+		}
+	}
+	return message, nil
+}
+
+// fields returns a job's enrichment fields, from the cache while fresh
+func (e *jobEnricher) fields(jobID string, now time.Time) (map[string]interface{}, error) {
+	if cached, ok := e.cache[jobID]; ok && now.Sub(cached.fetched) < jobEnrichTTL {
+		return cached.fields, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jobEnrichTimeout)
+	defer cancel()
+	job, err := e.load(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{
+		"progress":   job.Progress,
+		"successful": job.Successful,
+		"failed":     job.Failed,
+		"count":      job.Count,
+		"percentage": math.Round(job.GetProgress()*10) / 10,
+	}
+
+	if len(e.cache) >= jobEnrichCacheSize {
+		e.prune(now)
+	}
+	e.cache[jobID] = cachedJob{fields: fields, fetched: now}
+	return fields, nil
+}
+
+// prune drops expired entries, or every entry if none had expired
+func (e *jobEnricher) prune(now time.Time) {
+	for jobID, cached := range e.cache {
+		if now.Sub(cached.fetched) >= jobEnrichTTL {
+			delete(e.cache, jobID)
+		}
+	}
+	if len(e.cache) >= jobEnrichCacheSize {
+		e.cache = make(map[string]cachedJob)
+	}
+}
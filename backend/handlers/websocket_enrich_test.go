@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"botrix-backend/models"
+	"botrix-backend/services"
+)
+
+// stubJobs is a job store standing in for the database, counting lookups
+type stubJobs struct {
+	jobs    map[string]*models.Job
+	lookups map[string]int
+	err     error
+}
+
+func newStubJobs(jobs ...*models.Job) *stubJobs {
+	s := &stubJobs{jobs: make(map[string]*models.Job), lookups: make(map[string]int)}
+	for _, job := range jobs {
+		s.jobs[job.ID] = job
+	}
+	return s
+}
+
+func (s *stubJobs) load(ctx context.Context, jobID string) (*models.Job, error) {
+	s.lookups[jobID]++
+	if s.err != nil {
+		return nil, s.err
+	}
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	copied := *job
+	return &copied, nil
+}
+
+// statusUpdate is a bare status update as workers publish it
+func statusUpdate(jobID, status string) WebSocketMessage {
+	return WebSocketMessage{
+		Type:   "job_update",
+		JobID:  jobID,
+		Status: status,
+		Data:   map[string]interface{}{"job_id": jobID, "status": status},
+	}
+}
+
+func TestEnrichAddsJobProgressAndCounts(t *testing.T) {
+	stub := newStubJobs(&models.Job{ID: "job-1", Count: 8, Progress: 3, Successful: 2, Failed: 1})
+	enricher := newJobEnricher(stub.load)
+
+	got, err := enricher.enrich(statusUpdate("job-1", "running"), time.Now())
+	if err != nil {
+		t.Fatalf("enrich: %v", err)
+	}
+	want := map[string]interface{}{
+		"job_id":     "job-1",
+		"status":     "running",
+		"progress":   3,
+		"successful": 2,
+		"failed":     1,
+		"count":      8,
+		"percentage": 37.5,
+	}
+	if !reflect.DeepEqual(got.Data, want) {
+		t.Errorf("enriched data = %v, want %v", got.Data, want)
+	}
+
+	// Every status-change event is enriched; fields the publisher set win
+	for _, message := range []WebSocketMessage{
+		{Type: "job_update", JobID: "job-1", Data: map[string]interface{}{"event": "job_paused"}},
+		{Type: "job_update", JobID: "job-1", Data: map[string]interface{}{"event": "job_completed", "successful": 8}},
+		{Type: "job_update", JobID: "job-1", Data: map[string]interface{}{"event": "status_updated"}},
+	} {
+		got, _ := enricher.enrich(message, time.Now())
+		if got.Data["count"] != 8 {
+			t.Errorf("%s: data = %v, want job counts added", services.MessageEvent(message), got.Data)
+		}
+		if services.MessageEvent(message) == "job_completed" && got.Data["successful"] != 8 {
+			t.Errorf("job_completed: successful = %v, want the published 8 kept", got.Data["successful"])
+		}
+	}
+}
+
+func TestEnrichSkipsOtherEvents(t *testing.T) {
+	stub := newStubJobs(&models.Job{ID: "job-1", Count: 8})
+	enricher := newJobEnricher(stub.load)
+
+	for _, message := range []WebSocketMessage{
+		progressMessage("job-1", 4),
+		{Type: "job_update", JobID: "job-1", Data: map[string]interface{}{"event": services.JobLogEvent, "message": "hi"}},
+		{Type: "job_update", Status: "running"},
+		{Type: AccountUpdateMessage, Data: map[string]interface{}{"event": "account_status_changed"}},
+		{Type: services.SettingsUpdatedEvent, Data: map[string]interface{}{"event": services.SettingsUpdatedEvent}},
+	} {
+		before := fmt.Sprint(message.Data)
+		got, err := enricher.enrich(message, time.Now())
+		if err != nil || fmt.Sprint(got.Data) != before {
+			t.Errorf("%s: enrich = %v, %v, want the event unchanged", services.MessageEvent(message), got.Data, err)
+		}
+	}
+	if len(stub.lookups) != 0 {
+		t.Errorf("looked up %v for events that are not status changes", stub.lookups)
+	}
+
+	// Without a database nothing is looked up at all
+	if got, err := newJobEnricher(nil).enrich(statusUpdate("job-1", "running"), time.Now()); err != nil || len(got.Data) != 2 {
+		t.Errorf("enrich without a database = %v, %v, want the raw event", got.Data, err)
+	}
+}
+
+func TestEnrichReusesLookups(t *testing.T) {
+	stub := newStubJobs(
+		&models.Job{ID: "job-1", Count: 10, Progress: 1},
+		&models.Job{ID: "job-2", Count: 5, Progress: 5},
+	)
+	enricher := newJobEnricher(stub.load)
+	now := time.Now()
+
+	// A burst of status changes costs one query per job, not one per event
+	for i := 0; i < 50; i++ {
+		enricher.enrich(statusUpdate("job-1", "running"), now.Add(time.Duration(i)*time.Millisecond))
+		enricher.enrich(statusUpdate("job-2", "running"), now.Add(time.Duration(i)*time.Millisecond))
+	}
+	if want := map[string]int{"job-1": 1, "job-2": 1}; !reflect.DeepEqual(stub.lookups, want) {
+		t.Errorf("lookups = %v for 100 events, want %v", stub.lookups, want)
+	}
+
+	// Once the cached job is stale it is read again
+	stub.jobs["job-1"].Progress = 7
+	got, _ := enricher.enrich(statusUpdate("job-1", "completed"), now.Add(jobEnrichTTL))
+	if stub.lookups["job-1"] != 2 || got.Data["progress"] != 7 {
+		t.Errorf("after the TTL: %d lookups, progress %v, want a second lookup with progress 7", stub.lookups["job-1"], got.Data["progress"])
+	}
+
+	// The cache stays bounded however many jobs report
+	for i := 0; i < 2*jobEnrichCacheSize; i++ {
+		id := fmt.Sprintf("burst-%d", i)
+		stub.jobs[id] = &models.Job{ID: id, Count: 1}
+		enricher.enrich(statusUpdate(id, "running"), now)
+	}
+	if n := len(enricher.cache); n > jobEnrichCacheSize {
+		t.Errorf("cache holds %d jobs, want at most %d", n, jobEnrichCacheSize)
+	}
+}
+
+func TestEnrichFailureKeepsRawEvent(t *testing.T) {
+	stub := newStubJobs()
+	stub.err = errors.New("database is locked")
+	enricher := newJobEnricher(stub.load)
+
+	message := statusUpdate("job-1", "failed")
+	got, err := enricher.enrich(message, time.Now())
+	if err == nil || !reflect.DeepEqual(got, message) {
+		t.Errorf("enrich with a failing database = %+v, %v, want the raw event and the error", got, err)
+	}
+
+	// Failures are not cached, so the job is read again once it can be
+	stub.err = nil
+	stub.jobs["job-1"] = &models.Job{ID: "job-1", Count: 2, Failed: 2}
+	if got, err := enricher.enrich(statusUpdate("job-1", "failed"), time.Now()); err != nil || got.Data["failed"] != 2 {
+		t.Errorf("enrich after recovery = %v, %v, want the counts", got.Data, err)
+	}
+
+	// A database slower than the timeout is abandoned
+	slow := newJobEnricher(func(ctx context.Context, jobID string) (*models.Job, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	start := time.Now()
+	got, err = slow.enrich(message, start)
+	if elapsed := time.Since(start); elapsed > jobEnrichTimeout+time.Second || err != context.DeadlineExceeded {
+		t.Errorf("enrich with a hung database took %s and returned %v, want a timeout after %s", elapsed, err, jobEnrichTimeout)
+	}
+	if !reflect.DeepEqual(got, message) {
+		t.Errorf("enrich with a hung database = %+v, want the raw event", got)
+	}
+}
+
+func TestRelayedStatusUpdateIsEnriched(t *testing.T) {
+	h, _ := newTestWebSocketHandler(t, newTestConfig(t))
+	client := registerHubClient(t, h, "enriched-client", AllJobs)
+
+	job := &models.Job{ID: "job-enriched", Type: models.JobTypeGenerate, Count: 4, Progress: 2, Successful: 2, Status: models.JobStatusRunning}
+	if err := h.db.CreateJob(job); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	publishJobUpdate(t, h.queue, map[string]interface{}{"job_id": job.ID, "status": "running"})
+	publishJobUpdate(t, h.queue, map[string]interface{}{"job_id": "job-missing", "status": "running"})
+
+	for _, want := range []struct {
+		jobID string
+		count interface{}
+	}{{job.ID, float64(4)}, {"job-missing", nil}} {
+		message, ok := nextMessage(t, client, 2*time.Second)
+		if !ok {
+			t.Fatalf("update for %s not relayed", want.jobID)
+		}
+		if message.JobID != want.jobID || message.Data["count"] != want.count {
+			t.Errorf("relayed %s with data %v, want %s with count %v", message.JobID, message.Data, want.jobID, want.count)
+		}
+	}
+}
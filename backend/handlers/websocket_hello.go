@@ -0,0 +1,71 @@
+package handlers
+
+import "time"
+
+// WebSocketProtocolVersion is bumped whenever a message changes in a way
+// existing clients cannot handle
+const WebSocketProtocolVersion = 1
+
+// Features a client can rely on, announced in the hello message
+const (
+	FeatureSubscriptions = "subscriptions"
+	FeatureEventFilters  = "event_filters"
+	FeatureReplay        = "replay"
+	FeatureStats         = "stats"
+	FeatureStatsPush     = "stats_push"
+	FeatureCoalescing    = "coalescing"
+	FeatureResync        = "resync"
+	FeatureMsgpack       = "msgpack"
+	FeatureResume        = "resume"
+)
+
+// HelloMessage is the first message on every connection, sent as soon as
+// the client is registered:
+//
+//	{
+//	  "type": "hello",
+//	  "client_id": "20251107143000-aZ3kQ9xB",
+//	  "server_time": 1699369800123,
+//	  "protocol_version": 1,
+//	  "features": ["subscriptions", "event_filters", "replay", "stats", "resync"],
+//	  "last_seq": 12345
+//	}
+//
+// server_time is in unix milliseconds, so clients can correct for clock skew
+// before sending a replay hello with "since". last_seq is the sequence
+// number of the latest relayed event, where a later resume can start.
+// Clients should enable UI only for the features listed.
+type HelloMessage struct {
+	Type            string   `json:"type"`
+	ClientID        string   `json:"client_id"`
+	ServerTime      int64    `json:"server_time"`
+	ProtocolVersion int      `json:"protocol_version"`
+	Features        []string `json:"features"`
+	LastSeq         uint64   `json:"last_seq"`
+}
+
+// features lists what this handler supports. Replay and coalescing depend
+// on configuration and are left out when disabled.
+func (h *WebSocketHandler) features() []string {
+	features := []string{FeatureSubscriptions, FeatureEventFilters}
+	if h.hub.Replay().Capacity() > 0 {
+		features = append(features, FeatureReplay, FeatureResume)
+	}
+	features = append(features, FeatureStats, FeatureStatsPush)
+	if h.coalesceWindow > 0 {
+		features = append(features, FeatureCoalescing)
+	}
+	return append(features, FeatureResync, FeatureMsgpack)
+}
+
+// sendHello greets a newly registered client
+func (h *WebSocketHandler) sendHello(client *Client) {
+	h.sendToClient(client, HelloMessage{
+		Type:            "hello",
+		ClientID:        client.ID,
+		ServerTime:      time.Now().UnixMilli(),
+		ProtocolVersion: WebSocketProtocolVersion,
+		Features:        h.features(),
+		LastSeq:         h.hub.Replay().Seq(),
+	})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHelloFrame(t *testing.T) {
+	tests := []struct {
+		name     string
+		replay   int
+		coalesce time.Duration
+		features []string
+	}{
+		{
+			name:     "defaults",
+			features: []string{FeatureSubscriptions, FeatureEventFilters, FeatureStats, FeatureStatsPush, FeatureResync, FeatureMsgpack},
+		},
+		{
+			name:     "replay and coalescing",
+			replay:   16,
+			coalesce: 50 * time.Millisecond,
+			features: []string{FeatureSubscriptions, FeatureEventFilters, FeatureReplay, FeatureResume, FeatureStats, FeatureStatsPush, FeatureCoalescing, FeatureResync, FeatureMsgpack},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.WebSocket.AllowAnonymous = true
+			cfg.WebSocket.ReplayBufferSize = tt.replay
+			cfg.WebSocket.CoalesceWindow = tt.coalesce
+			h, _ := newTestWebSocketHandler(t, cfg)
+			addr := serveWebSocket(t, h, cfg.WebSocket)
+
+			// With replay enabled, last_seq tells the client where a later
+			// resume starts
+			var wantSeq uint64
+			if tt.replay > 0 {
+				publishJobUpdate(t, h.queue, map[string]interface{}{"event": "job_completed", "job_id": "job-1", "status": "completed"})
+				deadline := time.Now().Add(2 * time.Second)
+				for h.hub.Replay().Seq() == 0 && time.Now().Before(deadline) {
+					time.Sleep(10 * time.Millisecond)
+				}
+				if wantSeq = h.hub.Replay().Seq(); wantSeq == 0 {
+					t.Fatal("update was not recorded for replay")
+				}
+			}
+
+			before := time.Now().UnixMilli()
+			_, hello := connectWebSocket(t, addr, "")
+			after := time.Now().UnixMilli()
+
+			if hello.ClientID == "" {
+				t.Error("hello has no client_id")
+			}
+			if hello.ServerTime < before || hello.ServerTime > after {
+				t.Errorf("server_time = %d, want between %d and %d", hello.ServerTime, before, after)
+			}
+			if hello.ProtocolVersion != WebSocketProtocolVersion {
+				t.Errorf("protocol_version = %d, want %d", hello.ProtocolVersion, WebSocketProtocolVersion)
+			}
+			if !reflect.DeepEqual(hello.Features, tt.features) {
+				t.Errorf("features = %v, want %v", hello.Features, tt.features)
+			}
+			if hello.LastSeq != wantSeq {
+				t.Errorf("last_seq = %d, want %d", hello.LastSeq, wantSeq)
+			}
+
+			// The announced ID is the one the server tracks the client by
+			stats := webSocketStats(t, addr)
+			clients, _ := stats["clients"].([]interface{})
+			if len(clients) != 1 || clients[0].(map[string]interface{})["id"] != hello.ClientID {
+				t.Errorf("connected clients = %v, want only %s", clients, hello.ClientID)
+			}
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"botrix-backend/utils"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+const (
+	// logStreamQueueSize bounds the lines waiting between the logger hook
+	// and the fan-out; beyond it the hook drops lines instead of blocking
+	logStreamQueueSize = 1024
+
+	// logStreamBacklog is how many recent lines a new client receives
+	logStreamBacklog = 200
+
+	// logClientBufferSize is each client's send buffer, in lines
+	logClientBufferSize = 256
+)
+
+// LogLine is a log line streamed on /ws/logs. Lines sent from the recent
+// buffer on connect are marked Backlog.
+type LogLine struct {
+	Type      string            `json:"type"`
+	Level     string            `json:"level"`
+	Component string            `json:"component,omitempty"`
+	Message   string            `json:"message"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	Timestamp int64             `json:"timestamp"`
+	Backlog   bool              `json:"backlog,omitempty"`
+
+	level utils.LogLevel
+}
+
+// logClient is a connection to /ws/logs. Its level is a utils.LogLevel;
+// lines below it are not sent.
+type logClient struct {
+	conn    *websocket.Conn
+	send    chan []byte
+	level   atomic.Int32
+	dropped atomic.Uint64 // lines not yet reported in a lines_dropped notice
+}
+
+// LogStreamHandler streams log lines to admins over /ws/logs. Hook feeds it
+// from the logger through a bounded queue, so a slow stream never holds up
+// logging: lines that do not fit are dropped and counted.
+type LogStreamHandler struct {
+	entries chan utils.LogEntry
+	dropped atomic.Uint64 // lines the hook dropped with the queue full
+
+	// clients is guarded by mu; a client's send channel is closed only
+	// under the write lock, after it is removed, as in the hub
+	clients map[*logClient]bool
+	mu      sync.RWMutex
+	closing bool
+
+	// recent is a ring of the last logStreamBacklog lines, guarded by mu
+	recent []LogLine
+	next   int
+	full   bool
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pumps   sync.WaitGroup
+	workers sync.WaitGroup
+	logger  *utils.Logger
+}
+
+// NewLogStreamHandler creates the handler and starts its fan-out. Register
+// Hook with the loggers to stream.
+func NewLogStreamHandler(logger *utils.Logger) *LogStreamHandler {
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := &LogStreamHandler{
+		entries: make(chan utils.LogEntry, logStreamQueueSize),
+		clients: make(map[*logClient]bool),
+		recent:  make([]LogLine, logStreamBacklog),
+		ctx:     ctx,
+		cancel:  cancel,
+		logger:  logger,
+	}
+
+	handler.workers.Add(1)
+	go handler.run()
+
+	return handler
+}
+
+// Hook is a utils.LogHook queuing entries for the stream. It never blocks:
+// with the queue full the entry is dropped and counted.
+func (h *LogStreamHandler) Hook(entry utils.LogEntry) {
+	select {
+	case h.entries <- entry:
+	default:
+		h.dropped.Add(1)
+	}
+}
+
+// Shutdown sends every client a going-away close frame and stops the
+// fan-out, waiting for clients until ctx's deadline
+func (h *LogStreamHandler) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closing = true
+	clients := make([]*logClient, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.Unlock()
+
+	for _, client := range clients {
+		frame := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server restarting")
+		client.conn.WriteControl(websocket.CloseMessage, frame, time.Now().Add(time.Second))
+	}
+
+	var err error
+	if !waitGroupDone(ctx, &h.pumps) {
+		err = ctx.Err()
+		h.mu.RLock()
+		for client := range h.clients {
+			client.conn.Close()
+		}
+		h.mu.RUnlock()
+	}
+
+	h.cancel()
+	if !waitGroupDone(ctx, &h.workers) && err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
+// run turns queued entries into lines, records them for the backlog and
+// fans them out to the clients whose level admits them. It must not log:
+// its own lines would come straight back through the hook.
+func (h *LogStreamHandler) run() {
+	defer h.workers.Done()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case entry := <-h.entries:
+			line := newLogLine(entry)
+			payload, err := json.Marshal(line)
+			if err != nil {
+				continue
+			}
+
+			h.mu.Lock()
+			h.recent[h.next] = line
+			h.next = (h.next + 1) % len(h.recent)
+			if h.next == 0 {
+				h.full = true
+			}
+			h.mu.Unlock()
+
+			h.mu.RLock()
+			for client := range h.clients {
+				if line.level < utils.LogLevel(client.level.Load()) {
+					continue
+				}
+				select {
+				case client.send <- payload:
+				default:
+					client.dropped.Add(1)
+				}
+			}
+			h.mu.RUnlock()
+		}
+	}
+}
+
+// newLogLine converts an entry, formatting field values as the text log does
+func newLogLine(entry utils.LogEntry) LogLine {
+	line := LogLine{
+		Type:      "log",
+		Level:     entry.Level.String(),
+		Component: entry.Component,
+		Message:   entry.Message,
+		Timestamp: entry.Time.UnixMilli(),
+		level:     entry.Level,
+	}
+	if len(entry.Fields) > 0 {
+		line.Fields = make(map[string]string, len(entry.Fields))
+		for key, value := range entry.Fields {
+			line.Fields[key] = fmt.Sprint(value)
+		}
+	}
+	return line
+}
+
+// backlog returns the recent lines at or above level, oldest first. The
+// caller holds mu.
+func (h *LogStreamHandler) backlog(level utils.LogLevel) []LogLine {
+	start, count := 0, h.next
+	if h.full {
+		start, count = h.next, len(h.recent)
+	}
+
+	var lines []LogLine
+	for i := 0; i < count; i++ {
+		line := h.recent[(start+i)%len(h.recent)]
+		if line.level >= level {
+			line.Backlog = true
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// HandleWebSocket streams log lines to one client, starting with the recent
+// backlog. The level defaults to INFO and can be set with ?level= or
+// changed later with {"type": "set_level", "level": "warn"}.
+func (h *LogStreamHandler) HandleWebSocket(c *websocket.Conn) {
+	level := utils.INFO
+	if name := c.Query("level"); name != "" {
+		if parsed, ok := utils.ParseLogLevel(name); ok {
+			level = parsed
+		}
+	}
+
+	client := &logClient{
+		conn: c,
+		send: make(chan []byte, logClientBufferSize),
+	}
+	client.level.Store(int32(level))
+
+	// The backlog is queued under the write lock, so no live line can slip
+	// in ahead of it or be sent twice
+	h.mu.Lock()
+	if h.closing {
+		h.mu.Unlock()
+		c.Close()
+		return
+	}
+	for _, line := range h.backlog(level) {
+		payload, err := json.Marshal(line)
+		if err != nil {
+			continue
+		}
+		select {
+		case client.send <- payload:
+		default:
+			client.dropped.Add(1)
+		}
+	}
+	h.clients[client] = true
+	h.pumps.Add(2)
+	h.mu.Unlock()
+
+	h.logger.WithFields(map[string]interface{}{
+		"remote_addr": c.RemoteAddr().String(),
+		"level":       level.String(),
+	}).Info("Log stream client connected")
+
+	writeDone := make(chan struct{})
+	go func() {
+		defer close(writeDone)
+		defer h.pumps.Done()
+		h.writeLogs(client)
+	}()
+
+	defer h.pumps.Done()
+	h.readLogCommands(client)
+
+	// The connection is recycled once this handler returns, so wait for
+	// writeLogs; readLogCommands has removed the client and closed its send
+	// channel, which ends it
+	<-writeDone
+}
+
+// removeClient removes a client and closes its send channel, once
+func (h *LogStreamHandler) removeClient(client *logClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[client] {
+		delete(h.clients, client)
+		close(client.send)
+	}
+}
+
+// readLogCommands applies set_level requests until the connection closes
+func (h *LogStreamHandler) readLogCommands(client *logClient) {
+	defer func() {
+		h.removeClient(client)
+		client.conn.Close()
+		h.logger.WithField("remote_addr", client.conn.RemoteAddr().String()).Info("Log stream client disconnected")
+	}()
+
+	client.conn.SetReadDeadline(time.Now().Add(70 * time.Second))
+	client.conn.SetPongHandler(func(string) error {
+		client.conn.SetReadDeadline(time.Now().Add(70 * time.Second))
+		return nil
+	})
+
+	for {
+		_, message, err := client.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		client.conn.SetReadDeadline(time.Now().Add(70 * time.Second))
+
+		var msg map[string]interface{}
+		if err := json.Unmarshal(message, &msg); err != nil {
+			h.sendLogReply(client, WebSocketMessage{
+				Type: "error",
+				Data: map[string]interface{}{"error": "invalid JSON"},
+			})
+			continue
+		}
+
+		msgType := getStringValue(msg, "type")
+		if msgType != "set_level" {
+			h.sendLogReply(client, WebSocketMessage{
+				Type: "error",
+				Data: map[string]interface{}{"request": msgType, "error": "unsupported type"},
+			})
+			continue
+		}
+
+		level, ok := utils.ParseLogLevel(getStringValue(msg, "level"))
+		if !ok {
+			h.sendLogReply(client, WebSocketMessage{
+				Type: "error",
+				Data: map[string]interface{}{"request": msgType, "error": "level must be debug, info, warn, error or fatal"},
+			})
+			continue
+		}
+		client.level.Store(int32(level))
+		h.sendLogReply(client, WebSocketMessage{
+			Type: "level_set",
+			Data: map[string]interface{}{"level": level.String()},
+		})
+	}
+}
+
+// sendLogReply queues a reply for a client still connected, dropping it
+// if the client's buffer is full
+func (h *LogStreamHandler) sendLogReply(client *logClient, message interface{}) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if !h.clients[client] {
+		return
+	}
+	select {
+	case client.send <- payload:
+	default:
+		client.dropped.Add(1)
+	}
+}
+
+// writeLogs writes queued lines to the connection. Once the buffer has
+// drained it reports lines the client missed in a lines_dropped notice;
+// total_dropped adds those the hook dropped for every client.
+func (h *LogStreamHandler) writeLogs(client *logClient) {
+	ticker := time.NewTicker(54 * time.Second)
+	defer func() {
+		ticker.Stop()
+		client.conn.Close()
+	}()
+
+	for {
+		select {
+		case payload, ok := <-client.send:
+			client.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if !ok {
+				client.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := client.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+
+			if len(client.send) == 0 {
+				if n := client.dropped.Swap(0); n > 0 {
+					notice, _ := json.Marshal(map[string]interface{}{
+						"type":          "lines_dropped",
+						"count":         n,
+						"total_dropped": h.dropped.Load(),
+						"timestamp":     time.Now().UnixMilli(),
+					})
+					if err := client.conn.WriteMessage(websocket.TextMessage, notice); err != nil {
+						return
+					}
+				}
+			}
+
+		case <-h.ctx.Done():
+			return
+
+		case <-ticker.C:
+			client.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
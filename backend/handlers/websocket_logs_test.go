@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"botrix-backend/utils"
+
+	fastws "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// serveLogStream starts a log stream on /ws/logs of a local server and
+// returns its address; both are shut down when the test ends
+func serveLogStream(t *testing.T) (*LogStreamHandler, string) {
+	t.Helper()
+	h := NewLogStreamHandler(utils.GetDefaultLogger())
+	app := fiber.New()
+	app.Get("/ws/logs", websocket.New(h.HandleWebSocket))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		h.Shutdown(ctx)
+		app.ShutdownWithTimeout(2 * time.Second)
+	})
+	return h, ln.Addr().String()
+}
+
+// dialLogStream connects to /ws/logs with the given query string and waits
+// until the stream has registered the client
+func dialLogStream(t *testing.T, h *LogStreamHandler, addr, query string) *fastws.Conn {
+	t.Helper()
+	before := logClientCount(h)
+	conn, _, err := fastws.DefaultDialer.Dial("ws://"+addr+"/ws/logs?"+query, nil)
+	if err != nil {
+		t.Fatalf("dialing /ws/logs: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	waitForLogClients(t, h, before+1)
+	return conn
+}
+
+// logClientCount is the number of clients registered with h
+func logClientCount(h *LogStreamHandler) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// waitForLogClients waits until h has n clients registered
+func waitForLogClients(t *testing.T, h *LogStreamHandler, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for logClientCount(h) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d log stream clients registered, want %d", logClientCount(h), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// logLines feeds h lines "<prefix> 0" to "<prefix> n-1" at level and
+// waits until the last has been recorded
+func logLines(t *testing.T, h *LogStreamHandler, level utils.LogLevel, prefix string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		h.Hook(utils.LogEntry{Time: time.Now(), Level: level, Component: "TEST", Message: fmt.Sprintf("%s %d", prefix, i)})
+	}
+	last := fmt.Sprintf("%s %d", prefix, n-1)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.mu.RLock()
+		recorded := h.recent[(h.next+len(h.recent)-1)%len(h.recent)].Message
+		h.mu.RUnlock()
+		if recorded == last {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%q was not recorded", last)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// readLogMessages reads n messages from a log stream
+func readLogMessages(t *testing.T, conn *fastws.Conn, n int) []map[string]interface{} {
+	t.Helper()
+	messages := make([]map[string]interface{}, n)
+	for i := range messages {
+		readWebSocketJSON(t, conn, &messages[i])
+	}
+	return messages
+}
+
+// expectNoLogMessage fails if conn receives anything within a short wait
+func expectNoLogMessage(t *testing.T, conn *fastws.Conn) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, message, err := conn.ReadMessage(); err == nil {
+		t.Errorf("unexpected message %s", message)
+	}
+}
+
+func TestLogStreamBacklog(t *testing.T) {
+	h, addr := serveLogStream(t)
+
+	// Only the last logStreamBacklog lines are kept: lines 60 to 249 at
+	// INFO and ten at DEBUG
+	logLines(t, h, utils.INFO, "info", 250)
+	logLines(t, h, utils.DEBUG, "debug", 10)
+
+	tests := []struct {
+		query string
+		first string
+		count int
+	}{
+		{"", "info 60", 190},
+		{"level=bogus", "info 60", 190},
+		{"level=debug", "info 60", logStreamBacklog},
+		{"level=warn", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			conn := dialLogStream(t, h, addr, tt.query)
+			defer func() {
+				conn.Close()
+				waitForLogClients(t, h, 0)
+			}()
+			lines := readLogMessages(t, conn, tt.count)
+			expectNoLogMessage(t, conn)
+			if tt.count == 0 {
+				return
+			}
+			if lines[0]["message"] != tt.first {
+				t.Errorf("backlog starts at %v, want %q", lines[0]["message"], tt.first)
+			}
+			for _, line := range lines {
+				if line["type"] != "log" || line["backlog"] != true {
+					t.Fatalf("backlog line %v, want a log line marked backlog", line)
+				}
+			}
+		})
+	}
+}
+
+func TestLogStreamLevels(t *testing.T) {
+	h, addr := serveLogStream(t)
+	conn := dialLogStream(t, h, addr, "level=warn")
+
+	// Lines below the client's level are skipped
+	h.Hook(utils.LogEntry{Time: time.Now(), Level: utils.INFO, Message: "skipped"})
+	h.Hook(utils.LogEntry{Time: time.Now(), Level: utils.WARN, Component: "QUEUE", Message: "kept", Fields: map[string]interface{}{"attempt": 3}})
+	var line map[string]interface{}
+	readWebSocketJSON(t, conn, &line)
+	delete(line, "timestamp")
+	want := map[string]interface{}{"type": "log", "level": "WARN", "component": "QUEUE", "message": "kept", "fields": map[string]interface{}{"attempt": "3"}}
+	if !reflect.DeepEqual(line, want) {
+		t.Errorf("received %v, want %v", line, want)
+	}
+
+	commands := []struct {
+		command string
+		want    map[string]interface{}
+	}{
+		{`{"type":"set_level"`, map[string]interface{}{"type": "error", "data": map[string]interface{}{"error": "invalid JSON"}}},
+		{`{"type":"subscribe"}`, map[string]interface{}{"type": "error", "data": map[string]interface{}{"request": "subscribe", "error": "unsupported type"}}},
+		{`{"type":"set_level","level":"verbose"}`, map[string]interface{}{"type": "error", "data": map[string]interface{}{"request": "set_level", "error": "level must be debug, info, warn, error or fatal"}}},
+		{`{"type":"set_level","level":"debug"}`, map[string]interface{}{"type": "level_set", "data": map[string]interface{}{"level": "DEBUG"}}},
+	}
+	for _, tt := range commands {
+		if err := conn.WriteMessage(fastws.TextMessage, []byte(tt.command)); err != nil {
+			t.Fatalf("sending %s: %v", tt.command, err)
+		}
+		var reply map[string]interface{}
+		readWebSocketJSON(t, conn, &reply)
+		if !reflect.DeepEqual(reply, tt.want) {
+			t.Errorf("reply to %s = %v, want %v", tt.command, reply, tt.want)
+		}
+	}
+
+	// The new level applies to the next line
+	h.Hook(utils.LogEntry{Time: time.Now(), Level: utils.DEBUG, Message: "now sent"})
+	readWebSocketJSON(t, conn, &line)
+	if line["message"] != "now sent" || line["backlog"] != nil {
+		t.Errorf("received %v, want the live DEBUG line", line)
+	}
+}
+
+func TestLogStreamCleanup(t *testing.T) {
+	h, addr := serveLogStream(t)
+	leaving := dialLogStream(t, h, addr, "")
+	staying := dialLogStream(t, h, addr, "")
+
+	// A client that disconnects is removed and no longer sent lines
+	leaving.Close()
+	waitForLogClients(t, h, 1)
+	logLines(t, h, utils.INFO, "after", 1)
+	if lines := readLogMessages(t, staying, 1); lines[0]["message"] != "after 0" {
+		t.Errorf("received %v, want the line logged after the other client left", lines[0])
+	}
+
+	// The client answers the close frame as browsers do
+	closed := make(chan error, 1)
+	go func() {
+		staying.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, _, err := staying.ReadMessage()
+		closed <- err
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := h.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v, want every client closed in time", err)
+	}
+	err := <-closed
+	if closeErr, ok := err.(*fastws.CloseError); !ok || closeErr.Code != fastws.CloseGoingAway {
+		t.Errorf("read %v after Shutdown, want a going-away close frame", err)
+	}
+	if n := logClientCount(h); n != 0 {
+		t.Errorf("%d log stream clients still registered after Shutdown", n)
+	}
+
+	// With the fan-out stopped the hook drops lines rather than blocking
+	// the logger
+	for i := 0; i < logStreamQueueSize+10; i++ {
+		h.Hook(utils.LogEntry{Time: time.Now(), Level: utils.INFO, Message: "late"})
+	}
+	if h.dropped.Load() == 0 {
+		t.Error("no lines dropped with the queue full")
+	}
+}
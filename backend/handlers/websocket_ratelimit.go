@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// connectSweepInterval is how often idle buckets are dropped
+const connectSweepInterval = time.Minute
+
+// connectLimiter limits connection attempts per IP with a token bucket:
+// each IP may open burst connections at once, refilled at rate per second.
+type connectLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+
+	rate           float64 // tokens added per second
+	burst          float64
+	exemptLoopback bool
+
+	rejected atomic.Uint64
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// newConnectLimiter creates a limiter allowing perMinute attempts a minute
+// per IP after an initial burst; it returns nil, no limit, for perMinute 0
+func newConnectLimiter(perMinute, burst int, exemptLoopback bool) *connectLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &connectLimiter{
+		buckets:        make(map[string]*tokenBucket),
+		lastSweep:      time.Now(),
+		rate:           float64(perMinute) / 60,
+		burst:          float64(burst),
+		exemptLoopback: exemptLoopback,
+	}
+}
+
+// allow takes a token for ip, or reports how long until one is available
+func (l *connectLimiter) allow(ip string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= connectSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[ip]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, updated: now}
+		l.buckets[ip] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		l.rejected.Add(1)
+		return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep drops buckets that have refilled, as they behave like new ones.
+// The caller holds mu.
+func (l *connectLimiter) sweep(now time.Time) {
+	for ip, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, ip)
+		}
+	}
+	l.lastSweep = now
+}
+
+// stats reports the limits, the IPs being tracked and the rejected attempts
+func (l *connectLimiter) stats() fiber.Map {
+	if l == nil {
+		return fiber.Map{"enabled": false}
+	}
+	l.mu.Lock()
+	tracked := len(l.buckets)
+	l.mu.Unlock()
+
+	return fiber.Map{
+		"enabled":         true,
+		"rate_per_minute": l.rate * 60,
+		"burst":           int(l.burst),
+		"tracked_ips":     tracked,
+		"rejected":        l.rejected.Load(),
+	}
+}
+
+// UpgradeRateLimit returns middleware for /ws that limits upgrade attempts
+// per IP, rejecting excess ones with 429 and Retry-After before any
+// connection state is set up. Loopback addresses are exempt when so
+// configured, as they are in development by default.
+func (h *WebSocketHandler) UpgradeRateLimit() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if h.upgrades == nil || !websocket.IsWebSocketUpgrade(c) {
+			return c.Next()
+		}
+
+		ip := c.IP()
+		if h.upgrades.exemptLoopback {
+			if parsed := net.ParseIP(ip); parsed != nil && parsed.IsLoopback() {
+				return c.Next()
+			}
+		}
+
+		allowed, wait := h.upgrades.allow(ip, time.Now())
+		if allowed {
+			return c.Next()
+		}
+
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		h.logger.WithFields(map[string]interface{}{
+			"ip":          ip,
+			"retry_after": retryAfter,
+		}).Warn("WebSocket connection attempts rate limited")
+
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"success":             false,
+			"error":               "Too many connection attempts",
+			"retry_after_seconds": retryAfter,
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net"
+	"time"
+
+	"botrix-backend/services"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Delays between attempts to re-establish the Redis subscription, doubling
+// from the base up to the maximum
+const (
+	resubscribeBaseDelay = 1 * time.Second
+	resubscribeMaxDelay  = 30 * time.Second
+)
+
+// subscriptionPingInterval is how long the subscription may stay quiet
+// before it is pinged to check that the connection is still alive
+const subscriptionPingInterval = 30 * time.Second
+
+// SubscriptionHealthy reports whether the Redis subscription relaying job
+// updates is currently established
+func (h *WebSocketHandler) SubscriptionHealthy() bool {
+	return h.subscriptionHealthy.Load()
+}
+
+// subscribeToRedis relays job updates from Redis pub/sub to clients. When
+// the subscription fails or drops it is re-established with exponential
+// backoff and jitter until the handler shuts down.
+func (h *WebSocketHandler) subscribeToRedis() {
+	defer h.workers.Done()
+
+	// Progress events wait in pending until the next flush. Only this
+	// goroutine touches pending, so a job's events leave in order, and it
+	// outlives any one subscription.
+	pending := make(map[string]WebSocketMessage)
+	var flush <-chan time.Time
+	if h.coalesceWindow > 0 {
+		ticker := time.NewTicker(h.coalesceWindow)
+		defer ticker.Stop()
+		flush = ticker.C
+	}
+
+	failures := 0
+	resubscribe := false
+	for {
+		subscribed, stopped := h.listen(pending, flush, resubscribe)
+		if stopped {
+			return
+		}
+		if subscribed {
+			// Events published while reconnecting are lost
+			failures = 0
+			resubscribe = true
+		}
+
+		failures++
+		delay := resubscribeDelay(failures)
+		h.logger.WithFields(map[string]interface{}{
+			"attempt": failures,
+			"retry":   delay.String(),
+		}).Warn("Redis subscription lost, resubscribing")
+
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// listen subscribes once and relays messages until the subscription ends.
+// It reports whether the subscription was established and whether the
+// handler is stopping. After a resubscribe, clients are told to resync.
+func (h *WebSocketHandler) listen(pending map[string]WebSocketMessage, flush <-chan time.Time, resubscribe bool) (subscribed, stopped bool) {
+	pubsub, err := h.queue.SubscribeContext(h.ctx, services.JobUpdatesChannel, services.AccountUpdatesChannel)
+	if err != nil {
+		if h.ctx.Err() != nil {
+			return false, true
+		}
+		h.logger.WithField("error", err.Error()).Error("Failed to subscribe to Redis channel")
+		return false, false
+	}
+
+	h.logger.Info("Subscribed to Redis channels: " + services.JobUpdatesChannel + ", " + services.AccountUpdatesChannel)
+	h.subscriptionHealthy.Store(true)
+	defer h.subscriptionHealthy.Store(false)
+	h.subscribedOnce.Do(func() { close(h.subscribed) })
+	if resubscribe {
+		h.recommendResync()
+	}
+
+	// Listen for messages. The reader ends once pubsub is closed on return.
+	messages := make(chan *redis.Message)
+	dropped := make(chan error, 1)
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		h.receive(pubsub, messages, dropped)
+	}()
+	defer func() {
+		pubsub.Close()
+		<-readerDone
+	}()
+
+	for {
+		var msg *redis.Message
+		select {
+		case <-h.ctx.Done():
+			return true, true
+		case <-flush:
+			if !h.flushPending(pending) {
+				return true, true
+			}
+			continue
+		case err := <-dropped:
+			if h.ctx.Err() != nil {
+				return true, true
+			}
+			h.logger.WithField("error", err.Error()).Error("Redis subscription connection lost")
+			return true, false
+		case msg = <-messages:
+		}
+
+		// Parse the Redis message
+		var redisData map[string]interface{}
+		if err := json.Unmarshal([]byte(msg.Payload), &redisData); err != nil {
+			h.logger.WithField("error", err.Error()).Error("Failed to parse Redis message")
+			continue
+		}
+
+		// Create WebSocket message. Settings changes and account events are
+		// relayed under their own types so the UI need not inspect job
+		// updates for them.
+		wsMessage := WebSocketMessage{
+			Type:   "job_update",
+			JobID:  getStringValue(redisData, "job_id"),
+			Status: getStringValue(redisData, "status"),
+			Data:   redisData,
+		}
+		if msg.Channel == services.AccountUpdatesChannel {
+			wsMessage = WebSocketMessage{Type: AccountUpdateMessage, Data: redisData}
+		} else if getStringValue(redisData, "event") == services.SettingsUpdatedEvent {
+			wsMessage.Type = services.SettingsUpdatedEvent
+		}
+		wsMessage.Timestamp = time.Now().UnixMilli()
+
+		// A failed lookup degrades to the raw event, never drops it
+		enriched, err := h.enricher.enrich(wsMessage, time.Now())
+		if err != nil {
+			h.logger.WithFields(map[string]interface{}{
+				"job_id": wsMessage.JobID,
+				"error":  err.Error(),
+			}).Debug("Failed to enrich job update")
+		}
+		wsMessage = enriched
+
+		if !h.relay(wsMessage, pending) {
+			return true, true
+		}
+	}
+}
+
+// receive reads messages from pubsub into messages until the connection
+// fails, then reports the error on dropped. pubsub.Channel() would
+// reconnect behind the handler's back, so missed events would go unnoticed;
+// reading directly lets listen resubscribe and recommend a resync instead.
+// A quiet connection is pinged every subscriptionPingInterval.
+func (h *WebSocketHandler) receive(pubsub *redis.PubSub, messages chan<- *redis.Message, dropped chan<- error) {
+	for {
+		received, err := pubsub.ReceiveTimeout(h.ctx, subscriptionPingInterval)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				if err = pubsub.Ping(h.ctx); err == nil {
+					continue
+				}
+			}
+			dropped <- err
+			return
+		}
+
+		if msg, ok := received.(*redis.Message); ok {
+			select {
+			case messages <- msg:
+			case <-h.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// recommendResync tells every client that updates may have been missed
+// while the subscription was down, so they should refetch job state. It
+// bypasses event filters and is not replayed.
+func (h *WebSocketHandler) recommendResync() {
+	sent, _ := h.broadcastDirect(WebSocketMessage{
+		Type: "resync_recommended",
+		Data: map[string]interface{}{"reason": "redis subscription re-established"},
+	})
+	h.logger.WithField("clients", sent).Info("Recommended resync after resubscribing")
+}
+
+// resubscribeDelay is the wait before the given retry attempt: exponential
+// from resubscribeBaseDelay, capped at resubscribeMaxDelay, with the upper
+// half randomized so instances do not reconnect in lockstep
+func resubscribeDelay(attempt int) time.Duration {
+	delay := resubscribeMaxDelay
+	if attempt < 6 {
+		delay = resubscribeBaseDelay << (attempt - 1)
+		if delay > resubscribeMaxDelay {
+			delay = resubscribeMaxDelay
+		}
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"botrix-backend/models"
+	"botrix-backend/services"
+
+	"github.com/google/uuid"
+)
+
+// publishJobUpdate publishes a worker-style update on the job updates channel
+func publishJobUpdate(t *testing.T, queue *services.QueueService, update map[string]interface{}) {
+	t.Helper()
+	payload, err := json.Marshal(update)
+	if err != nil {
+		t.Fatalf("encoding update: %v", err)
+	}
+	if err := queue.GetRedisClient().Publish(context.Background(), services.JobUpdatesChannel, payload).Err(); err != nil {
+		t.Fatalf("publishing update: %v", err)
+	}
+}
+
+func TestJobAndAccountChannelsWithReconciler(t *testing.T) {
+	h, _ := newTestWebSocketHandler(t, newTestConfig(t))
+
+	// The watcher and reconciler both consume terminal job updates, as in main
+	if err := services.NewJobReconciler(h.db, h.queue).Start(); err != nil {
+		t.Fatalf("starting reconciler: %v", err)
+	}
+	if err := services.NewJobWatcher(h.db, h.queue, nil, services.NewWebhookDispatcher(h.db)).Start(); err != nil {
+		t.Fatalf("starting watcher: %v", err)
+	}
+	client := registerHubClient(t, h, "watcher-client", AllJobs)
+
+	// One job still running, one the reconciler has already completed
+	running := &models.Job{ID: uuid.New().String(), Type: models.JobTypeGenerate, Count: 3, Status: models.JobStatusRunning}
+	reconciled := &models.Job{ID: uuid.New().String(), Type: models.JobTypeGenerate, Count: 2, Status: models.JobStatusRunning}
+	for _, job := range []*models.Job{running, reconciled} {
+		if err := h.db.CreateJob(job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+	reconciled.Complete()
+	if err := h.db.UpdateJob(reconciled); err != nil {
+		t.Fatalf("UpdateJob: %v", err)
+	}
+
+	// Workers may publish a terminal update more than once
+	for i := 0; i < 2; i++ {
+		for _, job := range []*models.Job{running, reconciled} {
+			publishJobUpdate(t, h.queue, map[string]interface{}{
+				"job_id": job.ID,
+				"status": "completed",
+				"result": map[string]interface{}{"accounts_created": job.Count},
+			})
+		}
+	}
+	h.queue.PublishAccountEvent("account_status_changed", 7, map[string]interface{}{"new_status": models.AccountStatusBanned})
+
+	announced := map[string]int{}
+	jobUpdates := map[string]int{}
+	statusChanges := 0
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		done := len(announced) == 2 && len(jobUpdates) == 2 && statusChanges == 1
+		// Once everything expected has arrived, wait a little for duplicates
+		wait := time.Until(deadline)
+		if done {
+			wait = 300 * time.Millisecond
+		}
+		message, ok := nextMessage(t, client, wait)
+		if !ok {
+			break
+		}
+
+		switch message.Type {
+		case "job_update":
+			if message.Status == "completed" {
+				jobUpdates[message.JobID]++
+			}
+		case AccountUpdateMessage:
+			switch services.MessageEvent(message) {
+			case "accounts_created":
+				data, _ := message.Data["data"].(map[string]interface{})
+				jobID, _ := data["job_id"].(string)
+				announced[jobID]++
+				if count, _ := data["count"].(float64); jobID != "" && int(count) != map[string]int{running.ID: 3, reconciled.ID: 2}[jobID] {
+					t.Errorf("accounts_created for %s counts %v accounts", jobID, data["count"])
+				}
+			case "account_status_changed":
+				statusChanges++
+			}
+		}
+	}
+
+	for _, job := range []*models.Job{running, reconciled} {
+		if announced[job.ID] != 1 {
+			t.Errorf("job %s announced %d times, want once", job.ID, announced[job.ID])
+		}
+		if jobUpdates[job.ID] != 2 {
+			t.Errorf("job %s relayed %d completed updates, want both", job.ID, jobUpdates[job.ID])
+		}
+	}
+	if statusChanges != 1 {
+		t.Errorf("account_status_changed relayed %d times, want once", statusChanges)
+	}
+
+	if job, err := h.db.GetJob(running.ID); err != nil || job.Status != models.JobStatusCompleted {
+		t.Errorf("running job after its terminal update = %v, %v, want completed", job, err)
+	}
+}
+
+// waitForSubscription waits until the handler's Redis subscription is up
+// or down, as healthy says
+func waitForSubscription(t *testing.T, h *WebSocketHandler, healthy bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for h.SubscriptionHealthy() != healthy {
+		if time.Now().After(deadline) {
+			t.Fatalf("subscription healthy = %v, want %v", !healthy, healthy)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRedisRestartResubscribes(t *testing.T) {
+	h, server := newTestWebSocketHandler(t, newTestConfig(t))
+	client := registerHubClient(t, h, "restart-client", AllJobs)
+
+	publishJobUpdate(t, h.queue, map[string]interface{}{"job_id": "job-1", "status": "running"})
+	if message, ok := nextMessage(t, client, 2*time.Second); !ok || message.JobID != "job-1" {
+		t.Fatalf("before the restart: received %+v, %v, want the job-1 update", message, ok)
+	}
+
+	// Redis goes away mid-stream and comes back on the same address
+	addr := server.Addr()
+	server.Close()
+	waitForSubscription(t, h, false)
+	if err := server.StartAddr(addr); err != nil {
+		t.Fatalf("restarting Redis: %v", err)
+	}
+
+	// Clients are told they may have missed events once it is back
+	for {
+		message, ok := nextMessage(t, client, 5*time.Second)
+		if !ok {
+			t.Fatal("no resync_recommended after Redis came back")
+		}
+		if message.Type == "resync_recommended" {
+			break
+		}
+	}
+	waitForSubscription(t, h, true)
+
+	publishJobUpdate(t, h.queue, map[string]interface{}{"job_id": "job-2", "status": "completed"})
+	if message, ok := nextMessage(t, client, 2*time.Second); !ok || message.JobID != "job-2" || message.Status != "completed" {
+		t.Errorf("after the restart: received %+v, %v, want the job-2 update", message, ok)
+	}
+}
+
+func TestPublishUpdateReachesConnectedClient(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.WebSocket.AllowAnonymous = true
+	h, _ := newTestWebSocketHandler(t, cfg)
+	conn, _ := connectWebSocket(t, serveWebSocket(t, h, cfg.WebSocket), "")
+
+	if err := conn.WriteJSON(map[string]string{"type": "subscribe", "job_id": "job-1"}); err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	var ack WebSocketMessage
+	if readWebSocketJSON(t, conn, &ack); ack.Type != "subscribed" {
+		t.Fatalf("subscription ack = %+v", ack)
+	}
+
+	// Updates published by the queue arrive on the shared channel; other
+	// jobs' updates are not sent to the client
+	if err := h.queue.UpdateJobStatus("job-2", string(models.JobStatusRunning)); err != nil {
+		t.Fatalf("UpdateJobStatus: %v", err)
+	}
+	if err := h.queue.UpdateJobStatus("job-1", string(models.JobStatusRunning)); err != nil {
+		t.Fatalf("UpdateJobStatus: %v", err)
+	}
+	var update WebSocketMessage
+	readWebSocketJSON(t, conn, &update)
+	data, _ := update.Data["data"].(map[string]interface{})
+	if update.Type != "job_update" || update.JobID != "job-1" || services.MessageEvent(update) != "status_updated" || data["status"] != "running" {
+		t.Errorf("received %+v, want job-1's status_updated event", update)
+	}
+
+	// Events without a job reach every client under their own type
+	h.queue.PublishEvent(services.SettingsUpdatedEvent, map[string]interface{}{"changes": []string{"worker_count"}})
+	var settings WebSocketMessage
+	if readWebSocketJSON(t, conn, &settings); settings.Type != services.SettingsUpdatedEvent {
+		t.Errorf("received %+v, want %s", settings, services.SettingsUpdatedEvent)
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"math"
+
+	"botrix-backend/services"
+)
+
+// handleResume replays the buffered events numbered after last_seq that
+// the client wants, then sends "replay_complete". If the buffer no longer
+// holds every event since last_seq the client gets "resync_required"
+// instead and should refetch its state.
+func (h *WebSocketHandler) handleResume(client *Client, _ string, msg map[string]interface{}) error {
+	lastSeq, ok := msg["last_seq"].(float64)
+	if !ok || lastSeq < 0 || lastSeq != math.Trunc(lastSeq) {
+		return &requestError{message: "last_seq must be a non-negative integer"}
+	}
+
+	events, oldest, covered := h.hub.Replay().After(uint64(lastSeq))
+	if !covered {
+		h.logger.WithFields(map[string]interface{}{
+			"client_id":  client.ID,
+			"last_seq":   uint64(lastSeq),
+			"oldest_seq": oldest,
+		}).Debug("Resume not covered by replay buffer")
+
+		h.sendToClient(client, WebSocketMessage{
+			Type: "resync_required",
+			Data: map[string]interface{}{
+				"last_seq":   uint64(lastSeq),
+				"oldest_seq": oldest,
+				"latest_seq": h.hub.Replay().Seq(),
+			},
+		})
+		return nil
+	}
+
+	count := 0
+	for _, message := range events {
+		if !client.Wants(message.JobID, services.MessageEvent(message)) {
+			continue
+		}
+		message.Replayed = true
+		h.sendToClient(client, message)
+		count++
+	}
+
+	h.logger.WithFields(map[string]interface{}{
+		"client_id": client.ID,
+		"last_seq":  uint64(lastSeq),
+		"events":    count,
+	}).Debug("Resumed from sequence number")
+
+	h.sendToClient(client, WebSocketMessage{
+		Type: "replay_complete",
+		Data: map[string]interface{}{"count": count},
+	})
+	return nil
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"botrix-backend/services"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// resume sends a resume message with lastSeq, which may be any JSON value,
+// and returns the replies
+func resume(t *testing.T, h *WebSocketHandler, client *Client, lastSeq interface{}) []map[string]interface{} {
+	t.Helper()
+	message, err := json.Marshal(map[string]interface{}{"type": "resume", "last_seq": lastSeq})
+	if err != nil {
+		t.Fatalf("encoding resume: %v", err)
+	}
+	h.handleClientMessage(client, websocket.TextMessage, message)
+	return replies(t, client)
+}
+
+// replayedSeqs lists the sequence numbers of the replayed events in
+// messages, failing on any that is not marked as replayed
+func replayedSeqs(t *testing.T, messages []map[string]interface{}) []float64 {
+	t.Helper()
+	seqs := []float64{}
+	for _, message := range messages {
+		if message["type"] == "replay_complete" {
+			continue
+		}
+		if message["replayed"] != true {
+			t.Errorf("%v is not marked as replayed", message)
+		}
+		seqs = append(seqs, message["seq"].(float64))
+	}
+	return seqs
+}
+
+// broadcastAll broadcasts messages and waits until the hub has delivered
+// them, so clients registered afterwards do not receive them live
+func broadcastAll(t *testing.T, h *WebSocketHandler, messages []services.HubMessage) {
+	t.Helper()
+	sink := registerHubClient(t, h, "sink", AllJobs)
+	for _, message := range messages {
+		h.hub.Broadcast(message)
+	}
+	for range messages {
+		if _, ok := nextMessage(t, sink, 2*time.Second); !ok {
+			t.Fatal("broadcast was not delivered")
+		}
+	}
+	h.hub.Unregister(sink)
+}
+
+func TestResumeReplaysAfterLastSeq(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.WebSocket.ReplayBufferSize = 4
+	h, _ := newTestWebSocketHandler(t, cfg)
+
+	// Six events through a buffer of four leave 3 to 6 buffered
+	var events []services.HubMessage
+	for i := 1; i <= 6; i++ {
+		jobID := "job-1"
+		if i%2 == 0 {
+			jobID = "job-2"
+		}
+		events = append(events, services.HubMessage{Type: "job_update", JobID: jobID, Data: map[string]interface{}{"event": "job_progress"}})
+	}
+	broadcastAll(t, h, events)
+
+	tests := []struct {
+		name    string
+		jobIDs  []string
+		lastSeq uint64
+		want    []float64
+	}{
+		{"partial", []string{AllJobs}, 4, []float64{5, 6}},
+		{"oldest buffered", []string{AllJobs}, 2, []float64{3, 4, 5, 6}},
+		{"up to date", []string{AllJobs}, 6, []float64{}},
+		{"only subscribed jobs", []string{"job-1"}, 2, []float64{3, 5}},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{HubClient: registerHubClient(t, h, fmt.Sprintf("client-%d", i), tt.jobIDs...)}
+			got := resume(t, h, client, tt.lastSeq)
+			if seqs := replayedSeqs(t, got); !reflect.DeepEqual(seqs, tt.want) {
+				t.Errorf("replayed %v, want %v", seqs, tt.want)
+			}
+			last := got[len(got)-1]
+			if last["type"] != "replay_complete" || last["data"].(map[string]interface{})["count"] != float64(len(tt.want)) {
+				t.Errorf("last reply = %v, want replay_complete with count %d", last, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestResumeOutsideBufferRequiresResync(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.WebSocket.ReplayBufferSize = 4
+	h, _ := newTestWebSocketHandler(t, cfg)
+	events := make([]services.HubMessage, 6)
+	for i := range events {
+		events[i] = services.HubMessage{Type: "job_update", JobID: "job-1"}
+	}
+	broadcastAll(t, h, events)
+
+	// Events 1 and 2 were overwritten, so resuming before them leaves a gap;
+	// a last_seq ahead of the server's means it restarted
+	for _, lastSeq := range []float64{0, 1, 7, 1000} {
+		client := &Client{HubClient: registerHubClient(t, h, fmt.Sprintf("client-%v", lastSeq), AllJobs)}
+		got := resume(t, h, client, lastSeq)
+		want := []map[string]interface{}{{
+			"type": "resync_required",
+			"data": map[string]interface{}{"last_seq": lastSeq, "oldest_seq": float64(3), "latest_seq": float64(6)},
+		}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("resuming from %v: %v, want %v", lastSeq, got, want)
+		}
+	}
+}
+
+func TestResumeWithoutReplay(t *testing.T) {
+	h, _ := newTestWebSocketHandler(t, newTestConfig(t))
+	broadcastAll(t, h, []services.HubMessage{{Type: "job_update", JobID: "job-1"}})
+	client := &Client{HubClient: registerHubClient(t, h, "client", AllJobs)}
+
+	// With nothing buffered, only an up-to-date client is covered
+	if got := resume(t, h, client, 1); len(got) != 1 || got[0]["type"] != "replay_complete" {
+		t.Errorf("resuming from the latest event: %v, want replay_complete", got)
+	}
+	if got := resume(t, h, client, 0); len(got) != 1 || got[0]["type"] != "resync_required" {
+		t.Errorf("resuming from an earlier event: %v, want resync_required", got)
+	}
+}
+
+func TestResumeRejectsInvalidLastSeq(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.WebSocket.ReplayBufferSize = 4
+	h, _ := newTestWebSocketHandler(t, cfg)
+	client := &Client{HubClient: registerHubClient(t, h, "client", AllJobs)}
+
+	for _, lastSeq := range []interface{}{nil, -1, 1.5, "3", []int{3}} {
+		want := []map[string]interface{}{{
+			"type": "error",
+			"data": map[string]interface{}{"request": "resume", "error": "last_seq must be a non-negative integer"},
+		}}
+		if got := resume(t, h, client, lastSeq); !reflect.DeepEqual(got, want) {
+			t.Errorf("resuming from %v: %v, want %v", lastSeq, got, want)
+		}
+	}
+}
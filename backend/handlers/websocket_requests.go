@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"botrix-backend/services"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// AckMessage answers a client message. Every recognized message carrying
+// an "id" gets one once it has been handled, with the same id:
+//
+//	{"type": "ack", "id": 7, "ok": true}
+//	{"type": "ack", "id": 8, "ok": false, "error": "job_id is required"}
+//
+// Messages of unknown types and messages that are not JSON are refused
+// with ok false whether or not they carry an id.
+type AckMessage struct {
+	Type  string                 `json:"type"`
+	ID    interface{}            `json:"id,omitempty"`
+	OK    bool                   `json:"ok"`
+	Error string                 `json:"error,omitempty"`
+	Data  map[string]interface{} `json:"data,omitempty"`
+}
+
+// requestError is a client message that could not be applied. data adds
+// detail for the client, such as how long to wait before retrying.
+type requestError struct {
+	message string
+	data    map[string]interface{}
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// requestHandler applies one type of client message. Replies beyond the
+// ack, such as "subscribed", are its own to send.
+type requestHandler func(h *WebSocketHandler, client *Client, msgType string, msg map[string]interface{}) error
+
+// requestHandlers maps each client message type to its handler; a type not
+// listed here is unsupported
+var requestHandlers = map[string]requestHandler{
+	"ping":              (*WebSocketHandler).handlePing,
+	"subscribe":         (*WebSocketHandler).handleSubscription,
+	"unsubscribe":       (*WebSocketHandler).handleSubscription,
+	"set_filter":        (*WebSocketHandler).handleSetFilter,
+	"set_encoding":      (*WebSocketHandler).handleSetEncoding,
+	"get_stats":         (*WebSocketHandler).handleGetStats,
+	"subscribe_stats":   (*WebSocketHandler).handleSubscribeStats,
+	"unsubscribe_stats": (*WebSocketHandler).handleUnsubscribeStats,
+	"hello":             (*WebSocketHandler).handleHello,
+	"resume":            (*WebSocketHandler).handleResume,
+}
+
+// handleClientMessage dispatches one message from a client and answers it
+// by the AckMessage convention. Without an id, a failed request gets the
+// older {"type": "error"} reply instead of an ack.
+func (h *WebSocketHandler) handleClientMessage(client *Client, messageType int, message []byte) {
+	if messageType != websocket.TextMessage {
+		h.sendAck(client, nil, &requestError{message: "messages must be JSON text"})
+		return
+	}
+
+	var msg map[string]interface{}
+	if err := json.Unmarshal(message, &msg); err != nil {
+		h.sendAck(client, nil, &requestError{message: "invalid JSON"})
+		return
+	}
+	msgType, _ := msg["type"].(string)
+	id := msg["id"]
+
+	handle, ok := requestHandlers[msgType]
+	if !ok {
+		h.logger.WithFields(map[string]interface{}{
+			"client_id": client.ID,
+			"type":      msgType,
+		}).Debug("Received message of unsupported type")
+		h.sendAck(client, id, &requestError{message: "unsupported type"})
+		return
+	}
+
+	err := handle(h, client, msgType, msg)
+	if id != nil {
+		h.sendAck(client, id, err)
+		return
+	}
+	if reqErr, ok := err.(*requestError); ok {
+		data := map[string]interface{}{
+			"request": msgType,
+			"error":   reqErr.message,
+		}
+		for key, value := range reqErr.data {
+			data[key] = value
+		}
+		h.sendToClient(client, WebSocketMessage{Type: "error", Data: data})
+	}
+}
+
+// sendAck answers a client message: ok when err is nil, otherwise with the
+// error and any detail it carries
+func (h *WebSocketHandler) sendAck(client *Client, id interface{}, err error) {
+	ack := AckMessage{Type: "ack", ID: id, OK: err == nil}
+	if err != nil {
+		ack.Error = err.Error()
+		if reqErr, ok := err.(*requestError); ok {
+			ack.Data = reqErr.data
+		}
+	}
+	h.sendToClient(client, ack)
+}
+
+// handlePing answers an application-level ping with a pong
+func (h *WebSocketHandler) handlePing(client *Client, _ string, _ map[string]interface{}) error {
+	h.logger.WithField("client_id", client.ID).Debug("Received ping, sending pong")
+
+	// The client may have been evicted, so never write to SendChan directly
+	h.sendToClient(client, WebSocketMessage{
+		Type:      "pong",
+		Timestamp: time.Now().UnixMilli(),
+	})
+	return nil
+}
+
+// handleHello replays the buffered events the client missed since the
+// "since" timestamp
+func (h *WebSocketHandler) handleHello(client *Client, _ string, msg map[string]interface{}) error {
+	since, _ := msg["since"].(float64)
+	h.replayTo(client, int64(since))
+	return nil
+}
+
+// handleSetEncoding switches the encoding of the messages sent to a client
+// and acknowledges with "encoding_set", already in the new encoding
+func (h *WebSocketHandler) handleSetEncoding(client *Client, _ string, msg map[string]interface{}) error {
+	encoding := getStringValue(msg, "encoding")
+	if encoding != services.EncodingJSON && encoding != services.EncodingMsgpack {
+		return &requestError{message: fmt.Sprintf("encoding must be %q or %q", services.EncodingJSON, services.EncodingMsgpack)}
+	}
+
+	client.SetEncoding(encoding)
+	h.sendToClient(client, WebSocketMessage{
+		Type: "encoding_set",
+		Data: map[string]interface{}{"encoding": encoding},
+	})
+
+	h.logger.WithFields(map[string]interface{}{
+		"client_id": client.ID,
+		"encoding":  encoding,
+	}).Debug("Client changed encoding")
+	return nil
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// newRequestTestClient registers a client without a connection for calling
+// handleClientMessage directly
+func newRequestTestClient(t *testing.T, h *WebSocketHandler, id string) *Client {
+	t.Helper()
+	return &Client{HubClient: registerHubClient(t, h, id)}
+}
+
+// replies decodes every message queued for client so far
+func replies(t *testing.T, client *Client) []map[string]interface{} {
+	t.Helper()
+	var decoded []map[string]interface{}
+	for {
+		select {
+		case frame := <-client.SendChan:
+			var message map[string]interface{}
+			if err := json.Unmarshal(frame.Data, &message); err != nil {
+				t.Fatalf("decoding %s: %v", frame.Data, err)
+			}
+			delete(message, "timestamp")
+			decoded = append(decoded, message)
+		case <-time.After(50 * time.Millisecond):
+			return decoded
+		}
+	}
+}
+
+func TestClientMessageReplies(t *testing.T) {
+	h, _ := newTestWebSocketHandler(t, newTestConfig(t))
+
+	tests := []struct {
+		name        string
+		messageType int
+		message     string
+		want        []map[string]interface{}
+	}{
+		{
+			name:        "binary message",
+			messageType: websocket.BinaryMessage,
+			message:     `{"type":"ping"}`,
+			want:        []map[string]interface{}{{"type": "ack", "ok": false, "error": "messages must be JSON text"}},
+		},
+		{
+			name:    "malformed JSON",
+			message: `{"type":"subscribe",`,
+			want:    []map[string]interface{}{{"type": "ack", "ok": false, "error": "invalid JSON"}},
+		},
+		{
+			name:    "not an object",
+			message: `["subscribe"]`,
+			want:    []map[string]interface{}{{"type": "ack", "ok": false, "error": "invalid JSON"}},
+		},
+		{
+			name:    "unknown type",
+			message: `{"type":"launch","id":3}`,
+			want:    []map[string]interface{}{{"type": "ack", "id": float64(3), "ok": false, "error": "unsupported type"}},
+		},
+		{
+			name:    "unknown type without id",
+			message: `{"type":"launch"}`,
+			want:    []map[string]interface{}{{"type": "ack", "ok": false, "error": "unsupported type"}},
+		},
+		{
+			name:    "missing type",
+			message: `{"job_id":"job-1"}`,
+			want:    []map[string]interface{}{{"type": "ack", "ok": false, "error": "unsupported type"}},
+		},
+		{
+			name:    "subscribe",
+			message: `{"type":"subscribe","job_id":"job-1"}`,
+			want:    []map[string]interface{}{{"type": "subscribed", "job_id": "job-1"}},
+		},
+		{
+			name:    "subscribe with id",
+			message: `{"type":"subscribe","job_id":"job-1","id":"req-1"}`,
+			want: []map[string]interface{}{
+				{"type": "subscribed", "job_id": "job-1"},
+				{"type": "ack", "id": "req-1", "ok": true},
+			},
+		},
+		{
+			name:    "subscribe without job",
+			message: `{"type":"subscribe"}`,
+			want:    []map[string]interface{}{{"type": "error", "data": map[string]interface{}{"request": "subscribe", "error": "job_id is required"}}},
+		},
+		{
+			name:    "subscribe without job, with id",
+			message: `{"type":"subscribe","job_id":"","id":9}`,
+			want:    []map[string]interface{}{{"type": "ack", "id": float64(9), "ok": false, "error": "job_id is required"}},
+		},
+		{
+			name:    "unsubscribe",
+			message: `{"type":"unsubscribe","job_id":"job-1"}`,
+			want:    []map[string]interface{}{{"type": "unsubscribed", "job_id": "job-1"}},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newRequestTestClient(t, h, fmt.Sprintf("client-%d", i))
+			messageType := tt.messageType
+			if messageType == 0 {
+				messageType = websocket.TextMessage
+			}
+			h.handleClientMessage(client, messageType, []byte(tt.message))
+			if got := replies(t, client); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("replies to %s = %v, want %v", tt.message, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscribeControlsDelivery(t *testing.T) {
+	h, _ := newTestWebSocketHandler(t, newTestConfig(t))
+	client := newRequestTestClient(t, h, "client")
+
+	h.handleClientMessage(client, websocket.TextMessage, []byte(`{"type":"subscribe","job_id":"job-1"}`))
+	h.handleClientMessage(client, websocket.TextMessage, []byte(`{"type":"subscribe","job_id":"job-2"}`))
+	if !client.IsSubscribed("job-1") || !client.IsSubscribed("job-2") || client.IsSubscribed("job-3") {
+		t.Fatal("subscriptions not applied")
+	}
+	h.handleClientMessage(client, websocket.TextMessage, []byte(`{"type":"unsubscribe","job_id":"job-1"}`))
+	replies(t, client)
+
+	for _, jobID := range []string{"job-1", "job-2", "job-3"} {
+		publishJobUpdate(t, h.queue, map[string]interface{}{"job_id": jobID, "status": "running"})
+	}
+	message, ok := nextMessage(t, client.HubClient, 2*time.Second)
+	if !ok || message.JobID != "job-2" {
+		t.Fatalf("received %+v, %v; want job-2's update", message, ok)
+	}
+	if message, ok := nextMessage(t, client.HubClient, 100*time.Millisecond); ok {
+		t.Errorf("received %+v for a job the client is not subscribed to", message)
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"botrix-backend/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	// ErrClientNotFound is returned by SendToClient for an ID that is not
+	// connected
+	ErrClientNotFound = services.ErrClientNotFound
+
+	// ErrMessageDropped is returned by SendToClient when the client's send
+	// buffer is full
+	ErrMessageDropped = services.ErrMessageDropped
+)
+
+// SendToClient queues a message for the client with the given ID. It
+// never blocks: a client whose send buffer is full gets ErrMessageDropped.
+// A zero Timestamp is set to the current time.
+func (h *WebSocketHandler) SendToClient(clientID string, message WebSocketMessage) error {
+	return h.hub.SendTo(clientID, message)
+}
+
+// BroadcastMessage sends a message to every connected client. Unlike relayed
+// events it ignores subscriptions and event filters and is not replayed;
+// clients whose send buffer is full miss it.
+func (h *WebSocketHandler) BroadcastMessage(message WebSocketMessage) {
+	h.broadcastDirect(message)
+}
+
+// broadcastDirect is BroadcastMessage, reporting how many clients the
+// message was queued for and how many dropped it
+func (h *WebSocketHandler) broadcastDirect(message WebSocketMessage) (sent, dropped int) {
+	return h.hub.SendToAll(message)
+}
+
+// SendMessageRequest is the body of POST /ws/send
+type SendMessageRequest struct {
+	ClientID string                 `json:"client_id"` // a client ID, or AllClients
+	Type     string                 `json:"type"`      // defaults to "notice"
+	Data     map[string]interface{} `json:"data"`
+}
+
+// AllClients is the client_id that sends a message to every client
+const AllClients = "*"
+
+// SendMessage handles POST /ws/send, pushing an admin message such as a
+// maintenance notice to one client or, with client_id "*", to all of them.
+// WebSocketAdminAuth restricts it to admins.
+func (h *WebSocketHandler) SendMessage(c *fiber.Ctx) error {
+	var req SendMessageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+	if req.ClientID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   `client_id is required; use "*" for every client`,
+		})
+	}
+	if req.Type == "" {
+		req.Type = "notice"
+	}
+
+	message := WebSocketMessage{Type: req.Type, Data: req.Data}
+	log := h.logger.WithFields(map[string]interface{}{
+		"client_id": req.ClientID,
+		"type":      req.Type,
+	})
+
+	if req.ClientID == AllClients {
+		sent, dropped := h.broadcastDirect(message)
+		log.WithFields(map[string]interface{}{
+			"sent":    sent,
+			"dropped": dropped,
+		}).Info("Admin message broadcast")
+		return c.JSON(fiber.Map{
+			"success": true,
+			"sent":    sent,
+			"dropped": dropped,
+		})
+	}
+
+	switch err := h.SendToClient(req.ClientID, message); err {
+	case nil:
+		log.Info("Admin message sent")
+		return c.JSON(fiber.Map{
+			"success": true,
+			"sent":    1,
+			"dropped": 0,
+		})
+	case ErrClientNotFound:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Client not connected",
+		})
+	case ErrMessageDropped:
+		log.Warn("Admin message dropped for slow client")
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"success": false,
+			"error":   "Client's send buffer is full; message dropped",
+			"sent":    0,
+			"dropped": 1,
+		})
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid message data",
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"botrix-backend/config"
+	"botrix-backend/services"
+	"botrix-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// sendTestApp routes POST /ws/send as main does
+func sendTestApp(h *WebSocketHandler, cfg config.WebSocketConfig) *fiber.App {
+	app := fiber.New()
+	app.Post("/ws/send", WebSocketAdminAuth(cfg, utils.GetDefaultLogger()), h.SendMessage)
+	return app
+}
+
+func TestSendMessageDelivers(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.WebSocket.AllowAnonymous = true
+	h, _ := newTestWebSocketHandler(t, cfg)
+	app := sendTestApp(h, cfg.WebSocket)
+	alice := registerHubClient(t, h, "alice")
+	bob := registerHubClient(t, h, "bob", "job-1")
+
+	// A single client gets the message whatever it is subscribed to, with
+	// the type defaulting to "notice"
+	status, body := doRequest(t, app, "POST", "/ws/send", `{"client_id":"alice","data":{"text":"maintenance at 10:00"}}`)
+	if status != fiber.StatusOK || body["sent"] != float64(1) {
+		t.Fatalf("sending to alice = %d %v", status, body)
+	}
+	if message, ok := nextMessage(t, alice, time.Second); !ok || message.Type != "notice" || message.Data["text"] != "maintenance at 10:00" || message.Timestamp == 0 {
+		t.Errorf("alice received %+v, %v", message, ok)
+	}
+	if message, ok := nextMessage(t, bob, 50*time.Millisecond); ok {
+		t.Errorf("bob received %+v meant for alice", message)
+	}
+
+	// "*" reaches every client
+	status, body = doRequest(t, app, "POST", "/ws/send", `{"client_id":"*","type":"maintenance","data":{"minutes":5}}`)
+	if status != fiber.StatusOK || body["sent"] != float64(2) || body["dropped"] != float64(0) {
+		t.Fatalf("broadcast = %d %v", status, body)
+	}
+	for _, client := range []*services.HubClient{alice, bob} {
+		if message, ok := nextMessage(t, client, time.Second); !ok || message.Type != "maintenance" || message.Data["minutes"] != float64(5) {
+			t.Errorf("%s received %+v, %v", client.ID, message, ok)
+		}
+	}
+}
+
+func TestSendMessageErrors(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.WebSocket.AllowAnonymous = true
+	h, _ := newTestWebSocketHandler(t, cfg)
+	app := sendTestApp(h, cfg.WebSocket)
+
+	// Fill a client's send buffer so the next message is dropped
+	slow := registerHubClient(t, h, "slow")
+	for len(slow.SendChan) < cap(slow.SendChan) {
+		slow.SendChan <- services.Frame{}
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"invalid body", `{"client_id":`, fiber.StatusBadRequest},
+		{"missing client", `{"type":"notice"}`, fiber.StatusBadRequest},
+		{"unknown client", `{"client_id":"nobody"}`, fiber.StatusNotFound},
+		{"full buffer", `{"client_id":"slow"}`, fiber.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := doRequest(t, app, "POST", "/ws/send", tt.body)
+			if status != tt.status || body["success"] != false {
+				t.Errorf("POST /ws/send %s = %d %v, want %d", tt.body, status, body, tt.status)
+			}
+		})
+	}
+
+	// A full buffer counts as a drop when broadcasting, not as a failure
+	status, body := doRequest(t, app, "POST", "/ws/send", `{"client_id":"*"}`)
+	if status != fiber.StatusOK || body["sent"] != float64(0) || body["dropped"] != float64(1) {
+		t.Errorf("broadcast = %d %v, want 1 dropped", status, body)
+	}
+}
+
+func TestSendMessageRequiresAdminKey(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.WebSocket.AllowAnonymous = true
+	cfg.WebSocket.AdminKeys = []string{"admin-key"}
+	h, _ := newTestWebSocketHandler(t, cfg)
+	app := sendTestApp(h, cfg.WebSocket)
+	client := registerHubClient(t, h, "alice")
+
+	for _, tt := range []struct {
+		auth   string
+		status int
+	}{
+		{"", fiber.StatusUnauthorized},
+		{"Bearer wrong-key", fiber.StatusUnauthorized},
+		{"Bearer admin-key", fiber.StatusOK},
+	} {
+		req, _ := http.NewRequest("POST", "/ws/send", strings.NewReader(`{"client_id":"alice"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("POST /ws/send: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("with Authorization %q: status %d, want %d", tt.auth, resp.StatusCode, tt.status)
+		}
+	}
+
+	// Only the authorized request was delivered
+	if _, ok := nextMessage(t, client, time.Second); !ok {
+		t.Error("authorized message was not delivered")
+	}
+	if message, ok := nextMessage(t, client, 50*time.Millisecond); ok {
+		t.Errorf("unauthorized request delivered %+v", message)
+	}
+}
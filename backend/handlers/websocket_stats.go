@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// wsStatsInterval is the minimum time between get_stats requests from one
+// client
+const wsStatsInterval = 2 * time.Second
+
+// handleGetStats answers a get_stats request with a "stats" message. The
+// fetch runs in its own goroutine so a slow backend never stalls the read
+// loop; requests arriving within wsStatsInterval of the last one get an
+// error carrying retry_after_ms instead.
+func (h *WebSocketHandler) handleGetStats(client *Client, _ string, _ map[string]interface{}) error {
+	if wait := wsStatsInterval - time.Since(client.lastStatsRequest); wait > 0 {
+		return &requestError{
+			message: "rate limited",
+			data:    map[string]interface{}{"retry_after_ms": wait.Milliseconds()},
+		}
+	}
+	client.lastStatsRequest = time.Now()
+
+	go func() {
+		h.sendToClient(client, WebSocketMessage{
+			Type:      "stats",
+			Data:      h.collectStats(),
+			Timestamp: time.Now().UnixMilli(),
+		})
+	}()
+	return nil
+}
+
+// collectStats gathers queue stats, job stats and the connected-client
+// count concurrently. As in the HTTP summary, a section that fails or
+// exceeds statsSectionTimeout is reported as {"error": ...}.
+func (h *WebSocketHandler) collectStats() map[string]interface{} {
+	sections := map[string]statsSection{
+		"queue": func() (interface{}, error) {
+			return h.queue.GetQueueStats()
+		},
+		"websocket": func() (interface{}, error) {
+			return fiber.Map{"connected_clients": h.ClientCount()}, nil
+		},
+	}
+	if h.db != nil {
+		sections["jobs"] = func() (interface{}, error) {
+			return h.db.GetJobStats()
+		}
+	}
+
+	// Buffered so sections that finish after the deadline never block
+	results := make(chan sectionResult, len(sections))
+	for name, section := range sections {
+		go func(name string, section statsSection) {
+			data, err := section()
+			results <- sectionResult{name: name, data: data, err: err}
+		}(name, section)
+	}
+
+	stats := map[string]interface{}{}
+	deadline := time.After(statsSectionTimeout)
+
+collect:
+	for range sections {
+		select {
+		case result := <-results:
+			if result.err != nil {
+				h.logger.WithField("section", result.name).WithField("error", result.err.Error()).Warn("Stats section failed")
+				stats[result.name] = fiber.Map{"error": "unavailable"}
+				continue
+			}
+			stats[result.name] = result.data
+		case <-deadline:
+			break collect
+		}
+	}
+
+	for name := range sections {
+		if _, ok := stats[name]; !ok {
+			h.logger.WithField("section", name).Warn("Stats section timed out")
+			stats[name] = fiber.Map{"error": fmt.Sprintf("timed out after %s", statsSectionTimeout)}
+		}
+	}
+	return stats
+}
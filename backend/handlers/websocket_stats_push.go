@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+)
+
+// Bounds and default for the interval of pushed stats, in seconds
+const (
+	minStatsPushSeconds     = 2
+	maxStatsPushSeconds     = 60
+	defaultStatsPushSeconds = 5
+)
+
+// statsPusher groups stats subscribers by interval, so every distinct
+// interval has one ticker and one stats fetch per tick however many
+// clients share it
+type statsPusher struct {
+	mu       sync.Mutex
+	groups   map[int]*statsGroup // by interval in seconds
+	byClient map[*Client]int
+}
+
+// statsGroup is the clients pushed stats at one interval; stop is closed
+// when the last of them leaves
+type statsGroup struct {
+	clients map[*Client]bool
+	stop    chan struct{}
+}
+
+// SetStatsSource sets where pushed stats come from, normally the cached
+// stats behind /api/stats. Without one, pushes fall back to the get_stats
+// sections. Call it before serving connections.
+func (h *WebSocketHandler) SetStatsSource(source func() (*StatsResponse, error)) {
+	h.statsSource = source
+}
+
+// clampStatsInterval rounds a requested interval to whole seconds within
+// the allowed bounds; a missing one gets the default
+func clampStatsInterval(value interface{}) int {
+	requested, ok := value.(float64)
+	if !ok {
+		return defaultStatsPushSeconds
+	}
+	seconds := int(math.Round(requested))
+	if seconds < minStatsPushSeconds {
+		return minStatsPushSeconds
+	}
+	if seconds > maxStatsPushSeconds {
+		return maxStatsPushSeconds
+	}
+	return seconds
+}
+
+// handleSubscribeStats starts pushing "stats" to a client every
+// interval_seconds, replacing any earlier stats subscription, and
+// acknowledges with "stats_subscribed" carrying the interval in effect.
+// The first push follows at once.
+func (h *WebSocketHandler) handleSubscribeStats(client *Client, _ string, msg map[string]interface{}) error {
+	seconds := clampStatsInterval(msg["interval_seconds"])
+	if group, started := h.statsPush.add(client, seconds); started {
+		go h.pushStats(seconds, group)
+	}
+
+	h.logger.WithFields(map[string]interface{}{
+		"client_id":        client.ID,
+		"interval_seconds": seconds,
+	}).Debug("Client subscribed to stats")
+
+	h.sendToClient(client, WebSocketMessage{
+		Type: "stats_subscribed",
+		Data: map[string]interface{}{"interval_seconds": seconds},
+	})
+	go func() {
+		if message, ok := h.statsMessage(); ok {
+			h.sendToClient(client, message)
+		}
+	}()
+	return nil
+}
+
+// handleUnsubscribeStats stops pushing stats to a client and acknowledges
+// with "stats_unsubscribed"
+func (h *WebSocketHandler) handleUnsubscribeStats(client *Client, _ string, _ map[string]interface{}) error {
+	h.statsPush.remove(client)
+	h.sendToClient(client, WebSocketMessage{Type: "stats_unsubscribed"})
+	return nil
+}
+
+// add moves a client into the group for an interval, reporting the group
+// and whether it is new and so needs its ticker started
+func (p *statsPusher) add(client *Client, seconds int) (*statsGroup, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.groups == nil {
+		p.groups = make(map[int]*statsGroup)
+		p.byClient = make(map[*Client]int)
+	}
+	p.removeLocked(client)
+
+	group, ok := p.groups[seconds]
+	if !ok {
+		group = &statsGroup{clients: make(map[*Client]bool), stop: make(chan struct{})}
+		p.groups[seconds] = group
+	}
+	group.clients[client] = true
+	p.byClient[client] = seconds
+	return group, !ok
+}
+
+// remove drops a client's stats subscription, if any. The read pump calls
+// it on disconnect.
+func (p *statsPusher) remove(client *Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removeLocked(client)
+}
+
+// removeLocked drops a client from its group, stopping the group's ticker
+// when it empties. The caller holds mu.
+func (p *statsPusher) removeLocked(client *Client) {
+	seconds, ok := p.byClient[client]
+	if !ok {
+		return
+	}
+	delete(p.byClient, client)
+
+	group := p.groups[seconds]
+	delete(group.clients, client)
+	if len(group.clients) == 0 {
+		delete(p.groups, seconds)
+		close(group.stop)
+	}
+}
+
+// members copies a group's clients
+func (p *statsPusher) members(group *statsGroup) []*Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	clients := make([]*Client, 0, len(group.clients))
+	for client := range group.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// pushStats sends stats to a group's clients every interval until the
+// group empties or the handler stops
+func (h *WebSocketHandler) pushStats(seconds int, group *statsGroup) {
+	ticker := time.NewTicker(time.Duration(seconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-group.stop:
+			return
+		case <-ticker.C:
+		}
+
+		clients := h.statsPush.members(group)
+		if len(clients) == 0 {
+			continue
+		}
+		message, ok := h.statsMessage()
+		if !ok {
+			continue
+		}
+		for _, client := range clients {
+			h.sendToClient(client, message)
+		}
+	}
+}
+
+// statsMessage builds a pushed "stats" message. Its data is the /api/stats
+// body, including generated_at so clients can tell how old cached stats
+// are; without a stats source it is the get_stats sections stamped now.
+func (h *WebSocketHandler) statsMessage() (WebSocketMessage, bool) {
+	message := WebSocketMessage{Type: "stats", Timestamp: time.Now().UnixMilli()}
+
+	if h.statsSource == nil {
+		message.Data = h.collectStats()
+		message.Data["generated_at"] = time.Now()
+		return message, true
+	}
+
+	stats, err := h.statsSource()
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Warn("Failed to fetch stats for push")
+		return message, false
+	}
+	raw, err := json.Marshal(stats)
+	if err == nil {
+		err = json.Unmarshal(raw, &message.Data)
+	}
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to encode pushed stats")
+		return message, false
+	}
+	return message, true
+}
@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"botrix-backend/config"
+	"botrix-backend/services"
+	"botrix-backend/utils"
+
+	"github.com/alicebob/miniredis/v2"
+	fastws "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// newTestWebSocketHandler starts a handler with a hub of its own on a fresh
+// database and in-memory Redis, returning once its Redis subscription is
+// up. Both are stopped when the test ends.
+func newTestWebSocketHandler(t *testing.T, cfg *config.Config) (*WebSocketHandler, *miniredis.Miniredis) {
+	t.Helper()
+	queue, server := newTestQueue(t, cfg)
+	db := newTestDatabase(t, cfg)
+	hub := services.NewHub(cfg.WebSocket)
+	h := NewWebSocketHandlerWithLogger(hub, queue, db, utils.GetDefaultLogger(), cfg.WebSocket)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		h.Shutdown(ctx)
+		hub.Stop(ctx)
+	})
+
+	select {
+	case <-h.Subscribed():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Redis subscription not established")
+	}
+	return h, server
+}
+
+// registerHubClient registers a client without a connection, subscribed to
+// jobIDs; tests read what it is sent from its SendChan
+func registerHubClient(t *testing.T, h *WebSocketHandler, id string, jobIDs ...string) *services.HubClient {
+	t.Helper()
+	client := services.NewHubClient(id, nil, "127.0.0.1")
+	for _, jobID := range jobIDs {
+		client.Subscribe(jobID)
+	}
+	if err := h.hub.Register(client); err != nil {
+		t.Fatalf("registering %s: %v", id, err)
+	}
+	return client
+}
+
+// nextMessage waits up to timeout for the next message sent to client
+func nextMessage(t *testing.T, client *services.HubClient, timeout time.Duration) (WebSocketMessage, bool) {
+	t.Helper()
+	select {
+	case frame := <-client.SendChan:
+		var message WebSocketMessage
+		if err := json.Unmarshal(frame.Data, &message); err != nil {
+			t.Fatalf("decoding %s: %v", frame.Data, err)
+		}
+		return message, true
+	case <-time.After(timeout):
+		return WebSocketMessage{}, false
+	}
+}
+
+func TestRandomStringUniqueAndAlphanumeric(t *testing.T) {
+	const n = 10000
+	charset := regexp.MustCompile(`^[a-zA-Z0-9]{8}$`)
+
+	seen := make(map[string]bool, n)
+	for i := 0; i < n; i++ {
+		s := randomString(8)
+		if !charset.MatchString(s) {
+			t.Fatalf("randomString(8) = %q, want 8 characters from [a-zA-Z0-9]", s)
+		}
+		if seen[s] {
+			t.Fatalf("randomString(8) repeated %q after %d calls", s, i)
+		}
+		seen[s] = true
+	}
+}
+
+func TestAcceptAssignsUniqueClientIDs(t *testing.T) {
+	const n = 10000
+	cfg := newTestConfig(t)
+	queue, _ := newTestQueue(t, cfg)
+	hub := services.NewHub(cfg.WebSocket)
+	t.Cleanup(func() { hub.Stop(context.Background()) })
+	h := NewWebSocketHandlerWithLogger(hub, queue, nil, utils.GetDefaultLogger(), cfg.WebSocket)
+	format := regexp.MustCompile(`^\d{14}-[a-zA-Z0-9]{8}$`)
+
+	// IDs are registered as they are handed out, as connect does, so a
+	// repeat would also be refused by the hub
+	for i := 0; i < n; i++ {
+		id, err := h.accept()
+		if err != nil {
+			t.Fatalf("accept: %v", err)
+		}
+		if !format.MatchString(id) {
+			t.Fatalf("client ID %q does not match %s", id, format)
+		}
+		if err := hub.Register(services.NewHubClient(id, nil, "127.0.0.1")); err != nil {
+			t.Fatalf("registering client %d (%s): %v", i, id, err)
+		}
+	}
+	if got := hub.ClientCount(); got != n {
+		t.Errorf("ClientCount = %d, want %d", got, n)
+	}
+}
+
+// serveWebSocket serves h on /ws behind WebSocketAuth from a local port, as
+// main wires it, and returns the address. When the test ends h closes its
+// connections before the server stops.
+func serveWebSocket(t *testing.T, h *WebSocketHandler, cfg config.WebSocketConfig) string {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/ws/stats", h.GetStats)
+	app.Use("/ws", WebSocketAuth(cfg, utils.GetDefaultLogger()))
+	app.Get("/ws", websocket.New(h.HandleWebSocket, websocket.Config{
+		Subprotocols:      []string{WebSocketTokenSubprotocol},
+		EnableCompression: cfg.Compression,
+	}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		h.Shutdown(ctx)
+		app.ShutdownWithTimeout(2 * time.Second)
+	})
+	return ln.Addr().String()
+}
+
+// dialWebSocket opens a connection to /ws with the given query string and
+// headers. The response is returned so refused upgrades can be inspected.
+func dialWebSocket(t *testing.T, addr, query string, header http.Header) (*fastws.Conn, *http.Response, error) {
+	t.Helper()
+	target := "ws://" + addr + "/ws"
+	if query != "" {
+		target += "?" + query
+	}
+	conn, resp, err := fastws.DefaultDialer.Dial(target, header)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+// connectWebSocket dials /ws and reads the hello message, failing the test
+// if either does not succeed
+func connectWebSocket(t *testing.T, addr, query string) (*fastws.Conn, HelloMessage) {
+	t.Helper()
+	conn, _, err := dialWebSocket(t, addr, query, nil)
+	if err != nil {
+		t.Fatalf("dialing /ws: %v", err)
+	}
+	var hello HelloMessage
+	readWebSocketJSON(t, conn, &hello)
+	if hello.Type != "hello" {
+		t.Fatalf("first message = %+v, want hello", hello)
+	}
+	return conn, hello
+}
+
+// readWebSocketJSON decodes the next message on conn into v
+func readWebSocketJSON(t *testing.T, conn *fastws.Conn, v interface{}) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(v); err != nil {
+		t.Fatalf("reading WebSocket message: %v", err)
+	}
+}
+
+// handlerGoroutines counts the goroutines running h's method fn, such as
+// "readPump", or any of its methods if fn is empty. Stack traces show the
+// receiver, so other tests' handlers and the server's own worker pool,
+// which idles for a while after connections end, are not counted.
+func handlerGoroutines(h *WebSocketHandler, fn string) int {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	call := regexp.MustCompile(`handlers\.\(\*WebSocketHandler\)\.` + regexp.QuoteMeta(fn) + `[\w.]*\(` + fmt.Sprintf("%p", h) + `\??[,)]`)
+	count := 0
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		if call.MatchString(stack) {
+			count++
+		}
+	}
+	return count
+}
+
+// waitForGoroutines waits until n goroutines run h's method fn
+func waitForGoroutines(t *testing.T, h *WebSocketHandler, fn string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for handlerGoroutines(h, fn) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines running %q, want %d", handlerGoroutines(h, fn), fn, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestShutdownSendsCloseFrame(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.WebSocket.AllowAnonymous = true
+	h, _ := newTestWebSocketHandler(t, cfg)
+	addr := serveWebSocket(t, h, cfg.WebSocket)
+
+	// Each client reads until the server closes it, answering the close
+	// frame as browsers do
+	const clients = 3
+	closed := make(chan error, clients)
+	for i := 0; i < clients; i++ {
+		conn, _ := connectWebSocket(t, addr, "")
+		go func() {
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					closed <- err
+					return
+				}
+			}
+		}()
+	}
+
+	waitForGoroutines(t, h, "readPump", clients)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := h.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v, want every client closed in time", err)
+	}
+
+	for i := 0; i < clients; i++ {
+		err := <-closed
+		closeErr, ok := err.(*fastws.CloseError)
+		if !ok || closeErr.Code != fastws.CloseGoingAway || closeErr.Text != "server restarting" {
+			t.Errorf("client %d read %v, want a going-away close frame saying the server is restarting", i, err)
+		}
+	}
+	if n := h.ClientCount(); n != 0 {
+		t.Errorf("%d clients still registered after Shutdown", n)
+	}
+
+	// Connections arriving during shutdown are closed the same way
+	conn, _, err := dialWebSocket(t, addr, "", nil)
+	if err != nil {
+		t.Fatalf("dialing during shutdown: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conn.ReadMessage(); !fastws.IsCloseError(err, fastws.CloseGoingAway) {
+		t.Errorf("connection during shutdown read %v, want a going-away close frame", err)
+	}
+	conn.Close()
+
+	// The pumps and the Redis subscription have stopped
+	waitForGoroutines(t, h, "", 0)
+}
+
+func TestClientDisconnectDoesNotLeakGoroutines(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.WebSocket.AllowAnonymous = true
+	h, _ := newTestWebSocketHandler(t, cfg)
+	addr := serveWebSocket(t, h, cfg.WebSocket)
+
+	conns := make([]*fastws.Conn, 5)
+	for i := range conns {
+		conns[i], _ = connectWebSocket(t, addr, "")
+	}
+	waitForGoroutines(t, h, "readPump", len(conns))
+	waitForGoroutines(t, h, "writePump", len(conns))
+
+	// Dropping the connections without a close handshake ends both pumps
+	for _, conn := range conns {
+		conn.UnderlyingConn().Close()
+	}
+	waitForGoroutines(t, h, "readPump", 0)
+	waitForGoroutines(t, h, "writePump", 0)
+	waitForGoroutines(t, h, "HandleWebSocket", 0)
+	if n := h.ClientCount(); n != 0 {
+		t.Errorf("%d clients still registered after disconnecting", n)
+	}
+	if open := h.capacity()["connections"]; open != 0 {
+		t.Errorf("%v connections counted after disconnecting, want 0", open)
+	}
+}
+
+func TestCompressedRoundTrip(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.WebSocket.AllowAnonymous = true
+	cfg.WebSocket.Compression = true
+	cfg.WebSocket.CompressionLevel = 9
+	h, _ := newTestWebSocketHandler(t, cfg)
+	addr := serveWebSocket(t, h, cfg.WebSocket)
+
+	// A stats-sized payload that compresses well
+	rows := make([]interface{}, 2000)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"id": fmt.Sprintf("job-%04d", i), "status": "completed", "message": strings.Repeat("ok ", 20)}
+	}
+	payload := WebSocketMessage{Type: "stats", Data: map[string]interface{}{"rows": rows}}
+	encoded, _ := json.Marshal(payload)
+	var want WebSocketMessage
+	json.Unmarshal(encoded, &want)
+
+	// Clients that do not offer the extension get plain frames
+	for _, compress := range []bool{true, false} {
+		dialer := fastws.Dialer{EnableCompression: compress}
+		conn, resp, err := dialer.Dial("ws://"+addr+"/ws", nil)
+		if err != nil {
+			t.Fatalf("compress %v: dialing /ws: %v", compress, err)
+		}
+		defer conn.Close()
+		if negotiated := strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate"); negotiated != compress {
+			t.Errorf("compress %v: permessage-deflate negotiated = %v", compress, negotiated)
+		}
+
+		var hello HelloMessage
+		readWebSocketJSON(t, conn, &hello)
+		if err := h.hub.SendTo(hello.ClientID, payload); err != nil {
+			t.Fatalf("compress %v: SendTo: %v", compress, err)
+		}
+		var got WebSocketMessage
+		readWebSocketJSON(t, conn, &got)
+		got.Timestamp = 0
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("compress %v: %d-byte payload received altered", compress, len(encoded))
+		}
+
+		// Large messages from the client decompress as well
+		jobID := strings.Repeat("job-", 25000)
+		if err := conn.WriteJSON(map[string]string{"type": "subscribe", "job_id": jobID}); err != nil {
+			t.Fatalf("compress %v: subscribing: %v", compress, err)
+		}
+		var ack WebSocketMessage
+		readWebSocketJSON(t, conn, &ack)
+		if ack.Type != "subscribed" || ack.JobID != jobID {
+			t.Errorf("compress %v: ack = %s for a %d-byte job ID, want it subscribed", compress, ack.Type, len(jobID))
+		}
+	}
+}
+
+func TestConnectionCapRefusesExtraClients(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.WebSocket.AllowAnonymous = true
+	cfg.WebSocket.MaxConnections = 2
+	h, _ := newTestWebSocketHandler(t, cfg)
+	addr := serveWebSocket(t, h, cfg.WebSocket)
+
+	first, _ := connectWebSocket(t, addr, "")
+	connectWebSocket(t, addr, "")
+
+	// The connection over the cap is closed with "try again later"
+	refused, _, err := dialWebSocket(t, addr, "", nil)
+	if err != nil {
+		t.Fatalf("dialing over the cap: %v", err)
+	}
+	refused.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := refused.ReadMessage(); !fastws.IsCloseError(err, fastws.CloseTryAgainLater) {
+		t.Errorf("connection over the cap read %v, want close code %d", err, fastws.CloseTryAgainLater)
+	}
+
+	stats := webSocketStats(t, addr)
+	want := map[string]interface{}{"connections": float64(2), "max_connections": float64(2), "utilization": float64(1)}
+	if got := stats["capacity"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("capacity = %v, want %v", got, want)
+	}
+
+	// A client leaving frees its slot
+	first.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for h.capacity()["connections"] != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("capacity = %v after a client left, want 1 connection", h.capacity())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	connectWebSocket(t, addr, "")
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
@@ -10,6 +11,7 @@ import (
 	"botrix-backend/handlers"
 	"botrix-backend/services"
 	"botrix-backend/utils"
+	"botrix-backend/version"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -20,6 +22,18 @@ import (
 
 var logger *utils.Logger
 
+// wsShutdownTimeout bounds how long shutdown waits for WebSocket clients to
+// acknowledge the close frame
+const wsShutdownTimeout = 5 * time.Second
+
+// Startup readiness gates; /health/ready reports 503 until all are marked
+const (
+	gateConfigLoaded        = "config_loaded"
+	gateDatabaseMigrated    = "database_migrated"
+	gateQueueConnected      = "queue_connected"
+	gateWebSocketSubscribed = "websocket_subscribed"
+)
+
 func main() {
 	// Initialize logger
 	var err error
@@ -31,13 +45,22 @@ func main() {
 	// Redirect standard logger
 	utils.RedirectStandardLogger()
 
+	readiness := utils.NewReadinessTracker(
+		gateConfigLoaded,
+		gateDatabaseMigrated,
+		gateQueueConnected,
+		gateWebSocketSubscribed,
+	)
+
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		logger.Fatal("Failed to load configuration: %v", err)
 	}
+	readiness.Mark(gateConfigLoaded)
 
-	logger.WithComponent("STARTUP").Info("Starting Botrix Backend API...")
+	build := version.Get()
+	logger.WithComponent("STARTUP").Info("Starting Botrix Backend API %s (commit %s, built %s)...", build.Version, build.Commit, build.BuildTime)
 	logger.WithComponent("STARTUP").Info("Environment: %s", cfg.Server.Environment)
 
 	// Set log level based on environment
@@ -53,6 +76,7 @@ func main() {
 		dbLogger.Fatal("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
+	readiness.Mark(gateDatabaseMigrated)
 
 	// Initialize queue (Redis)
 	queueLogger := logger.WithComponent("QUEUE")
@@ -61,10 +85,41 @@ func main() {
 		queueLogger.Fatal("Failed to initialize queue: %v", err)
 	}
 	defer queue.Close()
+	readiness.Mark(gateQueueConnected)
+
+	// Apply outcomes of account-level jobs and deliver callbacks as jobs finish
+	callbacks := services.NewCallbackDispatcher(db, cfg)
+	webhooks := services.NewWebhookDispatcher(db)
+	jobWatcher := services.NewJobWatcher(db, queue, callbacks, webhooks)
+	if err := jobWatcher.Start(); err != nil {
+		queueLogger.Error("Failed to start job watcher: %v", err)
+	}
+
+	// Keep job rows in the database in step with statuses in Redis
+	reconciler := services.NewJobReconciler(db, queue)
+	if err := reconciler.Start(); err != nil {
+		queueLogger.Error("Failed to start job reconciler: %v", err)
+	}
+
+	// The hub holds the WebSocket clients; anything given it can reach them
+	hub := services.NewHub(cfg.WebSocket)
+
+	// Admins can tail the log over /ws/logs; the hooks see every component
+	// logger, and standard log output through the default logger
+	logStream := handlers.NewLogStreamHandler(logger.WithComponent("LOGSTREAM"))
+	logger.AddHook(logStream.Hook)
+	utils.GetDefaultLogger().AddHook(logStream.Hook)
+
+	// WebSocket handler is created ahead of the app; metrics report its clients
+	wsHandler := handlers.NewWebSocketHandlerWithLogger(hub, queue, db, logger.WithComponent("WEBSOCKET"), cfg.WebSocket)
+	go func() {
+		<-wsHandler.Subscribed()
+		readiness.Mark(gateWebSocketSubscribed)
+	}()
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
-		AppName:      "Botrix Backend API v1.0.0",
+		AppName:      "Botrix Backend API " + version.String(),
 		ServerHeader: "Botrix",
 		ErrorHandler: customErrorHandler,
 		ReadTimeout:  10 * time.Second,
@@ -72,6 +127,10 @@ func main() {
 		IdleTimeout:  120 * time.Second,
 	})
 
+	// Metrics are registered first so they time the full middleware chain
+	metricsHandler := handlers.NewMetricsHandler(db, queue, wsHandler)
+	app.Use(metricsHandler.Middleware())
+
 	// Middleware
 	app.Use(recover.New(recover.Config{
 		EnableStackTrace: cfg.IsDevelopment(),
@@ -84,71 +143,116 @@ func main() {
 	// CORS middleware
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     getAllowedOrigins(cfg),
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
+		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
 		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		ExposeHeaders:    "Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset",
 		AllowCredentials: true,
 		MaxAge:           86400, // 24 hours
 	}))
 
 	// Initialize handlers
-	healthHandler := handlers.NewHealthHandler()
-	accountsHandler := handlers.NewAccountsHandler(db, queue)
-	settingsHandler := handlers.NewSettingsHandler(db)
-	wsHandler := handlers.NewWebSocketHandlerWithLogger(queue.GetRedisClient(), logger.WithComponent("WEBSOCKET"))
+	healthHandler := handlers.NewHealthHandler(db, queue, cfg, readiness, wsHandler)
+	accountsHandler := handlers.NewAccountsHandler(db, queue, cfg, webhooks)
+	wsHandler.SetStatsSource(accountsHandler.CachedStats)
+	settingsHandler := handlers.NewSettingsHandler(db, queue)
+	webhooksHandler := handlers.NewWebhooksHandler(db, cfg)
 
 	// Initialize middleware
-	rateLimiter := handlers.NewRateLimiterWithLogger(10, 1*time.Minute, logger.WithComponent("RATELIMIT"))
+	rateLimiters := handlers.NewRateLimiters(cfg.RateLimit, logger.WithComponent("RATELIMIT"))
 	validator := handlers.RequestValidator()
 
+	statsHandler := handlers.NewStatsHandler(db, queue, wsHandler, rateLimiters)
+
 	// Health check routes (no rate limiting)
 	app.Get("/health", healthHandler.Check)
 	app.Get("/health/ping", healthHandler.Ping)
 	app.Get("/health/ready", healthHandler.Ready)
 	app.Get("/health/live", healthHandler.Live)
+	app.Get("/health/details", healthHandler.Details)
 
-	// WebSocket routes
-	app.Use("/ws", func(c *fiber.Ctx) error {
-		// IsWebSocketUpgrade returns true if the client requested upgrade to the WebSocket protocol
-		if websocket.IsWebSocketUpgrade(c) {
-			c.Locals("allowed", true)
-			return c.Next()
-		}
-		return fiber.ErrUpgradeRequired
-	})
-	app.Get("/ws", websocket.New(wsHandler.HandleWebSocket))
-	app.Get("/ws/stats", wsHandler.GetStats)
+	// Prometheus metrics
+	app.Get("/metrics", metricsHandler.Serve)
 
-	// API routes with validation
-	api := app.Group("/api", validator)
+	// WebSocket routes. Stats, admin messages and the log stream are
+	// registered first so the client auth middleware does not apply to them.
+	app.Get("/ws/stats", handlers.WebSocketStatsAuth(cfg.WebSocket, logger.WithComponent("WEBSOCKET")), wsHandler.GetStats)
+	app.Post("/ws/send", handlers.WebSocketAdminAuth(cfg.WebSocket, logger.WithComponent("WEBSOCKET")), wsHandler.SendMessage)
+	app.Get("/ws/logs", wsHandler.UpgradeRateLimit(), handlers.WebSocketAdminAuth(cfg.WebSocket, logger.WithComponent("WEBSOCKET")), websocket.New(logStream.HandleWebSocket, websocket.Config{
+		Subprotocols: []string{handlers.WebSocketTokenSubprotocol},
+	}))
+	app.Use("/ws", wsHandler.UpgradeRateLimit(), handlers.WebSocketAuth(cfg.WebSocket, logger.WithComponent("WEBSOCKET")))
+	app.Get("/ws", websocket.New(wsHandler.HandleWebSocket, websocket.Config{
+		Subprotocols:      []string{handlers.WebSocketTokenSubprotocol},
+		EnableCompression: cfg.WebSocket.Compression,
+	}))
 
-	// Account generation endpoint with rate limiting
-	api.Post("/accounts/generate", rateLimiter.Middleware(), accountsHandler.GenerateAccounts)
+	// API routes with validation. Reads and mutations are rate limited in
+	// separate buckets.
+	api := app.Group("/api", validator, rateLimiters.ByMethod(handlers.RateLimitRead, handlers.RateLimitWrite))
+
+	// Account generation endpoint, also limited in its own bucket
+	api.Post("/accounts/generate", rateLimiters.Middleware(handlers.RateLimitGenerate), accountsHandler.GenerateAccounts)
 
 	// Account routes
 	api.Get("/accounts", accountsHandler.ListAccounts)
+	api.Get("/accounts/by-username/:username", accountsHandler.GetAccountByUsername)
+	api.Get("/accounts/by-email/:email", accountsHandler.GetAccountByEmail)
 	api.Get("/accounts/:id", accountsHandler.GetAccount)
+	api.Get("/accounts/:id/job", accountsHandler.GetAccountJob)
+	api.Get("/accounts/:id/download", accountsHandler.DownloadAccount)
+	api.Get("/accounts/:id/kick-data", accountsHandler.GetKickData)
 	api.Post("/accounts", accountsHandler.CreateAccount)
+	api.Post("/accounts/bulk-status", accountsHandler.BulkUpdateStatus)
+	api.Post("/accounts/checkout", accountsHandler.CheckoutAccounts)
 	api.Put("/accounts/:id", accountsHandler.UpdateAccount)
-	api.Delete("/accounts/:accountId", accountsHandler.DeleteAccount)
+	api.Patch("/accounts/:id", accountsHandler.UpdateAccount)
+	api.Put("/accounts/:id/notes", accountsHandler.UpdateAccountNotes)
+	api.Put("/accounts/:id/kick-data", accountsHandler.SetKickData)
+	api.Delete("/accounts", accountsHandler.BulkDeleteAccounts)
+	api.Delete("/accounts/:id", accountsHandler.DeleteAccount)
+	api.Post("/accounts/:id/restore", accountsHandler.RestoreAccount)
+	api.Post("/accounts/:id/ban", accountsHandler.BanAccount)
+	api.Post("/accounts/:id/unban", accountsHandler.UnbanAccount)
+	api.Post("/accounts/:id/checkin", accountsHandler.CheckinAccount)
+	api.Post("/accounts/:id/verify", accountsHandler.VerifyAccount)
 
 	// Stats endpoint
 	api.Get("/stats", accountsHandler.GetStats)
+	api.Get("/stats/timeseries", accountsHandler.GetTimeseries)
+	api.Get("/stats/throughput", accountsHandler.GetThroughput)
+	api.Get("/stats/durations", accountsHandler.GetDurations)
+	api.Get("/stats/failures", accountsHandler.GetFailures)
+	api.Get("/stats/report", accountsHandler.GetStatsReport)
+	api.Get("/stats/summary", statsHandler.GetSummary)
 
 	// Job routes
-	api.Get("/jobs", accountsHandler.GetJobs)
-	api.Get("/jobs/:jobId", accountsHandler.GetJob)
-	api.Post("/jobs/:id/cancel", accountsHandler.CancelJob)
-	api.Get("/jobs/stats", accountsHandler.GetJobStats)
+	registerJobRoutes(api, accountsHandler, rateLimiters)
 
 	// Settings routes
 	api.Get("/settings", settingsHandler.GetSettings)
 	api.Post("/settings", settingsHandler.SaveSettings)
+	api.Patch("/settings", settingsHandler.UpdateSettings)
+	api.Get("/settings/history", settingsHandler.GetHistory)
+	api.Post("/settings/rollback/:id", settingsHandler.Rollback)
+	api.Get("/settings/export", settingsHandler.ExportSettings)
+	api.Post("/settings/import", settingsHandler.ImportSettings)
+	api.Post("/settings/test-imap", settingsHandler.TestIMAP)
+	api.Post("/settings/test-smtp", settingsHandler.TestSMTP)
+	api.Post("/settings/test-rapidapi", settingsHandler.TestRapidAPI)
+
+	// Webhook routes
+	api.Get("/webhooks", webhooksHandler.ListWebhooks)
+	api.Post("/webhooks", webhooksHandler.CreateWebhook)
+	api.Get("/webhooks/:id", webhooksHandler.GetWebhook)
+	api.Put("/webhooks/:id", webhooksHandler.UpdateWebhook)
+	api.Delete("/webhooks/:id", webhooksHandler.DeleteWebhook)
+	api.Get("/webhooks/:id/deliveries", webhooksHandler.ListDeliveries)
 
 	// Root route
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
 			"name":    "Botrix Backend API",
-			"version": "1.0.0",
+			"version": version.String(),
 			"status":  "running",
 			"endpoints": fiber.Map{
 				"health":    "/health",
@@ -177,6 +281,19 @@ func main() {
 
 		logger.WithComponent("SHUTDOWN").Warn("Received shutdown signal...")
 
+		// Close WebSocket clients with a handshake before the listener goes
+		ctx, cancel := context.WithTimeout(context.Background(), wsShutdownTimeout)
+		if err := wsHandler.Shutdown(ctx); err != nil {
+			logger.WithComponent("SHUTDOWN").Error("Error shutting down WebSocket handler: %v", err)
+		}
+		if err := logStream.Shutdown(ctx); err != nil {
+			logger.WithComponent("SHUTDOWN").Error("Error shutting down log stream: %v", err)
+		}
+		if err := hub.Stop(ctx); err != nil {
+			logger.WithComponent("SHUTDOWN").Error("Error stopping WebSocket hub: %v", err)
+		}
+		cancel()
+
 		if err := app.Shutdown(); err != nil {
 			logger.WithComponent("SHUTDOWN").Error("Error during shutdown: %v", err)
 		}
@@ -193,6 +310,31 @@ func main() {
 	}
 }
 
+// registerJobRoutes adds the job routes to the API group. Static paths are
+// registered before /jobs/:id, which would otherwise capture them as job IDs.
+// Creating jobs generates accounts, so it counts against the generate bucket.
+func registerJobRoutes(api fiber.Router, accountsHandler *handlers.AccountsHandler, rateLimiters *handlers.RateLimiters) {
+	api.Get("/jobs", accountsHandler.GetJobs)
+	api.Get("/jobs/labels", accountsHandler.GetJobLabels)
+	api.Get("/jobs/stats", accountsHandler.GetJobStats)
+	api.Get("/jobs/export", accountsHandler.ExportJobs)
+	api.Get("/jobs/:id", accountsHandler.GetJob)
+	api.Get("/jobs/:id/accounts", accountsHandler.GetJobAccounts)
+	api.Get("/jobs/:id/result", accountsHandler.GetJobResult)
+	api.Get("/jobs/:id/stream", accountsHandler.StreamJob)
+	api.Get("/jobs/:id/logs", accountsHandler.GetJobLogs)
+	api.Get("/jobs/:id/events", accountsHandler.GetJobEvents)
+	api.Post("/jobs", rateLimiters.Middleware(handlers.RateLimitGenerate), accountsHandler.CreateJob)
+	api.Post("/jobs/batch", rateLimiters.Middleware(handlers.RateLimitGenerate), accountsHandler.BatchCreateJobs)
+	api.Post("/jobs/bulk-cancel", accountsHandler.BulkCancelJobs)
+	api.Post("/jobs/:id/cancel", accountsHandler.CancelJob)
+	api.Post("/jobs/:id/retry", accountsHandler.RetryJob)
+	api.Post("/jobs/:id/pause", accountsHandler.PauseJob)
+	api.Post("/jobs/:id/resume", accountsHandler.ResumeJob)
+	api.Patch("/jobs/:id", accountsHandler.UpdateJob)
+	api.Delete("/jobs/:id", accountsHandler.DeleteJob)
+}
+
 // customErrorHandler handles errors globally
 func customErrorHandler(c *fiber.Ctx, err error) error {
 	code := fiber.StatusInternalServerError
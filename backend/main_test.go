@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"botrix-backend/config"
+	"botrix-backend/handlers"
+	"botrix-backend/models"
+	"botrix-backend/services"
+	"botrix-backend/utils"
+
+	"github.com/alicebob/miniredis/v2"
+	fastws "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"github.com/google/uuid"
+)
+
+// newTestServices opens a fresh database and connects a queue to an
+// in-memory Redis, closing both when the test ends
+func newTestServices(t *testing.T) (*config.Config, *services.Database, *services.QueueService) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	cfg := &config.Config{
+		Server:    config.ServerConfig{Environment: "test"},
+		Database:  config.DatabaseConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "botrix.db")},
+		Redis:     config.RedisConfig{Host: server.Host(), Port: server.Port()},
+		Reporting: config.ReportingConfig{Timezone: "UTC", Location: time.UTC},
+	}
+
+	db, err := services.NewDatabase(cfg)
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	queue, err := services.NewQueueService(cfg)
+	if err != nil {
+		t.Fatalf("NewQueueService: %v", err)
+	}
+	t.Cleanup(func() { queue.Close() })
+	return cfg, db, queue
+}
+
+// newTestJobApp serves the job routes as main registers them, backed by a
+// fresh database and in-memory Redis. Buckets not in rateLimits keep their
+// defaults.
+func newTestJobApp(t *testing.T, rateLimits string) (*fiber.App, *services.Database) {
+	t.Helper()
+	cfg, db, queue := newTestServices(t)
+	t.Setenv("RATE_LIMITS", rateLimits)
+	loaded, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("config.LoadConfig: %v", err)
+	}
+
+	app := fiber.New()
+	rateLimiters := handlers.NewRateLimiters(loaded.RateLimit, utils.GetDefaultLogger())
+	registerJobRoutes(app.Group("/api"), handlers.NewAccountsHandler(db, queue, cfg, nil), rateLimiters)
+	return app, db
+}
+
+func TestJobRouteDispatch(t *testing.T) {
+	app, db := newTestJobApp(t, "")
+
+	job := &models.Job{ID: uuid.New().String(), Count: 1, Status: models.JobStatusPending}
+	if err := db.CreateJob(job); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		key    string // a field only the intended handler returns
+	}{
+		{fiber.MethodGet, "/api/jobs/stats", fiber.StatusOK, "job_stats"},
+		{fiber.MethodGet, "/api/jobs/labels", fiber.StatusOK, "labels"},
+		{fiber.MethodGet, "/api/jobs/" + job.ID, fiber.StatusOK, "job"},
+		{fiber.MethodGet, "/api/jobs/" + uuid.New().String(), fiber.StatusNotFound, "error"},
+		{fiber.MethodPost, "/api/jobs/" + job.ID + "/cancel", fiber.StatusOK, "job"},
+	}
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest(tt.method, tt.path, nil), -1)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.method, tt.path, err)
+		}
+		raw, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s: status = %d, want %d (body %s)", tt.method, tt.path, resp.StatusCode, tt.status, raw)
+			continue
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Errorf("%s %s: decoding %s: %v", tt.method, tt.path, raw, err)
+			continue
+		}
+		if _, ok := body[tt.key]; !ok {
+			t.Errorf("%s %s: response has no %q field, so another handler served it: %s", tt.method, tt.path, tt.key, raw)
+		}
+	}
+
+	cancelled, err := db.GetJob(job.ID)
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if cancelled.Status != models.JobStatusCancelled {
+		t.Errorf("job status after cancel = %s, want cancelled", cancelled.Status)
+	}
+}
+
+func TestCreateJobUsesGenerateBucket(t *testing.T) {
+	app, _ := newTestJobApp(t, "generate:2/1m")
+
+	post := func(path, body string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(fiber.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var decoded map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&decoded)
+		return resp.StatusCode, decoded
+	}
+
+	for i := 0; i < 2; i++ {
+		if status, body := post("/api/jobs", `{"count": 1}`); status != fiber.StatusCreated {
+			t.Fatalf("POST /api/jobs %d: status = %d, want 201 (body %v)", i+1, status, body)
+		}
+	}
+
+	// Single and batch creation share the bucket
+	for _, path := range []string{"/api/jobs", "/api/jobs/batch"} {
+		status, body := post(path, `{"count": 1}`)
+		if status != fiber.StatusTooManyRequests || body["bucket"] != handlers.RateLimitGenerate {
+			t.Errorf("POST %s over the limit: %d %v, want 429 from the generate bucket", path, status, body)
+		}
+	}
+
+	// Other job mutations are not limited by it
+	if status, body := post("/api/jobs/bulk-cancel", `{"ids": []}`); status == fiber.StatusTooManyRequests {
+		t.Errorf("POST /api/jobs/bulk-cancel was rate limited: %v", body)
+	}
+}
+
+// readWSMessage reads the next message from a WebSocket connection
+func readWSMessage(t *testing.T, conn *fastws.Conn) services.HubMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("reading WebSocket message: %v", err)
+	}
+	var message services.HubMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		t.Fatalf("decoding %s: %v", data, err)
+	}
+	return message
+}
+
+func TestHubBroadcastReachesWebSocketClients(t *testing.T) {
+	cfg, db, queue := newTestServices(t)
+	cfg.WebSocket.AllowAnonymous = true
+
+	// Wired as in main: the hub is created here and shared with the handler
+	hub := services.NewHub(cfg.WebSocket)
+	wsHandler := handlers.NewWebSocketHandlerWithLogger(hub, queue, db, utils.GetDefaultLogger(), cfg.WebSocket)
+
+	app := fiber.New()
+	app.Use("/ws", handlers.WebSocketAuth(cfg.WebSocket, utils.GetDefaultLogger()))
+	app.Get("/ws", websocket.New(wsHandler.HandleWebSocket))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		wsHandler.Shutdown(ctx)
+		hub.Stop(ctx)
+		app.Shutdown()
+	})
+
+	conn, _, err := fastws.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dialing /ws: %v", err)
+	}
+	defer conn.Close()
+
+	var hello struct {
+		Type     string `json:"type"`
+		ClientID string `json:"client_id"`
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(&hello); err != nil || hello.Type != "hello" || hello.ClientID == "" {
+		t.Fatalf("hello = %+v, %v", hello, err)
+	}
+
+	if err := conn.WriteJSON(map[string]string{"type": "subscribe", "job_id": "job-1"}); err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	if ack := readWSMessage(t, conn); ack.Type != "subscribed" || ack.JobID != "job-1" {
+		t.Fatalf("subscription ack = %+v", ack)
+	}
+
+	// Broadcasts from outside the handler package reach subscribed clients
+	// and honour their subscriptions
+	hub.Broadcast(services.HubMessage{Type: "job_update", JobID: "job-2", Status: "running"})
+	hub.Broadcast(services.HubMessage{Type: "job_update", JobID: "job-1", Status: "completed"})
+	if got := readWSMessage(t, conn); got.Type != "job_update" || got.JobID != "job-1" || got.Status != "completed" || got.Seq == 0 {
+		t.Errorf("broadcast received as %+v, want the numbered job-1 update", got)
+	}
+
+	if err := hub.SendTo(hello.ClientID, services.HubMessage{Type: "settings_updated"}); err != nil {
+		t.Fatalf("SendTo: %v", err)
+	}
+	if got := readWSMessage(t, conn); got.Type != "settings_updated" {
+		t.Errorf("direct message received as %+v, want settings_updated", got)
+	}
+}
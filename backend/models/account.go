@@ -1,12 +1,30 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// Account status values
+const (
+	AccountStatusActive    = "active"
+	AccountStatusBanned    = "banned"
+	AccountStatusSuspended = "suspended"
+)
+
+// IsValidAccountStatus checks if a status is one of the known account statuses
+func IsValidAccountStatus(status string) bool {
+	switch status {
+	case AccountStatusActive, AccountStatusBanned, AccountStatusSuspended:
+		return true
+	}
+	return false
+}
+
 // Account represents a generated Kick.com account
 type Account struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
@@ -32,6 +50,10 @@ type Account struct {
 	KickAccountID string `json:"kick_account_id,omitempty"`
 	KickData      string `gorm:"type:text" json:"kick_data,omitempty"` // JSON string
 	Notes         string `gorm:"type:text" json:"notes,omitempty"`
+
+	// Checkout by downstream consumers; nil CheckedOutAt means available
+	CheckedOutAt *time.Time `gorm:"index" json:"checked_out_at,omitempty"`
+	CheckedOutBy string     `json:"checked_out_by,omitempty"`
 }
 
 // AccountCreateRequest represents the request to create a new account
@@ -41,6 +63,74 @@ type AccountCreateRequest struct {
 	Count    int    `json:"count" validate:"min=1,max=100"` // For batch creation
 }
 
+// AccountUpdateRequest represents a partial update of an account's mutable fields.
+// Nil fields are left untouched.
+type AccountUpdateRequest struct {
+	Status        *string `json:"status,omitempty"`
+	Notes         *string `json:"notes,omitempty"`
+	Password      *string `json:"password,omitempty"`
+	EmailPassword *string `json:"email_password,omitempty"`
+	KickData      *string `json:"kick_data,omitempty"`
+}
+
+// MaxNotesLength limits the size of Account.Notes
+const MaxNotesLength = 4000
+
+// AccountNotesRequest replaces an account's notes; an empty string clears them
+type AccountNotesRequest struct {
+	Notes *string `json:"notes"`
+}
+
+// AccountUpdatableFields lists the JSON keys accepted by AccountUpdateRequest
+var AccountUpdatableFields = map[string]bool{
+	"status":         true,
+	"notes":          true,
+	"password":       true,
+	"email_password": true,
+	"kick_data":      true,
+}
+
+// Validate checks the provided fields of an update request
+func (r *AccountUpdateRequest) Validate() error {
+	if r.Status != nil && !IsValidAccountStatus(*r.Status) {
+		return fmt.Errorf("status must be one of: active, banned, suspended")
+	}
+	if r.Password != nil && *r.Password == "" {
+		return fmt.Errorf("password cannot be empty")
+	}
+	if r.EmailPassword != nil && *r.EmailPassword == "" {
+		return fmt.Errorf("email_password cannot be empty")
+	}
+	if r.Notes != nil && len(*r.Notes) > MaxNotesLength {
+		return fmt.Errorf("notes must be at most %d characters", MaxNotesLength)
+	}
+	if r.KickData != nil && *r.KickData != "" {
+		if err := ValidateKickData([]byte(*r.KickData)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyTo copies the provided fields onto the account
+func (r *AccountUpdateRequest) ApplyTo(a *Account) {
+	if r.Status != nil {
+		a.Status = *r.Status
+	}
+	if r.Notes != nil {
+		a.Notes = *r.Notes
+	}
+	if r.Password != nil {
+		a.Password = *r.Password
+	}
+	if r.EmailPassword != nil {
+		a.EmailPassword = *r.EmailPassword
+	}
+	if r.KickData != nil {
+		a.KickData = *r.KickData
+	}
+}
+
 // AccountResponse represents the response for account operations
 type AccountResponse struct {
 	Success  bool      `json:"success"`
@@ -50,6 +140,65 @@ type AccountResponse struct {
 	Error    string    `json:"error,omitempty"`
 }
 
+// AccountFilter narrows account listings
+type AccountFilter struct {
+	Status      string     // exact account status
+	JobID       string     // job that produced the account
+	CreatedFrom *time.Time // inclusive lower bound on created_at
+	CreatedTo   *time.Time // inclusive upper bound on created_at
+
+	// CheckedOut, when set, keeps only checked out (true) or available (false) accounts
+	CheckedOut *bool
+
+	// IncludeDeleted also returns soft-deleted accounts (trash view)
+	IncludeDeleted bool
+}
+
+// IsEmpty reports whether no filter criteria are set
+func (f AccountFilter) IsEmpty() bool {
+	return f.Status == "" && f.JobID == "" && f.CreatedFrom == nil && f.CreatedTo == nil &&
+		f.CheckedOut == nil && !f.IncludeDeleted
+}
+
+// AccountSort describes the ordering of account listings
+type AccountSort struct {
+	Field      string
+	Descending bool
+}
+
+// accountSortFields whitelists the columns accounts may be sorted by
+var accountSortFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"username":   true,
+	"email":      true,
+	"status":     true,
+}
+
+// DefaultAccountSort is the ordering used when none is requested
+var DefaultAccountSort = AccountSort{Field: "created_at", Descending: true}
+
+// IsValidAccountSortField checks if a field may be used for sorting
+func IsValidAccountSortField(field string) bool {
+	return accountSortFields[field]
+}
+
+// OrderClause returns the SQL ORDER BY clause, tiebreaking on ID so that
+// pagination stays stable when sort values repeat
+func (s AccountSort) OrderClause() string {
+	field := s.Field
+	if !IsValidAccountSortField(field) {
+		field = DefaultAccountSort.Field
+	}
+
+	direction := "ASC"
+	if s.Descending {
+		direction = "DESC"
+	}
+
+	return fmt.Sprintf("%s %s, id %s", field, direction, direction)
+}
+
 // AccountStats represents statistics about accounts
 type AccountStats struct {
 	Total     int64 `json:"total"`
@@ -59,6 +208,13 @@ type AccountStats struct {
 	Today     int64 `json:"created_today"`
 }
 
+// BulkDeleteResult reports the outcome of a bulk account delete per ID
+type BulkDeleteResult struct {
+	Deleted        []uint `json:"deleted"`
+	AlreadyDeleted []uint `json:"already_deleted"`
+	NotFound       []uint `json:"not_found"`
+}
+
 // TableName specifies the table name for Account model
 func (Account) TableName() string {
 	return "accounts"
@@ -77,6 +233,35 @@ func (a *Account) ToJSON() map[string]interface{} {
 	}
 }
 
+// MaxKickDataLength limits the size of stored Kick API data
+const MaxKickDataLength = 64 * 1024
+
+// ValidateKickData checks that data is a JSON document within the size limit
+func ValidateKickData(data []byte) error {
+	if len(data) > MaxKickDataLength {
+		return fmt.Errorf("kick_data must be at most %d bytes", MaxKickDataLength)
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("kick_data must be valid JSON: %w", err)
+	}
+	return nil
+}
+
+// ParseKickData returns the stored KickData as JSON, or nil if none is set.
+// Older rows may hold malformed data, which is reported as an error.
+func (a *Account) ParseKickData() (json.RawMessage, error) {
+	if strings.TrimSpace(a.KickData) == "" {
+		return nil, nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(a.KickData), &v); err != nil {
+		return nil, fmt.Errorf("stored kick_data is malformed: %w", err)
+	}
+	return json.RawMessage(a.KickData), nil
+}
+
 // HidePasswords masks sensitive password information
 func (a *Account) HidePasswords() {
 	if a.Password != "" {
@@ -106,15 +291,15 @@ func (a *Account) Validate() error {
 
 // IsActive checks if the account is in active status
 func (a *Account) IsActive() bool {
-	return a.Status == "active"
+	return a.Status == AccountStatusActive
 }
 
 // IsBanned checks if the account is banned
 func (a *Account) IsBanned() bool {
-	return a.Status == "banned"
+	return a.Status == AccountStatusBanned
 }
 
 // IsSuspended checks if the account is suspended
 func (a *Account) IsSuspended() bool {
-	return a.Status == "suspended"
+	return a.Status == AccountStatusSuspended
 }
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Account history actions
+const (
+	AccountActionStatusChanged         = "status_changed"
+	AccountActionRestored              = "restored"
+	AccountActionCredentialsDownloaded = "credentials_downloaded"
+	AccountActionNoteChanged           = "note_changed"
+	AccountActionCheckedOut            = "checked_out"
+	AccountActionCheckedIn             = "checked_in"
+)
+
+// AccountHistory records an audited change to an account
+type AccountHistory struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	AccountID uint   `gorm:"index;not null" json:"account_id"`
+	Action    string `gorm:"index;not null" json:"action"`
+	OldValue  string `gorm:"type:text" json:"old_value,omitempty"`
+	NewValue  string `gorm:"type:text" json:"new_value,omitempty"`
+	Reason    string `gorm:"type:text" json:"reason,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// TableName specifies the table name for AccountHistory model
+func (AccountHistory) TableName() string {
+	return "account_history"
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Failure categories for failed job error messages
+const (
+	FailureCaptcha           = "captcha"
+	FailureProxy             = "proxy"
+	FailureEmailVerification = "email_verification"
+	FailureDuplicate         = "duplicate"
+	FailureRateLimit         = "rate_limit"
+	FailureUnknown           = "unknown"
+)
+
+// MaxFailureMessages is the number of raw messages listed per category
+const MaxFailureMessages = 5
+
+// FailureRule maps error messages matching Pattern to Category
+type FailureRule struct {
+	Category string
+	Pattern  *regexp.Regexp
+}
+
+// FailureRules are checked in order; the first match wins. Worker error
+// messages often join several account errors, so the more specific
+// categories come first. Messages matching no rule are reported as unknown
+// with their text so new rules can be added here.
+var FailureRules = []FailureRule{
+	{FailureCaptcha, regexp.MustCompile(`(?i)kasada|captcha|challenge`)},
+	{FailureRateLimit, regexp.MustCompile(`(?i)rate limit|too many requests|\b429\b`)},
+	{FailureEmailVerification, regexp.MustCompile(`(?i)verification email|verify email|email verification|imap|email pool|verification failed`)},
+	{FailureProxy, regexp.MustCompile(`(?i)proxy|tunnel|connection (refused|reset)|timed out|timeout`)},
+	{FailureDuplicate, regexp.MustCompile(`(?i)duplicate|already (taken|exists|registered|in use)|unique constraint`)},
+}
+
+// CategorizeFailure returns the failure category of an error message
+func CategorizeFailure(message string) string {
+	for _, rule := range FailureRules {
+		if rule.Pattern.MatchString(message) {
+			return rule.Category
+		}
+	}
+	return FailureUnknown
+}
+
+// FailureMessageCount is a distinct error message and how often it occurred
+type FailureMessageCount struct {
+	Message string `json:"message"`
+	Count   int64  `json:"count"`
+}
+
+// FailureCategoryStats counts failed jobs in one category
+type FailureCategoryStats struct {
+	Category    string                `json:"category"`
+	Count       int64                 `json:"count"`
+	TopMessages []FailureMessageCount `json:"top_messages"`
+}
+
+// digitRuns matches numbers that vary between otherwise identical messages
+var digitRuns = regexp.MustCompile(`\d+`)
+
+// normalizeFailureMessage collapses numbers (account indexes, retry counts)
+// so repeated failures group under one message
+func normalizeFailureMessage(message string) string {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return "(no error message)"
+	}
+	return digitRuns.ReplaceAllString(message, "N")
+}
+
+// BuildFailureBreakdown categorizes error messages, returning categories by
+// descending count, each with its most frequent messages
+func BuildFailureBreakdown(messages []string) []FailureCategoryStats {
+	counts := make(map[string]int64)
+	byMessage := make(map[string]map[string]int64)
+	for _, message := range messages {
+		category := CategorizeFailure(message)
+		counts[category]++
+		if byMessage[category] == nil {
+			byMessage[category] = make(map[string]int64)
+		}
+		byMessage[category][normalizeFailureMessage(message)]++
+	}
+
+	breakdown := make([]FailureCategoryStats, 0, len(counts))
+	for category, count := range counts {
+		top := make([]FailureMessageCount, 0, len(byMessage[category]))
+		for message, n := range byMessage[category] {
+			top = append(top, FailureMessageCount{Message: message, Count: n})
+		}
+		sort.Slice(top, func(i, j int) bool {
+			if top[i].Count != top[j].Count {
+				return top[i].Count > top[j].Count
+			}
+			return top[i].Message < top[j].Message
+		})
+		if len(top) > MaxFailureMessages {
+			top = top[:MaxFailureMessages]
+		}
+
+		breakdown = append(breakdown, FailureCategoryStats{
+			Category:    category,
+			Count:       count,
+			TopMessages: top,
+		})
+	}
+
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].Count != breakdown[j].Count {
+			return breakdown[i].Count > breakdown[j].Count
+		}
+		return breakdown[i].Category < breakdown[j].Category
+	})
+	return breakdown
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCategorizeFailure(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"Kasada challenge not solved", FailureCaptcha},
+		{"hCaptcha token rejected", FailureCaptcha},
+		{"Rate limit exceeded", FailureRateLimit},
+		{"HTTP 429 Too Many Requests", FailureRateLimit},
+		{"Timed out waiting for verification email", FailureEmailVerification},
+		{"IMAP login failed", FailureEmailVerification},
+		{"Email pool exhausted", FailureEmailVerification},
+		{"Proxy authentication required", FailureProxy},
+		{"dial tcp 10.0.0.1:8080: connection refused", FailureProxy},
+		{"read: connection reset by peer", FailureProxy},
+		{"request timeout after 30s", FailureProxy},
+		{"Username already taken", FailureDuplicate},
+		{"UNIQUE constraint failed: accounts.email", FailureDuplicate},
+		{"duplicate key value", FailureDuplicate},
+		// Joined worker errors take the first matching rule in order
+		{"account 1: captcha failed; account 2: proxy error", FailureCaptcha},
+		{"account 1: proxy error; account 2: username already exists", FailureProxy},
+		// Anything else is surfaced as unknown
+		{"worker crashed", FailureUnknown},
+		{"", FailureUnknown},
+		{"429ms elapsed", FailureUnknown},
+	}
+	for _, tt := range tests {
+		if got := CategorizeFailure(tt.message); got != tt.want {
+			t.Errorf("CategorizeFailure(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestBuildFailureBreakdown(t *testing.T) {
+	messages := []string{
+		"account 1: proxy error", "account 2: proxy error", "account 3: proxy error",
+		"Proxy tunnel failed",
+		"captcha failed",
+		"worker crashed", "worker crashed", "", "  ",
+	}
+
+	want := []FailureCategoryStats{
+		{Category: FailureProxy, Count: 4, TopMessages: []FailureMessageCount{
+			{Message: "account N: proxy error", Count: 3},
+			{Message: "Proxy tunnel failed", Count: 1},
+		}},
+		{Category: FailureUnknown, Count: 4, TopMessages: []FailureMessageCount{
+			{Message: "(no error message)", Count: 2},
+			{Message: "worker crashed", Count: 2},
+		}},
+		{Category: FailureCaptcha, Count: 1, TopMessages: []FailureMessageCount{
+			{Message: "captcha failed", Count: 1},
+		}},
+	}
+	if got := BuildFailureBreakdown(messages); !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildFailureBreakdown = %+v, want %+v", got, want)
+	}
+
+	// Only the most frequent messages are listed, but all are counted
+	var many []string
+	for i := 0; i < MaxFailureMessages+3; i++ {
+		for j := 0; j <= i; j++ {
+			many = append(many, fmt.Sprintf("unexpected error %c", 'a'+i))
+		}
+	}
+	got := BuildFailureBreakdown(many)
+	if len(got) != 1 || got[0].Category != FailureUnknown || got[0].Count != int64(len(many)) {
+		t.Fatalf("breakdown = %+v, want every message counted as unknown", got)
+	}
+	if top := got[0].TopMessages; len(top) != MaxFailureMessages || top[0].Message != "unexpected error h" || top[0].Count != 8 {
+		t.Errorf("top messages = %+v, want the %d most frequent, largest first", top, MaxFailureMessages)
+	}
+
+	if got := BuildFailureBreakdown(nil); len(got) != 0 {
+		t.Errorf("breakdown of no messages = %+v, want none", got)
+	}
+}
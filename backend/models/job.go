@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -17,6 +20,23 @@ const (
 	JobStatusCancelled JobStatus = "cancelled"
 )
 
+// IsValidJobStatus checks if a status is one of the known job statuses
+func IsValidJobStatus(status string) bool {
+	switch JobStatus(status) {
+	case JobStatusPending, JobStatusRunning, JobStatusCompleted, JobStatusFailed, JobStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// JobType identifies what a job does
+type JobType string
+
+const (
+	JobTypeGenerate JobType = "generate"
+	JobTypeVerify   JobType = "verify"
+)
+
 // Job represents an account creation job
 type Job struct {
 	ID        string         `gorm:"primarykey" json:"id"`
@@ -25,14 +45,29 @@ type Job struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
 	// Job configuration
-	Count    int    `gorm:"not null" json:"count"`
-	Username string `json:"username,omitempty"`
-	Password string `json:"password,omitempty"`
+	Type     JobType `gorm:"default:'generate';index" json:"type"`
+	Label    string  `gorm:"type:varchar(64);index" json:"label,omitempty"`
+	Count    int     `gorm:"not null" json:"count"`
+	Username string  `json:"username,omitempty"`
+	Password string  `json:"password,omitempty"`
+
+	// Generation options; zero values mean the worker defaults
+	UsernamePrefix       string          `json:"username_prefix,omitempty"`
+	UsernameSuffixLength int             `gorm:"default:0" json:"username_suffix_length,omitempty"`
+	PasswordPolicy       *PasswordPolicy `gorm:"serializer:json" json:"password_policy,omitempty"`
+
+	// Target account for account-level jobs (verify)
+	AccountID uint `gorm:"index" json:"account_id,omitempty"`
 
 	// Job status
 	Status   JobStatus `gorm:"default:'pending'" json:"status"`
 	Progress int       `gorm:"default:0" json:"progress"`
 
+	// A paused job keeps its status but is held out of the queue (pending)
+	// or halted between accounts by its worker (running)
+	Paused   bool       `gorm:"default:false;index" json:"paused"`
+	PausedAt *time.Time `json:"paused_at,omitempty"`
+
 	// Results
 	Successful int `gorm:"default:0" json:"successful"`
 	Failed     int `gorm:"default:0" json:"failed"`
@@ -47,24 +82,252 @@ type Job struct {
 	// Job metadata
 	TestMode bool `gorm:"default:false" json:"test_mode"`
 	Priority int  `gorm:"default:0" json:"priority"`
+
+	// Completion callback
+	CallbackURL         string     `gorm:"type:varchar(2048)" json:"callback_url,omitempty"`
+	CallbackStatus      string     `json:"callback_status,omitempty"` // pending, sending, delivered, failed
+	CallbackAttempts    int        `gorm:"default:0" json:"callback_attempts,omitempty"`
+	CallbackLastError   string     `gorm:"type:text" json:"callback_last_error,omitempty"`
+	CallbackDeliveredAt *time.Time `json:"callback_delivered_at,omitempty"`
 }
 
+// Callback delivery states
+const (
+	CallbackPending   = "pending"
+	CallbackSending   = "sending"
+	CallbackDelivered = "delivered"
+	CallbackFailed    = "failed"
+)
+
+// MaxCallbackURLLength limits the size of a stored callback URL
+const MaxCallbackURLLength = 2048
+
+// Kick username rules: letters, digits and underscores, starting with a letter
+const (
+	MinUsernameLength           = 4
+	MaxUsernameLength           = 25
+	MaxUsernamePrefixLength     = 12
+	MinUsernameSuffixLength     = 4
+	DefaultUsernameSuffixLength = 8
+)
+
+var usernamePrefixPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// ValidateUsernameOptions checks a username prefix and random suffix length.
+// A suffix length of 0 means DefaultUsernameSuffixLength.
+func ValidateUsernameOptions(prefix string, suffixLength int) error {
+	if prefix == "" {
+		if suffixLength != 0 {
+			return fmt.Errorf("username_suffix_length requires username_prefix")
+		}
+		return nil
+	}
+
+	if len(prefix) > MaxUsernamePrefixLength {
+		return fmt.Errorf("username_prefix must be at most %d characters", MaxUsernamePrefixLength)
+	}
+	if !usernamePrefixPattern.MatchString(prefix) {
+		return fmt.Errorf("username_prefix must start with a letter and contain only letters, digits and underscores")
+	}
+
+	if suffixLength == 0 {
+		suffixLength = DefaultUsernameSuffixLength
+	}
+	if suffixLength < MinUsernameSuffixLength {
+		return fmt.Errorf("username_suffix_length must be at least %d", MinUsernameSuffixLength)
+	}
+	if total := len(prefix) + suffixLength; total > MaxUsernameLength {
+		return fmt.Errorf("username_prefix plus suffix is %d characters, maximum is %d", total, MaxUsernameLength)
+	}
+
+	return nil
+}
+
+// PasswordPolicy controls how the worker generates account passwords
+type PasswordPolicy struct {
+	Length         int  `json:"length"`
+	RequireSymbols bool `json:"require_symbols"`
+	RequireDigits  bool `json:"require_digits"`
+}
+
+// Password policy limits
+const (
+	MinPasswordLength     = 8
+	MaxPasswordLength     = 64
+	DefaultPasswordLength = 16
+)
+
+// DefaultPasswordPolicy is what the worker uses when a job has no policy
+var DefaultPasswordPolicy = PasswordPolicy{Length: DefaultPasswordLength}
+
+// Validate checks the password policy. A length of 0 means the default.
+func (p *PasswordPolicy) Validate() error {
+	if p.Length == 0 {
+		return nil
+	}
+	if p.Length < MinPasswordLength || p.Length > MaxPasswordLength {
+		return fmt.Errorf("password_policy.length must be between %d and %d", MinPasswordLength, MaxPasswordLength)
+	}
+	return nil
+}
+
+// EffectivePasswordPolicy returns the job's password policy with defaults applied
+func (j *Job) EffectivePasswordPolicy() PasswordPolicy {
+	if j.PasswordPolicy == nil {
+		return DefaultPasswordPolicy
+	}
+
+	policy := *j.PasswordPolicy
+	if policy.Length == 0 {
+		policy.Length = DefaultPasswordLength
+	}
+	return policy
+}
+
+// EffectiveUsernameSuffixLength returns the random suffix length the worker
+// appends to UsernamePrefix, or 0 when the job uses fully random usernames
+func (j *Job) EffectiveUsernameSuffixLength() int {
+	if j.UsernamePrefix == "" {
+		return 0
+	}
+	if j.UsernameSuffixLength == 0 {
+		return DefaultUsernameSuffixLength
+	}
+	return j.UsernameSuffixLength
+}
+
+// VerificationResult is the result payload a worker stores for a verify job
+type VerificationResult struct {
+	AccountID uint   `json:"account_id"`
+	Outcome   string `json:"outcome"` // valid, invalid, banned, unknown
+	Message   string `json:"message,omitempty"`
+}
+
+// Verification outcomes reported by workers
+const (
+	VerificationValid   = "valid"
+	VerificationInvalid = "invalid"
+	VerificationBanned  = "banned"
+	VerificationUnknown = "unknown"
+)
+
+// AccountStatus maps a verification outcome to the account status it implies.
+// An empty string means the status should be left unchanged.
+func (r *VerificationResult) AccountStatus() string {
+	switch r.Outcome {
+	case VerificationValid:
+		return AccountStatusActive
+	case VerificationInvalid:
+		return AccountStatusSuspended
+	case VerificationBanned:
+		return AccountStatusBanned
+	default:
+		return ""
+	}
+}
+
+// Job priority bounds (low, normal, high)
+const (
+	MinJobPriority = 0
+	MaxJobPriority = 2
+)
+
 // JobCreateRequest represents a request to create a new job
 type JobCreateRequest struct {
-	Count    int    `json:"count" validate:"required,min=1,max=100"`
-	Username string `json:"username,omitempty"`
-	Password string `json:"password,omitempty"`
-	TestMode bool   `json:"test_mode,omitempty"`
-	Priority int    `json:"priority,omitempty"`
+	Type        JobType `json:"type,omitempty"`
+	Count       int     `json:"count" validate:"required,min=1,max=100"`
+	Label       string  `json:"label,omitempty"`
+	Username    string  `json:"username,omitempty"`
+	Password    string  `json:"password,omitempty"`
+	TestMode    bool    `json:"test_mode,omitempty"`
+	Priority    int     `json:"priority,omitempty" validate:"min=0,max=2"`
+	CallbackURL string  `json:"callback_url,omitempty"`
+}
+
+// Validate checks the request against its validation tags. Only generate
+// jobs are created directly; account jobs go through their account endpoints.
+func (r *JobCreateRequest) Validate() error {
+	if r.Type == "" {
+		r.Type = JobTypeGenerate
+	}
+	switch r.Type {
+	case JobTypeGenerate:
+	case JobTypeVerify:
+		return fmt.Errorf("%s jobs must be created through the account endpoints", r.Type)
+	default:
+		return fmt.Errorf("unsupported job type %q", r.Type)
+	}
+
+	if r.Count < 1 || r.Count > 100 {
+		return fmt.Errorf("count must be between 1 and 100")
+	}
+	if r.Priority < MinJobPriority || r.Priority > MaxJobPriority {
+		return fmt.Errorf("priority must be between %d and %d", MinJobPriority, MaxJobPriority)
+	}
+	return nil
 }
 
 // JobResponse represents the response for job operations
 type JobResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message,omitempty"`
-	Job     *Job   `json:"job,omitempty"`
-	Jobs    []Job  `json:"jobs,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message,omitempty"`
+	Job        *Job        `json:"job,omitempty"`
+	Jobs       []Job       `json:"jobs,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// MaxJobLabelLength limits the size of a job label
+const MaxJobLabelLength = 64
+
+// NormalizeJobLabel trims a job label and checks its length.
+// An empty result means the job has no label.
+func NormalizeJobLabel(label string) (string, error) {
+	label = strings.TrimSpace(label)
+	if len(label) > MaxJobLabelLength {
+		return "", fmt.Errorf("label must be at most %d characters", MaxJobLabelLength)
+	}
+	return label, nil
+}
+
+// JobFilter narrows job listings
+type JobFilter struct {
+	Label       string
+	Status      JobStatus
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+// IsEmpty reports whether no filter criteria are set
+func (f JobFilter) IsEmpty() bool {
+	return f.Label == "" && f.Status == "" && f.CreatedFrom == nil && f.CreatedTo == nil
+}
+
+// Pagination describes a page of a listing
+type Pagination struct {
+	Limit   int   `json:"limit"`
+	Offset  int   `json:"offset"`
+	Total   int64 `json:"total"`
+	Count   int   `json:"count"`
+	HasMore bool  `json:"has_more"`
+}
+
+// JobLabelStats summarizes the jobs sharing a label
+type JobLabelStats struct {
+	Label     string `json:"label"`
+	Total     int64  `json:"total"`
+	Pending   int64  `json:"pending"`
+	Running   int64  `json:"running"`
+	Completed int64  `json:"completed"`
+	Failed    int64  `json:"failed"`
+	Cancelled int64  `json:"cancelled"`
+}
+
+// BulkCancelResult reports the outcome of a bulk job cancel per ID
+type BulkCancelResult struct {
+	Cancelled      []string `json:"cancelled"`
+	NotCancellable []string `json:"not_cancellable"`
+	NotFound       []string `json:"not_found"`
 }
 
 // JobStats represents statistics about jobs
@@ -75,6 +338,44 @@ type JobStats struct {
 	Completed int64 `json:"completed"`
 	Failed    int64 `json:"failed"`
 	Cancelled int64 `json:"cancelled"`
+
+	// Pending or running jobs that are paused; also counted in their status
+	Paused int64 `json:"paused"`
+
+	// Job counts per type (generate, verify)
+	ByType map[JobType]int64 `json:"by_type"`
+}
+
+// MinThroughputJobs is the number of completed jobs needed before ETAs
+// are estimated
+const MinThroughputJobs = 3
+
+// JobThroughput summarizes the run time of recently completed jobs
+type JobThroughput struct {
+	Jobs         int64   `json:"jobs"`
+	Accounts     int64   `json:"accounts"`
+	TotalSeconds float64 `json:"total_seconds"`
+}
+
+// IsSufficient checks if there is enough history to estimate from
+func (t *JobThroughput) IsSufficient() bool {
+	return t != nil && t.Jobs >= MinThroughputJobs && t.Accounts > 0 && t.TotalSeconds > 0
+}
+
+// SecondsPerAccount returns the average time spent per account
+func (t *JobThroughput) SecondsPerAccount() float64 {
+	if t == nil || t.Accounts == 0 {
+		return 0
+	}
+	return t.TotalSeconds / float64(t.Accounts)
+}
+
+// AverageJobSeconds returns the average run time of a job
+func (t *JobThroughput) AverageJobSeconds() float64 {
+	if t == nil || t.Jobs == 0 {
+		return 0
+	}
+	return t.TotalSeconds / float64(t.Jobs)
 }
 
 // TableName specifies the table name for Job model
@@ -94,6 +395,68 @@ func (j *Job) CanBeCancelled() bool {
 	return j.Status == JobStatusPending || j.Status == JobStatusRunning
 }
 
+// CanBePaused checks if the job can be paused
+func (j *Job) CanBePaused() bool {
+	return !j.Paused && (j.Status == JobStatusPending || j.Status == JobStatusRunning)
+}
+
+// Pause marks the job as paused
+func (j *Job) Pause() {
+	now := time.Now()
+	j.Paused = true
+	j.PausedAt = &now
+}
+
+// Resume clears the paused flag
+func (j *Job) Resume() {
+	j.Paused = false
+	j.PausedAt = nil
+}
+
+// CanBeRetried checks if the job can be retried
+func (j *Job) CanBeRetried() bool {
+	return j.Status == JobStatusFailed || j.Status == JobStatusCancelled
+}
+
+// ResetForRetry clears the job's progress and results and returns it to
+// pending. A configured callback is re-armed so the retry is reported too.
+func (j *Job) ResetForRetry() {
+	j.Status = JobStatusPending
+	j.Progress = 0
+	j.Successful = 0
+	j.Failed = 0
+	j.ErrorMsg = ""
+	j.StartedAt = nil
+	j.CompletedAt = nil
+
+	if j.CallbackURL != "" {
+		j.CallbackStatus = CallbackPending
+		j.CallbackAttempts = 0
+		j.CallbackLastError = ""
+		j.CallbackDeliveredAt = nil
+	}
+}
+
+// CloneForRetry returns a new pending job with the same configuration
+func (j *Job) CloneForRetry(id string) *Job {
+	clone := &Job{
+		ID:                   id,
+		Type:                 j.Type,
+		Count:                j.Count,
+		Username:             j.Username,
+		Password:             j.Password,
+		UsernamePrefix:       j.UsernamePrefix,
+		UsernameSuffixLength: j.UsernameSuffixLength,
+		PasswordPolicy:       j.PasswordPolicy,
+		AccountID:            j.AccountID,
+		TestMode:             j.TestMode,
+		Priority:             j.Priority,
+		CallbackURL:          j.CallbackURL,
+	}
+	clone.ResetForRetry()
+	return clone
+}
+
 // GetDuration returns the duration of the job
 func (j *Job) GetDuration() time.Duration {
 	if j.StartedAt == nil {
@@ -108,6 +471,27 @@ func (j *Job) GetDuration() time.Duration {
 	return endTime.Sub(*j.StartedAt)
 }
 
+// EstimateRemaining estimates how long until the job completes from recent
+// throughput. Pending jobs also wait for the jobsAhead jobs queued before
+// them. It returns false when the job is finished or history is too thin.
+func (j *Job) EstimateRemaining(throughput *JobThroughput, jobsAhead int64) (time.Duration, bool) {
+	if j.IsCompleted() || !throughput.IsSufficient() {
+		return 0, false
+	}
+
+	remaining := j.Count - j.Progress
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	seconds := float64(remaining) * throughput.SecondsPerAccount()
+	if j.Status == JobStatusPending && jobsAhead > 0 {
+		seconds += float64(jobsAhead) * throughput.AverageJobSeconds()
+	}
+
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
 // GetProgress returns the progress percentage (0-100)
 func (j *Job) GetProgress() float64 {
 	if j.Count == 0 {
@@ -168,12 +552,16 @@ func (j *Job) IncrementProgress(successful bool) {
 func (j *Job) ToJSON() map[string]interface{} {
 	result := map[string]interface{}{
 		"id":         j.ID,
+		"type":       j.Type,
 		"count":      j.Count,
 		"status":     j.Status,
 		"progress":   j.Progress,
 		"successful": j.Successful,
 		"failed":     j.Failed,
 		"priority":   j.Priority,
+		"test_mode":  j.TestMode,
+		"label":      j.Label,
+		"paused":     j.Paused,
 		"created_at": j.CreatedAt,
 		"updated_at": j.UpdatedAt,
 	}
@@ -191,6 +579,10 @@ func (j *Job) ToJSON() map[string]interface{} {
 		result["error_msg"] = j.ErrorMsg
 	}
 
+	if j.AccountID != 0 {
+		result["account_id"] = j.AccountID
+	}
+
 	result["progress_percent"] = j.GetProgress()
 	result["success_rate"] = j.GetSuccessRate()
 
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// JobEvent is one entry of a job's status timeline, recorded from the job
+// updates channel
+type JobEvent struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"timestamp"`
+
+	JobID   string                 `gorm:"index;not null" json:"job_id"`
+	Event   string                 `gorm:"not null" json:"event"`
+	Status  string                 `json:"status"`
+	Payload map[string]interface{} `gorm:"serializer:json" json:"payload,omitempty"`
+}
+
+// TableName specifies the table name for JobEvent model
+func (JobEvent) TableName() string {
+	return "job_events"
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// Job log levels, from least to most severe
+const (
+	JobLogDebug = "debug"
+	JobLogInfo  = "info"
+	JobLogWarn  = "warn"
+	JobLogError = "error"
+)
+
+// MaxJobLogLines is the number of log lines kept per job; older lines are
+// trimmed as new ones are appended
+const MaxJobLogLines = 500
+
+// jobLogSeverity orders log levels for minimum-level filtering
+var jobLogSeverity = map[string]int{
+	JobLogDebug: 0,
+	JobLogInfo:  1,
+	JobLogWarn:  2,
+	JobLogError: 3,
+}
+
+// IsValidJobLogLevel checks if a log level is supported
+func IsValidJobLogLevel(level string) bool {
+	_, ok := jobLogSeverity[level]
+	return ok
+}
+
+// JobLogLevelsAtLeast returns the levels at or above the given level
+func JobLogLevelsAtLeast(level string) []string {
+	min := jobLogSeverity[level]
+	levels := make([]string, 0, len(jobLogSeverity))
+	for l, severity := range jobLogSeverity {
+		if severity >= min {
+			levels = append(levels, l)
+		}
+	}
+	return levels
+}
+
+// JobLog is a log line recorded for a job
+type JobLog struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"timestamp"`
+
+	JobID   string `gorm:"index;not null" json:"job_id"`
+	Level   string `gorm:"not null" json:"level"`
+	Message string `gorm:"type:text" json:"message"`
+}
+
+// TableName specifies the table name for JobLog model
+func (JobLog) TableName() string {
+	return "job_logs"
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimateRemaining(t *testing.T) {
+	// 4 jobs of 40 accounts in 400s: 10s per account, 100s per job
+	history := &JobThroughput{Jobs: 4, Accounts: 40, TotalSeconds: 400}
+
+	tests := []struct {
+		name       string
+		job        Job
+		throughput *JobThroughput
+		jobsAhead  int64
+		want       time.Duration
+		ok         bool
+	}{
+		{"running, half done", Job{Status: JobStatusRunning, Count: 10, Progress: 5}, history, 0, 50 * time.Second, true},
+		{"running ignores the queue", Job{Status: JobStatusRunning, Count: 10, Progress: 5}, history, 3, 50 * time.Second, true},
+		{"running, progress past count", Job{Status: JobStatusRunning, Count: 10, Progress: 12}, history, 0, 0, true},
+		{"pending at the head", Job{Status: JobStatusPending, Count: 3}, history, 0, 30 * time.Second, true},
+		{"pending behind two jobs", Job{Status: JobStatusPending, Count: 3}, history, 2, 230 * time.Second, true},
+		{"fractional seconds", Job{Status: JobStatusPending, Count: 1},
+			&JobThroughput{Jobs: 3, Accounts: 4, TotalSeconds: 3}, 0, 750 * time.Millisecond, true},
+		{"completed", Job{Status: JobStatusCompleted, Count: 10, Progress: 10}, history, 0, 0, false},
+		{"failed", Job{Status: JobStatusFailed, Count: 10, Progress: 4}, history, 0, 0, false},
+		{"cancelled", Job{Status: JobStatusCancelled, Count: 10}, history, 1, 0, false},
+		{"no history", Job{Status: JobStatusRunning, Count: 10}, nil, 0, 0, false},
+		{"too few jobs", Job{Status: JobStatusRunning, Count: 10},
+			&JobThroughput{Jobs: MinThroughputJobs - 1, Accounts: 20, TotalSeconds: 200}, 0, 0, false},
+		{"no accounts", Job{Status: JobStatusRunning, Count: 10},
+			&JobThroughput{Jobs: 5, TotalSeconds: 200}, 0, 0, false},
+		{"no elapsed time", Job{Status: JobStatusRunning, Count: 10},
+			&JobThroughput{Jobs: 5, Accounts: 20}, 0, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.job.EstimateRemaining(tt.throughput, tt.jobsAhead)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: EstimateRemaining = %s, %v, want %s, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestJobCreateRequestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		req  JobCreateRequest
+		err  string // part of the expected message, empty if valid
+	}{
+		{"defaults to generate", JobCreateRequest{Count: 1}, ""},
+		{"explicit generate", JobCreateRequest{Type: JobTypeGenerate, Count: 100, Priority: 2}, ""},
+		{"lowest priority", JobCreateRequest{Count: 5, Priority: MinJobPriority}, ""},
+		{"verify goes through accounts", JobCreateRequest{Type: JobTypeVerify, Count: 1}, "account endpoints"},
+		{"unknown type", JobCreateRequest{Type: "rotate", Count: 1}, `unsupported job type "rotate"`},
+		{"zero count", JobCreateRequest{}, "count must be between 1 and 100"},
+		{"negative count", JobCreateRequest{Count: -1}, "count must be between 1 and 100"},
+		{"count above the cap", JobCreateRequest{Count: 101}, "count must be between 1 and 100"},
+		{"priority below the range", JobCreateRequest{Count: 1, Priority: -1}, "priority must be between 0 and 2"},
+		{"priority above the range", JobCreateRequest{Count: 1, Priority: 3}, "priority must be between 0 and 2"},
+		{"type is checked before count", JobCreateRequest{Type: JobTypeVerify}, "account endpoints"},
+	}
+	for _, tt := range tests {
+		req := tt.req
+		err := req.Validate()
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: Validate() = %v, want nil", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: Validate() = %v, want an error mentioning %q", tt.name, err, tt.err)
+		}
+		if tt.req.Type == "" && req.Type != JobTypeGenerate {
+			t.Errorf("%s: type after Validate = %q, want it defaulted to %q", tt.name, req.Type, JobTypeGenerate)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MaxSettingRevisions is the number of settings revisions kept; older ones
+// are deleted as new revisions are recorded
+const MaxSettingRevisions = 50
+
+// SettingSnapshot is the configurable part of Setting, as stored in a
+// revision
+type SettingSnapshot struct {
+	RapidAPIKey  string `json:"rapidapi_key"`
+	IMAPServer   string `json:"imap_server"`
+	IMAPPort     int    `json:"imap_port"`
+	IMAPUsername string `json:"imap_username"`
+	IMAPPassword string `json:"imap_password"`
+	SMTPServer   string `json:"smtp_server"`
+	SMTPPort     int    `json:"smtp_port"`
+	SMTPUsername string `json:"smtp_username"`
+	SMTPPassword string `json:"smtp_password"`
+	ProxyURL     string `json:"proxy_url"`
+	WorkerCount  int    `json:"worker_count"`
+	RetryCount   int    `json:"retry_count"`
+	Timeout      int    `json:"timeout"`
+}
+
+// SettingRevision records the settings as saved at one point in time.
+// Snapshot secrets are stored as they are in the settings table, so they
+// are encrypted when settings encryption is enabled.
+type SettingRevision struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ChangedBy string          `json:"changed_by"`
+	Changes   []string        `gorm:"serializer:json" json:"changes"` // names of the fields that changed
+	Summary   string          `gorm:"type:text" json:"summary"`
+	Snapshot  SettingSnapshot `gorm:"serializer:json" json:"-"`
+}
+
+// TableName specifies the table name for SettingRevision model
+func (SettingRevision) TableName() string {
+	return "setting_revisions"
+}
+
+// SettingRevisionResponse is a revision with its snapshot's secrets masked
+type SettingRevisionResponse struct {
+	ID        uint             `json:"id"`
+	CreatedAt time.Time        `json:"created_at"`
+	ChangedBy string           `json:"changed_by"`
+	Changes   []string         `json:"changes"`
+	Summary   string           `json:"summary"`
+	Settings  SettingsResponse `json:"settings"`
+}
+
+// SnapshotOf captures the configurable fields of a setting
+func SnapshotOf(s *Setting) SettingSnapshot {
+	return SettingSnapshot{
+		RapidAPIKey:  s.RapidAPIKey,
+		IMAPServer:   s.IMAPServer,
+		IMAPPort:     s.IMAPPort,
+		IMAPUsername: s.IMAPUsername,
+		IMAPPassword: s.IMAPPassword,
+		SMTPServer:   s.SMTPServer,
+		SMTPPort:     s.SMTPPort,
+		SMTPUsername: s.SMTPUsername,
+		SMTPPassword: s.SMTPPassword,
+		ProxyURL:     s.ProxyURL,
+		WorkerCount:  s.WorkerCount,
+		RetryCount:   s.RetryCount,
+		Timeout:      s.Timeout,
+	}
+}
+
+// Secrets returns pointers to the snapshot's secret fields
+func (s *SettingSnapshot) Secrets() []*string {
+	return []*string{&s.RapidAPIKey, &s.IMAPPassword, &s.SMTPPassword}
+}
+
+// UpdateRequest returns a request setting every field to the snapshot's
+// value, so restoring a snapshot is validated like any other update
+func (s *SettingSnapshot) UpdateRequest() *SettingsUpdateRequest {
+	snap := *s
+	return &SettingsUpdateRequest{
+		RapidAPIKey:  &snap.RapidAPIKey,
+		IMAPServer:   &snap.IMAPServer,
+		IMAPPort:     &snap.IMAPPort,
+		IMAPUsername: &snap.IMAPUsername,
+		IMAPPassword: &snap.IMAPPassword,
+		SMTPServer:   &snap.SMTPServer,
+		SMTPPort:     &snap.SMTPPort,
+		SMTPUsername: &snap.SMTPUsername,
+		SMTPPassword: &snap.SMTPPassword,
+		ProxyURL:     &snap.ProxyURL,
+		WorkerCount:  &snap.WorkerCount,
+		RetryCount:   &snap.RetryCount,
+		Timeout:      &snap.Timeout,
+	}
+}
+
+// Response returns the snapshot in API form with its secrets masked
+func (s *SettingSnapshot) Response() SettingsResponse {
+	setting := Setting{}
+	s.UpdateRequest().ApplyTo(&setting)
+	return setting.ToResponse(false)
+}
+
+// DiffSnapshots lists the fields that differ between two snapshots and a
+// readable summary. Secret values never appear in the summary.
+func DiffSnapshots(before, after SettingSnapshot) ([]string, string) {
+	type field struct {
+		name   string
+		before interface{}
+		after  interface{}
+		secret bool
+	}
+	fields := []field{
+		{"rapidapi_key", before.RapidAPIKey, after.RapidAPIKey, true},
+		{"imap_server", before.IMAPServer, after.IMAPServer, false},
+		{"imap_port", before.IMAPPort, after.IMAPPort, false},
+		{"imap_username", before.IMAPUsername, after.IMAPUsername, false},
+		{"imap_password", before.IMAPPassword, after.IMAPPassword, true},
+		{"smtp_server", before.SMTPServer, after.SMTPServer, false},
+		{"smtp_port", before.SMTPPort, after.SMTPPort, false},
+		{"smtp_username", before.SMTPUsername, after.SMTPUsername, false},
+		{"smtp_password", before.SMTPPassword, after.SMTPPassword, true},
+		{"proxy_url", before.ProxyURL, after.ProxyURL, false},
+		{"worker_count", before.WorkerCount, after.WorkerCount, false},
+		{"retry_count", before.RetryCount, after.RetryCount, false},
+		{"timeout", before.Timeout, after.Timeout, false},
+	}
+
+	var changes, parts []string
+	for _, f := range fields {
+		if f.before == f.after {
+			continue
+		}
+		changes = append(changes, f.name)
+		switch {
+		case f.secret:
+			parts = append(parts, f.name+" changed")
+		case f.name == "proxy_url":
+			parts = append(parts, fmt.Sprintf("%s: %s -> %s", f.name,
+				redactProxyURL(before.ProxyURL), redactProxyURL(after.ProxyURL)))
+		default:
+			parts = append(parts, fmt.Sprintf("%s: %v -> %v", f.name, f.before, f.after))
+		}
+	}
+
+	if len(parts) == 0 {
+		return []string{}, "no changes"
+	}
+	return changes, strings.Join(parts, "; ")
+}
+
+// redactProxyURL hides any password embedded in a proxy URL
+func redactProxyURL(raw string) string {
+	if raw == "" {
+		return `""`
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "(unparseable URL)"
+	}
+	return u.Redacted()
+}
@@ -1,6 +1,10 @@
 package models
 
 import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -49,9 +53,34 @@ type SettingsResponse struct {
 	Timeout      int       `json:"timeout"`
 }
 
-// ToResponse converts Setting to SettingsResponse
-func (s *Setting) ToResponse() SettingsResponse {
-	return SettingsResponse{
+// Secrets returns pointers to the secret fields, which are masked in
+// responses and encrypted at rest
+func (s *Setting) Secrets() []*string {
+	return []*string{&s.RapidAPIKey, &s.IMAPPassword, &s.SMTPPassword}
+}
+
+// secretMask replaces all but the last characters of a secret
+const secretMask = "********"
+
+// secretVisibleChars is the number of trailing characters a masked secret shows
+const secretVisibleChars = 4
+
+// MaskSecret masks a secret, keeping its last 4 characters. Secrets too
+// short to keep any characters safely are masked entirely.
+func MaskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) <= secretVisibleChars*2 {
+		return secretMask
+	}
+	return secretMask + secret[len(secret)-secretVisibleChars:]
+}
+
+// ToResponse converts Setting to SettingsResponse. Secrets are masked
+// unless reveal is set.
+func (s *Setting) ToResponse(reveal bool) SettingsResponse {
+	resp := SettingsResponse{
 		ID:           s.ID,
 		CreatedAt:    s.CreatedAt,
 		UpdatedAt:    s.UpdatedAt,
@@ -69,4 +98,241 @@ func (s *Setting) ToResponse() SettingsResponse {
 		RetryCount:   s.RetryCount,
 		Timeout:      s.Timeout,
 	}
+
+	if !reveal {
+		resp.RapidAPIKey = MaskSecret(resp.RapidAPIKey)
+		resp.IMAPPassword = MaskSecret(resp.IMAPPassword)
+		resp.SMTPPassword = MaskSecret(resp.SMTPPassword)
+	}
+	return resp
+}
+
+// KeepMaskedSecrets restores secrets submitted as the masked value returned
+// by ToResponse, so saving a fetched form leaves them unchanged
+func (s *Setting) KeepMaskedSecrets(stored *Setting) {
+	keep := func(submitted *string, current string) {
+		if *submitted != "" && *submitted == MaskSecret(current) {
+			*submitted = current
+		}
+	}
+
+	keep(&s.RapidAPIKey, stored.RapidAPIKey)
+	keep(&s.IMAPPassword, stored.IMAPPassword)
+	keep(&s.SMTPPassword, stored.SMTPPassword)
+}
+
+// Settings field limits
+const (
+	MaxSettingsWorkerCount = 64
+	MaxSettingsRetryCount  = 10
+	MaxSettingsTimeout     = 600 // seconds
+)
+
+// SettingsUpdateRequest changes the provided settings fields. Nil fields
+// are left untouched, and secrets sent back masked keep their stored value.
+type SettingsUpdateRequest struct {
+	RapidAPIKey  *string `json:"rapidapi_key,omitempty"`
+	IMAPServer   *string `json:"imap_server,omitempty"`
+	IMAPPort     *int    `json:"imap_port,omitempty"`
+	IMAPUsername *string `json:"imap_username,omitempty"`
+	IMAPPassword *string `json:"imap_password,omitempty"`
+	SMTPServer   *string `json:"smtp_server,omitempty"`
+	SMTPPort     *int    `json:"smtp_port,omitempty"`
+	SMTPUsername *string `json:"smtp_username,omitempty"`
+	SMTPPassword *string `json:"smtp_password,omitempty"`
+	ProxyURL     *string `json:"proxy_url,omitempty"`
+	WorkerCount  *int    `json:"worker_count,omitempty"`
+	RetryCount   *int    `json:"retry_count,omitempty"`
+	Timeout      *int    `json:"timeout,omitempty"`
+}
+
+// SettingsFieldError describes one invalid settings field
+type SettingsFieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// SettingsValidationError lists every invalid field of a settings update
+type SettingsValidationError struct {
+	Fields []SettingsFieldError `json:"fields"`
+}
+
+func (e *SettingsValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + ": " + f.Message
+	}
+	return "invalid settings: " + strings.Join(parts, "; ")
+}
+
+// Validate checks the provided fields, returning a *SettingsValidationError
+// listing each invalid one
+func (r *SettingsUpdateRequest) Validate() error {
+	var fields []SettingsFieldError
+	fail := func(field, format string, args ...interface{}) {
+		fields = append(fields, SettingsFieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	checkHost := func(field string, host *string) {
+		if host == nil {
+			return
+		}
+		if !isValidHostname(strings.TrimSpace(*host)) {
+			fail(field, "must be a host name such as imap.example.com, without scheme or port")
+		}
+	}
+	checkPort := func(field string, port *int) {
+		if port != nil && (*port < 1 || *port > 65535) {
+			fail(field, "must be between 1 and 65535")
+		}
+	}
+
+	checkHost("imap_server", r.IMAPServer)
+	checkPort("imap_port", r.IMAPPort)
+	checkHost("smtp_server", r.SMTPServer)
+	checkPort("smtp_port", r.SMTPPort)
+
+	if r.ProxyURL != nil && strings.TrimSpace(*r.ProxyURL) != "" {
+		u, err := url.Parse(strings.TrimSpace(*r.ProxyURL))
+		switch {
+		case err != nil || u.Host == "":
+			fail("proxy_url", "must be a URL such as http://host:port")
+		case u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5" && u.Scheme != "socks5h":
+			fail("proxy_url", "scheme must be http, https, socks5 or socks5h, got %q", u.Scheme)
+		}
+	}
+
+	if r.WorkerCount != nil && (*r.WorkerCount < 1 || *r.WorkerCount > MaxSettingsWorkerCount) {
+		fail("worker_count", "must be between 1 and %d", MaxSettingsWorkerCount)
+	}
+	if r.RetryCount != nil && (*r.RetryCount < 0 || *r.RetryCount > MaxSettingsRetryCount) {
+		fail("retry_count", "must be between 0 and %d", MaxSettingsRetryCount)
+	}
+	if r.Timeout != nil && (*r.Timeout < 1 || *r.Timeout > MaxSettingsTimeout) {
+		fail("timeout", "must be between 1 and %d seconds", MaxSettingsTimeout)
+	}
+
+	if len(fields) > 0 {
+		return &SettingsValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// Validate checks every field of the settings
+func (s *Setting) Validate() error {
+	snapshot := SnapshotOf(s)
+	return snapshot.UpdateRequest().Validate()
+}
+
+// isValidHostname checks host name syntax (RFC 1123 labels) or accepts an
+// IP address
+func isValidHostname(host string) bool {
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, ch := range label {
+			if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ApplyTo copies the provided fields onto the settings
+func (r *SettingsUpdateRequest) ApplyTo(s *Setting) {
+	applySecret := func(dst *string, src *string) {
+		if src != nil && (*src == "" || *src != MaskSecret(*dst)) {
+			*dst = *src
+		}
+	}
+	applyString := func(dst *string, src *string) {
+		if src != nil {
+			*dst = strings.TrimSpace(*src)
+		}
+	}
+	applyInt := func(dst *int, src *int) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+
+	applySecret(&s.RapidAPIKey, r.RapidAPIKey)
+	applyString(&s.IMAPServer, r.IMAPServer)
+	applyInt(&s.IMAPPort, r.IMAPPort)
+	applyString(&s.IMAPUsername, r.IMAPUsername)
+	applySecret(&s.IMAPPassword, r.IMAPPassword)
+	applyString(&s.SMTPServer, r.SMTPServer)
+	applyInt(&s.SMTPPort, r.SMTPPort)
+	applyString(&s.SMTPUsername, r.SMTPUsername)
+	applySecret(&s.SMTPPassword, r.SMTPPassword)
+	applyString(&s.ProxyURL, r.ProxyURL)
+	applyInt(&s.WorkerCount, r.WorkerCount)
+	applyInt(&s.RetryCount, r.RetryCount)
+	applyInt(&s.Timeout, r.Timeout)
+}
+
+// Settings export file identification
+const (
+	SettingsExportFormat  = "botrix-settings"
+	SettingsExportVersion = 1
+)
+
+// RedactedSecret stands in for secrets left out of a settings export. On
+// import it means "keep the current value".
+const RedactedSecret = secretMask
+
+// SettingsExport is the settings export file
+type SettingsExport struct {
+	Format     string          `json:"format"`
+	Version    int             `json:"version"`
+	ExportedAt time.Time       `json:"exported_at"`
+	Settings   SettingSnapshot `json:"settings"`
+}
+
+// NewSettingsExport builds an export of the settings, replacing secrets
+// with RedactedSecret unless includeSecrets is set
+func NewSettingsExport(s *Setting, includeSecrets bool) SettingsExport {
+	snapshot := SnapshotOf(s)
+	if !includeSecrets {
+		for _, secret := range snapshot.Secrets() {
+			if *secret != "" {
+				*secret = RedactedSecret
+			}
+		}
+	}
+
+	return SettingsExport{
+		Format:     SettingsExportFormat,
+		Version:    SettingsExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Settings:   snapshot,
+	}
+}
+
+// UpdateRequest validates the export's header and returns a request
+// setting every field it holds. Redacted secrets are left out so the
+// current values are kept.
+func (e *SettingsExport) UpdateRequest() (*SettingsUpdateRequest, error) {
+	if e.Format != SettingsExportFormat {
+		return nil, fmt.Errorf("format must be %q", SettingsExportFormat)
+	}
+	if e.Version != SettingsExportVersion {
+		return nil, fmt.Errorf("unsupported settings export version %d", e.Version)
+	}
+
+	req := e.Settings.UpdateRequest()
+	for _, secret := range []**string{&req.RapidAPIKey, &req.IMAPPassword, &req.SMTPPassword} {
+		if **secret == RedactedSecret {
+			*secret = nil
+		}
+	}
+	return req, nil
 }
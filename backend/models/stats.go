@@ -0,0 +1,287 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Range caps for the time-series stats
+const (
+	MaxTimeseriesDays  = 365
+	MaxThroughputHours = 7 * 24
+	MaxDurationDays    = 90
+	MaxFailureDays     = 90
+	MaxReportDays      = 92
+)
+
+// DailyStats holds activity counts for one calendar day
+type DailyStats struct {
+	Date            string `json:"date"` // YYYY-MM-DD in the reporting timezone
+	AccountsCreated int64  `json:"accounts_created"`
+	JobsCompleted   int64  `json:"jobs_completed"`
+	JobsFailed      int64  `json:"jobs_failed"`
+}
+
+// HourlyStats holds job throughput for one clock hour
+type HourlyStats struct {
+	Hour                  time.Time `json:"hour"` // start of the hour in the reporting timezone
+	AccountsCompleted     int64     `json:"accounts_completed"`
+	JobsCompleted         int64     `json:"jobs_completed"`
+	AvgJobDurationSeconds float64   `json:"avg_job_duration_seconds"`
+}
+
+// CompletedJobTiming is the timing of one completed job, used for
+// throughput buckets
+type CompletedJobTiming struct {
+	StartedAt   *time.Time
+	CompletedAt time.Time
+	Successful  int
+}
+
+// StatsReportRow is one day, or the totals, of the downloadable stats report
+type StatsReportRow struct {
+	Date                  string  `json:"date"` // RFC3339 start of the day; "total" for the totals row
+	AccountsCreated       int64   `json:"accounts_created"`
+	JobsRun               int64   `json:"jobs_run"`
+	JobsCompleted         int64   `json:"jobs_completed"`
+	JobsFailed            int64   `json:"jobs_failed"`
+	SuccessRate           float64 `json:"success_rate"`
+	AvgJobDurationSeconds float64 `json:"avg_job_duration_seconds"`
+
+	// Completed jobs with a start time, the basis of the average duration
+	timedJobs    int64
+	totalSeconds float64
+}
+
+// DurationStats summarizes how long completed jobs of one priority ran,
+// from started_at to completed_at
+type DurationStats struct {
+	Priority     int     `json:"priority"`
+	PriorityName string  `json:"priority_name"`
+	Count        int64   `json:"count"`
+	AvgSeconds   float64 `json:"avg_seconds"`
+	P50Seconds   float64 `json:"p50_seconds"`
+	P95Seconds   float64 `json:"p95_seconds"`
+}
+
+// JobDurationSample is the run time of one completed job
+type JobDurationSample struct {
+	Priority    int
+	StartedAt   *time.Time
+	CompletedAt time.Time
+}
+
+// jobPriorityNames names the job priority levels
+var jobPriorityNames = map[int]string{
+	0: "low",
+	1: "normal",
+	2: "high",
+}
+
+// JobPriorityName returns the name of a priority level
+func JobPriorityName(priority int) string {
+	if name, ok := jobPriorityNames[priority]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// DayStart returns midnight, in loc, of the day containing t. Buckets are
+// computed in loc so day boundaries follow the reporting timezone.
+func DayStart(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
+
+// HourStart returns the start, in loc, of the clock hour containing t.
+// Unlike time.Truncate this respects zones with sub-hour UTC offsets.
+func HourStart(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), 0, 0, 0, loc)
+}
+
+// TimeseriesStart returns midnight, in loc, of the first of the last days
+// calendar days ending with the day containing now
+func TimeseriesStart(now time.Time, days int, loc *time.Location) time.Time {
+	return DayStart(now, loc).AddDate(0, 0, -(days - 1))
+}
+
+// ThroughputStart returns the start of the first of the last hours clock
+// hours ending with the hour containing now
+func ThroughputStart(now time.Time, hours int, loc *time.Location) time.Time {
+	return HourStart(now, loc).Add(-time.Duration(hours-1) * time.Hour)
+}
+
+// dayKey identifies the calendar day of t in loc
+func dayKey(t time.Time, loc *time.Location) string {
+	return DayStart(t, loc).Format("2006-01-02")
+}
+
+// CountByDay buckets timestamps by calendar day in loc
+func CountByDay(times []time.Time, loc *time.Location) map[string]int64 {
+	counts := make(map[string]int64)
+	for _, t := range times {
+		counts[dayKey(t, loc)]++
+	}
+	return counts
+}
+
+// BuildDailySeries returns one entry per day from start, zero-filled for
+// days with no activity
+func BuildDailySeries(start time.Time, days int, accounts, completed, failed map[string]int64) []DailyStats {
+	series := make([]DailyStats, days)
+	for i := range series {
+		date := start.AddDate(0, 0, i).Format("2006-01-02")
+		series[i] = DailyStats{
+			Date:            date,
+			AccountsCreated: accounts[date],
+			JobsCompleted:   completed[date],
+			JobsFailed:      failed[date],
+		}
+	}
+	return series
+}
+
+// BuildHourlySeries buckets completed jobs into one entry per hour from
+// start, zero-filled for hours with no completions
+func BuildHourlySeries(start time.Time, hours int, jobs []CompletedJobTiming, loc *time.Location) []HourlyStats {
+	series := make([]HourlyStats, hours)
+	index := make(map[int64]int, hours)
+	for i := range series {
+		hour := start.Add(time.Duration(i) * time.Hour).In(loc)
+		series[i].Hour = hour
+		index[hour.Unix()] = i
+	}
+
+	durations := make([]float64, hours)
+	timed := make([]int64, hours)
+	for _, job := range jobs {
+		i, ok := index[HourStart(job.CompletedAt, loc).Unix()]
+		if !ok {
+			continue
+		}
+		series[i].JobsCompleted++
+		series[i].AccountsCompleted += int64(job.Successful)
+		if job.StartedAt != nil {
+			durations[i] += job.CompletedAt.Sub(*job.StartedAt).Seconds()
+			timed[i]++
+		}
+	}
+
+	for i := range series {
+		if timed[i] > 0 {
+			series[i].AvgJobDurationSeconds = durations[i] / float64(timed[i])
+		}
+	}
+	return series
+}
+
+// ProjectHour extrapolates a count from the elapsed part of an hour to the
+// full hour. At least a minute is assumed elapsed to avoid wild projections.
+func ProjectHour(count int64, hourStart, now time.Time) float64 {
+	elapsed := now.Sub(hourStart)
+	if elapsed < time.Minute {
+		elapsed = time.Minute
+	}
+	if elapsed >= time.Hour {
+		return float64(count)
+	}
+	return float64(count) * float64(time.Hour) / float64(elapsed)
+}
+
+// BuildDurationStats groups job run times by priority. Every priority level
+// is present, with zero values when no jobs ran at that level; jobs without
+// a start time are skipped.
+func BuildDurationStats(samples []JobDurationSample) []DurationStats {
+	durations := make(map[int][]float64)
+	for _, sample := range samples {
+		if sample.StartedAt == nil {
+			continue
+		}
+		seconds := sample.CompletedAt.Sub(*sample.StartedAt).Seconds()
+		durations[sample.Priority] = append(durations[sample.Priority], seconds)
+	}
+
+	stats := make([]DurationStats, 0, MaxJobPriority-MinJobPriority+1)
+	for priority := MinJobPriority; priority <= MaxJobPriority; priority++ {
+		values := durations[priority]
+		entry := DurationStats{
+			Priority:     priority,
+			PriorityName: JobPriorityName(priority),
+			Count:        int64(len(values)),
+		}
+		if len(values) > 0 {
+			sort.Float64s(values)
+			var total float64
+			for _, v := range values {
+				total += v
+			}
+			entry.AvgSeconds = total / float64(len(values))
+			entry.P50Seconds = percentile(values, 50)
+			entry.P95Seconds = percentile(values, 95)
+		}
+		stats = append(stats, entry)
+	}
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// BuildStatsReport combines a daily series starting at start with the
+// timings of jobs completed in that range. It returns one row per day and a
+// totals row whose rates and averages are computed over the whole range.
+func BuildStatsReport(start time.Time, daily []DailyStats, timings []CompletedJobTiming, loc *time.Location) ([]StatsReportRow, StatsReportRow) {
+	rows := make([]StatsReportRow, len(daily))
+	index := make(map[string]int, len(daily))
+	for i, day := range daily {
+		rows[i] = StatsReportRow{
+			Date:            start.AddDate(0, 0, i).Format(time.RFC3339),
+			AccountsCreated: day.AccountsCreated,
+			JobsCompleted:   day.JobsCompleted,
+			JobsFailed:      day.JobsFailed,
+		}
+		index[day.Date] = i
+	}
+
+	for _, job := range timings {
+		i, ok := index[dayKey(job.CompletedAt, loc)]
+		if !ok || job.StartedAt == nil {
+			continue
+		}
+		rows[i].timedJobs++
+		rows[i].totalSeconds += job.CompletedAt.Sub(*job.StartedAt).Seconds()
+	}
+
+	totals := StatsReportRow{Date: "total"}
+	for i := range rows {
+		rows[i].finish()
+
+		totals.AccountsCreated += rows[i].AccountsCreated
+		totals.JobsCompleted += rows[i].JobsCompleted
+		totals.JobsFailed += rows[i].JobsFailed
+		totals.timedJobs += rows[i].timedJobs
+		totals.totalSeconds += rows[i].totalSeconds
+	}
+	totals.finish()
+
+	return rows, totals
+}
+
+// finish derives the run count, success rate and average duration
+func (r *StatsReportRow) finish() {
+	r.JobsRun = r.JobsCompleted + r.JobsFailed
+	if r.JobsRun > 0 {
+		r.SuccessRate = float64(r.JobsCompleted) / float64(r.JobsRun) * 100
+	}
+	if r.timedJobs > 0 {
+		r.AvgJobDurationSeconds = r.totalSeconds / float64(r.timedJobs)
+	}
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTimeseriesStart(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+	tokyo := time.FixedZone("JST", 9*60*60)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		days int
+		loc  *time.Location
+		want time.Time
+	}{
+		{"single day", time.Date(2024, 3, 15, 18, 30, 0, 0, time.UTC), 1, time.UTC,
+			time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"week", time.Date(2024, 3, 15, 18, 30, 0, 0, time.UTC), 7, time.UTC,
+			time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)},
+		{"at midnight", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), 2, time.UTC,
+			time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)},
+		// 23:30 UTC is already the next day in Tokyo
+		{"ahead of UTC", time.Date(2024, 3, 15, 23, 30, 0, 0, time.UTC), 1, tokyo,
+			time.Date(2024, 3, 16, 0, 0, 0, 0, tokyo)},
+		// 02:00 UTC is still the previous day in New York
+		{"behind UTC", time.Date(2024, 3, 15, 2, 0, 0, 0, time.UTC), 1, newYork,
+			time.Date(2024, 3, 14, 0, 0, 0, 0, newYork)},
+		{"across a month", time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC), 3, time.UTC,
+			time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Clocks sprang forward on 2024-03-10; the start is still midnight
+		{"across a DST change", time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC), 4, newYork,
+			time.Date(2024, 3, 9, 0, 0, 0, 0, newYork)},
+	}
+	for _, tt := range tests {
+		if got := TimeseriesStart(tt.now, tt.days, tt.loc); !got.Equal(tt.want) {
+			t.Errorf("%s: TimeseriesStart = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCountByDayUsesTheReportingTimezone(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	pacific := time.FixedZone("PST", -8*60*60)
+	times := []time.Time{
+		time.Date(2024, 3, 1, 7, 59, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 14, 59, 59, 0, time.UTC),
+		time.Date(2024, 3, 1, 15, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 23, 59, 59, 0, time.UTC),
+		time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		loc  *time.Location
+		want map[string]int64
+	}{
+		{time.UTC, map[string]int64{"2024-03-01": 5, "2024-03-02": 1}},
+		{tokyo, map[string]int64{"2024-03-01": 3, "2024-03-02": 3}},
+		{pacific, map[string]int64{"2024-02-29": 1, "2024-03-01": 5}},
+	}
+	for _, tt := range tests {
+		if got := CountByDay(times, tt.loc); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("CountByDay in %s = %v, want %v", tt.loc, got, tt.want)
+		}
+	}
+}
+
+func TestBuildDailySeriesZeroFills(t *testing.T) {
+	accounts := map[string]int64{"2024-02-27": 2, "2024-03-02": 5, "2024-03-03": 9}
+	completed := map[string]int64{"2024-02-29": 1}
+	failed := map[string]int64{"2024-03-02": 3, "2024-02-01": 7}
+
+	// 16:00 UTC on March 1st is already March 2nd in Tokyo, so the window
+	// of four days ends a day later there
+	now := time.Date(2024, 3, 1, 16, 0, 0, 0, time.UTC)
+	tests := []struct {
+		loc  *time.Location
+		want []DailyStats
+	}{
+		{time.UTC, []DailyStats{
+			{Date: "2024-02-27", AccountsCreated: 2},
+			{Date: "2024-02-28"},
+			{Date: "2024-02-29", JobsCompleted: 1},
+			{Date: "2024-03-01"},
+		}},
+		{time.FixedZone("JST", 9*60*60), []DailyStats{
+			{Date: "2024-02-28"},
+			{Date: "2024-02-29", JobsCompleted: 1},
+			{Date: "2024-03-01"},
+			{Date: "2024-03-02", AccountsCreated: 5, JobsFailed: 3},
+		}},
+	}
+	for _, tt := range tests {
+		start := TimeseriesStart(now, 4, tt.loc)
+		if got := BuildDailySeries(start, 4, accounts, completed, failed); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("series in %s = %+v, want %+v", tt.loc, got, tt.want)
+		}
+	}
+
+	start := TimeseriesStart(now, 2, time.UTC)
+	if got := BuildDailySeries(start, 2, nil, nil, nil); !reflect.DeepEqual(got, []DailyStats{
+		{Date: "2024-02-29"}, {Date: "2024-03-01"},
+	}) {
+		t.Errorf("series without activity = %+v, want two empty days", got)
+	}
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook events
+const (
+	WebhookEventAccountBanned    = "account_banned"
+	WebhookEventAccountSuspended = "account_suspended"
+	WebhookEventAccountActivated = "account_activated"
+	WebhookEventAccountRestored  = "account_restored"
+)
+
+// webhookEvents lists the events a webhook may subscribe to
+var webhookEvents = map[string]bool{
+	WebhookEventAccountBanned:    true,
+	WebhookEventAccountSuspended: true,
+	WebhookEventAccountActivated: true,
+	WebhookEventAccountRestored:  true,
+}
+
+// IsValidWebhookEvent checks if an event name is supported
+func IsValidWebhookEvent(event string) bool {
+	return webhookEvents[event]
+}
+
+// WebhookEventForStatus returns the event fired when an account moves to status
+func WebhookEventForStatus(status string) string {
+	switch status {
+	case AccountStatusBanned:
+		return WebhookEventAccountBanned
+	case AccountStatusSuspended:
+		return WebhookEventAccountSuspended
+	case AccountStatusActive:
+		return WebhookEventAccountActivated
+	default:
+		return ""
+	}
+}
+
+// Webhook is an external endpoint notified about account events
+type Webhook struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	URL     string `gorm:"type:varchar(2048);not null" json:"url"`
+	Secret  string `gorm:"type:varchar(255)" json:"secret,omitempty"`
+	Enabled bool   `json:"enabled"` // CreateWebhook defaults it to true
+
+	// Events the webhook receives; empty means all events
+	Events []string `gorm:"serializer:json" json:"events"`
+}
+
+// TableName specifies the table name for Webhook model
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// Matches checks if the webhook should receive the event
+func (w *Webhook) Matches(event string) bool {
+	if !w.Enabled {
+		return false
+	}
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// HideSecret masks the signing secret
+func (w *Webhook) HideSecret() {
+	if w.Secret != "" {
+		w.Secret = "********"
+	}
+}
+
+// WebhookRequest creates or updates a webhook. Nil fields are left untouched
+// on update.
+type WebhookRequest struct {
+	URL     *string   `json:"url,omitempty"`
+	Secret  *string   `json:"secret,omitempty"`
+	Enabled *bool     `json:"enabled,omitempty"`
+	Events  *[]string `json:"events,omitempty"`
+}
+
+// ValidateEvents checks that every requested event is supported
+func (r *WebhookRequest) ValidateEvents() error {
+	if r.Events == nil {
+		return nil
+	}
+	for _, event := range *r.Events {
+		if !IsValidWebhookEvent(event) {
+			return fmt.Errorf("unsupported event %q", event)
+		}
+	}
+	return nil
+}
+
+// ApplyTo copies the provided fields onto the webhook
+func (r *WebhookRequest) ApplyTo(w *Webhook) {
+	if r.URL != nil {
+		w.URL = *r.URL
+	}
+	if r.Secret != nil {
+		w.Secret = *r.Secret
+	}
+	if r.Enabled != nil {
+		w.Enabled = *r.Enabled
+	}
+	if r.Events != nil {
+		w.Events = *r.Events
+	}
+}
+
+// WebhookDelivery records one delivery attempt of a webhook event
+type WebhookDelivery struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	WebhookID  uint   `gorm:"index;not null" json:"webhook_id"`
+	DeliveryID string `gorm:"index" json:"delivery_id"` // shared by all attempts of one event
+	Event      string `json:"event"`
+	Attempt    int    `json:"attempt"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Success    bool   `json:"success"`
+	Error      string `gorm:"type:text" json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// TableName specifies the table name for WebhookDelivery model
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
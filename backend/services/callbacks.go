@@ -0,0 +1,161 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"botrix-backend/config"
+	"botrix-backend/models"
+)
+
+const (
+	// Callback delivery settings
+	callbackTimeout     = 10 * time.Second
+	callbackMaxAttempts = 5
+	callbackBaseBackoff = 2 * time.Second
+
+	// CallbackSignatureHeader carries the HMAC-SHA256 of "<timestamp>.<body>"
+	CallbackSignatureHeader = "X-Botrix-Signature"
+	// CallbackTimestampHeader carries the Unix time the payload was signed
+	CallbackTimestampHeader = "X-Botrix-Timestamp"
+)
+
+// CallbackPayload is the JSON body POSTed to a job's callback URL
+type CallbackPayload struct {
+	JobID           string  `json:"job_id"`
+	Status          string  `json:"status"`
+	Count           int     `json:"count"`
+	Successful      int     `json:"successful"`
+	Failed          int     `json:"failed"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Timestamp       int64   `json:"timestamp"`
+}
+
+// CallbackDispatcher delivers job completion callbacks
+type CallbackDispatcher struct {
+	db     *Database
+	client *http.Client
+	secret string
+}
+
+// NewCallbackDispatcher creates a new callback dispatcher
+func NewCallbackDispatcher(db *Database, cfg *config.Config) *CallbackDispatcher {
+	if cfg.Callbacks.SigningSecret == "" {
+		log.Println("[Callbacks] WARNING: CALLBACK_SIGNING_SECRET not set, callbacks will be unsigned")
+	}
+
+	return &CallbackDispatcher{
+		db:     db,
+		client: &http.Client{Timeout: callbackTimeout},
+		secret: cfg.Callbacks.SigningSecret,
+	}
+}
+
+// Dispatch delivers the job's callback in the background. It is a no-op if
+// the job has no callback or the callback was already claimed.
+func (d *CallbackDispatcher) Dispatch(job *models.Job, status models.JobStatus) {
+	if job.CallbackURL == "" {
+		return
+	}
+
+	claimed, err := d.db.ClaimJobCallback(job.ID)
+	if err != nil {
+		log.Printf("[Callbacks] ERROR: Failed to claim callback for job %s: %v", job.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	payload := CallbackPayload{
+		JobID:           job.ID,
+		Status:          string(status),
+		Count:           job.Count,
+		Successful:      job.Successful,
+		Failed:          job.Failed,
+		DurationSeconds: job.GetDuration().Seconds(),
+		Timestamp:       time.Now().Unix(),
+	}
+
+	go d.deliver(job.ID, job.CallbackURL, payload)
+}
+
+// deliver POSTs the payload with exponential backoff between attempts
+func (d *CallbackDispatcher) deliver(jobID, url string, payload CallbackPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("[Callbacks] ERROR: Failed to marshal callback for job %s: %v", jobID, err)
+		d.db.UpdateJobCallback(jobID, models.CallbackFailed, 0, err.Error(), nil)
+		return
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= callbackMaxAttempts; attempt++ {
+		lastErr = d.post(url, body)
+		if lastErr == nil {
+			now := time.Now()
+			d.db.UpdateJobCallback(jobID, models.CallbackDelivered, attempt, "", &now)
+			log.Printf("[Callbacks] Callback for job %s delivered (attempt %d)", jobID, attempt)
+			return
+		}
+
+		log.Printf("[Callbacks] WARNING: Callback for job %s failed (attempt %d/%d): %v",
+			jobID, attempt, callbackMaxAttempts, lastErr)
+
+		status := models.CallbackSending
+		if attempt == callbackMaxAttempts {
+			status = models.CallbackFailed
+		}
+		d.db.UpdateJobCallback(jobID, status, attempt, lastErr.Error(), nil)
+
+		if attempt < callbackMaxAttempts {
+			time.Sleep(callbackBaseBackoff * time.Duration(1<<(attempt-1)))
+		}
+	}
+
+	log.Printf("[Callbacks] ERROR: Giving up on callback for job %s: %v", jobID, lastErr)
+}
+
+// post sends one signed callback request
+func (d *CallbackDispatcher) post(url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Botrix-Callbacks/1.0")
+	req.Header.Set(CallbackTimestampHeader, timestamp)
+	if d.secret != "" {
+		req.Header.Set(CallbackSignatureHeader, "sha256="+SignPayload(d.secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignPayload returns the hex HMAC-SHA256 of "<timestamp>.<body>"
+func SignPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
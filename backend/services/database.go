@@ -1,22 +1,43 @@
 package services
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"botrix-backend/config"
 	"botrix-backend/models"
+	"botrix-backend/utils"
 
 	"github.com/glebarez/sqlite" // Pure Go SQLite driver based on modernc.org/sqlite
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// ErrJobInFlight is returned when an account already has a pending or
+// running job of the type being created
+var ErrJobInFlight = errors.New("account already has a job of this type in flight")
+
+// ErrAccountNotFound is returned when an account does not exist or is deleted
+var ErrAccountNotFound = errors.New("account not found")
+
+// ErrStatusTransition is returned when an account's current status does
+// not allow the requested status change
+var ErrStatusTransition = errors.New("account status does not allow this transition")
+
 // Database service handles all database operations
 type Database struct {
 	db     *gorm.DB
 	config *config.Config
+
+	// secrets encrypts setting secrets at rest; nil when no key is set
+	secrets *utils.SecretBox
+
+	// migrated is set once schema and settings migrations have completed
+	migrated bool
 }
 
 // NewDatabase creates a new database service
@@ -30,8 +51,11 @@ func NewDatabase(cfg *config.Config) (*Database, error) {
 		logLevel = logger.Info
 	}
 
+	// SQLite compares timestamps as text, so they are stored in UTC and
+	// range bounds must be converted to UTC before querying
 	gormConfig := &gorm.Config{
-		Logger: logger.Default.LogMode(logLevel),
+		Logger:  logger.Default.LogMode(logLevel),
+		NowFunc: func() time.Time { return time.Now().UTC() },
 	}
 
 	// Connect based on driver type
@@ -80,16 +104,143 @@ func NewDatabase(cfg *config.Config) (*Database, error) {
 		&models.Account{},
 		&models.Job{},
 		&models.Setting{},
+		&models.AccountHistory{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.JobLog{},
+		&models.JobEvent{},
+		&models.SettingRevision{},
 	); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	// At most one pending or running job of each type per account; the
+	// partial index is what keeps concurrent verify requests
+	// from both creating a job
+	if err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_account_in_flight
+		ON jobs (account_id, type)
+		WHERE account_id > 0 AND status IN ('pending', 'running') AND deleted_at IS NULL`).Error; err != nil {
+		return nil, fmt.Errorf("failed to create in-flight job index: %w", err)
+	}
+
 	log.Println("Database migration completed")
 
-	return &Database{
+	d := &Database{
 		db:     db,
 		config: cfg,
-	}, nil
+	}
+
+	if cfg.Security.SettingsEncryptionKey != "" {
+		d.secrets, err = utils.NewSecretBox(cfg.Security.SettingsEncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SETTINGS_ENCRYPTION_KEY: %w", err)
+		}
+	}
+	if err := d.migrateSettingsEncryption(); err != nil {
+		return nil, err
+	}
+	d.migrated = true
+
+	return d, nil
+}
+
+// migrateSettingsEncryption checks stored setting secrets against the
+// configured key and encrypts any still stored in plaintext. Encrypted
+// secrets that cannot be read fail startup in production; elsewhere the
+// problem is logged and surfaces when settings are read.
+func (d *Database) migrateSettingsEncryption() error {
+	var rows []models.Setting
+	if err := d.db.Find(&rows).Error; err != nil {
+		return fmt.Errorf("failed to load settings for encryption check: %w", err)
+	}
+
+rows:
+	for i := range rows {
+		setting := &rows[i]
+
+		plaintext := 0
+		for _, secret := range setting.Secrets() {
+			if *secret == "" {
+				continue
+			}
+			if !utils.IsEncryptedSecret(*secret) {
+				plaintext++
+				continue
+			}
+
+			err := utils.ErrSecretKeyMissing
+			if d.secrets != nil {
+				_, err = d.secrets.Decrypt(*secret)
+			}
+			if err != nil {
+				if d.config.IsProduction() {
+					return fmt.Errorf("cannot read encrypted settings (check SETTINGS_ENCRYPTION_KEY): %w", err)
+				}
+				// Mixing keys would make things worse, so leave the row alone
+				log.Printf("WARNING: cannot read encrypted settings (check SETTINGS_ENCRYPTION_KEY): %v", err)
+				continue rows
+			}
+		}
+
+		if plaintext == 0 {
+			continue
+		}
+		if d.secrets == nil {
+			if d.config.IsProduction() {
+				log.Println("WARNING: SETTINGS_ENCRYPTION_KEY not set, settings secrets are stored in plaintext")
+			}
+			continue
+		}
+
+		if err := d.encryptSecrets(setting); err != nil {
+			return fmt.Errorf("failed to encrypt settings: %w", err)
+		}
+		if err := d.db.Save(setting).Error; err != nil {
+			return fmt.Errorf("failed to save encrypted settings: %w", err)
+		}
+		log.Printf("Encrypted %d plaintext settings secret(s)", plaintext)
+	}
+
+	return nil
+}
+
+// encryptSecrets encrypts a setting's secrets in place. Without a key they
+// are left as plaintext.
+func (d *Database) encryptSecrets(setting *models.Setting) error {
+	if d.secrets == nil {
+		return nil
+	}
+	for _, secret := range setting.Secrets() {
+		encrypted, err := d.secrets.Encrypt(*secret)
+		if err != nil {
+			return err
+		}
+		*secret = encrypted
+	}
+	return nil
+}
+
+// decryptSecrets decrypts a setting's secrets in place
+func (d *Database) decryptSecrets(setting *models.Setting) error {
+	return d.decryptValues(setting.Secrets())
+}
+
+// decryptValues decrypts encrypted values in place, leaving plaintext alone
+func (d *Database) decryptValues(secrets []*string) error {
+	for _, secret := range secrets {
+		if !utils.IsEncryptedSecret(*secret) {
+			continue
+		}
+		if d.secrets == nil {
+			return utils.ErrSecretKeyMissing
+		}
+		plaintext, err := d.secrets.Decrypt(*secret)
+		if err != nil {
+			return err
+		}
+		*secret = plaintext
+	}
+	return nil
 }
 
 // GetDB returns the underlying GORM database instance
@@ -106,13 +257,27 @@ func (d *Database) Close() error {
 	return sqlDB.Close()
 }
 
-// Health checks the database connection
-func (d *Database) Health() error {
+// Health checks the database connection, giving up when ctx is done
+func (d *Database) Health(ctx context.Context) error {
 	sqlDB, err := d.db.DB()
 	if err != nil {
 		return err
 	}
-	return sqlDB.Ping()
+	return sqlDB.PingContext(ctx)
+}
+
+// Migrated reports whether startup migrations have completed
+func (d *Database) Migrated() bool {
+	return d.migrated
+}
+
+// PoolStats returns connection pool statistics for the database
+func (d *Database) PoolStats() (sql.DBStats, error) {
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+	return sqlDB.Stats(), nil
 }
 
 // Account operations
@@ -149,13 +314,70 @@ func (d *Database) GetAccountByUsername(username string) (*models.Account, error
 	return &account, nil
 }
 
-// ListAccounts retrieves all accounts with pagination
-func (d *Database) ListAccounts(limit, offset int) ([]models.Account, error) {
+// FindAccountBy retrieves an account by its email or username, optionally
+// including soft-deleted accounts
+func (d *Database) FindAccountBy(column, value string, includeDeleted bool) (*models.Account, error) {
+	if column != "email" && column != "username" {
+		return nil, fmt.Errorf("unsupported lookup column: %s", column)
+	}
+
+	query := d.db
+	if includeDeleted {
+		query = query.Unscoped()
+	}
+
+	var account models.Account
+	if err := query.Where(column+" = ?", value).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ListAccounts retrieves accounts matching the filter with sorting and pagination
+func (d *Database) ListAccounts(filter models.AccountFilter, sort models.AccountSort, limit, offset int) ([]models.Account, error) {
 	var accounts []models.Account
-	err := d.db.Limit(limit).Offset(offset).Order("created_at DESC").Find(&accounts).Error
+	err := d.applyAccountFilter(d.db, filter).
+		Limit(limit).
+		Offset(offset).
+		Order(sort.OrderClause()).
+		Find(&accounts).Error
 	return accounts, err
 }
 
+// CountAccountsFiltered returns the number of accounts matching the filter
+func (d *Database) CountAccountsFiltered(filter models.AccountFilter) (int64, error) {
+	var count int64
+	err := d.applyAccountFilter(d.db.Model(&models.Account{}), filter).Count(&count).Error
+	return count, err
+}
+
+// applyAccountFilter adds the filter's conditions to an account query
+func (d *Database) applyAccountFilter(query *gorm.DB, filter models.AccountFilter) *gorm.DB {
+	if filter.IncludeDeleted {
+		query = query.Unscoped()
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.JobID != "" {
+		query = query.Where("job_id = ?", filter.JobID)
+	}
+	if filter.CheckedOut != nil {
+		if *filter.CheckedOut {
+			query = query.Where("checked_out_at IS NOT NULL")
+		} else {
+			query = query.Where("checked_out_at IS NULL")
+		}
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", filter.CreatedFrom.UTC())
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at <= ?", filter.CreatedTo.UTC())
+	}
+	return query
+}
+
 // UpdateAccount updates an account
 func (d *Database) UpdateAccount(account *models.Account) error {
 	return d.db.Save(account).Error
@@ -166,6 +388,181 @@ func (d *Database) DeleteAccount(id uint) error {
 	return d.db.Delete(&models.Account{}, id).Error
 }
 
+// GetAccountUnscoped retrieves an account by ID, including soft-deleted ones
+func (d *Database) GetAccountUnscoped(id uint) (*models.Account, error) {
+	var account models.Account
+	if err := d.db.Unscoped().First(&account, id).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// FindAccountConflict returns a live account (other than excludeID) that uses
+// the given email or username, or nil if there is none
+func (d *Database) FindAccountConflict(excludeID uint, email, username string) (*models.Account, error) {
+	var account models.Account
+	err := d.db.Where("id <> ?", excludeID).
+		Where("email = ? OR username = ?", email, username).
+		First(&account).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// RestoreAccount clears the soft-delete marker of an account and records it in the history
+func (d *Database) RestoreAccount(id uint, requestID string) error {
+	return d.WithTransaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(&models.Account{}).
+			Where("id = ? AND deleted_at IS NOT NULL", id).
+			Update("deleted_at", nil)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("account %d is not deleted", id)
+		}
+
+		return tx.Create(&models.AccountHistory{
+			AccountID: id,
+			Action:    models.AccountActionRestored,
+			RequestID: requestID,
+		}).Error
+	})
+}
+
+// UpdateAccountNotes replaces an account's notes, recording the previous
+// value in the account history, and returns the updated account
+func (d *Database) UpdateAccountNotes(id uint, notes, requestID string) (*models.Account, error) {
+	var account models.Account
+
+	err := d.WithTransaction(func(tx *gorm.DB) error {
+		if err := tx.First(&account, id).Error; err != nil {
+			return err
+		}
+
+		oldNotes := account.Notes
+		if oldNotes == notes {
+			return nil
+		}
+
+		if err := tx.Model(&account).Update("notes", notes).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.AccountHistory{
+			AccountID: id,
+			Action:    models.AccountActionNoteChanged,
+			OldValue:  oldNotes,
+			NewValue:  notes,
+			RequestID: requestID,
+		}).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// CheckoutAccounts atomically marks up to count of the oldest available
+// active accounts as checked out by consumer and returns them. Each row is
+// claimed with a conditional update, so concurrent callers never receive the
+// same account.
+func (d *Database) CheckoutAccounts(count int, consumer, requestID string) ([]models.Account, error) {
+	var checkedOut []models.Account
+
+	err := d.WithTransaction(func(tx *gorm.DB) error {
+		var candidates []models.Account
+		err := tx.Where("status = ? AND checked_out_at IS NULL", models.AccountStatusActive).
+			Order("created_at ASC, id ASC").
+			Limit(count).
+			Find(&candidates).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, account := range candidates {
+			result := tx.Model(&models.Account{}).
+				Where("id = ? AND checked_out_at IS NULL", account.ID).
+				Updates(map[string]interface{}{
+					"checked_out_at": now,
+					"checked_out_by": consumer,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				// Claimed by a concurrent checkout
+				continue
+			}
+
+			if err := tx.Create(&models.AccountHistory{
+				AccountID: account.ID,
+				Action:    models.AccountActionCheckedOut,
+				NewValue:  consumer,
+				RequestID: requestID,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to record account history: %w", err)
+			}
+
+			account.CheckedOutAt = &now
+			account.CheckedOutBy = consumer
+			checkedOut = append(checkedOut, account)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return checkedOut, nil
+}
+
+// CheckinAccount releases a checked out account. It returns false if the
+// account was not checked out.
+func (d *Database) CheckinAccount(id uint, requestID string) (bool, error) {
+	released := false
+
+	err := d.WithTransaction(func(tx *gorm.DB) error {
+		var account models.Account
+		if err := tx.First(&account, id).Error; err != nil {
+			return err
+		}
+		if account.CheckedOutAt == nil {
+			return nil
+		}
+
+		result := tx.Model(&models.Account{}).
+			Where("id = ? AND checked_out_at IS NOT NULL", id).
+			Updates(map[string]interface{}{
+				"checked_out_at": nil,
+				"checked_out_by": "",
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		released = true
+
+		return tx.Create(&models.AccountHistory{
+			AccountID: id,
+			Action:    models.AccountActionCheckedIn,
+			OldValue:  account.CheckedOutBy,
+			RequestID: requestID,
+		}).Error
+	})
+
+	return released, err
+}
+
 // GetAccountStats retrieves statistics about accounts
 func (d *Database) GetAccountStats() (*models.AccountStats, error) {
 	var stats models.AccountStats
@@ -193,6 +590,18 @@ func (d *Database) CreateJob(job *models.Job) error {
 	return d.db.Create(job).Error
 }
 
+// CreateJobsBatch creates multiple jobs in a single transaction
+func (d *Database) CreateJobsBatch(jobs []*models.Job) error {
+	return d.WithTransaction(func(tx *gorm.DB) error {
+		for _, job := range jobs {
+			if err := tx.Create(job).Error; err != nil {
+				return fmt.Errorf("failed to create job %s: %w", job.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
 // GetJob retrieves a job by ID
 func (d *Database) GetJob(id string) (*models.Job, error) {
 	var job models.Job
@@ -202,13 +611,123 @@ func (d *Database) GetJob(id string) (*models.Job, error) {
 	return &job, nil
 }
 
-// ListJobs retrieves all jobs with pagination
-func (d *Database) ListJobs(limit, offset int) ([]models.Job, error) {
+// GetJobContext retrieves a job by ID, giving up when ctx is done
+func (d *Database) GetJobContext(ctx context.Context, id string) (*models.Job, error) {
+	var job models.Job
+	if err := d.db.WithContext(ctx).First(&job, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs retrieves jobs matching the filter with pagination
+func (d *Database) ListJobs(filter models.JobFilter, limit, offset int) ([]models.Job, error) {
 	var jobs []models.Job
-	err := d.db.Limit(limit).Offset(offset).Order("created_at DESC").Find(&jobs).Error
+	err := d.applyJobFilter(d.db, filter).Limit(limit).Offset(offset).Order("created_at DESC").Find(&jobs).Error
 	return jobs, err
 }
 
+// CountJobsFiltered returns the number of jobs matching the filter
+func (d *Database) CountJobsFiltered(filter models.JobFilter) (int64, error) {
+	var count int64
+	err := d.applyJobFilter(d.db.Model(&models.Job{}), filter).Count(&count).Error
+	return count, err
+}
+
+// applyJobFilter adds the filter's conditions to a job query
+func (d *Database) applyJobFilter(query *gorm.DB, filter models.JobFilter) *gorm.DB {
+	if filter.Label != "" {
+		query = query.Where("label = ?", filter.Label)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", filter.CreatedFrom.UTC())
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at <= ?", filter.CreatedTo.UTC())
+	}
+	return query
+}
+
+// IterateJobs walks the jobs matching the filter, oldest first, in batches
+// of batchSize so large histories are never loaded at once
+func (d *Database) IterateJobs(filter models.JobFilter, batchSize int, fn func([]models.Job) error) error {
+	var last *models.Job
+	for {
+		query := d.applyJobFilter(d.db, filter)
+		if last != nil {
+			// Keyset pagination on (created_at, id) stays stable while
+			// new jobs are inserted
+			query = query.Where("created_at > ? OR (created_at = ? AND id > ?)",
+				last.CreatedAt, last.CreatedAt, last.ID)
+		}
+
+		var batch []models.Job
+		if err := query.Order("created_at ASC, id ASC").Limit(batchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		last = &batch[len(batch)-1]
+	}
+}
+
+// GetJobLabelStats returns job counts by status for every label
+func (d *Database) GetJobLabelStats() ([]models.JobLabelStats, error) {
+	var rows []struct {
+		Label  string
+		Status models.JobStatus
+		Count  int64
+	}
+	err := d.db.Model(&models.Job{}).
+		Select("label, status, COUNT(*) AS count").
+		Where("label <> ''").
+		Group("label, status").
+		Order("label ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]models.JobLabelStats, 0)
+	index := make(map[string]int)
+	for _, row := range rows {
+		i, ok := index[row.Label]
+		if !ok {
+			i = len(stats)
+			index[row.Label] = i
+			stats = append(stats, models.JobLabelStats{Label: row.Label})
+		}
+
+		entry := &stats[i]
+		entry.Total += row.Count
+		switch row.Status {
+		case models.JobStatusPending:
+			entry.Pending += row.Count
+		case models.JobStatusRunning:
+			entry.Running += row.Count
+		case models.JobStatusCompleted:
+			entry.Completed += row.Count
+		case models.JobStatusFailed:
+			entry.Failed += row.Count
+		case models.JobStatusCancelled:
+			entry.Cancelled += row.Count
+		}
+	}
+
+	return stats, nil
+}
+
 // UpdateJob updates a job
 func (d *Database) UpdateJob(job *models.Job) error {
 	return d.db.Save(job).Error
@@ -216,7 +735,142 @@ func (d *Database) UpdateJob(job *models.Job) error {
 
 // DeleteJob deletes a job (soft delete)
 func (d *Database) DeleteJob(id string) error {
-	return d.db.Delete(&models.Job{}, "id = ?", id).Error
+	return d.WithTransaction(func(tx *gorm.DB) error {
+		if err := tx.Where("job_id = ?", id).Delete(&models.JobLog{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("job_id = ?", id).Delete(&models.JobEvent{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Job{}, "id = ?", id).Error
+	})
+}
+
+// DeleteJobWithAccounts soft deletes a job and, in the same transaction,
+// every account it produced. It returns the number of accounts deleted.
+func (d *Database) DeleteJobWithAccounts(id string) (int64, error) {
+	var deleted int64
+
+	err := d.WithTransaction(func(tx *gorm.DB) error {
+		result := tx.Where("job_id = ?", id).Delete(&models.Account{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+
+		if err := tx.Where("job_id = ?", id).Delete(&models.JobLog{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("job_id = ?", id).Delete(&models.JobEvent{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Job{}, "id = ?", id).Error
+	})
+
+	return deleted, err
+}
+
+// CancelJobs cancels multiple jobs in a transaction and reports which were
+// cancelled, which are not in a cancellable state, and which do not exist
+func (d *Database) CancelJobs(ids []string) (*models.BulkCancelResult, error) {
+	result := &models.BulkCancelResult{
+		Cancelled:      []string{},
+		NotCancellable: []string{},
+		NotFound:       []string{},
+	}
+
+	err := d.WithTransaction(func(tx *gorm.DB) error {
+		var jobs []models.Job
+		if err := tx.Where("id IN ?", ids).Find(&jobs).Error; err != nil {
+			return err
+		}
+
+		found := make(map[string]*models.Job, len(jobs))
+		for i := range jobs {
+			found[jobs[i].ID] = &jobs[i]
+		}
+
+		for _, id := range ids {
+			job, ok := found[id]
+			switch {
+			case !ok:
+				result.NotFound = append(result.NotFound, id)
+			case !job.CanBeCancelled():
+				result.NotCancellable = append(result.NotCancellable, id)
+			default:
+				job.Cancel()
+				if err := tx.Save(job).Error; err != nil {
+					return fmt.Errorf("failed to cancel job %s: %w", id, err)
+				}
+				result.Cancelled = append(result.Cancelled, id)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListJobIDsByStatus returns the IDs of jobs in a status, oldest first
+func (d *Database) ListJobIDsByStatus(status models.JobStatus, limit int) ([]string, error) {
+	var ids []string
+	err := d.db.Model(&models.Job{}).
+		Where("status = ?", status).
+		Order("created_at ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// ReconcileJobStatus moves a job that is still pending or running to the
+// given status. The update is conditional, so it is idempotent and safe to
+// race with other writers; it reports false when the job was already in
+// that status or had reached a terminal state.
+func (d *Database) ReconcileJobStatus(id string, status models.JobStatus, errorMsg string) (bool, error) {
+	now := time.Now()
+	updates := map[string]interface{}{"status": status}
+
+	switch status {
+	case models.JobStatusRunning:
+		updates["started_at"] = gorm.Expr("COALESCE(started_at, ?)", now)
+	case models.JobStatusCompleted, models.JobStatusFailed, models.JobStatusCancelled:
+		updates["started_at"] = gorm.Expr("COALESCE(started_at, ?)", now)
+		updates["completed_at"] = now
+		if errorMsg != "" {
+			updates["error_msg"] = errorMsg
+		}
+	}
+
+	result := d.db.Model(&models.Job{}).
+		Where("id = ? AND status IN ? AND status <> ?", id,
+			[]models.JobStatus{models.JobStatusPending, models.JobStatusRunning}, status).
+		Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ClaimJobCallback marks a pending callback as sending. It returns false if
+// another delivery already claimed it, which keeps delivery at-most-once.
+func (d *Database) ClaimJobCallback(jobID string) (bool, error) {
+	result := d.db.Model(&models.Job{}).
+		Where("id = ? AND callback_status = ?", jobID, models.CallbackPending).
+		Update("callback_status", models.CallbackSending)
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateJobCallback records the outcome of a callback delivery attempt
+func (d *Database) UpdateJobCallback(jobID, status string, attempts int, lastError string, deliveredAt *time.Time) error {
+	return d.db.Model(&models.Job{}).
+		Where("id = ?", jobID).
+		Updates(map[string]interface{}{
+			"callback_status":       status,
+			"callback_attempts":     attempts,
+			"callback_last_error":   lastError,
+			"callback_delivered_at": deliveredAt,
+		}).Error
 }
 
 // GetJobStats retrieves statistics about jobs
@@ -232,10 +886,281 @@ func (d *Database) GetJobStats() (*models.JobStats, error) {
 	d.db.Model(&models.Job{}).Where("status = ?", models.JobStatusCompleted).Count(&stats.Completed)
 	d.db.Model(&models.Job{}).Where("status = ?", models.JobStatusFailed).Count(&stats.Failed)
 	d.db.Model(&models.Job{}).Where("status = ?", models.JobStatusCancelled).Count(&stats.Cancelled)
+	d.db.Model(&models.Job{}).
+		Where("paused = ? AND status IN ?", true, []models.JobStatus{models.JobStatusPending, models.JobStatusRunning}).
+		Count(&stats.Paused)
+
+	// Per-type counts so verifications are distinguishable from generation
+	var typeCounts []struct {
+		Type  models.JobType
+		Count int64
+	}
+	d.db.Model(&models.Job{}).Select("type, COUNT(*) AS count").Group("type").Scan(&typeCounts)
+
+	stats.ByType = make(map[models.JobType]int64, len(typeCounts))
+	for _, tc := range typeCounts {
+		jobType := tc.Type
+		if jobType == "" {
+			jobType = models.JobTypeGenerate
+		}
+		stats.ByType[jobType] += tc.Count
+	}
 
 	return &stats, nil
 }
 
+// GetRecentThroughput summarizes the run time of the last window completed
+// jobs, excluding test-mode dry runs
+func (d *Database) GetRecentThroughput(window int) (*models.JobThroughput, error) {
+	var jobs []models.Job
+	err := d.db.Select("count, started_at, completed_at").
+		Where("status = ? AND test_mode = ?", models.JobStatusCompleted, false).
+		Where("started_at IS NOT NULL AND completed_at IS NOT NULL").
+		Order("completed_at DESC").
+		Limit(window).
+		Find(&jobs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	throughput := &models.JobThroughput{}
+	for _, job := range jobs {
+		elapsed := job.CompletedAt.Sub(*job.StartedAt).Seconds()
+		if elapsed <= 0 || job.Count <= 0 {
+			continue
+		}
+		throughput.Jobs++
+		throughput.Accounts += int64(job.Count)
+		throughput.TotalSeconds += elapsed
+	}
+
+	return throughput, nil
+}
+
+// CountPendingJobsAhead counts pending jobs that will be picked up before the
+// given job: higher priority first, then older jobs of the same priority
+func (d *Database) CountPendingJobsAhead(job *models.Job) (int64, error) {
+	var count int64
+	err := d.db.Model(&models.Job{}).
+		Where("status = ? AND id <> ?", models.JobStatusPending, job.ID).
+		Where("priority > ? OR (priority = ? AND created_at < ?)", job.Priority, job.Priority, job.CreatedAt).
+		Count(&count).Error
+	return count, err
+}
+
+// GetDailyStats returns per-day account creation and job outcome counts for
+// the last days calendar days in loc, zero-filled. Timestamps are bucketed
+// in Go rather than with SQL date functions so the query is portable across
+// SQLite and Postgres. Test-mode jobs are excluded; deleted accounts still
+// count toward the day they were created.
+func (d *Database) GetDailyStats(days int, loc *time.Location) ([]models.DailyStats, error) {
+	return d.GetDailyStatsFrom(models.TimeseriesStart(time.Now(), days, loc), days, loc)
+}
+
+// GetDailyStatsFrom returns per-day activity for days calendar days starting
+// at start, which must be midnight in loc
+func (d *Database) GetDailyStatsFrom(start time.Time, days int, loc *time.Location) ([]models.DailyStats, error) {
+	end := start.AddDate(0, 0, days)
+
+	var accountTimes []time.Time
+	if err := d.db.Unscoped().Model(&models.Account{}).
+		Where("created_at >= ? AND created_at < ?", start, end).
+		Pluck("created_at", &accountTimes).Error; err != nil {
+		return nil, err
+	}
+
+	outcomeTimes := func(status models.JobStatus) ([]time.Time, error) {
+		var times []time.Time
+		err := d.db.Model(&models.Job{}).
+			Where("status = ? AND test_mode = ? AND completed_at >= ? AND completed_at < ?", status, false, start, end).
+			Pluck("completed_at", &times).Error
+		return times, err
+	}
+
+	completedTimes, err := outcomeTimes(models.JobStatusCompleted)
+	if err != nil {
+		return nil, err
+	}
+	failedTimes, err := outcomeTimes(models.JobStatusFailed)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.BuildDailySeries(start, days,
+		models.CountByDay(accountTimes, loc),
+		models.CountByDay(completedTimes, loc),
+		models.CountByDay(failedTimes, loc),
+	), nil
+}
+
+// GetStatsReport returns one report row per calendar day starting at start,
+// which must be midnight in loc, and a totals row. Rows combine daily
+// activity with the average run time of non-test jobs completed that day.
+func (d *Database) GetStatsReport(start time.Time, days int, loc *time.Location) ([]models.StatsReportRow, models.StatsReportRow, error) {
+	var totals models.StatsReportRow
+
+	daily, err := d.GetDailyStatsFrom(start, days, loc)
+	if err != nil {
+		return nil, totals, err
+	}
+
+	var timings []models.CompletedJobTiming
+	err = d.db.Model(&models.Job{}).
+		Select("started_at, completed_at, successful").
+		Where("status = ? AND test_mode = ?", models.JobStatusCompleted, false).
+		Where("completed_at >= ? AND completed_at < ?", start, start.AddDate(0, 0, days)).
+		Scan(&timings).Error
+	if err != nil {
+		return nil, totals, err
+	}
+
+	rows, totals := models.BuildStatsReport(start, daily, timings, loc)
+	return rows, totals, nil
+}
+
+// GetHourlyThroughput returns per-hour completed account and job counts and
+// average job duration for the last hours clock hours in loc, zero-filled.
+// Like GetDailyStats it buckets in Go for portability; test-mode jobs are
+// excluded.
+func (d *Database) GetHourlyThroughput(hours int, loc *time.Location) ([]models.HourlyStats, error) {
+	start := models.ThroughputStart(time.Now(), hours, loc)
+
+	var jobs []models.CompletedJobTiming
+	err := d.db.Model(&models.Job{}).
+		Select("started_at, completed_at, successful").
+		Where("status = ? AND test_mode = ? AND completed_at >= ?", models.JobStatusCompleted, false, start).
+		Scan(&jobs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return models.BuildHourlySeries(start, hours, jobs, loc), nil
+}
+
+// GetDurationStatsByPriority returns run-time statistics per priority for
+// jobs completed between from and to, excluding test-mode dry runs.
+// Percentiles are computed in Go to stay portable across SQL drivers.
+func (d *Database) GetDurationStatsByPriority(from, to time.Time) ([]models.DurationStats, error) {
+	var samples []models.JobDurationSample
+	err := d.db.Model(&models.Job{}).
+		Select("priority, started_at, completed_at").
+		Where("status = ? AND test_mode = ?", models.JobStatusCompleted, false).
+		Where("started_at IS NOT NULL AND completed_at >= ? AND completed_at < ?", from, to).
+		Scan(&samples).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return models.BuildDurationStats(samples), nil
+}
+
+// GetFailureBreakdown categorizes the error messages of non-test jobs that
+// failed within the last days calendar days in loc
+func (d *Database) GetFailureBreakdown(days int, loc *time.Location) (time.Time, []models.FailureCategoryStats, error) {
+	start := models.TimeseriesStart(time.Now(), days, loc)
+
+	var messages []string
+	err := d.db.Model(&models.Job{}).
+		Where("status = ? AND test_mode = ? AND completed_at >= ?", models.JobStatusFailed, false, start).
+		Pluck("COALESCE(error_msg, '')", &messages).Error
+	if err != nil {
+		return start, nil, err
+	}
+
+	return start, models.BuildFailureBreakdown(messages), nil
+}
+
+// GetJobOutcomeCounts returns the number of completed and failed jobs,
+// excluding test-mode dry runs, for success-rate calculations
+func (d *Database) GetJobOutcomeCounts() (completed, failed int64, err error) {
+	err = d.db.Model(&models.Job{}).
+		Where("status = ? AND test_mode = ?", models.JobStatusCompleted, false).
+		Count(&completed).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = d.db.Model(&models.Job{}).
+		Where("status = ? AND test_mode = ?", models.JobStatusFailed, false).
+		Count(&failed).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return completed, failed, nil
+}
+
+// HasInFlightJob checks if the account has a pending or running job of the given type
+func (d *Database) HasInFlightJob(accountID uint, jobType models.JobType) (bool, error) {
+	var count int64
+	err := d.db.Model(&models.Job{}).
+		Where("account_id = ? AND type = ?", accountID, jobType).
+		Where("status IN ?", []models.JobStatus{models.JobStatusPending, models.JobStatusRunning}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// CreateAccountJob creates a job acting on one account (verify),
+// returning ErrJobInFlight if the account already has a pending or running
+// job of the same type. The idx_jobs_account_in_flight index rejects the
+// insert of a duplicate, however many requests race to create one.
+func (d *Database) CreateAccountJob(job *models.Job) error {
+	err := d.db.Create(job).Error
+	if err == nil {
+		return nil
+	}
+
+	if inFlight, checkErr := d.HasInFlightJob(job.AccountID, job.Type); checkErr == nil && inFlight {
+		return ErrJobInFlight
+	}
+	return err
+}
+
+// ApplyVerificationResult writes a verification outcome back to the account:
+// the implied status (if any) and a note line, both recorded in the history
+func (d *Database) ApplyVerificationResult(jobID string, result *models.VerificationResult) error {
+	return d.WithTransaction(func(tx *gorm.DB) error {
+		var account models.Account
+		if err := tx.First(&account, result.AccountID).Error; err != nil {
+			return fmt.Errorf("failed to load account %d: %w", result.AccountID, err)
+		}
+
+		note := fmt.Sprintf("[%s] Verification (job %s): %s",
+			time.Now().UTC().Format(time.RFC3339), jobID, result.Outcome)
+		if result.Message != "" {
+			note += " - " + result.Message
+		}
+
+		updates := map[string]interface{}{
+			"notes": appendNote(account.Notes, note),
+		}
+
+		newStatus := result.AccountStatus()
+		if newStatus != "" && newStatus != account.Status {
+			updates["status"] = newStatus
+			if err := tx.Create(&models.AccountHistory{
+				AccountID: account.ID,
+				Action:    models.AccountActionStatusChanged,
+				OldValue:  account.Status,
+				NewValue:  newStatus,
+				Reason:    fmt.Sprintf("verification job %s: %s", jobID, result.Outcome),
+			}).Error; err != nil {
+				return fmt.Errorf("failed to record account history: %w", err)
+			}
+		}
+
+		return tx.Model(&account).Updates(updates).Error
+	})
+}
+
+// appendNote appends a line to existing account notes
+func appendNote(notes, line string) string {
+	if notes == "" {
+		return line
+	}
+	return notes + "\n" + line
+}
+
 // GetPendingJobs retrieves all pending jobs
 func (d *Database) GetPendingJobs() ([]models.Job, error) {
 	var jobs []models.Job
@@ -282,13 +1207,24 @@ func (d *Database) CreateAccountsBatch(accounts []*models.Account) error {
 	})
 }
 
-// GetAccountsByJobID retrieves all accounts associated with a job
-func (d *Database) GetAccountsByJobID(jobID string) ([]models.Account, error) {
+// GetAccountsByJobID retrieves accounts associated with a job with pagination
+func (d *Database) GetAccountsByJobID(jobID string, limit, offset int) ([]models.Account, error) {
 	var accounts []models.Account
-	err := d.db.Where("job_id = ?", jobID).Order("created_at ASC").Find(&accounts).Error
+	err := d.db.Where("job_id = ?", jobID).
+		Limit(limit).
+		Offset(offset).
+		Order("created_at ASC, id ASC").
+		Find(&accounts).Error
 	return accounts, err
 }
 
+// CountAccountsByJobID returns the number of accounts associated with a job
+func (d *Database) CountAccountsByJobID(jobID string) (int64, error) {
+	var count int64
+	err := d.db.Model(&models.Account{}).Where("job_id = ?", jobID).Count(&count).Error
+	return count, err
+}
+
 // GetAccountsByStatus retrieves accounts filtered by status with pagination
 func (d *Database) GetAccountsByStatus(status string, limit, offset int) ([]models.Account, error) {
 	var accounts []models.Account
@@ -319,16 +1255,186 @@ func (d *Database) UpdateAccountStatus(id uint, status string) error {
 	return d.db.Model(&models.Account{}).Where("id = ?", id).Update("status", status).Error
 }
 
-// BulkUpdateAccountStatus updates status for multiple accounts in a transaction
-func (d *Database) BulkUpdateAccountStatus(ids []uint, status string) error {
-	return d.WithTransaction(func(tx *gorm.DB) error {
+// TransitionAccountStatus moves an account to status if its current status
+// is one of allowedFrom, recording the change in the account history in the
+// same transaction. It returns the status the account had: the one replaced
+// on success, the one that blocked the change with ErrStatusTransition. A
+// missing or deleted account gives ErrAccountNotFound.
+func (d *Database) TransitionAccountStatus(id uint, status string, allowedFrom []string, reason, requestID string) (string, error) {
+	var oldStatus string
+	err := d.WithTransaction(func(tx *gorm.DB) error {
+		// Each update carries its from-status in the condition, so the check
+		// and the write are one statement and concurrent transitions cannot
+		// both pass it. Writing before reading also takes SQLite's write
+		// lock up front rather than upgrading a read lock.
+		for _, from := range allowedFrom {
+			result := tx.Model(&models.Account{}).
+				Where("id = ? AND status = ?", id, from).
+				Update("status", status)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				oldStatus = from
+				break
+			}
+		}
+
+		if oldStatus == "" {
+			var account models.Account
+			err := tx.Select("id", "status").First(&account, id).Error
+			if err == gorm.ErrRecordNotFound {
+				return ErrAccountNotFound
+			}
+			if err != nil {
+				return err
+			}
+			oldStatus = account.Status
+			return ErrStatusTransition
+		}
+
+		if err := tx.Create(&models.AccountHistory{
+			AccountID: id,
+			Action:    models.AccountActionStatusChanged,
+			OldValue:  oldStatus,
+			NewValue:  status,
+			Reason:    reason,
+			RequestID: requestID,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to record account history: %w", err)
+		}
+		return nil
+	})
+	return oldStatus, err
+}
+
+// BulkUpdateAccountStatus updates status for multiple accounts in a transaction,
+// recording an audit history entry per changed account. It returns the number
+// of updated rows, the previous status of each updated account, and the IDs
+// that do not exist.
+func (d *Database) BulkUpdateAccountStatus(ids []uint, status, reason, requestID string) (int64, map[uint]string, []uint, error) {
+	var affected int64
+	var missing []uint
+	found := make(map[uint]string, len(ids))
+
+	err := d.WithTransaction(func(tx *gorm.DB) error {
+		var existing []models.Account
+		if err := tx.Select("id", "status").Where("id IN ?", ids).Find(&existing).Error; err != nil {
+			return err
+		}
+
+		for _, account := range existing {
+			found[account.ID] = account.Status
+		}
+		for _, id := range ids {
+			if _, ok := found[id]; !ok {
+				missing = append(missing, id)
+			}
+		}
+
+		if len(existing) == 0 {
+			return nil
+		}
+
 		result := tx.Model(&models.Account{}).Where("id IN ?", ids).Update("status", status)
 		if result.Error != nil {
 			return result.Error
 		}
-		log.Printf("Updated status to '%s' for %d accounts", status, result.RowsAffected)
+		affected = result.RowsAffected
+
+		history := make([]models.AccountHistory, 0, len(found))
+		for id, oldStatus := range found {
+			history = append(history, models.AccountHistory{
+				AccountID: id,
+				Action:    models.AccountActionStatusChanged,
+				OldValue:  oldStatus,
+				NewValue:  status,
+				Reason:    reason,
+				RequestID: requestID,
+			})
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return fmt.Errorf("failed to record account history: %w", err)
+		}
+
+		log.Printf("Updated status to '%s' for %d accounts", status, affected)
 		return nil
 	})
+
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return affected, found, missing, nil
+}
+
+// BulkDeleteAccounts soft deletes multiple accounts in a transaction and
+// reports which IDs were deleted, already deleted, or do not exist
+func (d *Database) BulkDeleteAccounts(ids []uint) (*models.BulkDeleteResult, error) {
+	result := &models.BulkDeleteResult{
+		Deleted:        []uint{},
+		AlreadyDeleted: []uint{},
+		NotFound:       []uint{},
+	}
+
+	err := d.WithTransaction(func(tx *gorm.DB) error {
+		var existing []models.Account
+		if err := tx.Unscoped().Select("id", "deleted_at").Where("id IN ?", ids).Find(&existing).Error; err != nil {
+			return err
+		}
+
+		deleted := make(map[uint]bool, len(existing))
+		for _, account := range existing {
+			deleted[account.ID] = account.DeletedAt.Valid
+		}
+
+		for _, id := range ids {
+			alreadyDeleted, ok := deleted[id]
+			switch {
+			case !ok:
+				result.NotFound = append(result.NotFound, id)
+			case alreadyDeleted:
+				result.AlreadyDeleted = append(result.AlreadyDeleted, id)
+			default:
+				result.Deleted = append(result.Deleted, id)
+			}
+		}
+
+		if len(result.Deleted) == 0 {
+			return nil
+		}
+
+		if err := tx.Where("id IN ?", result.Deleted).Delete(&models.Account{}).Error; err != nil {
+			return err
+		}
+
+		log.Printf("Soft deleted %d accounts", len(result.Deleted))
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetAccountIDsByJobID returns the IDs of every account a job produced,
+// including soft-deleted ones
+func (d *Database) GetAccountIDsByJobID(jobID string) ([]uint, error) {
+	var ids []uint
+	err := d.db.Unscoped().Model(&models.Account{}).Where("job_id = ?", jobID).Order("id ASC").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// RecordAccountHistory appends an entry to an account's audit history
+func (d *Database) RecordAccountHistory(entry *models.AccountHistory) error {
+	return d.db.Create(entry).Error
+}
+
+// GetAccountHistory retrieves the audit history of an account, newest first
+func (d *Database) GetAccountHistory(accountID uint) ([]models.AccountHistory, error) {
+	var history []models.AccountHistory
+	err := d.db.Where("account_id = ?", accountID).Order("created_at DESC, id DESC").Find(&history).Error
+	return history, err
 }
 
 // GetJobsByStatus retrieves jobs filtered by status with pagination
@@ -394,34 +1500,238 @@ func (d *Database) GetSettings() (*models.Setting, error) {
 		return nil, fmt.Errorf("failed to get settings: %w", err)
 	}
 
+	if err := d.decryptSecrets(&setting); err != nil {
+		return nil, fmt.Errorf("failed to decrypt settings: %w", err)
+	}
+
 	return &setting, nil
 }
 
-// SaveSettings updates the application settings in the database
-func (d *Database) SaveSettings(setting *models.Setting) error {
-	// Check if settings exist
-	var existingSetting models.Setting
-	err := d.db.First(&existingSetting).Error
+// SaveSettings updates the application settings in the database and
+// records a revision attributed to changedBy. The recorded revision is
+// returned so callers can announce which fields changed.
+func (d *Database) SaveSettings(setting *models.Setting, changedBy string) (*models.SettingRevision, error) {
+	// Secrets are encrypted in a copy so the caller keeps plaintext values
+	stored := *setting
+	if err := d.encryptSecrets(&stored); err != nil {
+		return nil, fmt.Errorf("failed to encrypt settings: %w", err)
+	}
+
+	var revision *models.SettingRevision
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		// Check if settings exist
+		var existingSetting models.Setting
+		err := tx.First(&existingSetting).Error
 
-	if err == gorm.ErrRecordNotFound {
-		// No settings exist, create new
-		if err := d.db.Create(setting).Error; err != nil {
-			return fmt.Errorf("failed to create settings: %w", err)
+		if err == gorm.ErrRecordNotFound {
+			// No settings exist, create new
+			if err := tx.Create(&stored).Error; err != nil {
+				return fmt.Errorf("failed to create settings: %w", err)
+			}
+		} else if err != nil {
+			return fmt.Errorf("failed to check existing settings: %w", err)
+		} else {
+			// Update existing settings
+			stored.ID = existingSetting.ID // Preserve the ID
+			stored.CreatedAt = existingSetting.CreatedAt
+			if err := tx.Save(&stored).Error; err != nil {
+				return fmt.Errorf("failed to update settings: %w", err)
+			}
 		}
-		log.Println("Settings created successfully")
-		return nil
+
+		// Diff against the previous values; if they cannot be decrypted the
+		// stored forms are compared, which still flags changed secrets
+		d.decryptSecrets(&existingSetting)
+		changes, summary := models.DiffSnapshots(models.SnapshotOf(&existingSetting), models.SnapshotOf(setting))
+
+		revision = &models.SettingRevision{
+			ChangedBy: changedBy,
+			Changes:   changes,
+			Summary:   summary,
+			Snapshot:  models.SnapshotOf(&stored),
+		}
+		if err := tx.Create(revision).Error; err != nil {
+			return fmt.Errorf("failed to record settings revision: %w", err)
+		}
+
+		// Keep only the newest revisions
+		var keep []uint
+		if err := tx.Model(&models.SettingRevision{}).
+			Order("id DESC").Limit(models.MaxSettingRevisions).
+			Pluck("id", &keep).Error; err != nil {
+			return fmt.Errorf("failed to trim settings revisions: %w", err)
+		}
+		return tx.Where("id NOT IN ?", keep).Delete(&models.SettingRevision{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	setting.ID = stored.ID
+	setting.CreatedAt = stored.CreatedAt
+	setting.UpdatedAt = stored.UpdatedAt
+
+	log.Println("Settings saved successfully")
+	return revision, nil
+}
+
+// ListSettingRevisions returns the newest settings revisions first, with
+// snapshot secrets decrypted
+func (d *Database) ListSettingRevisions(limit int) ([]models.SettingRevision, error) {
+	var revisions []models.SettingRevision
+	if err := d.db.Order("id DESC").Limit(limit).Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range revisions {
+		if err := d.decryptSnapshot(&revisions[i].Snapshot); err != nil {
+			return nil, fmt.Errorf("failed to decrypt settings revision %d: %w", revisions[i].ID, err)
+		}
+	}
+	return revisions, nil
+}
+
+// GetSettingRevision returns a settings revision with its snapshot secrets
+// decrypted
+func (d *Database) GetSettingRevision(id uint) (*models.SettingRevision, error) {
+	var revision models.SettingRevision
+	if err := d.db.First(&revision, id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := d.decryptSnapshot(&revision.Snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decrypt settings revision %d: %w", id, err)
+	}
+	return &revision, nil
+}
+
+// decryptSnapshot decrypts a revision snapshot's secrets in place
+func (d *Database) decryptSnapshot(snapshot *models.SettingSnapshot) error {
+	return d.decryptValues(snapshot.Secrets())
+}
+
+// CreateWebhook creates a new webhook
+func (d *Database) CreateWebhook(webhook *models.Webhook) error {
+	return d.db.Create(webhook).Error
+}
+
+// GetWebhook retrieves a webhook by ID
+func (d *Database) GetWebhook(id uint) (*models.Webhook, error) {
+	var webhook models.Webhook
+	if err := d.db.First(&webhook, id).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// ListWebhooks retrieves all webhooks
+func (d *Database) ListWebhooks() ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := d.db.Order("id ASC").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// ListEnabledWebhooks retrieves webhooks that should receive events
+func (d *Database) ListEnabledWebhooks() ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := d.db.Where("enabled = ?", true).Find(&webhooks).Error
+	return webhooks, err
+}
+
+// UpdateWebhook updates a webhook
+func (d *Database) UpdateWebhook(webhook *models.Webhook) error {
+	return d.db.Save(webhook).Error
+}
+
+// DeleteWebhook soft deletes a webhook
+func (d *Database) DeleteWebhook(id uint) error {
+	return d.db.Delete(&models.Webhook{}, id).Error
+}
+
+// CreateWebhookDelivery records a webhook delivery attempt
+func (d *Database) CreateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	return d.db.Create(delivery).Error
+}
+
+// ListWebhookDeliveries retrieves a webhook's delivery attempts, newest first
+func (d *Database) ListWebhookDeliveries(webhookID uint, limit, offset int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := d.db.Where("webhook_id = ?", webhookID).
+		Limit(limit).
+		Offset(offset).
+		Order("created_at DESC, id DESC").
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// AppendJobLog records a log line for a job and trims the job's log to the
+// most recent MaxJobLogLines lines
+func (d *Database) AppendJobLog(jobID, level, message string) (*models.JobLog, error) {
+	entry := &models.JobLog{
+		JobID:   jobID,
+		Level:   level,
+		Message: message,
+	}
+	if err := d.db.Create(entry).Error; err != nil {
+		return nil, err
 	}
 
+	// Drop everything older than the newest MaxJobLogLines lines
+	keep := d.db.Model(&models.JobLog{}).
+		Select("id").
+		Where("job_id = ?", jobID).
+		Order("id DESC").
+		Limit(models.MaxJobLogLines)
+	if err := d.db.Where("job_id = ? AND id NOT IN (?)", jobID, keep).
+		Delete(&models.JobLog{}).Error; err != nil {
+		return entry, err
+	}
+
+	return entry, nil
+}
+
+// ListJobLogs retrieves a job's most recent log lines in chronological order,
+// optionally restricted to levels at or above minLevel
+func (d *Database) ListJobLogs(jobID, minLevel string, limit int) ([]models.JobLog, error) {
+	query := d.db.Where("job_id = ?", jobID)
+	if minLevel != "" {
+		query = query.Where("level IN ?", models.JobLogLevelsAtLeast(minLevel))
+	}
+
+	var logs []models.JobLog
+	if err := query.Order("id DESC").Limit(limit).Find(&logs).Error; err != nil {
+		return nil, err
+	}
+
+	// Newest lines were selected first; return them oldest first
+	for i, j := 0, len(logs)-1; i < j; i, j = i+1, j-1 {
+		logs[i], logs[j] = logs[j], logs[i]
+	}
+	return logs, nil
+}
+
+// RecordJobEvent appends an event to a job's timeline. An event repeating the
+// status of the job's previous event is skipped; the return value reports
+// whether the event was stored.
+func (d *Database) RecordJobEvent(event *models.JobEvent) (bool, error) {
+	var last models.JobEvent
+	err := d.db.Where("job_id = ?", event.JobID).Order("id DESC").Limit(1).Find(&last).Error
 	if err != nil {
-		return fmt.Errorf("failed to check existing settings: %w", err)
+		return false, err
+	}
+	if last.ID != 0 && last.Status == event.Status {
+		return false, nil
 	}
 
-	// Update existing settings
-	setting.ID = existingSetting.ID // Preserve the ID
-	if err := d.db.Save(setting).Error; err != nil {
-		return fmt.Errorf("failed to update settings: %w", err)
+	if err := d.db.Create(event).Error; err != nil {
+		return false, err
 	}
+	return true, nil
+}
 
-	log.Println("Settings updated successfully")
-	return nil
+// ListJobEvents retrieves a job's timeline in chronological order
+func (d *Database) ListJobEvents(jobID string) ([]models.JobEvent, error) {
+	var events []models.JobEvent
+	err := d.db.Where("job_id = ?", jobID).Order("id ASC").Find(&events).Error
+	return events, err
 }
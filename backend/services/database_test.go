@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"botrix-backend/models"
+
+	"github.com/google/uuid"
+)
+
+func TestCreateAccountJobRejectsInFlightDuplicates(t *testing.T) {
+	db := newTestDatabase(t, newTestConfig(t))
+
+	newJob := func(accountID uint, jobType models.JobType) *models.Job {
+		return &models.Job{
+			ID:        uuid.New().String(),
+			Type:      jobType,
+			Count:     1,
+			AccountID: accountID,
+			Status:    models.JobStatusPending,
+		}
+	}
+
+	first := newJob(1, models.JobTypeVerify)
+	if err := db.CreateAccountJob(first); err != nil {
+		t.Fatalf("first verify job: %v", err)
+	}
+	if err := db.CreateAccountJob(newJob(1, models.JobTypeVerify)); !errors.Is(err, ErrJobInFlight) {
+		t.Errorf("second verify job: err = %v, want ErrJobInFlight", err)
+	}
+
+	// Other accounts are independent
+	if err := db.CreateAccountJob(newJob(2, models.JobTypeVerify)); err != nil {
+		t.Errorf("verify job for another account: %v", err)
+	}
+
+	// The index holds even for inserts that skip the check
+	if err := db.CreateJob(newJob(1, models.JobTypeVerify)); err == nil {
+		t.Error("unchecked insert of a duplicate in-flight job succeeded")
+	}
+
+	// Generation jobs carry no account and are never constrained
+	for i := 0; i < 2; i++ {
+		if err := db.CreateJob(newJob(0, models.JobTypeGenerate)); err != nil {
+			t.Fatalf("generate job %d: %v", i, err)
+		}
+	}
+
+	// A finished job no longer blocks a new one
+	first.Status = models.JobStatusCompleted
+	if err := db.UpdateJob(first); err != nil {
+		t.Fatalf("completing job: %v", err)
+	}
+	if err := db.CreateAccountJob(newJob(1, models.JobTypeVerify)); err != nil {
+		t.Errorf("verify job after completion: %v", err)
+	}
+}
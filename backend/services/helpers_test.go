@@ -0,0 +1,101 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"botrix-backend/config"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestConfig returns a configuration with a fresh SQLite file and no
+// Redis address; newTestQueue fills that in
+func newTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+	return &config.Config{
+		Server:    config.ServerConfig{Environment: "test"},
+		Database:  config.DatabaseConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "botrix.db")},
+		Reporting: config.ReportingConfig{Timezone: "UTC", Location: time.UTC},
+	}
+}
+
+// newTestDatabase opens the configured database, closing it when the test ends
+func newTestDatabase(t *testing.T, cfg *config.Config) *Database {
+	t.Helper()
+	db, err := NewDatabase(cfg)
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// newTestQueue starts an in-memory Redis and connects a queue to it
+func newTestQueue(t *testing.T, cfg *config.Config) (*QueueService, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	cfg.Redis.Host = server.Host()
+	cfg.Redis.Port = server.Port()
+
+	queue, err := NewQueueService(cfg)
+	if err != nil {
+		t.Fatalf("NewQueueService: %v", err)
+	}
+	t.Cleanup(func() { queue.Close() })
+	return queue, server
+}
+
+// newTestTLSConfigs returns a server TLS configuration with a self-signed
+// certificate for 127.0.0.1 and a client configuration trusting it
+func newTestTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "botrix test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing certificate: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client = &tls.Config{RootCAs: roots, ServerName: "127.0.0.1", MinVersion: tls.VersionTLS12}
+	return server, client
+}
+
+// listenTest listens on a free local port, closing the listener when the
+// test ends, and returns it with the port
+func listenTest(t *testing.T) (net.Listener, int) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln, ln.Addr().(*net.TCPAddr).Port
+}
@@ -0,0 +1,647 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"botrix-backend/config"
+)
+
+// AllJobs is the subscription wildcard: a client subscribed to it receives
+// updates for every job
+const AllJobs = "*"
+
+// Slow-consumer limits used when none are configured: a client is evicted
+// once more than DefaultSlowClientMaxDrops messages are dropped for it
+// within DefaultSlowClientWindow
+const (
+	DefaultSlowClientMaxDrops = 100
+	DefaultSlowClientWindow   = 10 * time.Second
+)
+
+const (
+	// hubPingInterval is how often the hub pings every client
+	hubPingInterval = 30 * time.Second
+
+	// hubInactiveTimeout is how long a client may stay silent before the
+	// hub drops it
+	hubInactiveTimeout = 2 * time.Minute
+)
+
+var (
+	// ErrClientNotFound is returned when sending to a client that is not
+	// connected
+	ErrClientNotFound = errors.New("websocket client not found")
+
+	// ErrMessageDropped is returned when a client's send buffer is full
+	ErrMessageDropped = errors.New("websocket client send buffer full, message dropped")
+
+	// ErrDuplicateClient is returned by Register for an ID already in use
+	ErrDuplicateClient = errors.New("websocket client ID already registered")
+)
+
+// HubMessage is a message for WebSocket clients. Broadcast events carry the
+// time they were received in unix milliseconds and a sequence number;
+// events sent again from the replay buffer are marked Replayed.
+type HubMessage struct {
+	Type      string                 `json:"type"`
+	JobID     string                 `json:"job_id,omitempty"`
+	Status    string                 `json:"status,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Timestamp int64                  `json:"timestamp,omitempty"`
+	Replayed  bool                   `json:"replayed,omitempty"`
+	Seq       uint64                 `json:"seq,omitempty"` // set on broadcast events, increasing by one per event
+}
+
+// MessageEvent names a message's event type for filtering: the published
+// "event" field if set, otherwise "job_<status>" for the bare status
+// updates workers publish (job_completed, job_failed, ...)
+func MessageEvent(message HubMessage) string {
+	if event := stringField(message.Data, "event"); event != "" {
+		return event
+	}
+	if message.Status != "" {
+		return "job_" + message.Status
+	}
+	return message.Type
+}
+
+// DroppedNotice tells a client how many messages it missed while its send
+// buffer was full. It is sent once the buffer drains:
+//
+//	{"type": "messages_dropped", "count": 12, "timestamp": 1699369800123}
+type DroppedNotice struct {
+	Type      string `json:"type"`
+	Count     uint64 `json:"count"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Conn is the transport side of a hub client
+type Conn interface {
+	// Ping checks the connection is alive; an error drops the client
+	Ping() error
+}
+
+// HubClient is a client registered with the hub: its subscriptions, event
+// filter, encoding and send buffer. The transport drains SendChan to the
+// connection; the hub closes it when the client is removed.
+type HubClient struct {
+	ID          string
+	Conn        Conn
+	SendChan    chan Frame
+	RemoteAddr  string
+	ConnectedAt time.Time
+
+	// Activity and delivery counters, updated atomically by the transport
+	// and the broadcast path and read by stats
+	lastActive      atomic.Int64 // unix nanoseconds
+	messagesSent    atomic.Uint64
+	messagesDropped atomic.Uint64
+	drops           dropTracker
+
+	// subscriptions holds the job IDs the client receives updates for and
+	// eventFilter the event types; an empty filter accepts every event
+	subscriptions map[string]bool
+	eventFilter   map[string]bool
+	subsMutex     sync.RWMutex
+
+	// encoding is EncodingJSON or EncodingMsgpack, guarded by subsMutex
+	encoding string
+}
+
+// NewHubClient creates a client with a 256-message send buffer, receiving
+// JSON
+func NewHubClient(id string, conn Conn, remoteAddr string) *HubClient {
+	client := &HubClient{
+		ID:            id,
+		Conn:          conn,
+		SendChan:      make(chan Frame, 256),
+		RemoteAddr:    remoteAddr,
+		ConnectedAt:   time.Now(),
+		subscriptions: make(map[string]bool),
+		encoding:      EncodingJSON,
+	}
+	client.Touch()
+	return client
+}
+
+// Touch records activity from the client
+func (c *HubClient) Touch() {
+	c.lastActive.Store(time.Now().UnixNano())
+}
+
+// LastActive returns when the client was last heard from
+func (c *HubClient) LastActive() time.Time {
+	return time.Unix(0, c.lastActive.Load())
+}
+
+// MarkSent counts a message written to the connection
+func (c *HubClient) MarkSent() {
+	c.messagesSent.Add(1)
+}
+
+// MessagesSent returns the messages written to the connection
+func (c *HubClient) MessagesSent() uint64 {
+	return c.messagesSent.Load()
+}
+
+// MessagesDropped returns the messages the full send buffer could not take
+func (c *HubClient) MessagesDropped() uint64 {
+	return c.messagesDropped.Load()
+}
+
+// Pending returns the messages waiting in the send buffer
+func (c *HubClient) Pending() int {
+	return len(c.SendChan)
+}
+
+// TakeDroppedNotice returns the notice for messages the client missed since
+// the last one, if any
+func (c *HubClient) TakeDroppedNotice() (DroppedNotice, bool) {
+	n := c.drops.takeUnreported()
+	if n == 0 {
+		return DroppedNotice{}, false
+	}
+	return DroppedNotice{
+		Type:      "messages_dropped",
+		Count:     n,
+		Timestamp: time.Now().UnixMilli(),
+	}, true
+}
+
+// Subscribe adds a job ID, or AllJobs, to the client's subscriptions
+func (c *HubClient) Subscribe(jobID string) {
+	c.subsMutex.Lock()
+	defer c.subsMutex.Unlock()
+	c.subscriptions[jobID] = true
+}
+
+// Unsubscribe removes a job ID, or AllJobs, from the client's subscriptions
+func (c *HubClient) Unsubscribe(jobID string) {
+	c.subsMutex.Lock()
+	defer c.subsMutex.Unlock()
+	delete(c.subscriptions, jobID)
+}
+
+// Subscriptions returns the number of job subscriptions
+func (c *HubClient) Subscriptions() int {
+	c.subsMutex.RLock()
+	defer c.subsMutex.RUnlock()
+	return len(c.subscriptions)
+}
+
+// IsSubscribed reports whether the client receives updates for a job.
+// Updates not tied to a job (empty jobID) go to every client.
+func (c *HubClient) IsSubscribed(jobID string) bool {
+	if jobID == "" {
+		return true
+	}
+	c.subsMutex.RLock()
+	defer c.subsMutex.RUnlock()
+	return c.subscriptions[AllJobs] || c.subscriptions[jobID]
+}
+
+// SetFilter replaces the event types the client receives; no events means
+// every event
+func (c *HubClient) SetFilter(events []string) {
+	filter := make(map[string]bool, len(events))
+	for _, event := range events {
+		filter[event] = true
+	}
+	c.subsMutex.Lock()
+	defer c.subsMutex.Unlock()
+	c.eventFilter = filter
+}
+
+// Filter returns the event types the client receives, sorted; empty means
+// every event
+func (c *HubClient) Filter() []string {
+	c.subsMutex.RLock()
+	defer c.subsMutex.RUnlock()
+	events := make([]string, 0, len(c.eventFilter))
+	for event := range c.eventFilter {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+	return events
+}
+
+// Encoding returns the encoding of the messages sent to the client
+func (c *HubClient) Encoding() string {
+	c.subsMutex.RLock()
+	defer c.subsMutex.RUnlock()
+	return c.encoding
+}
+
+// SetEncoding changes the encoding of the messages sent to the client;
+// messages already queued keep theirs
+func (c *HubClient) SetEncoding(encoding string) {
+	c.subsMutex.Lock()
+	defer c.subsMutex.Unlock()
+	c.encoding = encoding
+}
+
+// Wants reports whether the client receives a message, which must match
+// both its job subscriptions and its event filter
+func (c *HubClient) Wants(jobID, event string) bool {
+	if !c.IsSubscribed(jobID) {
+		return false
+	}
+	c.subsMutex.RLock()
+	defer c.subsMutex.RUnlock()
+	return len(c.eventFilter) == 0 || c.eventFilter[event]
+}
+
+// dropTracker counts a client's dropped messages in fixed windows, for
+// eviction, and since the last DroppedNotice, for the client
+type dropTracker struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	inWindow    int
+	unreported  uint64
+}
+
+// record counts a drop and reports whether the client has now dropped more
+// than maxDrops messages within window; maxDrops 0 never evicts
+func (d *dropTracker) record(now time.Time, window time.Duration, maxDrops int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if now.Sub(d.windowStart) >= window {
+		d.windowStart = now
+		d.inWindow = 0
+	}
+	d.inWindow++
+	d.unreported++
+	return maxDrops > 0 && d.inWindow > maxDrops
+}
+
+// takeUnreported returns the drops since the last call and resets them
+func (d *dropTracker) takeUnreported() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := d.unreported
+	d.unreported = 0
+	return n
+}
+
+// outboundMessage is a serialized message with the job it concerns, if
+// any, and its event type. The MsgPack form is encoded on first use, so it
+// costs nothing while every client uses JSON.
+type outboundMessage struct {
+	jobID   string
+	event   string
+	message HubMessage
+	payload []byte
+	packed  []byte
+}
+
+// frameFor returns the message as a frame in the given encoding
+func (m *outboundMessage) frameFor(encoding string) (Frame, error) {
+	if encoding != EncodingMsgpack {
+		return Frame{Data: m.payload}, nil
+	}
+	if m.packed == nil {
+		packed, err := appendMsgpack(nil, m.message)
+		if err != nil {
+			return Frame{}, err
+		}
+		m.packed = packed
+	}
+	return Frame{Binary: true, Data: m.packed}, nil
+}
+
+// Hub fans messages out to connected clients. It owns the client registry,
+// the broadcast loop, the replay buffer and liveness pings; a transport
+// such as the WebSocket handler registers clients and writes what arrives
+// on their SendChan. Anything holding the hub can reach clients directly,
+// without going through Redis.
+type Hub struct {
+	clients      map[string]*HubClient
+	clientsMutex sync.RWMutex
+	broadcast    chan outboundMessage
+
+	// replay keeps recent events for clients that reconnect
+	replay *ReplayBuffer
+
+	// A client is evicted once more than slowClientMaxDrops messages are
+	// dropped for it within slowClientWindow; 0 never evicts
+	slowClientMaxDrops int
+	slowClientWindow   time.Duration
+	slowClientsEvicted atomic.Uint64
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+}
+
+// NewHub creates a hub sized for replay and slow-client eviction by cfg and
+// starts its broadcast loop and ping ticker
+func NewHub(cfg config.WebSocketConfig) *Hub {
+	ctx, cancel := context.WithCancel(context.Background())
+	hub := &Hub{
+		clients:   make(map[string]*HubClient),
+		broadcast: make(chan outboundMessage, 256),
+		replay:    NewReplayBuffer(cfg.ReplayBufferSize),
+
+		slowClientMaxDrops: cfg.SlowClientMaxDrops,
+		slowClientWindow:   cfg.SlowClientWindow,
+
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	hub.workers.Add(2)
+	go hub.run()
+	go hub.pingClients()
+
+	return hub
+}
+
+// Stop ends the broadcast loop and ping ticker, waiting for them until
+// ctx is done. Clients still registered are left to their transport.
+func (h *Hub) Stop(ctx context.Context) error {
+	h.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		h.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Register adds a client. A client whose ID is taken is refused with
+// ErrDuplicateClient rather than replacing the registered one.
+func (h *Hub) Register(client *HubClient) error {
+	h.clientsMutex.Lock()
+	defer h.clientsMutex.Unlock()
+
+	if h.clients[client.ID] != nil {
+		return ErrDuplicateClient
+	}
+	h.clients[client.ID] = client
+	return nil
+}
+
+// Unregister removes a client and closes its SendChan, reporting whether
+// it was still registered
+func (h *Hub) Unregister(client *HubClient) bool {
+	h.clientsMutex.Lock()
+	defer h.clientsMutex.Unlock()
+	return h.removeClientLocked(client)
+}
+
+// removeClientLocked removes a client and closes its SendChan, reporting
+// whether it was still registered. Every removal goes through here with
+// clientsMutex write-locked, so each SendChan is closed exactly once and
+// never while another goroutine is sending to it.
+func (h *Hub) removeClientLocked(client *HubClient) bool {
+	if h.clients[client.ID] != client {
+		return false
+	}
+	delete(h.clients, client.ID)
+	close(client.SendChan)
+	return true
+}
+
+// Has reports whether a client ID is registered
+func (h *Hub) Has(clientID string) bool {
+	h.clientsMutex.RLock()
+	defer h.clientsMutex.RUnlock()
+	return h.clients[clientID] != nil
+}
+
+// Clients copies the client list, holding the read lock only for the copy
+// so stats requests never hold up the broadcast path
+func (h *Hub) Clients() []*HubClient {
+	h.clientsMutex.RLock()
+	defer h.clientsMutex.RUnlock()
+
+	clients := make([]*HubClient, 0, len(h.clients))
+	for _, client := range h.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// ClientCount returns the number of registered clients
+func (h *Hub) ClientCount() int {
+	h.clientsMutex.RLock()
+	defer h.clientsMutex.RUnlock()
+	return len(h.clients)
+}
+
+// Replay returns the buffer of recent broadcast events
+func (h *Hub) Replay() *ReplayBuffer {
+	return h.replay
+}
+
+// Broadcast numbers a message, records it for replay and queues it for the
+// clients whose subscriptions and event filter accept it. It returns false
+// once the hub is stopped.
+func (h *Hub) Broadcast(message HubMessage) bool {
+	message = h.replay.Add(message)
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("[Hub] ERROR: Failed to marshal %s message: %v", message.Type, err)
+		return true
+	}
+
+	select {
+	case h.broadcast <- outboundMessage{jobID: message.JobID, event: MessageEvent(message), message: message, payload: payload}:
+		return true
+	case <-h.ctx.Done():
+		return false
+	}
+}
+
+// Send queues a message, usually a HubMessage, for one registered client.
+// It never blocks: a client whose send buffer is full gets
+// ErrMessageDropped, and one that has been removed ErrClientNotFound.
+func (h *Hub) Send(client *HubClient, message interface{}) error {
+	f, err := EncodeFrame(client.Encoding(), message)
+	if err != nil {
+		return err
+	}
+
+	// SendChan is only closed under the write lock, after the client is
+	// removed, so a registered client's channel is open while we hold this
+	h.clientsMutex.RLock()
+	if h.clients[client.ID] != client {
+		h.clientsMutex.RUnlock()
+		return ErrClientNotFound
+	}
+	delivered, evict := h.deliver(client, f)
+	h.clientsMutex.RUnlock()
+
+	if evict {
+		h.evictSlowClients([]*HubClient{client})
+	}
+	if !delivered {
+		return ErrMessageDropped
+	}
+	return nil
+}
+
+// SendTo queues a message for the client with the given ID, bypassing its
+// subscriptions and filter. A zero Timestamp is set to the current time.
+func (h *Hub) SendTo(clientID string, message HubMessage) error {
+	if message.Timestamp == 0 {
+		message.Timestamp = time.Now().UnixMilli()
+	}
+
+	h.clientsMutex.RLock()
+	client := h.clients[clientID]
+	h.clientsMutex.RUnlock()
+	if client == nil {
+		return ErrClientNotFound
+	}
+	return h.Send(client, message)
+}
+
+// SendToAll queues a message for every client, bypassing subscriptions and
+// filters; it is not replayed. It reports how many clients the message was
+// queued for and how many dropped it.
+func (h *Hub) SendToAll(message HubMessage) (sent, dropped int) {
+	if message.Timestamp == 0 {
+		message.Timestamp = time.Now().UnixMilli()
+	}
+	for _, client := range h.Clients() {
+		switch err := h.Send(client, message); err {
+		case nil:
+			sent++
+		case ErrMessageDropped:
+			dropped++
+		}
+	}
+	return sent, dropped
+}
+
+// SlowClientStats reports the eviction policy and how many clients it
+// removed
+func (h *Hub) SlowClientStats() map[string]interface{} {
+	return map[string]interface{}{
+		"max_drops": h.slowClientMaxDrops,
+		"window":    h.slowClientWindow.String(),
+		"evicted":   h.slowClientsEvicted.Load(),
+	}
+}
+
+// run fans broadcast messages out to the clients that want them
+func (h *Hub) run() {
+	defer h.workers.Done()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+
+		case message := <-h.broadcast:
+			h.clientsMutex.RLock()
+			var slowClients []*HubClient
+			for _, client := range h.clients {
+				// Job updates only reach clients subscribed to the job
+				// whose filter accepts the event
+				if !client.Wants(message.jobID, message.event) {
+					continue
+				}
+				f, err := message.frameFor(client.Encoding())
+				if err != nil {
+					log.Printf("[Hub] ERROR: Failed to encode %s message: %v", message.message.Type, err)
+					continue
+				}
+				// A full buffer drops the message; only clients that keep
+				// dropping are evicted
+				if _, evict := h.deliver(client, f); evict {
+					slowClients = append(slowClients, client)
+				}
+			}
+			h.clientsMutex.RUnlock()
+
+			// Slow clients are evicted under the write lock, as other
+			// goroutines send to SendChan under the read lock
+			h.evictSlowClients(slowClients)
+		}
+	}
+}
+
+// deliver queues a frame for a registered client without blocking. When
+// the client's buffer is full the frame is dropped and counted; deliver
+// then reports whether the client has dropped so many that it should be
+// evicted. The caller holds clientsMutex for reading.
+func (h *Hub) deliver(client *HubClient, f Frame) (delivered, evict bool) {
+	select {
+	case client.SendChan <- f:
+		return true, false
+	default:
+	}
+
+	client.messagesDropped.Add(1)
+	return false, client.drops.record(time.Now(), h.slowClientWindow, h.slowClientMaxDrops)
+}
+
+// evictSlowClients removes clients that kept dropping messages. It takes
+// the write lock, so it must be called without clientsMutex held. Closing
+// SendChan makes the transport end the connection.
+func (h *Hub) evictSlowClients(clients []*HubClient) {
+	if len(clients) == 0 {
+		return
+	}
+
+	h.clientsMutex.Lock()
+	defer h.clientsMutex.Unlock()
+	for _, client := range clients {
+		if h.removeClientLocked(client) {
+			h.slowClientsEvicted.Add(1)
+			log.Printf("[Hub] WARNING: Client %s removed due to slow consumer (%d dropped)", client.ID, client.messagesDropped.Load())
+		}
+	}
+}
+
+// pingClients pings every client each hubPingInterval and drops those that
+// fail the ping or have been silent for hubInactiveTimeout
+func (h *Hub) pingClients() {
+	defer h.workers.Done()
+
+	ticker := time.NewTicker(hubPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var inactive []*HubClient
+		for _, client := range h.Clients() {
+			if time.Since(client.LastActive()) > hubInactiveTimeout {
+				inactive = append(inactive, client)
+				continue
+			}
+			if err := client.Conn.Ping(); err != nil {
+				inactive = append(inactive, client)
+			}
+		}
+
+		for _, client := range inactive {
+			if h.Unregister(client) {
+				log.Printf("[Hub] Client %s disconnected: inactive or failed ping", client.ID)
+			}
+		}
+	}
+}
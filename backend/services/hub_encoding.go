@@ -0,0 +1,210 @@
+package services
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+)
+
+// Encodings a hub client can receive messages in. JSON goes out in text
+// frames and MsgPack in binary frames.
+const (
+	EncodingJSON    = "json"
+	EncodingMsgpack = "msgpack"
+)
+
+// Frame is an encoded message queued for a client
+type Frame struct {
+	Binary bool
+	Data   []byte
+}
+
+// EncodeFrame encodes a message, usually a HubMessage, in the given
+// encoding
+func EncodeFrame(encoding string, message interface{}) (Frame, error) {
+	if encoding == EncodingMsgpack {
+		data, err := appendMsgpack(nil, message)
+		return Frame{Binary: true, Data: data}, err
+	}
+	data, err := json.Marshal(message)
+	return Frame{Data: data}, err
+}
+
+// appendMsgpack appends the MsgPack encoding of v to b. The message types
+// and the values JSON decoding produces are encoded directly; anything else
+// goes through a JSON round trip, so it encodes as its JSON form would.
+// Whole floats are encoded as integers, as JSON does not tell them apart.
+func appendMsgpack(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case string:
+		return appendMsgpackString(b, v), nil
+	case []byte:
+		return appendMsgpackBinary(b, v), nil
+	case int:
+		return appendMsgpackInt(b, int64(v)), nil
+	case int32:
+		return appendMsgpackInt(b, int64(v)), nil
+	case int64:
+		return appendMsgpackInt(b, v), nil
+	case uint:
+		return appendMsgpackUint(b, uint64(v)), nil
+	case uint32:
+		return appendMsgpackUint(b, uint64(v)), nil
+	case uint64:
+		return appendMsgpackUint(b, v), nil
+	case float32:
+		return appendMsgpackFloat(b, float64(v)), nil
+	case float64:
+		return appendMsgpackFloat(b, v), nil
+	case []string:
+		b = appendMsgpackHeader(b, len(v), 0x90, 0xdc, 0xdd, 16)
+		for _, s := range v {
+			b = appendMsgpackString(b, s)
+		}
+		return b, nil
+	case []interface{}:
+		b = appendMsgpackHeader(b, len(v), 0x90, 0xdc, 0xdd, 16)
+		for _, item := range v {
+			var err error
+			if b, err = appendMsgpack(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		return appendMsgpackMap(b, v)
+	case HubMessage:
+		return appendMsgpackMap(b, v.fields())
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return appendMsgpack(b, generic)
+}
+
+// fields returns the message as a map with the keys its JSON form has
+func (m HubMessage) fields() map[string]interface{} {
+	fields := map[string]interface{}{"type": m.Type}
+	if m.JobID != "" {
+		fields["job_id"] = m.JobID
+	}
+	if m.Status != "" {
+		fields["status"] = m.Status
+	}
+	if len(m.Data) > 0 {
+		fields["data"] = m.Data
+	}
+	if m.Timestamp != 0 {
+		fields["timestamp"] = m.Timestamp
+	}
+	if m.Replayed {
+		fields["replayed"] = true
+	}
+	if m.Seq != 0 {
+		fields["seq"] = m.Seq
+	}
+	return fields
+}
+
+func appendMsgpackMap(b []byte, m map[string]interface{}) ([]byte, error) {
+	b = appendMsgpackHeader(b, len(m), 0x80, 0xde, 0xdf, 16)
+	for key, value := range m {
+		b = appendMsgpackString(b, key)
+		var err error
+		if b, err = appendMsgpack(b, value); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendMsgpackHeader appends an array or map header: the fix form for
+// fewer than fixLimit entries, otherwise the 16- or 32-bit form
+func appendMsgpackHeader(b []byte, n int, fix, code16, code32 byte, fixLimit int) []byte {
+	switch {
+	case n < fixLimit:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, code16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, code32), uint32(n))
+	}
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackBinary(b []byte, data []byte) []byte {
+	switch n := len(data); {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+	}
+	return append(b, data...)
+}
+
+func appendMsgpackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendMsgpackUint(b, uint64(i))
+	case i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(i))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+	}
+}
+
+func appendMsgpackUint(b []byte, u uint64) []byte {
+	switch {
+	case u <= 127:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(u))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), u)
+	}
+}
+
+func appendMsgpackFloat(b []byte, f float64) []byte {
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return appendMsgpackInt(b, int64(f))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(f))
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// normalize converts decoded JSON or MsgPack to a common form: every
+// number becomes a float64 and every map a map[string]interface{}
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int8:
+		return float64(v)
+	case int16:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case uint16:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = normalize(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[key] = normalize(value)
+		}
+		return out
+	}
+	return v
+}
+
+// decodeMsgpack decodes a frame with an independent MsgPack implementation
+func decodeMsgpack(t *testing.T, data []byte) interface{} {
+	t.Helper()
+	var decoded interface{}
+	if err := msgpack.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("decoding MsgPack % x: %v", data, err)
+	}
+	return decoded
+}
+
+// assertSameAsJSON encodes message both ways and checks the MsgPack frame
+// decodes to what the JSON frame does
+func assertSameAsJSON(t *testing.T, message interface{}) {
+	t.Helper()
+	jsonFrame, err := EncodeFrame(EncodingJSON, message)
+	if err != nil {
+		t.Fatalf("encoding JSON: %v", err)
+	}
+	packedFrame, err := EncodeFrame(EncodingMsgpack, message)
+	if err != nil {
+		t.Fatalf("encoding MsgPack: %v", err)
+	}
+	if jsonFrame.Binary || !packedFrame.Binary {
+		t.Errorf("JSON binary = %v, MsgPack binary = %v, want text and binary frames", jsonFrame.Binary, packedFrame.Binary)
+	}
+
+	var fromJSON interface{}
+	if err := json.Unmarshal(jsonFrame.Data, &fromJSON); err != nil {
+		t.Fatalf("decoding JSON: %v", err)
+	}
+	got, want := normalize(decodeMsgpack(t, packedFrame.Data)), normalize(fromJSON)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MsgPack decodes to\n%#v\nwant the JSON form\n%#v", got, want)
+	}
+}
+
+func TestMsgpackMatchesJSONForHubMessages(t *testing.T) {
+	many := func(n int) map[string]interface{} {
+		m := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			m[fmt.Sprintf("key%d", i)] = i
+		}
+		return m
+	}
+	list := func(n int) []interface{} {
+		l := make([]interface{}, n)
+		for i := range l {
+			l[i] = float64(i) - 8
+		}
+		return l
+	}
+
+	messages := map[string]HubMessage{
+		"empty":       {},
+		"type only":   {Type: "pong"},
+		"replayed":    {Type: "job_update", JobID: "0b6f3c3e-4a9e-4d55-9a3b-1f0c4c2f8b6e", Status: "running", Replayed: true, Seq: 42, Timestamp: 1699369800123},
+		"largest seq": {Type: "job_update", Seq: math.MaxUint64},
+		"job progress": {
+			Type:   "job_update",
+			JobID:  "job-1",
+			Status: "running",
+			Data: map[string]interface{}{
+				"event":     "job_progress",
+				"progress":  float64(37),
+				"ratio":     0.375,
+				"completed": 3,
+				"failed":    int64(-1),
+				"cancelled": false,
+				"paused":    true,
+				"error":     nil,
+				"labels":    []string{"a", "b"},
+				"results":   []interface{}{"ok", 1.5, map[string]interface{}{"nested": true}},
+				"account":   map[string]interface{}{"id": uint(7), "email": "a@example.com"},
+			},
+		},
+		"string lengths": {Type: "job_update", Data: map[string]interface{}{
+			"s31":    strings.Repeat("a", 31),
+			"s32":    strings.Repeat("b", 32),
+			"s255":   strings.Repeat("c", 255),
+			"s256":   strings.Repeat("d", 256),
+			"s65535": strings.Repeat("e", 65535),
+			"s65536": strings.Repeat("f", 65536),
+			"utf8":   "zürich ✓ 日本",
+		}},
+		"collection sizes": {Type: "job_update", Data: map[string]interface{}{
+			"map15":     many(15),
+			"map16":     many(16),
+			"map65536":  many(65536),
+			"list15":    list(15),
+			"list16":    list(16),
+			"list65536": list(65536),
+			"strings16": []string{
+				"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13", "14", "15",
+			},
+		}},
+	}
+	for name, message := range messages {
+		t.Run(name, func(t *testing.T) {
+			assertSameAsJSON(t, message)
+		})
+	}
+}
+
+func TestMsgpackMatchesJSONForOtherMessages(t *testing.T) {
+	// Messages other than HubMessage go out in their JSON form
+	messages := map[string]interface{}{
+		"dropped notice": DroppedNotice{Type: "messages_dropped", Count: 12, Timestamp: 1699369800123},
+		"map":            map[string]interface{}{"type": "hello", "client_id": "abc", "replay": []interface{}{}},
+		"struct pointer": &struct {
+			Type  string   `json:"type"`
+			Jobs  []string `json:"jobs,omitempty"`
+			Count int      `json:"count"`
+		}{Type: "subscribed", Jobs: []string{"job-1"}, Count: 1},
+	}
+	for name, message := range messages {
+		t.Run(name, func(t *testing.T) {
+			assertSameAsJSON(t, message)
+		})
+	}
+}
+
+func TestMsgpackNumbers(t *testing.T) {
+	signed := []int64{
+		0, 1, -1, 127, 128, 255, 256, -32, -33, -128, -129,
+		math.MaxInt16, math.MinInt16, math.MinInt16 - 1, math.MaxUint16, math.MaxUint16 + 1,
+		math.MaxInt32, math.MinInt32, math.MinInt32 - 1, math.MaxUint32, math.MaxUint32 + 1,
+		math.MaxInt64, math.MinInt64,
+	}
+	for _, want := range signed {
+		packed, err := appendMsgpack(nil, want)
+		if err != nil {
+			t.Fatalf("encoding %d: %v", want, err)
+		}
+		var got int64
+		if err := msgpack.Unmarshal(packed, &got); err != nil || got != want {
+			t.Errorf("int64 %d decodes to %d, %v (% x)", want, got, err, packed)
+		}
+	}
+
+	for _, want := range []uint64{math.MaxUint32 + 1, math.MaxInt64 + 1, math.MaxUint64} {
+		packed, _ := appendMsgpack(nil, want)
+		var got uint64
+		if err := msgpack.Unmarshal(packed, &got); err != nil || got != want {
+			t.Errorf("uint64 %d decodes to %d, %v (% x)", want, got, err, packed)
+		}
+	}
+
+	floats := map[float64]int{
+		3:     1, // whole floats use the shortest integer form
+		-3:    1,
+		1e6:   5,
+		0.5:   9,
+		-1e-9: 9,
+		1e300: 9,
+	}
+	for f, size := range floats {
+		packed, _ := appendMsgpack(nil, f)
+		var got float64
+		if err := msgpack.Unmarshal(packed, &got); err != nil || got != f {
+			t.Errorf("float %g decodes to %g, %v (% x)", f, got, err, packed)
+		}
+		if len(packed) != size {
+			t.Errorf("float %g encodes to %d bytes, want %d", f, len(packed), size)
+		}
+	}
+}
+
+func TestMsgpackBinary(t *testing.T) {
+	for _, n := range []int{0, 255, 256, 65535, 65536} {
+		want := []byte(strings.Repeat("\x01", n))
+		packed, _ := appendMsgpack(nil, want)
+		var got []byte
+		if err := msgpack.Unmarshal(packed, &got); err != nil || len(got) != n {
+			t.Errorf("%d-byte binary decodes to %d bytes, %v", n, len(got), err)
+		}
+	}
+}
+
+// benchmarkMessage is a typical progress update
+var benchmarkMessage = HubMessage{
+	Type:      "job_update",
+	JobID:     "0b6f3c3e-4a9e-4d55-9a3b-1f0c4c2f8b6e",
+	Status:    "running",
+	Timestamp: 1699369800123,
+	Seq:       123456,
+	Data: map[string]interface{}{
+		"event":     "job_progress",
+		"progress":  float64(37),
+		"completed": float64(37),
+		"failed":    float64(2),
+		"total":     float64(100),
+		"message":   "Created account 37 of 100",
+	},
+}
+
+func BenchmarkEncodeFrameJSON(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := EncodeFrame(EncodingJSON, benchmarkMessage); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeFrameMsgpack(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := EncodeFrame(EncodingMsgpack, benchmarkMessage); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
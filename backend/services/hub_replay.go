@@ -0,0 +1,106 @@
+package services
+
+import "sync"
+
+// DefaultReplayBufferSize is the number of recent events kept for replay
+// when no size is configured
+const DefaultReplayBufferSize = 200
+
+// ReplayBuffer is a fixed-size ring of recently broadcast events, replayed
+// to clients that reconnect. It also numbers every event it is given, so the
+// buffered events always have consecutive sequence numbers ending at seq.
+// It has its own lock so replays never contend with the clients map.
+type ReplayBuffer struct {
+	mu     sync.Mutex
+	events []HubMessage
+	next   int    // index the next event is written to
+	full   bool   // whether the ring has wrapped
+	seq    uint64 // sequence number of the latest event; 0 before the first
+}
+
+// NewReplayBuffer creates a buffer holding up to size events; a size of
+// zero or less disables replay
+func NewReplayBuffer(size int) *ReplayBuffer {
+	if size < 0 {
+		size = 0
+	}
+	return &ReplayBuffer{events: make([]HubMessage, size)}
+}
+
+// Capacity is the number of events the buffer holds; zero when replay is
+// disabled
+func (b *ReplayBuffer) Capacity() int {
+	return len(b.events)
+}
+
+// Add numbers an event and records it, overwriting the oldest when the
+// buffer is full. It returns the event with its Seq set; events are
+// numbered even when replay is disabled.
+func (b *ReplayBuffer) Add(message HubMessage) HubMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	message.Seq = b.seq
+	if len(b.events) == 0 {
+		return message
+	}
+	b.events[b.next] = message
+	b.next = (b.next + 1) % len(b.events)
+	if b.next == 0 {
+		b.full = true
+	}
+	return message
+}
+
+// Seq returns the sequence number of the latest event
+func (b *ReplayBuffer) Seq() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.seq
+}
+
+// After returns the events numbered after lastSeq, oldest first. covered
+// is false when some of them have already been overwritten, or when
+// lastSeq is ahead of the latest event, as after a server restart; the
+// caller then has no way to fill the gap. oldest is the first sequence
+// number still buffered.
+func (b *ReplayBuffer) After(lastSeq uint64) (events []HubMessage, oldest uint64, covered bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start, count := 0, b.next
+	if b.full {
+		start, count = b.next, len(b.events)
+	}
+	oldest = b.seq - uint64(count) + 1
+
+	if lastSeq > b.seq || lastSeq+1 < oldest {
+		return nil, oldest, false
+	}
+	for i := count - int(b.seq-lastSeq); i < count; i++ {
+		events = append(events, b.events[(start+i)%len(b.events)])
+	}
+	return events, oldest, true
+}
+
+// Since returns the buffered events newer than sinceMS (unix milliseconds),
+// oldest first
+func (b *ReplayBuffer) Since(sinceMS int64) []HubMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start, count := 0, b.next
+	if b.full {
+		start, count = b.next, len(b.events)
+	}
+
+	var events []HubMessage
+	for i := 0; i < count; i++ {
+		message := b.events[(start+i)%len(b.events)]
+		if message.Timestamp > sinceMS {
+			events = append(events, message)
+		}
+	}
+	return events
+}
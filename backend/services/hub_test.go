@@ -0,0 +1,448 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"botrix-backend/config"
+)
+
+// fakeConn is a hub client transport that is always alive
+type fakeConn struct{}
+
+func (fakeConn) Ping() error { return nil }
+
+// newTestHub starts a hub, stopping it when the test ends
+func newTestHub(t *testing.T, cfg config.WebSocketConfig) *Hub {
+	t.Helper()
+	hub := NewHub(cfg)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		hub.Stop(ctx)
+	})
+	return hub
+}
+
+// registerTestClient registers a client subscribed to jobIDs
+func registerTestClient(t *testing.T, hub *Hub, id string, jobIDs ...string) *HubClient {
+	t.Helper()
+	client := NewHubClient(id, fakeConn{}, "127.0.0.1")
+	for _, jobID := range jobIDs {
+		client.Subscribe(jobID)
+	}
+	if err := hub.Register(client); err != nil {
+		t.Fatalf("registering %s: %v", id, err)
+	}
+	return client
+}
+
+// hubMarker is broadcast after the messages under test. It is not tied to
+// a job, so every client receives it, and per-client order is preserved, so
+// a client that reaches it has received everything it ever will before it.
+const hubMarker = "test_marker"
+
+// receiveUntilMarker broadcasts the marker and returns the messages client
+// received before it
+func receiveUntilMarker(t *testing.T, hub *Hub, client *HubClient) []HubMessage {
+	t.Helper()
+	hub.Broadcast(HubMessage{Type: hubMarker})
+
+	var received []HubMessage
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case f, ok := <-client.SendChan:
+			if !ok {
+				t.Fatalf("client %s was removed", client.ID)
+			}
+			var message HubMessage
+			if err := json.Unmarshal(f.Data, &message); err != nil {
+				t.Fatalf("decoding %s: %v", f.Data, err)
+			}
+			if message.Type == hubMarker {
+				return received
+			}
+			received = append(received, message)
+		case <-timeout:
+			t.Fatalf("client %s did not receive the marker", client.ID)
+		}
+	}
+}
+
+// jobIDs lists the job of each message
+func jobIDs(messages []HubMessage) []string {
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		ids[i] = message.JobID
+	}
+	return ids
+}
+
+func TestHubIsolatesJobSubscriptions(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{})
+	alice := registerTestClient(t, hub, "alice", "job-a")
+	bob := registerTestClient(t, hub, "bob", "job-b")
+	dashboard := registerTestClient(t, hub, "dashboard", AllJobs)
+	idle := registerTestClient(t, hub, "idle")
+
+	for _, jobID := range []string{"job-a", "job-b", "job-c", "job-a"} {
+		hub.Broadcast(HubMessage{Type: "job_update", JobID: jobID, Status: "running"})
+	}
+
+	tests := []struct {
+		client *HubClient
+		want   []string
+	}{
+		{alice, []string{"job-a", "job-a"}},
+		{bob, []string{"job-b"}},
+		{dashboard, []string{"job-a", "job-b", "job-c", "job-a"}},
+		{idle, []string{}},
+	}
+	for _, tt := range tests {
+		if got := jobIDs(receiveUntilMarker(t, hub, tt.client)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s received jobs %v, want %v", tt.client.ID, got, tt.want)
+		}
+	}
+}
+
+func TestHubUnsubscribeStopsUpdates(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{})
+	alice := registerTestClient(t, hub, "alice", "job-a", "job-b")
+	bob := registerTestClient(t, hub, "bob", "job-a")
+
+	alice.Unsubscribe("job-a")
+	hub.Broadcast(HubMessage{Type: "job_update", JobID: "job-a"})
+	hub.Broadcast(HubMessage{Type: "job_update", JobID: "job-b"})
+
+	if got := jobIDs(receiveUntilMarker(t, hub, alice)); !reflect.DeepEqual(got, []string{"job-b"}) {
+		t.Errorf("alice received jobs %v after unsubscribing from job-a, want [job-b]", got)
+	}
+	if got := jobIDs(receiveUntilMarker(t, hub, bob)); !reflect.DeepEqual(got, []string{"job-a"}) {
+		t.Errorf("bob received jobs %v, want [job-a]", got)
+	}
+}
+
+// drainUntilClosed reads a client's SendChan until the hub closes it,
+// reporting the close on done
+func drainUntilClosed(client *HubClient, done chan<- string) {
+	for range client.SendChan {
+	}
+	done <- client.ID
+}
+
+func TestHubEvictsSlowClientsUnderLoad(t *testing.T) {
+	const (
+		fastClients  = 50
+		slowClients  = 50
+		broadcasters = 4
+		perSender    = 200
+		maxDrops     = 20
+	)
+	hub := newTestHub(t, config.WebSocketConfig{SlowClientMaxDrops: maxDrops, SlowClientWindow: time.Minute})
+
+	closed := make(chan string, fastClients+slowClients)
+	var fast, slow []*HubClient
+	// Fast clients read concurrently and have room for every message, so
+	// they never drop one however the reader goroutines are scheduled
+	for i := 0; i < fastClients; i++ {
+		client := NewHubClient(fmt.Sprintf("fast-%d", i), fakeConn{}, "127.0.0.1")
+		client.SendChan = make(chan Frame, (broadcasters+1)*perSender)
+		client.Subscribe(AllJobs)
+		if err := hub.Register(client); err != nil {
+			t.Fatalf("registering %s: %v", client.ID, err)
+		}
+		fast = append(fast, client)
+		go drainUntilClosed(client, closed)
+	}
+	// Slow clients never read their SendChan
+	for i := 0; i < slowClients; i++ {
+		slow = append(slow, registerTestClient(t, hub, fmt.Sprintf("slow-%d", i), AllJobs))
+	}
+
+	// Broadcasts race direct sends, stats reads and clients coming and going
+	var wg sync.WaitGroup
+	for b := 0; b < broadcasters; b++ {
+		wg.Add(1)
+		go func(b int) {
+			defer wg.Done()
+			for i := 0; i < perSender; i++ {
+				hub.Broadcast(HubMessage{Type: "job_update", JobID: fmt.Sprintf("job-%d", i%7), Status: "running"})
+			}
+		}(b)
+	}
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < perSender; i++ {
+			hub.SendToAll(HubMessage{Type: "notice"})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < perSender; i++ {
+			for _, client := range hub.Clients() {
+				client.Pending()
+				client.MessagesDropped()
+			}
+			hub.ClientCount()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < perSender; i++ {
+			client := registerTestClient(t, hub, fmt.Sprintf("transient-%d", i), AllJobs)
+			hub.Unregister(client)
+			// A second removal must not close the channel again
+			hub.Unregister(client)
+		}
+	}()
+	wg.Wait()
+
+	// Every slow client dropped far more than maxDrops, so all are evicted
+	// and their SendChan closed exactly once
+	deadline := time.After(5 * time.Second)
+	for _, client := range slow {
+		for hub.Has(client.ID) {
+			select {
+			case <-deadline:
+				t.Fatalf("slow client %s was not evicted (%d dropped)", client.ID, client.MessagesDropped())
+			case <-time.After(time.Millisecond):
+			}
+		}
+		go drainUntilClosed(client, closed)
+	}
+	for i := 0; i < slowClients; i++ {
+		select {
+		case id := <-closed:
+			if !strings.HasPrefix(id, "slow-") {
+				t.Errorf("fast client %s was evicted", id)
+			}
+		case <-deadline:
+			t.Fatal("evicted clients' SendChan was not closed")
+		}
+	}
+
+	for _, client := range fast {
+		if !hub.Has(client.ID) {
+			t.Errorf("fast client %s was evicted (%d dropped)", client.ID, client.MessagesDropped())
+		}
+	}
+	if evicted := hub.SlowClientStats()["evicted"]; evicted != uint64(slowClients) {
+		t.Errorf("evicted = %v, want %d", evicted, slowClients)
+	}
+}
+
+func TestHubRejectsDuplicateClientID(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{})
+	first := registerTestClient(t, hub, "same-id", "job-a")
+
+	second := NewHubClient("same-id", fakeConn{}, "127.0.0.2")
+	if err := hub.Register(second); err != ErrDuplicateClient {
+		t.Fatalf("registering a taken ID: %v, want ErrDuplicateClient", err)
+	}
+
+	// The registered client keeps its entry and still receives updates
+	hub.Broadcast(HubMessage{Type: "job_update", JobID: "job-a"})
+	if got := jobIDs(receiveUntilMarker(t, hub, first)); !reflect.DeepEqual(got, []string{"job-a"}) {
+		t.Errorf("first client received jobs %v, want [job-a]", got)
+	}
+	if hub.Unregister(second) {
+		t.Error("unregistering the refused client removed the registered one")
+	}
+	if !hub.Has("same-id") {
+		t.Error("the registered client is gone")
+	}
+}
+
+// events lists the job and event type of each message
+func events(messages []HubMessage) []string {
+	names := make([]string, len(messages))
+	for i, message := range messages {
+		names[i] = message.JobID + ":" + MessageEvent(message)
+	}
+	return names
+}
+
+func TestHubCombinesFilterAndSubscriptions(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{})
+
+	// Filters accept the marker, which is not tied to a job, so
+	// receiveUntilMarker works for filtered clients too
+	terminalOnA := registerTestClient(t, hub, "terminal-on-a", "job-a")
+	terminalOnA.SetFilter([]string{"job_completed", "job_failed", hubMarker})
+	terminalEverywhere := registerTestClient(t, hub, "terminal-everywhere", AllJobs)
+	terminalEverywhere.SetFilter([]string{"job_completed", "job_failed", hubMarker})
+	everythingOnA := registerTestClient(t, hub, "everything-on-a", "job-a")
+	progressOnB := registerTestClient(t, hub, "progress-on-b", "job-b")
+	progressOnB.SetFilter([]string{"job_progress", hubMarker})
+
+	for _, message := range []HubMessage{
+		{Type: "job_update", JobID: "job-a", Status: "running"},
+		{Type: "job_update", JobID: "job-a", Data: map[string]interface{}{"event": "job_progress"}},
+		{Type: "job_update", JobID: "job-b", Data: map[string]interface{}{"event": "job_progress"}},
+		{Type: "job_update", JobID: "job-b", Status: "failed"},
+		{Type: "job_update", JobID: "job-a", Status: "completed"},
+	} {
+		hub.Broadcast(message)
+	}
+
+	tests := []struct {
+		client *HubClient
+		want   []string
+	}{
+		{terminalOnA, []string{"job-a:job_completed"}},
+		{terminalEverywhere, []string{"job-b:job_failed", "job-a:job_completed"}},
+		{everythingOnA, []string{"job-a:job_running", "job-a:job_progress", "job-a:job_completed"}},
+		{progressOnB, []string{"job-b:job_progress"}},
+	}
+	for _, tt := range tests {
+		if got := events(receiveUntilMarker(t, hub, tt.client)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s received %v, want %v", tt.client.ID, got, tt.want)
+		}
+	}
+}
+
+func TestHubClearedFilterAcceptsEverything(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{})
+	client := registerTestClient(t, hub, "client", "job-a")
+	client.SetFilter([]string{"job_completed"})
+
+	// An empty filter restores every event of the subscribed jobs
+	client.SetFilter(nil)
+	hub.Broadcast(HubMessage{Type: "job_update", JobID: "job-a", Status: "running"})
+	if got := events(receiveUntilMarker(t, hub, client)); !reflect.DeepEqual(got, []string{"job-a:job_running"}) {
+		t.Errorf("after clearing the filter the client received %v, want [job-a:job_running]", got)
+	}
+}
+
+// evictedByOldPolicy reports whether the eviction policy before drop
+// counting would have removed the client: it evicted at the first message
+// a full buffer could not take
+func evictedByOldPolicy(client *HubClient) bool {
+	return client.MessagesDropped() > 0
+}
+
+// fillSendChan sends until the client's buffer is full and then extra more
+// messages, returning how many of those were dropped
+func fillSendChan(t *testing.T, hub *Hub, client *HubClient, extra int) int {
+	t.Helper()
+	for len(client.SendChan) < cap(client.SendChan) {
+		if err := hub.Send(client, HubMessage{Type: "job_update", JobID: "job-a"}); err != nil {
+			t.Fatalf("filling the buffer: %v", err)
+		}
+	}
+	dropped := 0
+	for i := 0; i < extra; i++ {
+		switch err := hub.Send(client, HubMessage{Type: "job_update", JobID: "job-a"}); err {
+		case ErrMessageDropped:
+			dropped++
+		case ErrClientNotFound:
+			return dropped
+		default:
+			t.Fatalf("sending to a full buffer: %v, want ErrMessageDropped", err)
+		}
+	}
+	return dropped
+}
+
+func TestHubBriefStallKeepsClient(t *testing.T) {
+	const maxDrops = 20
+	hub := newTestHub(t, config.WebSocketConfig{SlowClientMaxDrops: maxDrops, SlowClientWindow: time.Minute})
+	client := registerTestClient(t, hub, "stalled", AllJobs)
+
+	// The reader stalls long enough to miss maxDrops messages
+	if dropped := fillSendChan(t, hub, client, maxDrops); dropped != maxDrops {
+		t.Fatalf("dropped %d messages, want %d", dropped, maxDrops)
+	}
+	if !evictedByOldPolicy(client) {
+		t.Fatal("the stall did not overflow the buffer")
+	}
+	if !hub.Has(client.ID) {
+		t.Fatalf("client was evicted after %d drops, within the limit of %d", maxDrops, maxDrops)
+	}
+
+	// The reader catches up, learns what it missed and keeps receiving
+	for len(client.SendChan) > 0 {
+		<-client.SendChan
+	}
+	notice, ok := client.TakeDroppedNotice()
+	if !ok || notice.Type != "messages_dropped" || notice.Count != maxDrops {
+		t.Errorf("dropped notice = %+v, %v, want messages_dropped with count %d", notice, ok, maxDrops)
+	}
+	if _, ok := client.TakeDroppedNotice(); ok {
+		t.Error("the dropped notice was reported twice")
+	}
+	hub.Broadcast(HubMessage{Type: "job_update", JobID: "job-b"})
+	if got := jobIDs(receiveUntilMarker(t, hub, client)); !reflect.DeepEqual(got, []string{"job-b"}) {
+		t.Errorf("after catching up the client received %v, want [job-b]", got)
+	}
+}
+
+func TestHubSustainedStallEvictsClient(t *testing.T) {
+	const maxDrops = 20
+	hub := newTestHub(t, config.WebSocketConfig{SlowClientMaxDrops: maxDrops, SlowClientWindow: time.Minute})
+	client := registerTestClient(t, hub, "stalled", AllJobs)
+
+	// Both policies evict a reader that never resumes; the new one only
+	// once it drops more than maxDrops messages
+	dropped := fillSendChan(t, hub, client, 10*maxDrops)
+	if dropped != maxDrops+1 {
+		t.Errorf("client was evicted after %d drops, want %d", dropped, maxDrops+1)
+	}
+	if hub.Has(client.ID) {
+		t.Fatal("client was not evicted")
+	}
+	if evicted := hub.SlowClientStats()["evicted"]; evicted != uint64(1) {
+		t.Errorf("evicted = %v, want 1", evicted)
+	}
+
+	// The transport sees SendChan closed once it drains the buffer
+	for range client.SendChan {
+	}
+}
+
+func TestHubDropsSpreadOverWindowsKeepClient(t *testing.T) {
+	const (
+		maxDrops = 5
+		window   = 100 * time.Millisecond
+	)
+	hub := newTestHub(t, config.WebSocketConfig{SlowClientMaxDrops: maxDrops, SlowClientWindow: window})
+	client := registerTestClient(t, hub, "flaky", AllJobs)
+
+	// Three stalls each drop maxDrops messages, in separate windows
+	for stall := 0; stall < 3; stall++ {
+		if dropped := fillSendChan(t, hub, client, maxDrops); dropped != maxDrops {
+			t.Fatalf("stall %d dropped %d messages, want %d", stall, dropped, maxDrops)
+		}
+		time.Sleep(window + 20*time.Millisecond)
+	}
+
+	if !evictedByOldPolicy(client) {
+		t.Fatal("the stalls did not overflow the buffer")
+	}
+	if !hub.Has(client.ID) {
+		t.Errorf("client was evicted after %d drops spread over separate windows", client.MessagesDropped())
+	}
+	if got := client.MessagesDropped(); got != 3*maxDrops {
+		t.Errorf("MessagesDropped = %d, want %d", got, 3*maxDrops)
+	}
+}
+
+func TestHubZeroMaxDropsNeverEvicts(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{SlowClientMaxDrops: 0, SlowClientWindow: time.Minute})
+	client := registerTestClient(t, hub, "stalled", AllJobs)
+
+	if dropped := fillSendChan(t, hub, client, 1000); dropped != 1000 {
+		t.Errorf("dropped %d messages, want 1000", dropped)
+	}
+	if !hub.Has(client.ID) {
+		t.Error("client was evicted with eviction disabled")
+	}
+}
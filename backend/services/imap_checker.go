@@ -0,0 +1,227 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// imapCheckTimeout bounds a whole IMAP connection test. It stays under the
+// server's 10s write timeout so the result reaches the client.
+const imapCheckTimeout = 8 * time.Second
+
+// IMAP check failure kinds
+const (
+	IMAPErrorDNS      = "dns_failure"
+	IMAPErrorConnect  = "connection_failure"
+	IMAPErrorTLS      = "tls_failure"
+	IMAPErrorAuth     = "auth_failure"
+	IMAPErrorTimeout  = "timeout"
+	IMAPErrorProtocol = "protocol_error"
+)
+
+// IMAPCheckError describes why an IMAP connection test failed
+type IMAPCheckError struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+func (e *IMAPCheckError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+}
+
+// IMAPCredentials identify the mailbox an IMAP check logs in to
+type IMAPCredentials struct {
+	Server   string
+	Port     int
+	Username string
+	Password string
+}
+
+// IMAPChecker verifies IMAP settings by logging in and selecting INBOX
+type IMAPChecker struct {
+	timeout time.Duration
+
+	// tlsConfig overrides the client TLS configuration; nil verifies the
+	// server certificate against the system roots
+	tlsConfig *tls.Config
+}
+
+// NewIMAPChecker creates an IMAP checker with the default timeout
+func NewIMAPChecker() *IMAPChecker {
+	return &IMAPChecker{timeout: imapCheckTimeout}
+}
+
+// Check dials the server over TLS, logs in and selects INBOX, returning the
+// number of messages in it. Failures are returned as *IMAPCheckError. The
+// password is never included in errors.
+func (c *IMAPChecker) Check(ctx context.Context, creds IMAPCredentials) (int, error) {
+	if creds.Server == "" || creds.Username == "" || creds.Password == "" {
+		return 0, &IMAPCheckError{Kind: IMAPErrorAuth, Message: "server, username and password are required"}
+	}
+	if creds.Port < 1 || creds.Port > 65535 {
+		return 0, &IMAPCheckError{Kind: IMAPErrorConnect, Message: "port must be between 1 and 65535"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	if _, err := net.DefaultResolver.LookupHost(ctx, creds.Server); err != nil {
+		return 0, classifyIMAPError(ctx, IMAPErrorDNS, err)
+	}
+
+	addr := net.JoinHostPort(creds.Server, strconv.Itoa(creds.Port))
+	var dialer net.Dialer
+	rawConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return 0, classifyIMAPError(ctx, IMAPErrorConnect, err)
+	}
+	defer rawConn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		rawConn.SetDeadline(deadline)
+	}
+
+	tlsConfig := c.tlsConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: creds.Server, MinVersion: tls.VersionTLS12}
+	}
+	conn := tls.Client(rawConn, tlsConfig)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return 0, classifyIMAPError(ctx, IMAPErrorTLS, err)
+	}
+
+	session := &imapSession{conn: conn, reader: bufio.NewReader(conn)}
+	count, err := session.run(creds)
+	if err != nil {
+		return 0, classifyIMAPError(ctx, IMAPErrorProtocol, err)
+	}
+	return count, nil
+}
+
+// classifyIMAPError maps an error to an IMAPCheckError, reporting timeouts
+// as such regardless of the step that hit them
+func classifyIMAPError(ctx context.Context, kind string, err error) error {
+	var checkErr *IMAPCheckError
+	if errors.As(err, &checkErr) {
+		return checkErr
+	}
+
+	var netErr net.Error
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &IMAPCheckError{Kind: IMAPErrorTimeout, Message: "IMAP server did not respond in time"}
+	}
+	return &IMAPCheckError{Kind: kind, Message: err.Error()}
+}
+
+// imapSession speaks the few IMAP commands a connection test needs
+type imapSession struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	tag    int
+}
+
+// run reads the greeting, logs in, selects INBOX and logs out
+func (s *imapSession) run(creds IMAPCredentials) (int, error) {
+	greeting, err := s.readLine()
+	if err != nil {
+		return 0, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		return 0, fmt.Errorf("unexpected greeting: %s", greeting)
+	}
+
+	username, err := imapQuote(creds.Username)
+	if err != nil {
+		return 0, &IMAPCheckError{Kind: IMAPErrorAuth, Message: "username " + err.Error()}
+	}
+	password, err := imapQuote(creds.Password)
+	if err != nil {
+		return 0, &IMAPCheckError{Kind: IMAPErrorAuth, Message: "password " + err.Error()}
+	}
+
+	if _, status, err := s.command("LOGIN " + username + " " + password); err != nil {
+		return 0, err
+	} else if status != "" {
+		return 0, &IMAPCheckError{Kind: IMAPErrorAuth, Message: status}
+	}
+
+	untagged, status, err := s.command("SELECT INBOX")
+	if err != nil {
+		return 0, err
+	}
+	if status != "" {
+		return 0, fmt.Errorf("SELECT INBOX failed: %s", status)
+	}
+
+	count := -1
+	for _, line := range untagged {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && strings.EqualFold(fields[2], "EXISTS") {
+			if n, err := strconv.Atoi(fields[1]); err == nil {
+				count = n
+			}
+		}
+	}
+	if count < 0 {
+		return 0, fmt.Errorf("SELECT INBOX did not report a message count")
+	}
+
+	// Best effort; the test already succeeded
+	s.command("LOGOUT")
+
+	return count, nil
+}
+
+// command sends a tagged command and reads until its completion. It returns
+// the untagged responses and, if the server answered NO or BAD, its reply.
+func (s *imapSession) command(cmd string) ([]string, string, error) {
+	s.tag++
+	tag := fmt.Sprintf("a%d", s.tag)
+
+	if _, err := fmt.Fprintf(s.conn, "%s %s\r\n", tag, cmd); err != nil {
+		return nil, "", err
+	}
+
+	var untagged []string
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			return nil, "", err
+		}
+		if !strings.HasPrefix(line, tag+" ") {
+			untagged = append(untagged, line)
+			continue
+		}
+
+		result := strings.TrimPrefix(line, tag+" ")
+		if strings.HasPrefix(strings.ToUpper(result), "OK") {
+			return untagged, "", nil
+		}
+		return untagged, result, nil
+	}
+}
+
+// readLine reads one CRLF-terminated response line
+func (s *imapSession) readLine() (string, error) {
+	line, err := s.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// imapQuote encodes a value as an IMAP quoted string
+func imapQuote(value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", errors.New("must not contain line breaks")
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+	return `"` + escaped + `"`, nil
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeIMAPServer accepts one login over implicit TLS and reports a fixed
+// INBOX message count
+type fakeIMAPServer struct {
+	username string
+	password string
+	exists   int
+
+	// logins receives each LOGIN command line as sent by the client
+	logins chan string
+}
+
+// start serves connections on ln until the listener is closed
+func (s *fakeIMAPServer) start(ln net.Listener, tlsConfig *tls.Config) {
+	s.logins = make(chan string, 10)
+	tlsListener := tls.NewListener(ln, tlsConfig)
+	go func() {
+		for {
+			conn, err := tlsListener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+}
+
+func (s *fakeIMAPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		for _, line := range lines {
+			fmt.Fprintf(conn, "%s\r\n", line)
+		}
+	}
+
+	reply("* OK fake IMAP ready")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, cmd, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+
+		switch {
+		case strings.HasPrefix(cmd, "LOGIN "):
+			s.logins <- cmd
+			want := fmt.Sprintf("LOGIN %q %q", s.username, s.password)
+			if cmd != want {
+				reply(tag + " NO [AUTHENTICATIONFAILED] Invalid credentials")
+				continue
+			}
+			reply(tag + " OK LOGIN completed")
+		case cmd == "SELECT INBOX":
+			reply(
+				`* FLAGS (\Answered \Flagged \Deleted \Seen \Draft)`,
+				fmt.Sprintf("* %d EXISTS", s.exists),
+				"* 0 RECENT",
+				tag+" OK [READ-WRITE] SELECT completed",
+			)
+		case cmd == "LOGOUT":
+			reply("* BYE logging out", tag+" OK LOGOUT completed")
+			return
+		default:
+			reply(tag + " BAD unknown command")
+		}
+	}
+}
+
+// newTestIMAPChecker returns a checker trusting the test certificate
+func newTestIMAPChecker(clientTLS *tls.Config) *IMAPChecker {
+	return &IMAPChecker{timeout: 2 * time.Second, tlsConfig: clientTLS}
+}
+
+func TestIMAPCheckCountsInbox(t *testing.T) {
+	serverTLS, clientTLS := newTestTLSConfigs(t)
+	ln, port := listenTest(t)
+	server := &fakeIMAPServer{username: "inbox@example.com", password: `pa"ss\word`, exists: 42}
+	server.start(ln, serverTLS)
+
+	count, err := newTestIMAPChecker(clientTLS).Check(context.Background(), IMAPCredentials{
+		Server: "127.0.0.1", Port: port, Username: server.username, Password: server.password,
+	})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if count != 42 {
+		t.Errorf("count = %d, want 42", count)
+	}
+	if got, want := <-server.logins, `LOGIN "inbox@example.com" "pa\"ss\\word"`; got != want {
+		t.Errorf("login command = %s, want %s", got, want)
+	}
+}
+
+func TestIMAPCheckFailures(t *testing.T) {
+	serverTLS, clientTLS := newTestTLSConfigs(t)
+	const password = "correct-horse-battery"
+
+	ln, port := listenTest(t)
+	server := &fakeIMAPServer{username: "inbox@example.com", password: password, exists: 1}
+	server.start(ln, serverTLS)
+
+	// Accepts connections but never answers the TLS handshake
+	silent, silentPort := listenTest(t)
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(io.Discard, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	// Nothing listens on a closed listener's port
+	closed, closedPort := listenTest(t)
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		creds   IMAPCredentials
+		checker *IMAPChecker
+		kind    string
+	}{
+		{
+			name:    "wrong password",
+			creds:   IMAPCredentials{Server: "127.0.0.1", Port: port, Username: "inbox@example.com", Password: "wrong-" + password},
+			checker: newTestIMAPChecker(clientTLS),
+			kind:    IMAPErrorAuth,
+		},
+		{
+			name:    "missing password",
+			creds:   IMAPCredentials{Server: "127.0.0.1", Port: port, Username: "inbox@example.com"},
+			checker: newTestIMAPChecker(clientTLS),
+			kind:    IMAPErrorAuth,
+		},
+		{
+			name:    "line break in password",
+			creds:   IMAPCredentials{Server: "127.0.0.1", Port: port, Username: "inbox@example.com", Password: "a\r\nb"},
+			checker: newTestIMAPChecker(clientTLS),
+			kind:    IMAPErrorAuth,
+		},
+		{
+			name:    "untrusted certificate",
+			creds:   IMAPCredentials{Server: "127.0.0.1", Port: port, Username: "inbox@example.com", Password: password},
+			checker: &IMAPChecker{timeout: 2 * time.Second},
+			kind:    IMAPErrorTLS,
+		},
+		{
+			name:    "connection refused",
+			creds:   IMAPCredentials{Server: "127.0.0.1", Port: closedPort, Username: "inbox@example.com", Password: password},
+			checker: newTestIMAPChecker(clientTLS),
+			kind:    IMAPErrorConnect,
+		},
+		{
+			name:    "invalid port",
+			creds:   IMAPCredentials{Server: "127.0.0.1", Port: 70000, Username: "inbox@example.com", Password: password},
+			checker: newTestIMAPChecker(clientTLS),
+			kind:    IMAPErrorConnect,
+		},
+		{
+			name:    "server never answers",
+			creds:   IMAPCredentials{Server: "127.0.0.1", Port: silentPort, Username: "inbox@example.com", Password: password},
+			checker: &IMAPChecker{timeout: 200 * time.Millisecond, tlsConfig: clientTLS},
+			kind:    IMAPErrorTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.checker.Check(context.Background(), tt.creds)
+			checkErr, ok := err.(*IMAPCheckError)
+			if !ok {
+				t.Fatalf("Check error = %v (%T), want *IMAPCheckError", err, err)
+			}
+			if checkErr.Kind != tt.kind {
+				t.Errorf("kind = %s, want %s (%s)", checkErr.Kind, tt.kind, checkErr.Message)
+			}
+			if strings.Contains(checkErr.Error(), password) {
+				t.Errorf("error %q contains the password", checkErr.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"botrix-backend/models"
+)
+
+// JobUpdate is a parsed message from the job updates channel.
+// The backend nests fields under "data" while workers publish them at the
+// top level, so both layouts are accepted.
+type JobUpdate struct {
+	Event  string
+	JobID  string
+	Status string
+	Raw    map[string]interface{}
+}
+
+// ParseJobUpdate parses a job updates channel payload
+func ParseJobUpdate(payload string) (*JobUpdate, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(payload), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse job update: %w", err)
+	}
+
+	update := &JobUpdate{
+		Event:  stringField(raw, "event"),
+		JobID:  stringField(raw, "job_id"),
+		Status: stringField(raw, "status"),
+		Raw:    raw,
+	}
+
+	if data, ok := raw["data"].(map[string]interface{}); ok {
+		if update.JobID == "" {
+			update.JobID = stringField(data, "job_id")
+		}
+		if update.Status == "" {
+			update.Status = stringField(data, "status")
+		}
+	}
+
+	return update, nil
+}
+
+// IsTerminal checks if the update reports a terminal job status
+func (u *JobUpdate) IsTerminal() bool {
+	switch models.JobStatus(u.Status) {
+	case models.JobStatusCompleted, models.JobStatusFailed, models.JobStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// JobWatcher follows the job updates channel, records each job's status
+// timeline, applies the outcome of account-level jobs (verify) back
+// to the database and fires completion callbacks and account webhooks
+type JobWatcher struct {
+	db        *Database
+	queue     *QueueService
+	callbacks *CallbackDispatcher
+	webhooks  *WebhookDispatcher
+}
+
+// NewJobWatcher creates a new job watcher
+func NewJobWatcher(db *Database, queue *QueueService, callbacks *CallbackDispatcher, webhooks *WebhookDispatcher) *JobWatcher {
+	return &JobWatcher{
+		db:        db,
+		queue:     queue,
+		callbacks: callbacks,
+		webhooks:  webhooks,
+	}
+}
+
+// Start subscribes to job updates and processes them in the background
+func (w *JobWatcher) Start() error {
+	pubsub, err := w.queue.Subscribe(JobUpdatesChannel)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer pubsub.Close()
+
+		for msg := range pubsub.Channel() {
+			update, err := ParseJobUpdate(msg.Payload)
+			if err != nil {
+				log.Printf("[JobWatcher] WARNING: %v", err)
+				continue
+			}
+
+			if update.JobID == "" {
+				continue
+			}
+
+			if update.Event == JobLogEvent {
+				w.recordWorkerLog(update)
+				continue
+			}
+
+			if update.Status != "" {
+				w.recordEvent(update)
+			}
+
+			if !update.IsTerminal() {
+				continue
+			}
+
+			w.handleTerminal(update)
+		}
+
+		log.Println("[JobWatcher] Update channel closed, watcher stopped")
+	}()
+
+	log.Println("[JobWatcher] Watching job updates")
+	return nil
+}
+
+// handleTerminal applies the result of a job that reached a terminal state
+func (w *JobWatcher) handleTerminal(update *JobUpdate) {
+	job, err := w.db.GetJob(update.JobID)
+	if err != nil {
+		// Not every job on the channel is known to this database
+		return
+	}
+
+	// Updates may be published more than once; apply outcomes only once
+	switch job.Type {
+	case models.JobTypeVerify:
+		if !job.IsCompleted() {
+			w.applyVerification(job, update)
+		}
+	case models.JobTypeGenerate:
+		// JobReconciler may already have completed the row, so the
+		// announcement is claimed separately rather than keyed on it
+		w.announceCreatedAccounts(job, update)
+	}
+
+	// Callbacks are claimed atomically, so repeated updates deliver once
+	if w.callbacks != nil {
+		w.callbacks.Dispatch(job, models.JobStatus(update.Status))
+	}
+}
+
+// recordEvent adds a status update to the job's persisted timeline
+func (w *JobWatcher) recordEvent(update *JobUpdate) {
+	event := &models.JobEvent{
+		JobID:   update.JobID,
+		Event:   update.Event,
+		Status:  update.Status,
+		Payload: update.Raw,
+	}
+	if event.Event == "" {
+		// Workers publish bare status updates
+		event.Event = "status_updated"
+	}
+
+	if _, err := w.db.RecordJobEvent(event); err != nil {
+		log.Printf("[JobWatcher] WARNING: Failed to record %s event for job %s: %v", event.Event, update.JobID, err)
+	}
+}
+
+// recordWorkerLog persists a log line published by a worker. Lines logged
+// by the backend itself are already stored and are skipped.
+func (w *JobWatcher) recordWorkerLog(update *JobUpdate) {
+	if stringField(update.Raw, "source") != "worker" {
+		return
+	}
+
+	level := stringField(update.Raw, "level")
+	if !models.IsValidJobLogLevel(level) {
+		level = models.JobLogInfo
+	}
+
+	if _, err := w.db.AppendJobLog(update.JobID, level, stringField(update.Raw, "message")); err != nil {
+		log.Printf("[JobWatcher] WARNING: Failed to store log line for job %s: %v", update.JobID, err)
+	}
+}
+
+// applyVerification writes a verify job's outcome to its account
+func (w *JobWatcher) applyVerification(job *models.Job, update *JobUpdate) {
+	if models.JobStatus(update.Status) != models.JobStatusCompleted {
+		w.finishJob(job, update)
+		return
+	}
+
+	raw, err := w.queue.GetJobResult(job.ID)
+	if err != nil {
+		job.Fail("verification result missing")
+		w.saveJob(job)
+		return
+	}
+
+	var result models.VerificationResult
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		job.Fail(fmt.Sprintf("invalid verification result: %v", err))
+		w.saveJob(job)
+		return
+	}
+
+	// The job record is authoritative for which account was verified
+	result.AccountID = job.AccountID
+
+	account, err := w.db.GetAccount(job.AccountID)
+	if err != nil {
+		job.Fail(fmt.Sprintf("account %d not found", job.AccountID))
+		w.saveJob(job)
+		return
+	}
+	oldStatus := account.Status
+
+	if err := w.db.ApplyVerificationResult(job.ID, &result); err != nil {
+		log.Printf("[JobWatcher] ERROR: Failed to apply verification for job %s: %v", job.ID, err)
+		job.Fail(err.Error())
+		w.saveJob(job)
+		return
+	}
+
+	if newStatus := result.AccountStatus(); newStatus != "" {
+		w.webhooks.AccountStatusChanged(account, oldStatus, newStatus, "verification: "+result.Outcome)
+		w.queue.PublishAccountEvent("account_status_changed", account.ID, map[string]interface{}{
+			"username":   account.Username,
+			"old_status": oldStatus,
+			"new_status": newStatus,
+			"reason":     "verification: " + result.Outcome,
+		})
+	}
+
+	log.Printf("[JobWatcher] Verification job %s applied to account %d: %s", job.ID, job.AccountID, result.Outcome)
+
+	job.Progress = job.Count
+	job.Successful = job.Count
+	job.Complete()
+	w.saveJob(job)
+}
+
+// announceCreatedAccounts publishes an accounts_created event, once, for a
+// generate job that produced at least one account
+func (w *JobWatcher) announceCreatedAccounts(job *models.Job, update *JobUpdate) {
+	if models.JobStatus(update.Status) != models.JobStatusCompleted {
+		return
+	}
+
+	result, _ := update.Raw["result"].(map[string]interface{})
+	created, _ := result["accounts_created"].(float64)
+	if created < 1 {
+		return
+	}
+
+	claimed, err := w.queue.ClaimJobAnnouncement(job.ID)
+	if err != nil {
+		log.Printf("[JobWatcher] WARNING: Failed to claim announcement for job %s: %v", job.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	w.queue.PublishAccountEvent("accounts_created", 0, map[string]interface{}{
+		"job_id": job.ID,
+		"count":  int(created),
+	})
+}
+
+// finishJob records a non-successful terminal status on the job
+func (w *JobWatcher) finishJob(job *models.Job, update *JobUpdate) {
+	switch models.JobStatus(update.Status) {
+	case models.JobStatusCancelled:
+		job.Cancel()
+	default:
+		job.Fail(stringField(update.Raw, "error"))
+	}
+	w.saveJob(job)
+}
+
+// saveJob persists a job, logging failures
+func (w *JobWatcher) saveJob(job *models.Job) {
+	if err := w.db.UpdateJob(job); err != nil {
+		log.Printf("[JobWatcher] ERROR: Failed to update job %s: %v", job.ID, err)
+	}
+}
+
+// stringField safely reads a string value from a map
+func stringField(data map[string]interface{}, key string) string {
+	if val, ok := data[key].(string); ok {
+		return val
+	}
+	return ""
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -36,12 +37,42 @@ const (
 	JobStatusKey      = "botrix:jobs:status:"
 	JobDataKey        = "botrix:jobs:data:"
 	JobResultsKey     = "botrix:jobs:results:"
+	JobPausedKey      = "botrix:jobs:paused:"
+	JobAnnouncedKey   = "botrix:jobs:announced:"
 	JobUpdatesChannel = "botrix:jobs:updates"
 
+	// AccountUpdatesChannel carries account lifecycle events, published
+	// with PublishAccountEvent
+	AccountUpdatesChannel = "botrix:accounts:updates"
+
+	// EmailPoolAvailableKey holds the number of unused emails in the worker
+	// email pool. Workers keep it current as emails are consumed.
+	EmailPoolAvailableKey = "botrix:emails:available"
+
+	// JobLogEvent is the update event carrying a job log line. Workers
+	// publish it with "source": "worker" for the backend to persist.
+	JobLogEvent = "job_log"
+
+	// JobProgressEvent is the update event workers publish as a job moves
+	// through its accounts. The WebSocket relay coalesces bursts of it.
+	JobProgressEvent = "job_progress"
+
+	// SettingsUpdatedEvent is the update event published after settings
+	// are saved. Its data names the changed fields, never their values;
+	// workers re-read settings from the backend when they receive it.
+	SettingsUpdatedEvent = "settings_updated"
+
 	// Job TTL in seconds (1 hour)
 	JobTTL = 3600
 )
 
+// ErrJobResultNotFound is returned when Redis holds no result for a job,
+// either because it has not finished or because the result expired
+var ErrJobResultNotFound = errors.New("job result not found")
+
+// ErrJobNotQueued is returned when a job is not in the Redis queue
+var ErrJobNotQueued = errors.New("job not in queue")
+
 // NewQueueService creates a new queue service
 func NewQueueService(cfg *config.Config) (*QueueService, error) {
 	ctx := context.Background()
@@ -77,9 +108,9 @@ func (q *QueueService) GetRedisClient() *redis.Client {
 	return q.client
 }
 
-// Health checks the Redis connection
-func (q *QueueService) Health() error {
-	return q.client.Ping(q.ctx).Err()
+// Health checks the Redis connection, giving up when ctx is done
+func (q *QueueService) Health(ctx context.Context) error {
+	return q.client.Ping(ctx).Err()
 }
 
 // AddJob adds a job to the queue and returns the job ID
@@ -246,20 +277,28 @@ func (q *QueueService) GetPendingJobs() ([]models.Job, error) {
 
 // Subscribe creates a pub/sub subscription for real-time job updates
 func (q *QueueService) Subscribe(channel string) (*redis.PubSub, error) {
-	if channel == "" {
-		channel = JobUpdatesChannel
+	return q.SubscribeContext(q.ctx, channel)
+}
+
+// SubscribeContext is Subscribe for one or more channels, with a context
+// bounding the wait for the subscription to be confirmed so long-lived
+// subscribers can be stopped
+func (q *QueueService) SubscribeContext(ctx context.Context, channels ...string) (*redis.PubSub, error) {
+	if len(channels) == 0 || (len(channels) == 1 && channels[0] == "") {
+		channels = []string{JobUpdatesChannel}
 	}
 
-	pubsub := q.client.Subscribe(q.ctx, channel)
+	pubsub := q.client.Subscribe(ctx, channels...)
 
 	// Wait for confirmation
-	_, err := pubsub.Receive(q.ctx)
+	_, err := pubsub.Receive(ctx)
 	if err != nil {
-		log.Printf("[QueueService] ERROR: Failed to subscribe to channel %s: %v", channel, err)
+		pubsub.Close()
+		log.Printf("[QueueService] ERROR: Failed to subscribe to channels %v: %v", channels, err)
 		return nil, fmt.Errorf("failed to subscribe: %w", err)
 	}
 
-	log.Printf("[QueueService] Subscribed to channel: %s", channel)
+	log.Printf("[QueueService] Subscribed to channels: %v", channels)
 	return pubsub, nil
 }
 
@@ -400,6 +439,12 @@ func (q *QueueService) CancelJob(jobID string) error {
 		log.Printf("[QueueService] WARNING: Failed to remove job %s from queue: %v", jobID, err)
 	}
 
+	// Clear any pause; the status is set first so a waiting worker sees
+	// the cancellation rather than a resume
+	if err := q.client.Del(q.ctx, fmt.Sprintf("%s%s", JobPausedKey, jobID)).Err(); err != nil {
+		log.Printf("[QueueService] WARNING: Failed to clear paused flag for job %s: %v", jobID, err)
+	}
+
 	log.Printf("[QueueService] Job %s cancelled", jobID)
 
 	// Publish cancellation notification
@@ -411,6 +456,91 @@ func (q *QueueService) CancelJob(jobID string) error {
 	return nil
 }
 
+// PauseJob sets the job's paused flag, which workers check between
+// accounts, and takes a pending job out of the queue so it is not dequeued.
+// The flag does not expire, as a pause may outlast any TTL; ResumeJob and
+// CancelJob clear it.
+func (q *QueueService) PauseJob(jobID string) error {
+	if jobID == "" {
+		return fmt.Errorf("job ID cannot be empty")
+	}
+
+	pausedKey := fmt.Sprintf("%s%s", JobPausedKey, jobID)
+	if err := q.client.Set(q.ctx, pausedKey, time.Now().Unix(), 0).Err(); err != nil {
+		log.Printf("[QueueService] ERROR: Failed to set paused flag for job %s: %v", jobID, err)
+		return fmt.Errorf("failed to pause job: %w", err)
+	}
+
+	if err := q.client.ZRem(q.ctx, JobQueueKey, jobID).Err(); err != nil {
+		log.Printf("[QueueService] WARNING: Failed to remove paused job %s from queue: %v", jobID, err)
+	}
+
+	log.Printf("[QueueService] Job %s paused", jobID)
+
+	q.publishUpdate(jobID, "job_paused", map[string]interface{}{
+		"job_id": jobID,
+		"paused": true,
+	})
+
+	return nil
+}
+
+// ResumeJob clears the job's paused flag. A pending job is put back in the
+// queue with its priority.
+func (q *QueueService) ResumeJob(job models.Job) error {
+	pausedKey := fmt.Sprintf("%s%s", JobPausedKey, job.ID)
+	if err := q.client.Del(q.ctx, pausedKey).Err(); err != nil {
+		log.Printf("[QueueService] ERROR: Failed to clear paused flag for job %s: %v", job.ID, err)
+		return fmt.Errorf("failed to resume job: %w", err)
+	}
+
+	if job.Status == models.JobStatusPending {
+		if err := q.client.ZAddNX(q.ctx, JobQueueKey, &redis.Z{
+			Score:  float64(-job.Priority),
+			Member: job.ID,
+		}).Err(); err != nil {
+			log.Printf("[QueueService] ERROR: Failed to requeue resumed job %s: %v", job.ID, err)
+			return fmt.Errorf("failed to requeue job: %w", err)
+		}
+	}
+
+	log.Printf("[QueueService] Job %s resumed", job.ID)
+
+	q.publishUpdate(job.ID, "job_resumed", map[string]interface{}{
+		"job_id": job.ID,
+		"paused": false,
+	})
+
+	return nil
+}
+
+// RemoveJobArtifacts deletes every Redis trace of a job: its status, data
+// and result keys and its queue and processing set membership
+func (q *QueueService) RemoveJobArtifacts(jobID string) error {
+	if jobID == "" {
+		return fmt.Errorf("job ID cannot be empty")
+	}
+
+	_, err := q.client.TxPipelined(q.ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(q.ctx,
+			fmt.Sprintf("%s%s", JobStatusKey, jobID),
+			fmt.Sprintf("%s%s", JobDataKey, jobID),
+			fmt.Sprintf("%s%s", JobResultsKey, jobID),
+			fmt.Sprintf("%s%s", JobPausedKey, jobID),
+		)
+		pipe.ZRem(q.ctx, JobQueueKey, jobID)
+		pipe.SRem(q.ctx, JobProcessingKey, jobID)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[QueueService] ERROR: Failed to remove artifacts for job %s: %v", jobID, err)
+		return fmt.Errorf("failed to remove job artifacts: %w", err)
+	}
+
+	log.Printf("[QueueService] Artifacts for job %s removed", jobID)
+	return nil
+}
+
 // GetQueueLength returns the number of jobs in the queue
 func (q *QueueService) GetQueueLength() (int64, error) {
 	count, err := q.client.ZCard(q.ctx, JobQueueKey).Result()
@@ -421,6 +551,73 @@ func (q *QueueService) GetQueueLength() (int64, error) {
 	return count, nil
 }
 
+// GetJobPositionByID returns the number of jobs DequeueJob will pop before
+// the given job. Queue scores encode priority and ties are popped in member
+// order, which is exactly the sorted set rank.
+func (q *QueueService) GetJobPositionByID(jobID string) (int64, error) {
+	rank, err := q.client.ZRank(q.ctx, JobQueueKey, jobID).Result()
+	if err == redis.Nil {
+		return 0, ErrJobNotQueued
+	}
+	if err != nil {
+		log.Printf("[QueueService] ERROR: Failed to get queue position of job %s: %v", jobID, err)
+		return 0, err
+	}
+	return rank, nil
+}
+
+// UpdateJobPriority re-scores a queued job so the new priority affects
+// dequeue order, and updates the stored job data to match. It returns
+// ErrJobNotQueued when the job is no longer in the queue.
+func (q *QueueService) UpdateJobPriority(jobID string, priority int) error {
+	if _, err := q.client.ZScore(q.ctx, JobQueueKey, jobID).Result(); err == redis.Nil {
+		return ErrJobNotQueued
+	} else if err != nil {
+		return fmt.Errorf("failed to check queue entry: %w", err)
+	}
+
+	// XX only updates existing members, so a job dequeued in the meantime
+	// is not re-added
+	if err := q.client.ZAddXX(q.ctx, JobQueueKey, &redis.Z{
+		Score:  float64(-priority),
+		Member: jobID,
+	}).Err(); err != nil {
+		log.Printf("[QueueService] ERROR: Failed to re-score job %s: %v", jobID, err)
+		return fmt.Errorf("failed to update job priority: %w", err)
+	}
+
+	if job, err := q.getJobData(jobID); err == nil {
+		job.Priority = priority
+		if jobData, err := json.Marshal(job); err == nil {
+			jobDataKey := fmt.Sprintf("%s%s", JobDataKey, jobID)
+			q.client.Set(q.ctx, jobDataKey, jobData, redis.KeepTTL)
+		}
+	}
+
+	log.Printf("[QueueService] Job %s re-scored with priority %d", jobID, priority)
+
+	q.publishUpdate(jobID, "priority_changed", map[string]interface{}{
+		"job_id":   jobID,
+		"priority": priority,
+	})
+
+	return nil
+}
+
+// GetEmailPoolAvailable returns the number of unused emails in the pool.
+// The second return value is false when no worker has reported a count yet.
+func (q *QueueService) GetEmailPoolAvailable() (int64, bool, error) {
+	count, err := q.client.Get(q.ctx, EmailPoolAvailableKey).Int64()
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+	if err != nil {
+		log.Printf("[QueueService] ERROR: Failed to get email pool count: %v", err)
+		return 0, false, err
+	}
+	return count, true, nil
+}
+
 // GetProcessingCount returns the number of jobs being processed
 func (q *QueueService) GetProcessingCount() (int64, error) {
 	count, err := q.client.SCard(q.ctx, JobProcessingKey).Result()
@@ -431,6 +628,26 @@ func (q *QueueService) GetProcessingCount() (int64, error) {
 	return count, nil
 }
 
+// GetQueueDepth returns the pending and processing job counts in one round
+// trip, giving up when ctx is done
+func (q *QueueService) GetQueueDepth(ctx context.Context) (pending, processing int64, err error) {
+	var pendingCmd, processingCmd *redis.IntCmd
+	_, err = q.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pendingCmd = pipe.ZCard(ctx, JobQueueKey)
+		processingCmd = pipe.SCard(ctx, JobProcessingKey)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return pendingCmd.Val(), processingCmd.Val(), nil
+}
+
+// PoolStats returns connection pool statistics for the Redis client
+func (q *QueueService) PoolStats() *redis.PoolStats {
+	return q.client.PoolStats()
+}
+
 // IsJobProcessing checks if a job is currently being processed
 func (q *QueueService) IsJobProcessing(jobID string) (bool, error) {
 	if jobID == "" {
@@ -478,7 +695,7 @@ func (q *QueueService) GetJobResult(jobID string) (string, error) {
 
 	if err == redis.Nil {
 		log.Printf("[QueueService] Result not found for job %s", jobID)
-		return "", fmt.Errorf("job result not found")
+		return "", ErrJobResultNotFound
 	}
 
 	if err != nil {
@@ -521,10 +738,11 @@ func (q *QueueService) GetQueueStats() (map[string]interface{}, error) {
 		return nil, err
 	}
 
-	// Get priority distribution
+	// Get priority distribution; scores are -priority, so the ranges are
+	// exclusive at the lower end to count each job once
 	highPriority, _ := q.client.ZCount(q.ctx, JobQueueKey, "-inf", "-2").Result()
-	normalPriority, _ := q.client.ZCount(q.ctx, JobQueueKey, "-2", "-1").Result()
-	lowPriority, _ := q.client.ZCount(q.ctx, JobQueueKey, "-1", "inf").Result()
+	normalPriority, _ := q.client.ZCount(q.ctx, JobQueueKey, "(-2", "-1").Result()
+	lowPriority, _ := q.client.ZCount(q.ctx, JobQueueKey, "(-1", "+inf").Result()
 
 	return map[string]interface{}{
 		"queue_length":     queueLength,
@@ -536,6 +754,43 @@ func (q *QueueService) GetQueueStats() (map[string]interface{}, error) {
 	}, nil
 }
 
+// PublishEvent publishes an event that is not tied to a single job
+// (e.g. settings changes) on the updates channel
+func (q *QueueService) PublishEvent(eventType string, data map[string]interface{}) {
+	q.publishUpdate("", eventType, data)
+}
+
+// PublishAccountEvent publishes an account lifecycle event on the account
+// updates channel. accountID is 0 for events covering several accounts.
+func (q *QueueService) PublishAccountEvent(eventType string, accountID uint, data map[string]interface{}) {
+	message := map[string]interface{}{
+		"event":     eventType,
+		"timestamp": time.Now().Unix(),
+		"data":      data,
+	}
+	if accountID != 0 {
+		message["account_id"] = accountID
+	}
+	q.publish(AccountUpdatesChannel, message)
+}
+
+// ClaimJobAnnouncement marks a job's accounts_created event as sent. It
+// returns false if it was already claimed, so repeated terminal updates
+// announce a job once.
+func (q *QueueService) ClaimJobAnnouncement(jobID string) (bool, error) {
+	return q.client.SetNX(q.ctx, JobAnnouncedKey+jobID, 1, time.Duration(JobTTL)*time.Second).Result()
+}
+
+// PublishJobLog publishes a persisted job log line so live viewers see it
+func (q *QueueService) PublishJobLog(entry *models.JobLog) {
+	q.publishUpdate(entry.JobID, JobLogEvent, map[string]interface{}{
+		"id":        entry.ID,
+		"level":     entry.Level,
+		"message":   entry.Message,
+		"logged_at": entry.CreatedAt.Unix(),
+	})
+}
+
 // Helper methods
 
 // getJobData retrieves job data from Redis
@@ -574,20 +829,23 @@ func (q *QueueService) removeFromQueues(jobID string) {
 
 // publishUpdate publishes a job update to the pub/sub channel
 func (q *QueueService) publishUpdate(jobID, eventType string, data map[string]interface{}) {
-	message := map[string]interface{}{
+	q.publish(JobUpdatesChannel, map[string]interface{}{
 		"event":     eventType,
 		"job_id":    jobID,
 		"timestamp": time.Now().Unix(),
 		"data":      data,
-	}
+	})
+}
 
+// publish sends a message to a pub/sub channel; failures are only logged
+func (q *QueueService) publish(channel string, message map[string]interface{}) {
 	messageData, err := json.Marshal(message)
 	if err != nil {
 		log.Printf("[QueueService] WARNING: Failed to marshal update message: %v", err)
 		return
 	}
 
-	if err := q.client.Publish(q.ctx, JobUpdatesChannel, messageData).Err(); err != nil {
-		log.Printf("[QueueService] WARNING: Failed to publish update: %v", err)
+	if err := q.client.Publish(q.ctx, channel, messageData).Err(); err != nil {
+		log.Printf("[QueueService] WARNING: Failed to publish update to %s: %v", channel, err)
 	}
 }
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"botrix-backend/models"
+
+	"github.com/google/uuid"
+)
+
+func TestPausedFlagOutlastsJobTTL(t *testing.T) {
+	queue, server := newTestQueue(t, newTestConfig(t))
+
+	job := &models.Job{ID: uuid.New().String(), Count: 1, Status: models.JobStatusPending}
+	if err := queue.EnqueueJob(job); err != nil {
+		t.Fatalf("EnqueueJob: %v", err)
+	}
+	pausedKey := JobPausedKey + job.ID
+
+	if err := queue.PauseJob(job.ID); err != nil {
+		t.Fatalf("PauseJob: %v", err)
+	}
+	if ttl := server.TTL(pausedKey); ttl != 0 {
+		t.Errorf("paused flag TTL = %s, want none", ttl)
+	}
+	if members, _ := server.ZMembers(JobQueueKey); len(members) > 0 {
+		t.Errorf("paused pending job still queued: %v", members)
+	}
+
+	// A pause longer than the job TTL must not resume the job by itself
+	server.FastForward(3 * time.Duration(JobTTL) * time.Second)
+	if !server.Exists(pausedKey) {
+		t.Fatal("paused flag expired")
+	}
+
+	if err := queue.ResumeJob(*job); err != nil {
+		t.Fatalf("ResumeJob: %v", err)
+	}
+	if server.Exists(pausedKey) {
+		t.Error("paused flag remains after resume")
+	}
+	if members, _ := server.ZMembers(JobQueueKey); len(members) != 1 || members[0] != job.ID {
+		t.Errorf("queue after resume = %v, want the resumed job", members)
+	}
+}
+
+func TestCancelClearsPausedFlag(t *testing.T) {
+	queue, server := newTestQueue(t, newTestConfig(t))
+
+	jobID := uuid.New().String()
+	if err := queue.PauseJob(jobID); err != nil {
+		t.Fatalf("PauseJob: %v", err)
+	}
+	if err := queue.CancelJob(jobID); err != nil {
+		t.Fatalf("CancelJob: %v", err)
+	}
+
+	if server.Exists(JobPausedKey + jobID) {
+		t.Error("paused flag remains after cancel")
+	}
+	if status, _ := server.Get(JobStatusKey + jobID); status != string(models.JobStatusCancelled) {
+		t.Errorf("status = %q, want cancelled", status)
+	}
+}
+
+func TestRemoveJobArtifacts(t *testing.T) {
+	queue, server := newTestQueue(t, newTestConfig(t))
+
+	removed := &models.Job{ID: uuid.New().String(), Count: 1, Status: models.JobStatusPending}
+	kept := &models.Job{ID: uuid.New().String(), Count: 1, Status: models.JobStatusPending}
+	for _, job := range []*models.Job{removed, kept} {
+		if err := queue.EnqueueJob(job); err != nil {
+			t.Fatalf("EnqueueJob: %v", err)
+		}
+	}
+	server.Set(JobResultsKey+removed.ID, "{}")
+	if err := queue.PauseJob(removed.ID); err != nil {
+		t.Fatalf("PauseJob: %v", err)
+	}
+	server.SAdd(JobProcessingKey, removed.ID)
+
+	if err := queue.RemoveJobArtifacts(removed.ID); err != nil {
+		t.Fatalf("RemoveJobArtifacts: %v", err)
+	}
+	for _, key := range []string{JobStatusKey, JobDataKey, JobResultsKey, JobPausedKey} {
+		if server.Exists(key + removed.ID) {
+			t.Errorf("%s%s remains", key, removed.ID)
+		}
+	}
+	if processing, _ := server.SMembers(JobProcessingKey); len(processing) != 0 {
+		t.Errorf("processing set = %v, want it empty", processing)
+	}
+
+	// Other jobs are untouched
+	if members, _ := server.ZMembers(JobQueueKey); len(members) != 1 || members[0] != kept.ID {
+		t.Errorf("queue = %v, want only %s", members, kept.ID)
+	}
+	if !server.Exists(JobDataKey+kept.ID) || !server.Exists(JobStatusKey+kept.ID) {
+		t.Error("artifacts of another job were removed")
+	}
+
+	// Removing a job Redis no longer knows is not an error
+	if err := queue.RemoveJobArtifacts(uuid.New().String()); err != nil {
+		t.Errorf("RemoveJobArtifacts of an unknown job: %v", err)
+	}
+}
+
+func TestDequeueSkipsCancelledJob(t *testing.T) {
+	queue, server := newTestQueue(t, newTestConfig(t))
+
+	cancelled := &models.Job{ID: uuid.New().String(), Count: 1, Status: models.JobStatusPending, Priority: 2}
+	next := &models.Job{ID: uuid.New().String(), Count: 1, Status: models.JobStatusPending, Priority: 1}
+	for _, job := range []*models.Job{cancelled, next} {
+		if err := queue.EnqueueJob(job); err != nil {
+			t.Fatalf("EnqueueJob: %v", err)
+		}
+	}
+
+	// The cancelled job had the higher priority and would be dequeued first
+	if err := queue.CancelJob(cancelled.ID); err != nil {
+		t.Fatalf("CancelJob: %v", err)
+	}
+	job, err := queue.DequeueJob()
+	if err != nil || job == nil || job.ID != next.ID {
+		t.Fatalf("DequeueJob = %v, %v, want %s", job, err, next.ID)
+	}
+	if job, err := queue.DequeueJob(); err != nil || job != nil {
+		t.Errorf("DequeueJob on a drained queue = %v, %v, want nothing", job, err)
+	}
+	if status, _ := server.Get(JobStatusKey + cancelled.ID); status != string(models.JobStatusCancelled) {
+		t.Errorf("cancelled job status = %q, want cancelled", status)
+	}
+
+	// Cancelling a job Redis has forgotten still succeeds
+	if err := queue.CancelJob(uuid.New().String()); err != nil {
+		t.Errorf("CancelJob of an unknown job: %v", err)
+	}
+}
+
+func TestQueuePriorityOrder(t *testing.T) {
+	queue, _ := newTestQueue(t, newTestConfig(t))
+
+	// Enqueued low first; ties are popped in member order
+	enqueued := []*models.Job{
+		{ID: "job-a", Priority: 0},
+		{ID: "job-b", Priority: 1},
+		{ID: "job-c", Priority: 2},
+		{ID: "job-d", Priority: 0},
+		{ID: "job-e", Priority: 1},
+	}
+	for _, job := range enqueued {
+		job.Count, job.Status = 1, models.JobStatusPending
+		if err := queue.EnqueueJob(job); err != nil {
+			t.Fatalf("EnqueueJob(%s): %v", job.ID, err)
+		}
+	}
+	want := []string{"job-c", "job-b", "job-e", "job-a", "job-d"}
+
+	// Positions predict the dequeue order
+	for i, id := range want {
+		if position, err := queue.GetJobPositionByID(id); err != nil || position != int64(i) {
+			t.Errorf("GetJobPositionByID(%s) = %d, %v, want %d", id, position, err, i)
+		}
+	}
+
+	for i, id := range want {
+		job, err := queue.DequeueJob()
+		if err != nil || job == nil || job.ID != id {
+			t.Fatalf("dequeue %d = %v, %v, want %s", i, job, err, id)
+		}
+		if _, err := queue.GetJobPositionByID(id); err != ErrJobNotQueued {
+			t.Errorf("GetJobPositionByID(%s) after dequeue error = %v, want ErrJobNotQueued", id, err)
+		}
+	}
+	if job, err := queue.DequeueJob(); err != nil || job != nil {
+		t.Errorf("DequeueJob on an empty queue = %v, %v, want nothing", job, err)
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// RapidAPI Kasada solver endpoint used by the workers
+	rapidAPIHost = "kasada-reverse.p.rapidapi.com"
+	rapidAPIURL  = "https://" + rapidAPIHost + "/kasada"
+
+	// rapidAPICheckTimeout bounds a RapidAPI key check
+	rapidAPICheckTimeout = 5 * time.Second
+)
+
+// rapidAPIQuotaHeaderPrefix prefixes the quota headers RapidAPI returns,
+// e.g. X-RateLimit-Requests-Remaining
+const rapidAPIQuotaHeaderPrefix = "X-Ratelimit-"
+
+// RapidAPICheckResult reports whether a RapidAPI key was accepted
+type RapidAPICheckResult struct {
+	Valid       bool              `json:"valid"`
+	RateLimited bool              `json:"rate_limited"`
+	StatusCode  int               `json:"status_code"`
+	Message     string            `json:"message"`
+	Quota       map[string]string `json:"quota,omitempty"`
+	LatencyMS   int64             `json:"latency_ms"`
+}
+
+// RapidAPIChecker validates RapidAPI keys against the Kasada solver endpoint
+type RapidAPIChecker struct {
+	client *http.Client
+	url    string
+}
+
+// NewRapidAPIChecker creates a checker using client, or a client with a
+// short timeout when nil
+func NewRapidAPIChecker(client *http.Client) *RapidAPIChecker {
+	if client == nil {
+		client = &http.Client{Timeout: rapidAPICheckTimeout}
+	}
+	return &RapidAPIChecker{
+		client: client,
+		url:    rapidAPIURL,
+	}
+}
+
+// Check sends a minimal authenticated request with key. The request has no
+// target URL, so an accepted key gets a validation error from the solver
+// rather than a solved challenge; the gateway rejects bad keys with 401 or
+// 403 before that. An error is returned only when no response arrived.
+func (c *RapidAPIChecker) Check(ctx context.Context, key string) (*RapidAPICheckResult, error) {
+	if strings.TrimSpace(key) == "" {
+		return &RapidAPICheckResult{Message: "No RapidAPI key configured"}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rapidAPICheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader([]byte("{}")))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-RapidAPI-Key", key)
+	req.Header.Set("X-RapidAPI-Host", rapidAPIHost)
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// The URL in transport errors carries no credentials; the key is a header
+		return nil, fmt.Errorf("RapidAPI request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	result := &RapidAPICheckResult{
+		StatusCode: resp.StatusCode,
+		Quota:      rapidAPIQuota(resp.Header),
+		LatencyMS:  time.Since(start).Milliseconds(),
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		result.Message = "Invalid or missing RapidAPI key"
+	case resp.StatusCode == http.StatusForbidden:
+		result.Message = "RapidAPI key is not subscribed to the Kasada solver API"
+	case resp.StatusCode == http.StatusTooManyRequests:
+		result.Valid = true
+		result.RateLimited = true
+		result.Message = "RapidAPI key is valid but its rate limit is exceeded"
+	case resp.StatusCode >= 500:
+		result.Message = fmt.Sprintf("RapidAPI returned status %d; the key could not be verified", resp.StatusCode)
+	default:
+		result.Valid = true
+		result.Message = "RapidAPI key is valid"
+	}
+
+	return result, nil
+}
+
+// rapidAPIQuota collects the rate limit headers from a RapidAPI response,
+// keyed by their lower-cased names
+func rapidAPIQuota(header http.Header) map[string]string {
+	quota := make(map[string]string)
+	for name, values := range header {
+		if strings.HasPrefix(http.CanonicalHeaderKey(name), rapidAPIQuotaHeaderPrefix) && len(values) > 0 {
+			quota[strings.ToLower(name)] = values[0]
+		}
+	}
+	if len(quota) == 0 {
+		return nil
+	}
+	return quota
+}
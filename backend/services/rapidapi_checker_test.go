@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newStubRapidAPIChecker points a checker at a stub answering every request
+// with status and the given headers, recording the key it was sent
+func newStubRapidAPIChecker(t *testing.T, status int, headers map[string]string, gotKey *string) *RapidAPIChecker {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*gotKey = r.Header.Get("X-RapidAPI-Key")
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"message":"stub"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	checker := NewRapidAPIChecker(server.Client())
+	checker.url = server.URL
+	return checker
+}
+
+func TestRapidAPICheckStatuses(t *testing.T) {
+	tests := []struct {
+		status      int
+		valid       bool
+		rateLimited bool
+	}{
+		{http.StatusOK, true, false},
+		{http.StatusBadRequest, true, false},
+		{http.StatusUnauthorized, false, false},
+		{http.StatusForbidden, false, false},
+		{http.StatusTooManyRequests, true, true},
+		{http.StatusBadGateway, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			var gotKey string
+			checker := newStubRapidAPIChecker(t, tt.status, map[string]string{
+				"X-RateLimit-Requests-Remaining": "97",
+				"X-RateLimit-Requests-Limit":     "100",
+				"Server":                         "stub",
+			}, &gotKey)
+
+			result, err := checker.Check(context.Background(), "test-key")
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			if gotKey != "test-key" {
+				t.Errorf("stub received key %q, want test-key", gotKey)
+			}
+			if result.StatusCode != tt.status || result.Valid != tt.valid || result.RateLimited != tt.rateLimited {
+				t.Errorf("result = %+v, want status %d, valid %v, rate limited %v",
+					result, tt.status, tt.valid, tt.rateLimited)
+			}
+			if result.Message == "" {
+				t.Error("result has no message")
+			}
+			want := map[string]string{
+				"x-ratelimit-requests-remaining": "97",
+				"x-ratelimit-requests-limit":     "100",
+			}
+			if len(result.Quota) != len(want) {
+				t.Errorf("quota = %v, want %v", result.Quota, want)
+			}
+			for name, value := range want {
+				if result.Quota[name] != value {
+					t.Errorf("quota[%s] = %q, want %q", name, result.Quota[name], value)
+				}
+			}
+		})
+	}
+}
+
+func TestRapidAPICheckWithoutKey(t *testing.T) {
+	var gotKey string
+	checker := newStubRapidAPIChecker(t, http.StatusOK, nil, &gotKey)
+
+	result, err := checker.Check(context.Background(), "  ")
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if result.Valid || result.StatusCode != 0 {
+		t.Errorf("result = %+v, want an invalid result with no status", result)
+	}
+	if gotKey != "" {
+		t.Error("a blank key was sent to RapidAPI")
+	}
+}
+
+func TestRapidAPICheckUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	checker := NewRapidAPIChecker(server.Client())
+	checker.url = server.URL
+	server.Close()
+
+	if _, err := checker.Check(context.Background(), "test-key"); err == nil {
+		t.Fatal("Check against a closed server returned no error")
+	}
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"botrix-backend/models"
+)
+
+const (
+	// reconcileInterval is how often Redis statuses are swept into the database
+	reconcileInterval = time.Minute
+	// reconcileSweepLimit caps the jobs checked per status in one sweep
+	reconcileSweepLimit = 500
+)
+
+// ReconcilerStats counts database rows the reconciler has corrected
+type ReconcilerStats struct {
+	FromUpdates int64 `json:"from_updates"`
+	FromSweeps  int64 `json:"from_sweeps"`
+	Sweeps      int64 `json:"sweeps"`
+}
+
+// JobReconciler keeps job rows in the database in step with the status
+// workers write to Redis. It applies updates from the job updates channel
+// as they arrive and periodically sweeps unfinished jobs for drift.
+//
+// Writes are conditional on the row still being pending or running, so the
+// reconciler is idempotent and safe alongside other writers. Terminal
+// outcomes of verify jobs are left to JobWatcher, which must
+// apply their results before the job is marked finished.
+type JobReconciler struct {
+	db    *Database
+	queue *QueueService
+
+	fromUpdates int64
+	fromSweeps  int64
+	sweeps      int64
+}
+
+// NewJobReconciler creates a new job reconciler
+func NewJobReconciler(db *Database, queue *QueueService) *JobReconciler {
+	return &JobReconciler{
+		db:    db,
+		queue: queue,
+	}
+}
+
+// Start subscribes to job updates and starts the periodic sweep
+func (r *JobReconciler) Start() error {
+	pubsub, err := r.queue.Subscribe(JobUpdatesChannel)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer pubsub.Close()
+
+		for msg := range pubsub.Channel() {
+			update, err := ParseJobUpdate(msg.Payload)
+			if err != nil || update.JobID == "" || update.Status == "" {
+				continue
+			}
+			if update.Event == JobLogEvent {
+				continue
+			}
+
+			if r.applyUpdate(update) {
+				atomic.AddInt64(&r.fromUpdates, 1)
+			}
+		}
+
+		log.Println("[Reconciler] Update channel closed, reconciler stopped")
+	}()
+
+	go func() {
+		ticker := time.NewTicker(reconcileInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			r.Sweep()
+		}
+	}()
+
+	log.Println("[Reconciler] Reconciling job statuses")
+	return nil
+}
+
+// Stats returns the reconciler's counters
+func (r *JobReconciler) Stats() ReconcilerStats {
+	return ReconcilerStats{
+		FromUpdates: atomic.LoadInt64(&r.fromUpdates),
+		FromSweeps:  atomic.LoadInt64(&r.fromSweeps),
+		Sweeps:      atomic.LoadInt64(&r.sweeps),
+	}
+}
+
+// Sweep compares the Redis status of unfinished jobs with the database and
+// fixes any drift. It returns the number of rows corrected.
+func (r *JobReconciler) Sweep() int {
+	fixed := 0
+
+	for _, status := range []models.JobStatus{models.JobStatusPending, models.JobStatusRunning} {
+		ids, err := r.db.ListJobIDsByStatus(status, reconcileSweepLimit)
+		if err != nil {
+			log.Printf("[Reconciler] ERROR: Failed to list %s jobs: %v", status, err)
+			continue
+		}
+
+		for _, id := range ids {
+			redisStatus, err := r.queue.GetJobStatus(id)
+			if err != nil || redisStatus == "" || models.JobStatus(redisStatus) == status {
+				continue
+			}
+
+			update := &JobUpdate{
+				JobID:  id,
+				Status: redisStatus,
+				Raw:    map[string]interface{}{},
+			}
+			if r.applyUpdate(update) {
+				fixed++
+			}
+		}
+	}
+
+	atomic.AddInt64(&r.sweeps, 1)
+	if fixed > 0 {
+		atomic.AddInt64(&r.fromSweeps, int64(fixed))
+		log.Printf("[Reconciler] Sweep corrected %d job(s)", fixed)
+	}
+	return fixed
+}
+
+// applyUpdate writes an update's status, and a finished generate job's
+// account counts, to the database. It reports whether the row changed.
+func (r *JobReconciler) applyUpdate(update *JobUpdate) bool {
+	status := models.JobStatus(update.Status)
+	if !models.IsValidJobStatus(update.Status) {
+		return false
+	}
+
+	job, err := r.db.GetJob(update.JobID)
+	if err != nil || job.IsCompleted() || job.Status == status {
+		return false
+	}
+
+	terminal := update.IsTerminal()
+	if terminal && job.Type == models.JobTypeVerify {
+		return false
+	}
+
+	changed, err := r.db.ReconcileJobStatus(job.ID, status, stringField(update.Raw, "error"))
+	if err != nil {
+		log.Printf("[Reconciler] ERROR: Failed to reconcile job %s to %s: %v", job.ID, status, err)
+		return false
+	}
+	if !changed {
+		return false
+	}
+
+	if status == models.JobStatusCompleted {
+		r.applyResultCounts(job, update)
+	}
+
+	log.Printf("[Reconciler] Job %s reconciled: %s -> %s", job.ID, job.Status, status)
+	return true
+}
+
+// applyResultCounts records how many accounts a completed job created, from
+// the result in the update or, failing that, the result stored in Redis
+func (r *JobReconciler) applyResultCounts(job *models.Job, update *JobUpdate) {
+	result, ok := update.Raw["result"].(map[string]interface{})
+	if !ok {
+		raw, err := r.queue.GetJobResult(job.ID)
+		if err != nil || json.Unmarshal([]byte(raw), &result) != nil {
+			return
+		}
+	}
+
+	created, ok := result["accounts_created"].(float64)
+	if !ok {
+		return
+	}
+
+	successful := int(created)
+	failed := job.Count - successful
+	if failed < 0 {
+		failed = 0
+	}
+
+	if err := r.db.UpdateJobProgress(job.ID, job.Count, successful, failed); err != nil {
+		log.Printf("[Reconciler] ERROR: Failed to record counts for job %s: %v", job.ID, err)
+	}
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// smtpCheckTimeout bounds a whole SMTP connection test, like imapCheckTimeout
+const smtpCheckTimeout = 8 * time.Second
+
+// smtpImplicitTLSPort is the submission port that expects TLS from the start
+const smtpImplicitTLSPort = 465
+
+// SMTP check stages, in the order they run
+const (
+	SMTPStageDNS     = "dns"
+	SMTPStageConnect = "connect"
+	SMTPStageTLS     = "tls"
+	SMTPStageAuth    = "auth"
+	SMTPStageSend    = "send"
+)
+
+// SMTPCheckError describes the stage at which an SMTP connection test failed
+type SMTPCheckError struct {
+	Stage    string `json:"stage"`
+	TimedOut bool   `json:"timed_out"`
+	Message  string `json:"message"`
+}
+
+func (e *SMTPCheckError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Stage, e.Message)
+}
+
+// SMTPCredentials identify the account an SMTP check authenticates as
+type SMTPCredentials struct {
+	Server   string
+	Port     int
+	Username string
+	Password string
+}
+
+// SMTPChecker verifies SMTP settings by connecting, securing the connection
+// and authenticating, optionally sending a test message
+type SMTPChecker struct {
+	timeout time.Duration
+
+	// tlsConfig overrides the client TLS configuration; nil verifies the
+	// server certificate against the system roots
+	tlsConfig *tls.Config
+}
+
+// NewSMTPChecker creates an SMTP checker with the default timeout
+func NewSMTPChecker() *SMTPChecker {
+	return &SMTPChecker{timeout: smtpCheckTimeout}
+}
+
+// Check connects to the server, upgrades to TLS (implicitly on port 465,
+// with STARTTLS otherwise) and authenticates. When sendTo is not empty a
+// test message is sent to it from the authenticated account. Failures are
+// returned as *SMTPCheckError naming the stage that failed.
+func (c *SMTPChecker) Check(ctx context.Context, creds SMTPCredentials, sendTo string) error {
+	if creds.Server == "" || creds.Username == "" || creds.Password == "" {
+		return &SMTPCheckError{Stage: SMTPStageAuth, Message: "server, username and password are required"}
+	}
+	if creds.Port < 1 || creds.Port > 65535 {
+		return &SMTPCheckError{Stage: SMTPStageConnect, Message: "port must be between 1 and 65535"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	if _, err := net.DefaultResolver.LookupHost(ctx, creds.Server); err != nil {
+		return smtpStageError(ctx, SMTPStageDNS, err)
+	}
+
+	addr := net.JoinHostPort(creds.Server, strconv.Itoa(creds.Port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return smtpStageError(ctx, SMTPStageConnect, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	tlsConfig := c.tlsConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: creds.Server, MinVersion: tls.VersionTLS12}
+	}
+
+	if creds.Port == smtpImplicitTLSPort {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return smtpStageError(ctx, SMTPStageTLS, err)
+		}
+		conn = tlsConn
+	}
+
+	client, err := smtp.NewClient(conn, creds.Server)
+	if err != nil {
+		return smtpStageError(ctx, SMTPStageConnect, err)
+	}
+	defer client.Close()
+
+	if creds.Port != smtpImplicitTLSPort {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return &SMTPCheckError{Stage: SMTPStageTLS, Message: "server does not offer STARTTLS"}
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return smtpStageError(ctx, SMTPStageTLS, err)
+		}
+	}
+
+	if ok, _ := client.Extension("AUTH"); !ok {
+		return &SMTPCheckError{Stage: SMTPStageAuth, Message: "server does not offer authentication"}
+	}
+	if err := client.Auth(smtp.PlainAuth("", creds.Username, creds.Password, creds.Server)); err != nil {
+		return smtpStageError(ctx, SMTPStageAuth, err)
+	}
+
+	if sendTo != "" {
+		if err := sendSMTPTestMessage(client, creds.Username, sendTo); err != nil {
+			return smtpStageError(ctx, SMTPStageSend, err)
+		}
+	}
+
+	// Best effort; the test already succeeded
+	client.Quit()
+
+	return nil
+}
+
+// sendSMTPTestMessage sends a short plain-text message
+func sendSMTPTestMessage(client *smtp.Client, from, to string) error {
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: Botrix SMTP test\r\nDate: %s\r\n\r\n"+
+		"This is a test message sent from the Botrix settings page.\r\n",
+		from, to, time.Now().Format(time.RFC1123Z))
+	if _, err := w.Write([]byte(message)); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// smtpStageError wraps an error with the stage it occurred at, flagging
+// timeouts so a blocked port can be told apart from a refused one
+func smtpStageError(ctx context.Context, stage string, err error) error {
+	var netErr net.Error
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+
+	message := err.Error()
+	if timedOut {
+		message = "SMTP server did not respond in time"
+	}
+	return &SMTPCheckError{Stage: stage, TimedOut: timedOut, Message: message}
+}
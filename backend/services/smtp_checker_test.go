@@ -0,0 +1,241 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer speaks enough ESMTP for a connection test: STARTTLS, AUTH
+// PLAIN and a single message delivery
+type fakeSMTPServer struct {
+	username string
+	password string
+
+	// noStartTLS leaves STARTTLS out of the EHLO extensions
+	noStartTLS bool
+
+	// rejectRcpt is refused as a recipient
+	rejectRcpt string
+
+	// messages receives the recipient and body of each delivered message
+	messages chan [2]string
+}
+
+// start serves connections on ln until the listener is closed
+func (s *fakeSMTPServer) start(ln net.Listener, tlsConfig *tls.Config) {
+	s.messages = make(chan [2]string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, tlsConfig)
+		}
+	}()
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn, tlsConfig *tls.Config) {
+	defer func() { conn.Close() }() // conn is replaced after STARTTLS
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		for _, line := range lines {
+			fmt.Fprintf(conn, "%s\r\n", line)
+		}
+	}
+
+	secure := false
+	var rcpt string
+	reply("220 fake ESMTP ready")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO":
+			// AUTH is only offered once the connection is secure, or
+			// when there is no way to secure it
+			if secure || s.noStartTLS {
+				reply("250-fake greets you", "250 AUTH PLAIN")
+			} else {
+				reply("250-fake greets you", "250 STARTTLS")
+			}
+		case "STARTTLS":
+			reply("220 2.0.0 ready to start TLS")
+			tlsConn := tls.Server(conn, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, reader, secure = tlsConn, bufio.NewReader(tlsConn), true
+		case "AUTH":
+			fields := strings.Fields(line)
+			decoded, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			if string(decoded) != "\x00"+s.username+"\x00"+s.password {
+				reply("535 5.7.8 authentication credentials invalid")
+				continue
+			}
+			reply("235 2.7.0 authentication successful")
+		case "MAIL":
+			reply("250 2.1.0 ok")
+		case "RCPT":
+			rcpt = strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>")
+			if rcpt == s.rejectRcpt {
+				reply("550 5.1.1 mailbox unavailable")
+				continue
+			}
+			reply("250 2.1.5 ok")
+		case "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var body strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				body.WriteString(dataLine)
+			}
+			s.messages <- [2]string{rcpt, body.String()}
+			reply("250 2.0.0 queued")
+		case "QUIT":
+			reply("221 2.0.0 bye")
+			return
+		default:
+			reply("502 5.5.2 command not recognized")
+		}
+	}
+}
+
+// newTestSMTPChecker returns a checker trusting the test certificate
+func newTestSMTPChecker(clientTLS *tls.Config) *SMTPChecker {
+	return &SMTPChecker{timeout: 2 * time.Second, tlsConfig: clientTLS}
+}
+
+func TestSMTPCheckSendsTestMessage(t *testing.T) {
+	serverTLS, clientTLS := newTestTLSConfigs(t)
+	ln, port := listenTest(t)
+	server := &fakeSMTPServer{username: "outbox@example.com", password: "smtp-secret"}
+	server.start(ln, serverTLS)
+
+	err := newTestSMTPChecker(clientTLS).Check(context.Background(), SMTPCredentials{
+		Server: "127.0.0.1", Port: port, Username: server.username, Password: server.password,
+	}, "someone@example.com")
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	select {
+	case msg := <-server.messages:
+		if msg[0] != "someone@example.com" {
+			t.Errorf("recipient = %q, want someone@example.com", msg[0])
+		}
+		if !strings.Contains(msg[1], "Subject: Botrix SMTP test") {
+			t.Errorf("message has no test subject:\n%s", msg[1])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no test message delivered")
+	}
+}
+
+func TestSMTPCheckWithoutSendDeliversNothing(t *testing.T) {
+	serverTLS, clientTLS := newTestTLSConfigs(t)
+	ln, port := listenTest(t)
+	server := &fakeSMTPServer{username: "outbox@example.com", password: "smtp-secret"}
+	server.start(ln, serverTLS)
+
+	err := newTestSMTPChecker(clientTLS).Check(context.Background(), SMTPCredentials{
+		Server: "127.0.0.1", Port: port, Username: server.username, Password: server.password,
+	}, "")
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	select {
+	case msg := <-server.messages:
+		t.Errorf("unexpected message to %s", msg[0])
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSMTPCheckFailureStages(t *testing.T) {
+	serverTLS, clientTLS := newTestTLSConfigs(t)
+	const password = "smtp-secret"
+
+	ln, port := listenTest(t)
+	server := &fakeSMTPServer{username: "outbox@example.com", password: password, rejectRcpt: "nobody@example.com"}
+	server.start(ln, serverTLS)
+
+	plainLn, plainPort := listenTest(t)
+	(&fakeSMTPServer{username: "outbox@example.com", password: password, noStartTLS: true}).start(plainLn, serverTLS)
+
+	// Accepts connections but never sends a greeting
+	silent, silentPort := listenTest(t)
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(io.Discard, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	closed, closedPort := listenTest(t)
+	closed.Close()
+
+	creds := func(port int, password string) SMTPCredentials {
+		return SMTPCredentials{Server: "127.0.0.1", Port: port, Username: "outbox@example.com", Password: password}
+	}
+
+	tests := []struct {
+		name     string
+		creds    SMTPCredentials
+		sendTo   string
+		checker  *SMTPChecker
+		stage    string
+		timedOut bool
+	}{
+		{"missing password", creds(port, ""), "", newTestSMTPChecker(clientTLS), SMTPStageAuth, false},
+		{"invalid port", creds(0, password), "", newTestSMTPChecker(clientTLS), SMTPStageConnect, false},
+		{"connection refused", creds(closedPort, password), "", newTestSMTPChecker(clientTLS), SMTPStageConnect, false},
+		{"no greeting", creds(silentPort, password), "", &SMTPChecker{timeout: 200 * time.Millisecond, tlsConfig: clientTLS}, SMTPStageConnect, true},
+		{"no STARTTLS", creds(plainPort, password), "", newTestSMTPChecker(clientTLS), SMTPStageTLS, false},
+		{"untrusted certificate", creds(port, password), "", &SMTPChecker{timeout: 2 * time.Second}, SMTPStageTLS, false},
+		{"wrong password", creds(port, "wrong-"+password), "", newTestSMTPChecker(clientTLS), SMTPStageAuth, false},
+		{"recipient rejected", creds(port, password), "nobody@example.com", newTestSMTPChecker(clientTLS), SMTPStageSend, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.checker.Check(context.Background(), tt.creds, tt.sendTo)
+			checkErr, ok := err.(*SMTPCheckError)
+			if !ok {
+				t.Fatalf("Check error = %v (%T), want *SMTPCheckError", err, err)
+			}
+			if checkErr.Stage != tt.stage || checkErr.TimedOut != tt.timedOut {
+				t.Errorf("stage = %s, timed_out = %v, want %s, %v (%s)",
+					checkErr.Stage, checkErr.TimedOut, tt.stage, tt.timedOut, checkErr.Message)
+			}
+			if strings.Contains(checkErr.Error(), password) {
+				t.Errorf("error %q contains the password", checkErr.Error())
+			}
+		})
+	}
+}
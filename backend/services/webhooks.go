@@ -0,0 +1,180 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"botrix-backend/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Webhook delivery settings
+	webhookTimeout     = 10 * time.Second
+	webhookMaxAttempts = 5
+	webhookBaseBackoff = 2 * time.Second
+
+	// WebhookEventHeader carries the event name
+	WebhookEventHeader = "X-Botrix-Event"
+	// WebhookDeliveryHeader carries an ID shared by all attempts of one event
+	WebhookDeliveryHeader = "X-Botrix-Delivery"
+)
+
+// WebhookPayload is the JSON body POSTed to a webhook
+type WebhookPayload struct {
+	DeliveryID string                 `json:"delivery_id"`
+	Event      string                 `json:"event"`
+	Timestamp  int64                  `json:"timestamp"`
+	Data       map[string]interface{} `json:"data"`
+}
+
+// WebhookDispatcher delivers account events to registered webhooks.
+// Requests are signed like job callbacks (see CallbackSignatureHeader),
+// using each webhook's own secret.
+type WebhookDispatcher struct {
+	db     *Database
+	client *http.Client
+}
+
+// NewWebhookDispatcher creates a new webhook dispatcher
+func NewWebhookDispatcher(db *Database) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		db:     db,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// AccountStatusChanged notifies webhooks that an account moved to a new status
+func (d *WebhookDispatcher) AccountStatusChanged(account *models.Account, oldStatus, newStatus, reason string) {
+	event := models.WebhookEventForStatus(newStatus)
+	if event == "" || oldStatus == newStatus {
+		return
+	}
+
+	d.Dispatch(event, map[string]interface{}{
+		"account_id": account.ID,
+		"username":   account.Username,
+		"old_status": oldStatus,
+		"new_status": newStatus,
+		"reason":     reason,
+	})
+}
+
+// AccountRestored notifies webhooks that a soft-deleted account was restored
+func (d *WebhookDispatcher) AccountRestored(account *models.Account) {
+	d.Dispatch(models.WebhookEventAccountRestored, map[string]interface{}{
+		"account_id": account.ID,
+		"username":   account.Username,
+		"status":     account.Status,
+	})
+}
+
+// Dispatch delivers an event to every matching webhook in the background.
+// It never blocks the caller.
+func (d *WebhookDispatcher) Dispatch(event string, data map[string]interface{}) {
+	if d == nil {
+		return
+	}
+
+	go func() {
+		webhooks, err := d.db.ListEnabledWebhooks()
+		if err != nil {
+			log.Printf("[Webhooks] ERROR: Failed to load webhooks for %s: %v", event, err)
+			return
+		}
+
+		for i := range webhooks {
+			webhook := webhooks[i]
+			if !webhook.Matches(event) {
+				continue
+			}
+
+			payload := WebhookPayload{
+				DeliveryID: uuid.New().String(),
+				Event:      event,
+				Timestamp:  time.Now().Unix(),
+				Data:       data,
+			}
+			go d.deliver(&webhook, payload)
+		}
+	}()
+}
+
+// deliver POSTs the payload with exponential backoff, logging every attempt
+func (d *WebhookDispatcher) deliver(webhook *models.Webhook, payload WebhookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("[Webhooks] ERROR: Failed to marshal %s for webhook %d: %v", payload.Event, webhook.ID, err)
+		return
+	}
+
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		start := time.Now()
+		statusCode, err := d.post(webhook, payload, body)
+
+		delivery := &models.WebhookDelivery{
+			WebhookID:  webhook.ID,
+			DeliveryID: payload.DeliveryID,
+			Event:      payload.Event,
+			Attempt:    attempt,
+			StatusCode: statusCode,
+			Success:    err == nil,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		if logErr := d.db.CreateWebhookDelivery(delivery); logErr != nil {
+			log.Printf("[Webhooks] ERROR: Failed to record delivery for webhook %d: %v", webhook.ID, logErr)
+		}
+
+		if err == nil {
+			log.Printf("[Webhooks] %s delivered to webhook %d (attempt %d)", payload.Event, webhook.ID, attempt)
+			return
+		}
+
+		log.Printf("[Webhooks] WARNING: %s to webhook %d failed (attempt %d/%d): %v",
+			payload.Event, webhook.ID, attempt, webhookMaxAttempts, err)
+
+		if attempt < webhookMaxAttempts {
+			time.Sleep(webhookBaseBackoff * time.Duration(1<<(attempt-1)))
+		}
+	}
+
+	log.Printf("[Webhooks] ERROR: Giving up on %s for webhook %d", payload.Event, webhook.ID)
+}
+
+// post sends one signed webhook request and returns the response status code
+func (d *WebhookDispatcher) post(webhook *models.Webhook, payload WebhookPayload, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Botrix-Webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, payload.Event)
+	req.Header.Set(WebhookDeliveryHeader, payload.DeliveryID)
+	req.Header.Set(CallbackTimestampHeader, timestamp)
+	if webhook.Secret != "" {
+		req.Header.Set(CallbackSignatureHeader, "sha256="+SignPayload(webhook.Secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -46,6 +46,25 @@ func (l LogLevel) String() string {
 	}
 }
 
+// ParseLogLevel parses a level name such as "warn" or "ERROR"; "warning"
+// is accepted for WARN
+func ParseLogLevel(name string) (LogLevel, bool) {
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case "DEBUG":
+		return DEBUG, true
+	case "INFO":
+		return INFO, true
+	case "WARN", "WARNING":
+		return WARN, true
+	case "ERROR":
+		return ERROR, true
+	case "FATAL":
+		return FATAL, true
+	default:
+		return INFO, false
+	}
+}
+
 // Color returns ANSI color code for terminal output
 func (l LogLevel) Color() string {
 	switch l {
@@ -76,6 +95,29 @@ type Logger struct {
 	prefix        string
 	component     string
 	contextFields map[string]interface{}
+
+	// hooks is shared with every logger derived from this one, so a hook
+	// added to the root also sees its component loggers' entries
+	hooks *logHooks
+}
+
+// LogEntry is one line a logger wrote, as passed to hooks. Fields must not
+// be modified.
+type LogEntry struct {
+	Time      time.Time
+	Level     LogLevel
+	Component string
+	Message   string
+	Fields    map[string]interface{}
+}
+
+// LogHook receives every entry a logger writes. Hooks run on the logging
+// goroutine, so they must return quickly and must not log themselves.
+type LogHook func(entry LogEntry)
+
+type logHooks struct {
+	mu    sync.RWMutex
+	hooks []LogHook
 }
 
 var (
@@ -130,6 +172,7 @@ func NewLogger(config LoggerConfig) *Logger {
 		prefix:        config.Prefix,
 		component:     config.Component,
 		contextFields: make(map[string]interface{}),
+		hooks:         &logHooks{},
 	}
 }
 
@@ -154,6 +197,14 @@ func (l *Logger) AddOutput(output io.Writer) {
 	l.outputs = append(l.outputs, output)
 }
 
+// AddHook registers a hook for the entries this logger and the loggers
+// derived from it write
+func (l *Logger) AddHook(hook LogHook) {
+	l.hooks.mu.Lock()
+	defer l.hooks.mu.Unlock()
+	l.hooks.hooks = append(l.hooks.hooks, hook)
+}
+
 // WithField adds a context field to the logger
 func (l *Logger) WithField(key string, value interface{}) *Logger {
 	l.mu.RLock()
@@ -169,6 +220,7 @@ func (l *Logger) WithField(key string, value interface{}) *Logger {
 		prefix:        l.prefix,
 		component:     l.component,
 		contextFields: make(map[string]interface{}),
+		hooks:         l.hooks,
 	}
 
 	for k, v := range l.contextFields {
@@ -193,6 +245,7 @@ func (l *Logger) WithFields(fields map[string]interface{}) *Logger {
 		prefix:        l.prefix,
 		component:     l.component,
 		contextFields: make(map[string]interface{}),
+		hooks:         l.hooks,
 	}
 
 	for k, v := range l.contextFields {
@@ -219,6 +272,7 @@ func (l *Logger) WithComponent(component string) *Logger {
 		prefix:        l.prefix,
 		component:     component,
 		contextFields: make(map[string]interface{}),
+		hooks:         l.hooks,
 	}
 
 	for k, v := range l.contextFields {
@@ -236,6 +290,12 @@ func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
 	}
 	l.mu.RUnlock()
 
+	now := time.Now()
+	text := format
+	if len(args) > 0 {
+		text = fmt.Sprintf(format, args...)
+	}
+
 	var msg strings.Builder
 
 	// Add color if enabled
@@ -245,7 +305,7 @@ func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
 
 	// Add timestamp
 	if l.enableTime {
-		msg.WriteString(time.Now().Format(l.timeFormat))
+		msg.WriteString(now.Format(l.timeFormat))
 		msg.WriteString(" ")
 	}
 
@@ -272,11 +332,7 @@ func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
 
 	// Add message
 	msg.WriteString(" ")
-	if len(args) > 0 {
-		msg.WriteString(fmt.Sprintf(format, args...))
-	} else {
-		msg.WriteString(format)
-	}
+	msg.WriteString(text)
 
 	// Add context fields
 	if len(l.contextFields) > 0 {
@@ -300,6 +356,18 @@ func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
 	}
 	l.mu.RUnlock()
 
+	l.hooks.mu.RLock()
+	for _, hook := range l.hooks.hooks {
+		hook(LogEntry{
+			Time:      now,
+			Level:     level,
+			Component: l.component,
+			Message:   text,
+			Fields:    l.contextFields,
+		})
+	}
+	l.hooks.mu.RUnlock()
+
 	// For FATAL, exit the program
 	if level == FATAL {
 		os.Exit(1)
@@ -0,0 +1,45 @@
+package utils
+
+import "sync"
+
+// ReadinessTracker records which startup steps have completed. Each step
+// is a named gate; the process is ready once every gate is marked.
+type ReadinessTracker struct {
+	mu    sync.RWMutex
+	gates []string
+	done  map[string]bool
+}
+
+// NewReadinessTracker creates a tracker with the given gates, all pending
+func NewReadinessTracker(gates ...string) *ReadinessTracker {
+	return &ReadinessTracker{
+		gates: gates,
+		done:  make(map[string]bool, len(gates)),
+	}
+}
+
+// Mark records a gate as complete
+func (r *ReadinessTracker) Mark(gate string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.done[gate] = true
+}
+
+// Pending returns the gates not yet marked, in the order they were declared
+func (r *ReadinessTracker) Pending() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	pending := []string{}
+	for _, gate := range r.gates {
+		if !r.done[gate] {
+			pending = append(pending, gate)
+		}
+	}
+	return pending
+}
+
+// Ready reports whether every gate has been marked
+func (r *ReadinessTracker) Ready() bool {
+	return len(r.Pending()) == 0
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// secretPrefixV1 marks values encrypted with AES-256-GCM under the current
+// key. The version lets a later key rotation tell old ciphertexts apart.
+const secretPrefixV1 = "enc:v1:"
+
+var (
+	// ErrSecretKeyMissing is returned when an encrypted value is read
+	// without an encryption key
+	ErrSecretKeyMissing = errors.New("value is encrypted but no encryption key is configured")
+
+	// ErrSecretDecrypt is returned when a value cannot be decrypted,
+	// usually because the key differs from the one that encrypted it
+	ErrSecretDecrypt = errors.New("failed to decrypt value: wrong encryption key or corrupted data")
+)
+
+// SecretBox encrypts short secrets for storage
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox creates a secret box from a 32-byte key given as 64 hex
+// characters or standard base64
+func NewSecretBox(encodedKey string) (*SecretBox, error) {
+	key, err := decodeSecretKey(strings.TrimSpace(encodedKey))
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// decodeSecretKey decodes a 32-byte key from hex or base64
+func decodeSecretKey(encoded string) ([]byte, error) {
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, fmt.Errorf("encryption key must be 32 bytes, encoded as 64 hex characters or base64")
+}
+
+// IsEncryptedSecret reports whether a stored value is encrypted
+func IsEncryptedSecret(value string) bool {
+	return strings.HasPrefix(value, secretPrefixV1)
+}
+
+// Encrypt encrypts a value. Empty and already encrypted values are
+// returned unchanged.
+func (b *SecretBox) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" || IsEncryptedSecret(plaintext) {
+		return plaintext, nil
+	}
+
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return secretPrefixV1 + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value produced by Encrypt. Values without the
+// encryption prefix are legacy plaintext and returned unchanged.
+func (b *SecretBox) Decrypt(value string) (string, error) {
+	if !IsEncryptedSecret(value) {
+		return value, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, secretPrefixV1))
+	if err != nil || len(sealed) < b.aead.NonceSize() {
+		return "", ErrSecretDecrypt
+	}
+
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrSecretDecrypt
+	}
+	return string(plaintext), nil
+}
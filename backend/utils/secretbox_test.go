@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newTestSecretKey returns a random 32-byte key encoded as hex
+func newTestSecretKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	return hex.EncodeToString(key)
+}
+
+func newTestSecretBox(t *testing.T, key string) *SecretBox {
+	t.Helper()
+	box, err := NewSecretBox(key)
+	if err != nil {
+		t.Fatalf("NewSecretBox: %v", err)
+	}
+	return box
+}
+
+func TestSecretBoxRoundTrip(t *testing.T) {
+	box := newTestSecretBox(t, newTestSecretKey(t))
+
+	for _, plaintext := range []string{"imap-password", "p@ss wörd \"quoted\"", strings.Repeat("x", 500)} {
+		encrypted, err := box.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt(%q): %v", plaintext, err)
+		}
+		if !IsEncryptedSecret(encrypted) || strings.Contains(encrypted, plaintext) {
+			t.Fatalf("Encrypt(%q) = %q, want an opaque encrypted value", plaintext, encrypted)
+		}
+
+		// Encrypting an encrypted value must not wrap it twice
+		if again, err := box.Encrypt(encrypted); err != nil || again != encrypted {
+			t.Errorf("re-encrypting = %q, %v, want the value unchanged", again, err)
+		}
+
+		decrypted, err := box.Decrypt(encrypted)
+		if err != nil {
+			t.Fatalf("Decrypt: %v", err)
+		}
+		if decrypted != plaintext {
+			t.Errorf("Decrypt = %q, want %q", decrypted, plaintext)
+		}
+	}
+
+	// Each encryption uses a fresh nonce
+	first, _ := box.Encrypt("same")
+	second, _ := box.Encrypt("same")
+	if first == second {
+		t.Error("encrypting the same value twice gave identical ciphertexts")
+	}
+}
+
+func TestSecretBoxPassesThroughPlainValues(t *testing.T) {
+	box := newTestSecretBox(t, newTestSecretKey(t))
+
+	if encrypted, err := box.Encrypt(""); err != nil || encrypted != "" {
+		t.Errorf("Encrypt(\"\") = %q, %v, want it empty", encrypted, err)
+	}
+	if decrypted, err := box.Decrypt("legacy-plaintext"); err != nil || decrypted != "legacy-plaintext" {
+		t.Errorf("Decrypt(legacy) = %q, %v, want it unchanged", decrypted, err)
+	}
+}
+
+func TestSecretBoxWrongKey(t *testing.T) {
+	box := newTestSecretBox(t, newTestSecretKey(t))
+	encrypted, err := box.Encrypt("imap-password")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	other := newTestSecretBox(t, newTestSecretKey(t))
+	if decrypted, err := other.Decrypt(encrypted); !errors.Is(err, ErrSecretDecrypt) || decrypted != "" {
+		t.Errorf("Decrypt with another key = %q, %v, want ErrSecretDecrypt", decrypted, err)
+	}
+
+	// The right key still refuses tampered or malformed values
+	corrupted := encrypted[:len(encrypted)-4] + "AAAA"
+	if _, err := box.Decrypt(corrupted); !errors.Is(err, ErrSecretDecrypt) {
+		t.Errorf("Decrypt of corrupted value: %v, want ErrSecretDecrypt", err)
+	}
+	if _, err := box.Decrypt(secretPrefixV1 + "not base64!"); !errors.Is(err, ErrSecretDecrypt) {
+		t.Errorf("Decrypt of malformed value: %v, want ErrSecretDecrypt", err)
+	}
+}
+
+func TestNewSecretBoxKeyEncodings(t *testing.T) {
+	raw := make([]byte, 32)
+	for i := range raw {
+		raw[i] = byte(i)
+	}
+
+	for name, key := range map[string]string{
+		"hex":    hex.EncodeToString(raw),
+		"base64": base64.StdEncoding.EncodeToString(raw),
+		"padded": "  " + hex.EncodeToString(raw) + "\n",
+	} {
+		if _, err := NewSecretBox(key); err != nil {
+			t.Errorf("NewSecretBox(%s key): %v", name, err)
+		}
+	}
+
+	for _, key := range []string{"", "too-short", hex.EncodeToString(raw[:16]), base64.StdEncoding.EncodeToString(raw[:31])} {
+		if _, err := NewSecretBox(key); err == nil {
+			t.Errorf("NewSecretBox(%q) accepted an invalid key", key)
+		}
+	}
+}
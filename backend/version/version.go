@@ -0,0 +1,96 @@
+// Package version reports the version and build details of the backend.
+//
+// Release builds set the variables with -ldflags, e.g.
+//
+//	go build -ldflags="-X botrix-backend/version.Version=1.2.0 \
+//	  -X botrix-backend/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X botrix-backend/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Values left unset fall back to the build information embedded by the Go
+// toolchain.
+package version
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// Set with -ldflags at build time
+var (
+	Version   string
+	Commit    string
+	BuildTime string
+)
+
+// Fallbacks when neither -ldflags nor the build information say anything
+const (
+	devVersion = "dev"
+	unknown    = "unknown"
+)
+
+// BuildInfo identifies the running build
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+}
+
+var (
+	resolveOnce sync.Once
+	resolved    BuildInfo
+)
+
+// Get returns the build details, resolving fallbacks on first use
+func Get() BuildInfo {
+	resolveOnce.Do(func() {
+		resolved = resolve(Version, Commit, BuildTime)
+	})
+	return resolved
+}
+
+// String returns the version alone, as shown in the app name and root route
+func String() string {
+	return Get().Version
+}
+
+// resolve fills the values not set with -ldflags from the embedded build
+// information: the module version and the VCS revision and commit time
+func resolve(version, commit, buildTime string) BuildInfo {
+	info := BuildInfo{Version: version, Commit: commit, BuildTime: buildTime}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+
+		var modified bool
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = setting.Value
+				}
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if modified && commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = devVersion
+	}
+	if info.Commit == "" {
+		info.Commit = unknown
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = unknown
+	}
+	return info
+}
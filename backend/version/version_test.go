@@ -0,0 +1,24 @@
+package version
+
+import "testing"
+
+func TestResolveFallsBack(t *testing.T) {
+	info := resolve("", "", "")
+	if info.Version == "" || info.Commit == "" || info.BuildTime == "" {
+		t.Errorf("resolve without -ldflags = %+v, want every field filled in", info)
+	}
+
+	if got := Get(); got.Version == "" || got.Commit == "" || got.BuildTime == "" {
+		t.Errorf("Get() = %+v, want every field filled in", got)
+	}
+	if String() != Get().Version {
+		t.Errorf("String() = %q, want the version %q", String(), Get().Version)
+	}
+}
+
+func TestResolvePrefersLinkerValues(t *testing.T) {
+	want := BuildInfo{Version: "1.2.0", Commit: "abc1234", BuildTime: "2024-03-01T12:00:00Z"}
+	if got := resolve(want.Version, want.Commit, want.BuildTime); got != want {
+		t.Errorf("resolve with -ldflags values = %+v, want %+v", got, want)
+	}
+}
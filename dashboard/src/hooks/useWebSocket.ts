@@ -3,6 +3,8 @@ import toast from 'react-hot-toast';
 import type { WebSocketMessage } from '@/types';
 
 const WS_URL = import.meta.env.VITE_WS_URL || 'ws://localhost:8080/ws';
+// Token for backends that require WebSocket authentication (WS_API_KEYS)
+const WS_TOKEN = import.meta.env.VITE_WS_TOKEN || '';
 const INITIAL_RECONNECT_DELAY = 1000; // 1 second
 const MAX_RECONNECT_DELAY = 30000; // 30 seconds
 const MAX_RECONNECT_ATTEMPTS = 10;
@@ -22,6 +24,10 @@ export function useWebSocket(onMessage?: (message: WebSocketMessage) => void) {
   const onMessageRef = useRef(onMessage);
   const hasShownConnectedToastRef = useRef(false);
   const isMountedRef = useRef(true);
+  // Time and sequence number of the last relayed event, so a reconnect can
+  // replay what was missed
+  const lastEventAtRef = useRef(0);
+  const lastSeqRef = useRef(0);
 
   // Keep onMessage ref updated
   useEffect(() => {
@@ -80,7 +86,9 @@ export function useWebSocket(onMessage?: (message: WebSocketMessage) => void) {
 
     try {
       console.log('[WebSocket] Connecting to', WS_URL);
-      const ws = new WebSocket(WS_URL);
+      const ws = WS_TOKEN
+        ? new WebSocket(WS_URL, ['botrix.token', WS_TOKEN])
+        : new WebSocket(WS_URL);
 
       ws.onopen = () => {
         console.log('[WebSocket] Connection established');
@@ -97,6 +105,17 @@ export function useWebSocket(onMessage?: (message: WebSocketMessage) => void) {
         }
         hasShownConnectedToastRef.current = true;
 
+        // The dashboard follows every job, so subscribe to all of them
+        ws.send(JSON.stringify({ type: 'subscribe', job_id: '*' }));
+
+        // After a reconnect, ask for the events missed while away. Resuming
+        // by sequence number is exact; older servers only replay by time.
+        if (lastSeqRef.current > 0) {
+          ws.send(JSON.stringify({ type: 'resume', last_seq: lastSeqRef.current }));
+        } else if (lastEventAtRef.current > 0) {
+          ws.send(JSON.stringify({ type: 'hello', since: lastEventAtRef.current }));
+        }
+
         // Wait a bit before sending initial ping (let server setup connection)
         setTimeout(() => {
           if (ws.readyState === WebSocket.OPEN) {
@@ -116,6 +135,21 @@ export function useWebSocket(onMessage?: (message: WebSocketMessage) => void) {
       ws.onmessage = (event) => {
         try {
           const message: WebSocketMessage = JSON.parse(event.data);
+          // Only relayed events count; pongs carry their own timestamps
+          const isEvent =
+            message.type === 'job_update' ||
+            message.type === 'settings_updated' ||
+            message.type === 'account_update';
+          if (isEvent && message.timestamp && message.timestamp > lastEventAtRef.current) {
+            lastEventAtRef.current = message.timestamp;
+          }
+          if (message.seq && message.seq > lastSeqRef.current) {
+            lastSeqRef.current = message.seq;
+          }
+          // A restarted server numbers events from scratch, so start over
+          if (message.type === 'resync_required') {
+            lastSeqRef.current = message.data?.latest_seq ?? 0;
+          }
           console.log('[WebSocket] Message received:', message.type, message);
           setLastMessage(message);
           onMessageRef.current?.(message);
@@ -137,9 +171,11 @@ export function useWebSocket(onMessage?: (message: WebSocketMessage) => void) {
         // Stop ping interval
         clearPingInterval();
         
-        // Don't reconnect if closed normally or component unmounted
-        if (event.code === 1000 || event.code === 1001 || !isMountedRef.current) {
-          console.log('[WebSocket] Normal closure (code 1000/1001) or unmounted, not reconnecting');
+        // Don't reconnect if closed normally or component unmounted. The
+        // server closes with 1001 (going away) when it restarts, so that
+        // code does reconnect.
+        if (event.code === 1000 || !isMountedRef.current) {
+          console.log('[WebSocket] Normal closure (code 1000) or unmounted, not reconnecting');
           return;
         }
         
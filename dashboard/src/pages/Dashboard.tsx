@@ -44,6 +44,23 @@ export default function Dashboard() {
         queryClient.invalidateQueries({ queryKey: ['jobs'] });
       }
     }
+
+    // Updates were missed while the server's Redis subscription was down,
+    // or while this client's send buffer was full
+    if (
+      lastMessage &&
+      (lastMessage.type === 'resync_recommended' ||
+        lastMessage.type === 'resync_required' ||
+        lastMessage.type === 'messages_dropped')
+    ) {
+      queryClient.invalidateQueries({ queryKey: ['stats'] });
+      queryClient.invalidateQueries({ queryKey: ['jobs'] });
+    }
+
+    // Admin notices pushed through POST /ws/send
+    if (lastMessage && lastMessage.type === 'notice' && lastMessage.data?.message) {
+      toast(lastMessage.data.message);
+    }
   }, [lastMessage, queryClient]);
 
   // Create job mutation
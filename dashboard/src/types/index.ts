@@ -61,13 +61,41 @@ export interface Stats {
 }
 
 export interface WebSocketMessage {
-  type: 'job_update' | 'account_created' | 'error'
+  type:
+    | 'hello'
+    | 'job_update'
+    | 'account_created'
+    | 'account_update'
+    | 'settings_updated'
+    | 'subscribed'
+    | 'unsubscribed'
+    | 'filter_set'
+    | 'replay_complete'
+    | 'stats'
+    | 'stats_subscribed'
+    | 'stats_unsubscribed'
+    | 'resync_recommended'
+    | 'notice'
+    | 'messages_dropped'
+    | 'ack'
+    | 'resync_required'
+    | 'error'
   job_id?: string
   account_id?: string
   status?: string
   progress?: number
   data?: any
   message?: string
+  timestamp?: number
+  replayed?: boolean
+  seq?: number
+  // Messages missed while the send buffer was full (messages_dropped)
+  count?: number
+  // Sent in the server's hello
+  client_id?: string
+  server_time?: number
+  protocol_version?: number
+  features?: string[]
 }
 
 export interface CreateAccountRequest {
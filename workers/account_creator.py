@@ -31,40 +31,61 @@ class RegistrationFailedError(AccountCreationError):
     pass
 
 
-def generate_random_username(length: int = 10) -> str:
+def generate_random_username(length: int = 10, prefix: str = "") -> str:
     """
     Generate a random username for Kick.com
     
     Args:
-        length: Length of username (default: 10)
+        length: Length of username, or of the random suffix when prefix is set (default: 10)
+        prefix: Optional fixed prefix (e.g. "strm_"), validated by the backend
         
     Returns:
         Random username string
     """
     # Kick usernames: letters, numbers, underscores
     chars = string.ascii_letters + string.digits + '_'
-    # Start with a letter
-    username = random.choice(string.ascii_letters)
-    # Add random characters
-    username += ''.join(random.choices(chars, k=length - 1))
+    
+    if prefix:
+        username = prefix + ''.join(random.choices(chars, k=length))
+    else:
+        # Start with a letter
+        username = random.choice(string.ascii_letters)
+        # Add random characters
+        username += ''.join(random.choices(chars, k=length - 1))
     
     logger.debug(f"Generated username: {username}")
     return username
 
 
-def generate_random_password(length: int = 16) -> str:
+def generate_random_password(
+    length: int = 16,
+    require_symbols: bool = False,
+    require_digits: bool = False
+) -> str:
     """
     Generate a secure random password
     
     Args:
         length: Length of password (default: 16)
+        require_symbols: Guarantee at least one special character
+        require_digits: Guarantee at least one digit
         
     Returns:
         Random password string
     """
     # Mix of uppercase, lowercase, digits, and special characters
-    chars = string.ascii_letters + string.digits + '!@#$%^&*'
-    password = ''.join(random.choices(chars, k=length))
+    symbols = '!@#$%^&*'
+    chars = string.ascii_letters + string.digits + symbols
+    
+    required = []
+    if require_symbols:
+        required.append(random.choice(symbols))
+    if require_digits:
+        required.append(random.choice(string.digits))
+    
+    password_chars = required + random.choices(chars, k=length - len(required))
+    random.shuffle(password_chars)
+    password = ''.join(password_chars)
     
     logger.debug(f"Generated password with length: {length}")
     return password
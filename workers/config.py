@@ -52,6 +52,7 @@ class Config:
         try:
             response = requests.get(
                 f"{cls.BACKEND_URL}/api/settings",
+                params={"reveal": "true"},  # Secrets are masked otherwise
                 timeout=timeout
             )
             response.raise_for_status()
@@ -96,6 +97,15 @@ class Config:
             print(f"Error loading settings from backend: {e}")
             print("Using default/empty values")
 
+    @classmethod
+    def reload_settings(cls) -> None:
+        """
+        Re-read settings from the backend, e.g. after a settings_updated
+        event on the botrix:jobs:updates channel
+        """
+        cls._settings_loaded = False
+        cls.load_settings()
+
     @classmethod
     def validate(cls) -> bool:
         """
@@ -33,7 +33,12 @@ sys.path.insert(0, str(Path(__file__).parent.parent))
 import redis
 from redis.exceptions import RedisError, ConnectionError as RedisConnectionError
 
-from workers.account_creator import KickAccountCreator, AccountCreationError
+from workers.account_creator import (
+    KickAccountCreator,
+    AccountCreationError,
+    generate_random_username,
+    generate_random_password,
+)
 from workers.utils import get_logger
 
 # Initialize logger
@@ -45,8 +50,10 @@ PROCESSING_KEY = "botrix:jobs:processing"
 STATUS_KEY_PREFIX = "botrix:jobs:status:"
 DATA_KEY_PREFIX = "botrix:jobs:data:"
 RESULTS_KEY_PREFIX = "botrix:jobs:results:"
+PAUSED_KEY_PREFIX = "botrix:jobs:paused:"
 UPDATES_CHANNEL = "botrix:jobs:updates"
 HEALTH_KEY_PREFIX = "botrix:worker:health:"
+EMAIL_POOL_AVAILABLE_KEY = "botrix:emails:available"
 
 # Job statuses
 STATUS_PENDING = "pending"
@@ -55,12 +62,20 @@ STATUS_COMPLETED = "completed"
 STATUS_FAILED = "failed"
 STATUS_CANCELLED = "cancelled"
 
+# Job types
+JOB_TYPE_GENERATE = "generate"
+JOB_TYPE_VERIFY = "verify"
+
+# Verification outcomes (see models.VerificationResult in the backend)
+VERIFICATION_UNKNOWN = "unknown"
+
 # Configuration
 DEFAULT_REDIS_URL = "redis://localhost:6379/0"
 DEFAULT_MAX_RETRIES = 3
 DEFAULT_HEALTH_CHECK_INTERVAL = 30
 DEFAULT_JOB_TIMEOUT = 300  # 5 minutes
 BLPOP_TIMEOUT = 5  # 5 seconds
+PAUSE_POLL_INTERVAL = 2  # seconds between paused-flag checks
 
 
 class WorkerDaemon:
@@ -171,6 +186,21 @@ class WorkerDaemon:
             
         except Exception as e:
             logger.warning(f"[{self.worker_id}] Failed to update health check: {e}")
+        
+        self.publish_email_pool_count()
+    
+    def publish_email_pool_count(self) -> None:
+        """Publish the number of unused pool emails for the backend capacity check"""
+        email_pool = getattr(self.account_creator, "email_pool", None)
+        if email_pool is None:
+            return
+        
+        try:
+            available = email_pool.get_stats()["available"]
+            self.redis_client.set(EMAIL_POOL_AVAILABLE_KEY, available)
+            logger.debug(f"[{self.worker_id}] Email pool available: {available}")
+        except Exception as e:
+            logger.warning(f"[{self.worker_id}] Failed to publish email pool count: {e}")
     
     async def health_check_loop(self) -> None:
         """Background task to update health check periodically"""
@@ -240,6 +270,71 @@ class WorkerDaemon:
         except Exception as e:
             logger.error(f"[{self.worker_id}] Failed to update job status: {e}")
     
+    def publish_job_log(self, job_id: str, level: str, message: str) -> None:
+        """
+        Publish a job log line; the backend stores it with the job
+        
+        Args:
+            job_id: Job identifier
+            level: debug, info, warn or error
+            message: Log message
+        """
+        try:
+            log_data = {
+                "event": "job_log",
+                "source": "worker",
+                "job_id": job_id,
+                "level": level,
+                "message": message,
+                "worker_id": self.worker_id,
+                "timestamp": datetime.utcnow().isoformat(),
+            }
+            self.redis_client.publish(UPDATES_CHANNEL, json.dumps(log_data))
+        except Exception as e:
+            logger.warning(f"[{self.worker_id}] Failed to publish log for job {job_id}: {e}")
+    
+    async def wait_while_paused(self, job_id: str) -> bool:
+        """
+        Block while the job's paused flag is set
+        
+        The flag has no expiry; only a resume or a cancel removes it. A
+        Redis error leaves the job waiting, since it cannot tell whether the
+        job was resumed.
+        
+        Args:
+            job_id: Job identifier
+            
+        Returns:
+            False if the job was cancelled, True otherwise
+        """
+        paused_key = f"{PAUSED_KEY_PREFIX}{job_id}"
+        status_key = f"{STATUS_KEY_PREFIX}{job_id}"
+        announced = False
+        
+        while not self.shutdown_requested:
+            try:
+                # Cancel clears the flag too, so check for it first
+                if self.redis_client.get(status_key) == STATUS_CANCELLED:
+                    return False
+                if not self.redis_client.exists(paused_key):
+                    break
+            except RedisError as e:
+                logger.warning(f"[{self.worker_id}] Failed to check pause state of job {job_id}, still waiting: {e}")
+                await asyncio.sleep(PAUSE_POLL_INTERVAL)
+                continue
+            
+            if not announced:
+                logger.info(f"[{self.worker_id}] Job {job_id} paused, waiting")
+                self.publish_job_log(job_id, "info", "Paused, waiting to resume")
+                announced = True
+            
+            await asyncio.sleep(PAUSE_POLL_INTERVAL)
+        
+        if announced:
+            logger.info(f"[{self.worker_id}] Job {job_id} resumed")
+            self.publish_job_log(job_id, "info", "Resumed")
+        return True
+    
     async def process_job(self, job_data: Dict[str, Any]) -> bool:
         """
         Process a single job
@@ -263,6 +358,12 @@ class WorkerDaemon:
         try:
             # Update status to running
             self.update_job_status(job_id, STATUS_RUNNING)
+            self.publish_job_log(job_id, "info", f"Picked up by worker {self.worker_id} (retry {retry_count}/{self.max_retries})")
+            
+            # Route account-level jobs to their processors
+            job_type = job_data.get("type") or JOB_TYPE_GENERATE
+            if job_type == JOB_TYPE_VERIFY:
+                return await self.process_verify_job(job_data)
             
             # Initialize account creator if needed
             if not self.account_creator:
@@ -273,6 +374,11 @@ class WorkerDaemon:
             username = job_data.get("username")
             password = job_data.get("password")
             
+            # Optional generation options (see GenerateAccountsRequest in the backend)
+            username_prefix = job_data.get("username_prefix") or ""
+            username_suffix_length = job_data.get("username_suffix_length") or 8
+            password_policy = job_data.get("password_policy")
+            
             # Process account creation
             logger.info(f"[{self.worker_id}] Creating {count} account(s) for job {job_id}")
             
@@ -281,33 +387,59 @@ class WorkerDaemon:
             errors = []
             
             for i in range(count):
+                # Honor pause and cancel requests between accounts
+                if not await self.wait_while_paused(job_id):
+                    logger.info(f"[{self.worker_id}] Job {job_id} cancelled")
+                    self.publish_job_log(job_id, "info", f"Cancelled after {len(accounts_created)} account(s)")
+                    return False
+                
                 try:
                     logger.info(f"[{self.worker_id}] Creating account {i+1}/{count} for job {job_id}")
                     
+                    # Apply the job's generation options; None lets the creator use its defaults
+                    account_username = username
+                    if not account_username and username_prefix:
+                        account_username = generate_random_username(username_suffix_length, prefix=username_prefix)
+                    
+                    account_password = password
+                    if not account_password and password_policy:
+                        account_password = generate_random_password(
+                            length=password_policy.get("length") or 16,
+                            require_symbols=bool(password_policy.get("require_symbols")),
+                            require_digits=bool(password_policy.get("require_digits")),
+                        )
+                    
                     # Create account (this is synchronous, wrap in executor if needed)
                     account_data = await asyncio.get_event_loop().run_in_executor(
                         None,
                         self.account_creator.create_account,
-                        username,
-                        password
+                        account_username,
+                        account_password
                     )
                     
                     if account_data:
                         accounts_created.append(account_data)
                         logger.info(f"[{self.worker_id}] Account created: {account_data.get('username')}")
+                        self.publish_job_log(job_id, "info", f"Account {i+1}/{count} created: {account_data.get('username')}")
                     else:
                         error_msg = f"Account creation returned None for iteration {i+1}"
                         errors.append(error_msg)
                         logger.warning(f"[{self.worker_id}] {error_msg}")
+                        self.publish_job_log(job_id, "warn", error_msg)
                         
                 except AccountCreationError as e:
                     error_msg = f"Account {i+1} failed: {str(e)}"
                     errors.append(error_msg)
                     logger.error(f"[{self.worker_id}] {error_msg}")
+                    self.publish_job_log(job_id, "error", error_msg)
                 except Exception as e:
                     error_msg = f"Unexpected error for account {i+1}: {str(e)}"
                     errors.append(error_msg)
                     logger.error(f"[{self.worker_id}] {error_msg}", exc_info=True)
+                    self.publish_job_log(job_id, "error", error_msg)
+            
+            # Keep the backend's view of pool capacity current
+            self.publish_email_pool_count()
             
             # Determine final status
             if len(accounts_created) == count:
@@ -340,6 +472,7 @@ class WorkerDaemon:
                 # Total failure - check if we should retry
                 if retry_count < self.max_retries:
                     logger.warning(f"[{self.worker_id}] Job {job_id} failed, requeueing (retry {retry_count + 1}/{self.max_retries})")
+                    self.publish_job_log(job_id, "warn", f"All accounts failed, requeueing (retry {retry_count + 1}/{self.max_retries})")
                     
                     # Requeue with incremented retry count
                     job_data["retry_count"] = retry_count + 1
@@ -353,11 +486,13 @@ class WorkerDaemon:
                     self.update_job_status(job_id, STATUS_FAILED, error_msg=error_msg)
                     self.jobs_failed += 1
                     logger.error(f"[{self.worker_id}] Job {job_id} failed permanently")
+                    self.publish_job_log(job_id, "error", error_msg)
                     return False
                     
         except Exception as e:
             error_msg = f"Unexpected error processing job: {str(e)}"
             logger.error(f"[{self.worker_id}] {error_msg}", exc_info=True)
+            self.publish_job_log(job_id, "error", error_msg)
             
             # Check retry count
             if retry_count < self.max_retries:
@@ -375,6 +510,39 @@ class WorkerDaemon:
             self.current_job_id = None
             self.jobs_processed += 1
     
+    async def process_verify_job(self, job_data: Dict[str, Any]) -> bool:
+        """
+        Process a verify job (stub)
+        
+        Checking whether an account still logs into Kick is not implemented
+        yet, so this reports an "unknown" outcome. The backend writes the
+        outcome back to the account's status and notes.
+        
+        Args:
+            job_data: Job data from queue
+            
+        Returns:
+            True if the job completed
+        """
+        job_id = job_data.get("id")
+        account_id = job_data.get("account_id")
+        
+        if not account_id:
+            self.update_job_status(job_id, STATUS_FAILED, error_msg="Verify job missing account_id")
+            self.jobs_failed += 1
+            return False
+        
+        logger.info(f"[{self.worker_id}] Verifying account {account_id} for job {job_id}")
+        
+        result = {
+            "account_id": account_id,
+            "outcome": VERIFICATION_UNKNOWN,
+            "message": "Login verification not implemented yet",
+        }
+        self.update_job_status(job_id, STATUS_COMPLETED, result=result)
+        self.jobs_succeeded += 1
+        return True
+    
     async def work_loop(self) -> None:
         """Main worker loop that processes jobs from queue"""
         logger.info(f"[{self.worker_id}] Starting work loop")